@@ -7,24 +7,92 @@ import (
 )
 
 type Config struct {
-	Port           string
-	DatabaseURL    string
-	SessionSecret  string
-	PearlPoints    int
-	TargetScore    int
-	MaxGameTime    time.Duration
-	MoveCooldown   time.Duration
+	Port                 string
+	DatabaseURL          string
+	SessionSecret        string
+	PearlPoints          int
+	GoldenPearlPoints    int
+	BlackPearlPenalty    int
+	GoldenPearlLifetime  time.Duration
+	EnemyMoveEvery       int
+	TargetScore          int
+	MaxGameTime          time.Duration
+	MoveCooldown         time.Duration
+	LevelsDir            string
+	CampaignFile         string
+	ChallengesFile       string
+	LessonsDir           string
+	MatchMinPlayers      int
+	MatchMaxPlayers      int
+	MatchCountdown       time.Duration
+	MatchTimeLimit       time.Duration
+	MatchTargetScore     int
+	SpectatorDelay       time.Duration
+	LiveGameIdle         time.Duration
+	RatingK              int
+	RatingProvisional    int
+	RatingBand           int
+	RatingBandGrowth     int
+	TournamentRoundBreak time.Duration
+	TournamentNoShow     time.Duration
+	ChatMaxLength        int
+	ChatRateLimit        int
+	ChatRateWindow       time.Duration
+	ChatHistory          int
+	TimeAttackDuration   time.Duration
+	SurvivalLives        int
+	SurvivalPearlLife    time.Duration
+	SurvivalMinPearlLife time.Duration
+	SurvivalLifeStep     time.Duration
+	AdminUsers           string
+	CheatReviewScore     int
+	CheatExcludeScore    int
+	ReplayCheckInterval  time.Duration
 }
 
 func Load() *Config {
 	return &Config{
-		Port:          getEnv("PORT", "8080"),
-		DatabaseURL:   getEnv("DATABASE_URL", "boba_vim.db"),
-		SessionSecret: getEnv("SESSION_SECRET", "your-secret-key-change-in-production"),
-		PearlPoints:   getEnvInt("PEARL_POINTS", 100),
-		TargetScore:   getEnvInt("TARGET_SCORE", 1000),
-		MaxGameTime:   time.Duration(getEnvInt("MAX_GAME_TIME", 1800)) * time.Second, // 30 minutes
-		MoveCooldown:  time.Duration(getEnvInt("MOVE_COOLDOWN", 100)) * time.Millisecond, // 100ms cooldown
+		Port:                 getEnv("PORT", "8080"),
+		DatabaseURL:          getEnv("DATABASE_URL", "boba_vim.db"),
+		SessionSecret:        getEnv("SESSION_SECRET", "your-secret-key-change-in-production"),
+		PearlPoints:          getEnvInt("PEARL_POINTS", 100),
+		GoldenPearlPoints:    getEnvInt("GOLDEN_PEARL_POINTS", 300),
+		BlackPearlPenalty:    getEnvInt("BLACK_PEARL_PENALTY", 200),
+		GoldenPearlLifetime:  time.Duration(getEnvInt("GOLDEN_PEARL_LIFETIME", 10)) * time.Second,
+		EnemyMoveEvery:       getEnvInt("ENEMY_MOVE_EVERY", 2), // enemies step once every 2 player moves
+		TargetScore:          getEnvInt("TARGET_SCORE", 1000),
+		MaxGameTime:          time.Duration(getEnvInt("MAX_GAME_TIME", 1800)) * time.Second,     // 30 minutes
+		MoveCooldown:         time.Duration(getEnvInt("MOVE_COOLDOWN", 100)) * time.Millisecond, // 100ms cooldown
+		LevelsDir:            getEnv("LEVELS_DIR", "levels"),
+		CampaignFile:         getEnv("CAMPAIGN_FILE", "levels/campaign/campaign.json"),
+		ChallengesFile:       getEnv("CHALLENGES_FILE", "levels/challenges/challenges.json"),
+		LessonsDir:           getEnv("LESSONS_DIR", "lessons"),
+		MatchMinPlayers:      getEnvInt("MATCH_MIN_PLAYERS", 2),
+		MatchMaxPlayers:      getEnvInt("MATCH_MAX_PLAYERS", 8),
+		MatchCountdown:       time.Duration(getEnvInt("MATCH_COUNTDOWN", 10)) * time.Second, // lobby wait once enough players joined
+		MatchTimeLimit:       time.Duration(getEnvInt("MATCH_TIME_LIMIT", 180)) * time.Second,
+		MatchTargetScore:     getEnvInt("MATCH_TARGET_SCORE", 1000),
+		SpectatorDelay:       time.Duration(getEnvInt("SPECTATOR_DELAY", 5)) * time.Second,  // spectators can't help players live
+		LiveGameIdle:         time.Duration(getEnvInt("LIVE_GAME_IDLE", 120)) * time.Second, // games idle longer are not listed as live
+		RatingK:              getEnvInt("RATING_K", 32),
+		RatingProvisional:    getEnvInt("RATING_PROVISIONAL_MATCHES", 10),                          // rated matches before a rating counts as settled
+		RatingBand:           getEnvInt("RATING_BAND", 200),                                        // matchmaking prefers lobbies within this rating distance
+		RatingBandGrowth:     getEnvInt("RATING_BAND_GROWTH", 10),                                  // band widening per second a lobby has been waiting
+		TournamentRoundBreak: time.Duration(getEnvInt("TOURNAMENT_ROUND_BREAK", 60)) * time.Second, // pause between tournament rounds
+		TournamentNoShow:     time.Duration(getEnvInt("TOURNAMENT_NO_SHOW", 180)) * time.Second,    // players who don't join a round's match by then forfeit it
+		ChatMaxLength:        getEnvInt("CHAT_MAX_LENGTH", 200),
+		ChatRateLimit:        getEnvInt("CHAT_RATE_LIMIT", 5), // messages per CHAT_RATE_WINDOW
+		ChatRateWindow:       time.Duration(getEnvInt("CHAT_RATE_WINDOW", 10)) * time.Second,
+		ChatHistory:          getEnvInt("CHAT_HISTORY", 50), // messages sent to players joining a match
+		TimeAttackDuration:   time.Duration(getEnvInt("TIME_ATTACK_DURATION", 60)) * time.Second,
+		SurvivalLives:        getEnvInt("SURVIVAL_LIVES", 3),
+		SurvivalPearlLife:    time.Duration(getEnvInt("SURVIVAL_PEARL_LIFE", 10000)) * time.Millisecond,    // countdown of the first pearl
+		SurvivalMinPearlLife: time.Duration(getEnvInt("SURVIVAL_MIN_PEARL_LIFE", 2000)) * time.Millisecond, // the countdown never gets shorter
+		SurvivalLifeStep:     time.Duration(getEnvInt("SURVIVAL_PEARL_LIFE_STEP", 400)) * time.Millisecond, // countdown lost per pearl collected
+		AdminUsers:           getEnv("ADMIN_USERS", ""),                                                    // comma separated usernames that can review flagged games
+		CheatReviewScore:     getEnvInt("CHEAT_REVIEW_SCORE", 40),                                          // games scoring this much are queued for review
+		CheatExcludeScore:    getEnvInt("CHEAT_EXCLUDE_SCORE", 70),                                         // and kept off the leaderboard until an admin clears them
+		ReplayCheckInterval:  time.Duration(getEnvInt("REPLAY_CHECK_INTERVAL", 5)) * time.Second,           // finished games are replayed this often before they are listed
 	}
 }
 
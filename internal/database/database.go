@@ -20,6 +20,21 @@ func Initialize(databaseURL string) (*gorm.DB, error) {
 	err = db.AutoMigrate(
 		&models.Player{},
 		&models.GameSession{},
+		&models.GameMove{},
+		&models.CampaignLevel{},
+		&models.LevelProgress{},
+		&models.TutorialSession{},
+		&models.TutorialProgress{},
+		&models.Match{},
+		&models.MatchPlayer{},
+		&models.RatingChange{},
+		&models.Tournament{},
+		&models.TournamentEntry{},
+		&models.TournamentPairing{},
+		&models.ChatMessage{},
+		&models.RoomBan{},
+		&models.Challenge{},
+		&models.ChallengeSubmission{},
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,1577 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Editor replays vimgolf solutions on a text. It covers the part of vim that
+// golfing leans on: counts, motions, the d, c, y, g~, gu and gU operators
+// with motions and text objects, puts, joins, undo and redo, searches and the
+// dot command, plus insert mode. Keys it doesn't know are reported instead of
+// ignored, so a solution can't pass by accident.
+
+const (
+	MaxEditorCount = 1000   // largest count a command may take
+	MaxEditorText  = 100000 // characters the text may grow to
+)
+
+// Keys with a name in vim notation, any other key is the character it types
+const (
+	KeyEscape    = "<Esc>"
+	KeyEnter     = "<CR>"
+	KeyBackspace = "<BS>"
+	KeyTab       = "<Tab>"
+	KeyRedo      = "<C-r>"
+)
+
+var keyNames = map[string]string{
+	"esc":       KeyEscape,
+	"cr":        KeyEnter,
+	"enter":     KeyEnter,
+	"return":    KeyEnter,
+	"bs":        KeyBackspace,
+	"backspace": KeyBackspace,
+	"tab":       KeyTab,
+	"c-r":       KeyRedo,
+	"space":     " ",
+	"lt":        "<",
+	"bar":       "|",
+	"bslash":    "\\",
+}
+
+// ParseKeys splits a solution written in vim key notation, e.g. "ciwfoo<Esc>",
+// into keystrokes. Raw newlines and tabs count as <CR> and <Tab>.
+func ParseKeys(input string) ([]string, error) {
+	runes := []rune(input)
+	var keys []string
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\r':
+			if i+1 < len(runes) && runes[i+1] == '\n' {
+				i++
+			}
+			keys = append(keys, KeyEnter)
+		case r == '\n':
+			keys = append(keys, KeyEnter)
+		case r == '\t':
+			keys = append(keys, KeyTab)
+		case r == '<':
+			if name, length := keyName(runes[i+1:]); name != "" {
+				keys = append(keys, name)
+				i += length
+				continue
+			}
+			keys = append(keys, "<")
+		case unicode.IsControl(r):
+			return nil, fmt.Errorf("unsupported control character %q", r)
+		default:
+			keys = append(keys, string(r))
+		}
+	}
+	return keys, nil
+}
+
+// keyName resolves the "name>" following a "<", returning the key and how many runes it used
+func keyName(runes []rune) (string, int) {
+	for end := 0; end < len(runes) && end <= 10; end++ {
+		if runes[end] == '>' {
+			key := keyNames[strings.ToLower(string(runes[:end]))]
+			if key == "" {
+				return "", 0
+			}
+			return key, end + 1
+		}
+	}
+	return "", 0
+}
+
+// FormatKeys writes keystrokes back in vim notation, the inverse of ParseKeys
+func FormatKeys(keys []string) string {
+	var builder strings.Builder
+	for _, key := range keys {
+		if key == "<" {
+			builder.WriteString("<lt>")
+		} else {
+			builder.WriteString(key)
+		}
+	}
+	return builder.String()
+}
+
+type pos struct {
+	row, col int
+}
+
+func (p pos) before(other pos) bool {
+	return p.row < other.row || (p.row == other.row && p.col < other.col)
+}
+
+// Motion kinds decide what an operator covers
+const (
+	exclusive = iota
+	inclusive
+	linewise
+)
+
+type motion struct {
+	to       pos
+	kind     int
+	vertical bool // keeps the wanted column, j and k
+}
+
+// textRange is the text an operator works on. Charwise ranges end before
+// end, linewise ranges cover the rows from start to end.
+type textRange struct {
+	start, end pos
+	linewise   bool
+}
+
+type editorState struct {
+	lines  [][]rune
+	cursor pos
+}
+
+// Editor holds a text and the vim state needed to replay keys on it
+type Editor struct {
+	lines    [][]rune
+	cursor   pos
+	curswant int // column j and k aim for, maxInt after $
+
+	register         [][]rune // yanked or deleted text, one entry per line
+	registerLinewise bool
+
+	undo []editorState
+	redo []editorState
+
+	lastChange []string // keys of the last change without its count, for "."
+	lastCount  int
+	lastFind   string // f, F, t or T and its character, for ";" and ","
+	lastSearch string
+	searchBack bool
+	searchWord bool // the last search came from * or # and matches whole words
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// NewEditor starts an editor on text with the cursor on its first character
+func NewEditor(text string) *Editor {
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	e := &Editor{}
+	for _, line := range strings.Split(text, "\n") {
+		e.lines = append(e.lines, []rune(line))
+	}
+	return e
+}
+
+// ReplayKeys runs keys on text and returns the text they leave
+func ReplayKeys(text string, keys []string) (string, error) {
+	e := NewEditor(text)
+	if err := e.Run(keys); err != nil {
+		return "", err
+	}
+	return e.Text(), nil
+}
+
+// Text returns the edited text
+func (e *Editor) Text() string {
+	lines := make([]string, len(e.lines))
+	for i, line := range e.lines {
+		lines[i] = string(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Run replays keys, stopping at the first one it can't handle
+func (e *Editor) Run(keys []string) error {
+	r := &keyReader{keys: keys}
+	for !r.done() {
+		start := r.pos
+		if err := e.step(r); err != nil {
+			return fmt.Errorf("key %d: %w", start+1, err)
+		}
+		if e.size() > MaxEditorText {
+			return errors.New("the text grew too large")
+		}
+	}
+	return nil
+}
+
+// step runs one command and records it for undo when it changed the text
+func (e *Editor) step(r *keyReader) error {
+	start := r.pos
+	count, err := r.count()
+	if err != nil {
+		return err
+	}
+	if key, ok := r.peek(); ok && (key == "u" || key == KeyRedo) {
+		r.next()
+		for i := 0; i < max(count, 1); i++ {
+			if key == "u" {
+				e.undoChange()
+			} else {
+				e.redoChange()
+			}
+		}
+		return nil
+	}
+
+	r.pos = start
+	before := e.state()
+	if err := e.command(r); err != nil {
+		return err
+	}
+	if !sameLines(before.lines, e.lines) {
+		e.undo = append(e.undo, before)
+		e.redo = nil
+	}
+	return nil
+}
+
+// command runs a normal mode command with its count
+func (e *Editor) command(r *keyReader) error {
+	count, err := r.count()
+	if err != nil {
+		return err
+	}
+	start := r.pos
+	key, ok := r.next()
+	if !ok {
+		return nil
+	}
+
+	if key == "." {
+		if e.lastChange == nil {
+			return nil
+		}
+		if count == 0 {
+			count = e.lastCount
+		}
+		return e.command(&keyReader{keys: append(countKeys(count), e.lastChange...)})
+	}
+
+	repeatable, err := e.normal(key, count, r)
+	if err != nil {
+		return err
+	}
+	if repeatable {
+		e.lastChange = append([]string(nil), r.keys[start:r.pos]...)
+		e.lastCount = count
+	}
+	return nil
+}
+
+// normal runs the command of key and reports whether "." repeats it
+func (e *Editor) normal(key string, count int, r *keyReader) (bool, error) {
+	n := max(count, 1)
+	switch key {
+	case KeyEscape:
+		return false, nil
+	case "i", "a", "I", "A", "o", "O":
+		return true, e.startInsert(key, n, r)
+	case "d", "c", "y":
+		return true, e.operator(key, count, r)
+	case "x", "X", "s", "S", "C", "D", "Y":
+		shortcut := map[string][]string{
+			"x": {"d", "l"}, "X": {"d", "h"}, "s": {"c", "l"}, "S": {"c", "c"},
+			"C": {"c", "$"}, "D": {"d", "$"}, "Y": {"y", "y"},
+		}[key]
+		// The insert of s, S and C reads the keys that follow
+		return key != "Y", e.operator(shortcut[0], count, &keyReader{keys: shortcut[1:], then: r})
+	case "p", "P":
+		e.put(key == "P", n)
+		return true, nil
+	case "J":
+		e.join(n)
+		return true, nil
+	case "~":
+		e.toggleCase(n)
+		return true, nil
+	case "r":
+		char, ok := r.next()
+		if !ok {
+			return false, nil
+		}
+		replacement, err := typedChar(char)
+		if err != nil {
+			return false, err
+		}
+		e.replace(replacement, n)
+		return true, nil
+	case "g":
+		next, ok := r.peek()
+		if ok && (next == "~" || next == "u" || next == "U") {
+			r.next()
+			return true, e.operator("g"+next, count, r)
+		}
+	}
+
+	m, ok, err := e.motion(key, count, r, "")
+	if err != nil || !ok {
+		return false, err
+	}
+	e.moveTo(m)
+	return false, nil
+}
+
+// moveTo puts the cursor at the end of a motion
+func (e *Editor) moveTo(m motion) {
+	e.cursor = m.to
+	e.clampCursor()
+	if !m.vertical {
+		e.curswant = e.cursor.col
+	}
+}
+
+// operator applies op to the text covered by the motion or text object that follows
+func (e *Editor) operator(op string, count int, r *keyReader) error {
+	count2, err := r.count()
+	if err != nil {
+		return err
+	}
+	n := max(count, 1) * max(count2, 1)
+	if n > MaxEditorCount {
+		return errors.New("count is too large")
+	}
+	key, ok := r.next()
+	if !ok {
+		return nil
+	}
+
+	// A doubled operator works on whole lines: dd, cc, yy, g~~, guu, gugu
+	last := op[len(op)-1:]
+	if key == "g" && len(op) == 2 {
+		if next, ok := r.peek(); ok && next == last {
+			r.next()
+			key = last
+		}
+	}
+	if key == last {
+		endRow := min(e.cursor.row+n-1, len(e.lines)-1)
+		return e.apply(op, textRange{start: pos{e.cursor.row, 0}, end: pos{endRow, 0}, linewise: true}, r)
+	}
+
+	if key == "i" || key == "a" {
+		object, ok := r.next()
+		if !ok {
+			return nil
+		}
+		rng, found, err := e.textObject(key+object, n)
+		if err != nil || !found {
+			return err
+		}
+		return e.apply(op, rng, r)
+	}
+
+	// "cw" changes to the end of the word, without the blanks after it
+	if op == "c" && (key == "w" || key == "W") && e.classAt(e.cursor, key == "W") > classBlank {
+		m := e.changeWordEnd(n, key == "W")
+		return e.apply(op, e.motionRange(m), r)
+	}
+
+	// Without any count G still goes to the last line
+	motionCount := n
+	if count == 0 && count2 == 0 {
+		motionCount = 0
+	}
+	m, ok, err := e.motion(key, motionCount, r, op)
+	if err != nil || !ok {
+		return err
+	}
+	return e.apply(op, e.motionRange(m), r)
+}
+
+// motionRange turns a motion from the cursor into the text it covers
+func (e *Editor) motionRange(m motion) textRange {
+	start, end := e.cursor, m.to
+	if end.before(start) {
+		start, end = end, start
+	}
+	if m.kind == linewise {
+		return textRange{start: pos{start.row, 0}, end: pos{end.row, 0}, linewise: true}
+	}
+	if m.kind == inclusive {
+		end.col = min(end.col+1, len(e.lines[end.row]))
+		return textRange{start: start, end: end}
+	}
+
+	// An exclusive motion ending at the start of a later line stops at the end
+	// of the line before, and covers whole lines when it started at the indent
+	if end.col == 0 && end.row > start.row {
+		if start.col <= e.firstNonBlank(start.row) {
+			return textRange{start: pos{start.row, 0}, end: pos{end.row - 1, 0}, linewise: true}
+		}
+		end = pos{end.row - 1, len(e.lines[end.row-1])}
+	}
+	return textRange{start: start, end: end}
+}
+
+// apply runs an operator on a range, c goes on to read the inserted text from r
+func (e *Editor) apply(op string, rng textRange, r *keyReader) error {
+	switch op {
+	case "y":
+		e.yank(rng)
+		if rng.linewise {
+			e.cursor.row = rng.start.row
+		} else {
+			e.cursor = rng.start
+		}
+		e.clampCursor()
+	case "d":
+		e.yank(rng)
+		e.delete(rng)
+		e.clampCursor()
+	case "c":
+		e.yank(rng)
+		if rng.linewise {
+			e.lines = replaceRows(e.lines, rng.start.row, rng.end.row, [][]rune{{}})
+			e.cursor = pos{rng.start.row, 0}
+		} else {
+			e.delete(rng)
+		}
+		return e.insert(r, 1, "")
+	case "g~", "gu", "gU":
+		e.changeCase(op, rng)
+		e.cursor = rng.start
+		if rng.linewise {
+			e.cursor.col = e.firstNonBlank(rng.start.row)
+		}
+		e.clampCursor()
+	}
+	e.curswant = e.cursor.col
+	return nil
+}
+
+// yank copies a range to the register
+func (e *Editor) yank(rng textRange) {
+	e.registerLinewise = rng.linewise
+	e.register = nil
+	if rng.linewise {
+		for row := rng.start.row; row <= rng.end.row; row++ {
+			e.register = append(e.register, append([]rune(nil), e.lines[row]...))
+		}
+		return
+	}
+	for row := rng.start.row; row <= rng.end.row; row++ {
+		from, to := 0, len(e.lines[row])
+		if row == rng.start.row {
+			from = rng.start.col
+		}
+		if row == rng.end.row {
+			to = rng.end.col
+		}
+		e.register = append(e.register, append([]rune(nil), e.lines[row][from:to]...))
+	}
+}
+
+// delete removes a range and leaves the cursor where it started
+func (e *Editor) delete(rng textRange) {
+	if rng.linewise {
+		e.lines = replaceRows(e.lines, rng.start.row, rng.end.row, nil)
+		if len(e.lines) == 0 {
+			e.lines = [][]rune{{}}
+		}
+		row := min(rng.start.row, len(e.lines)-1)
+		e.cursor = pos{row, e.firstNonBlank(row)}
+		return
+	}
+
+	joined := append(append([]rune(nil), e.lines[rng.start.row][:rng.start.col]...), e.lines[rng.end.row][rng.end.col:]...)
+	e.lines = replaceRows(e.lines, rng.start.row, rng.end.row, [][]rune{joined})
+	e.cursor = rng.start
+}
+
+// changeCase toggles, lowers or raises the case of a range
+func (e *Editor) changeCase(op string, rng textRange) {
+	for row := rng.start.row; row <= rng.end.row; row++ {
+		from, to := 0, len(e.lines[row])
+		if !rng.linewise && row == rng.start.row {
+			from = rng.start.col
+		}
+		if !rng.linewise && row == rng.end.row {
+			to = rng.end.col
+		}
+		for col := from; col < to; col++ {
+			e.lines[row][col] = convertCase(op, e.lines[row][col])
+		}
+	}
+}
+
+func convertCase(op string, r rune) rune {
+	switch {
+	case op == "gu":
+		return unicode.ToLower(r)
+	case op == "gU":
+		return unicode.ToUpper(r)
+	case unicode.IsUpper(r):
+		return unicode.ToLower(r)
+	default:
+		return unicode.ToUpper(r)
+	}
+}
+
+// put pastes the register n times after the cursor, or before it
+func (e *Editor) put(before bool, n int) {
+	if e.register == nil {
+		return
+	}
+
+	if e.registerLinewise {
+		var block [][]rune
+		for i := 0; i < n; i++ {
+			for _, line := range e.register {
+				block = append(block, append([]rune(nil), line...))
+			}
+		}
+		row := e.cursor.row + 1
+		if before {
+			row = e.cursor.row
+		}
+		e.lines = insertRows(e.lines, row, block)
+		e.cursor = pos{row, e.firstNonBlank(row)}
+		e.curswant = e.cursor.col
+		return
+	}
+
+	text := [][]rune{append([]rune(nil), e.register[0]...)}
+	for _, line := range e.register[1:] {
+		text = append(text, append([]rune(nil), line...))
+	}
+	for i := 1; i < n; i++ {
+		text[len(text)-1] = append(text[len(text)-1], e.register[0]...)
+		for _, line := range e.register[1:] {
+			text = append(text, append([]rune(nil), line...))
+		}
+	}
+
+	line := e.lines[e.cursor.row]
+	col := e.cursor.col
+	if !before && len(line) > 0 {
+		col++
+	}
+	e.insertText(pos{e.cursor.row, col}, text)
+	if len(text) == 1 {
+		e.cursor = pos{e.cursor.row, col + len(text[0]) - 1}
+	} else {
+		e.cursor = pos{e.cursor.row, col}
+	}
+	e.clampCursor()
+	e.curswant = e.cursor.col
+}
+
+// insertText inserts lines of text at p, the first and last joining the line at p
+func (e *Editor) insertText(p pos, text [][]rune) {
+	line := e.lines[p.row]
+	head := append([]rune(nil), line[:p.col]...)
+	tail := append([]rune(nil), line[p.col:]...)
+
+	rows := make([][]rune, len(text))
+	for i, piece := range text {
+		rows[i] = append([]rune(nil), piece...)
+	}
+	rows[0] = append(head, rows[0]...)
+	rows[len(rows)-1] = append(rows[len(rows)-1], tail...)
+	e.lines = replaceRows(e.lines, p.row, p.row, rows)
+}
+
+// join joins n lines, at least two, putting a space between them like J
+func (e *Editor) join(n int) {
+	joins := max(n, 2) - 1
+	if e.cursor.row+joins >= len(e.lines) {
+		joins = len(e.lines) - 1 - e.cursor.row
+	}
+	for i := 0; i < joins; i++ {
+		row := e.cursor.row
+		current := e.lines[row]
+		next := []rune(strings.TrimLeft(string(e.lines[row+1]), " \t"))
+
+		joined := append([]rune(nil), current...)
+		col := len(joined)
+		if len(next) > 0 && len(current) > 0 && !isBlank(current[len(current)-1]) && next[0] != ')' {
+			joined = append(joined, ' ')
+		}
+		joined = append(joined, next...)
+		e.lines = replaceRows(e.lines, row, row+1, [][]rune{joined})
+		e.cursor.col = col
+	}
+	e.clampCursor()
+	e.curswant = e.cursor.col
+}
+
+// toggleCase switches the case of n characters and moves past them like ~
+func (e *Editor) toggleCase(n int) {
+	line := e.lines[e.cursor.row]
+	if len(line) == 0 {
+		return
+	}
+	end := min(e.cursor.col+n, len(line))
+	for col := e.cursor.col; col < end; col++ {
+		line[col] = convertCase("g~", line[col])
+	}
+	e.cursor.col = end
+	e.clampCursor()
+	e.curswant = e.cursor.col
+}
+
+// replace overwrites n characters with r, doing nothing when the line is too short
+func (e *Editor) replace(r rune, n int) {
+	line := e.lines[e.cursor.row]
+	if e.cursor.col+n > len(line) {
+		return
+	}
+	for col := e.cursor.col; col < e.cursor.col+n; col++ {
+		line[col] = r
+	}
+	e.cursor.col += n - 1
+	e.curswant = e.cursor.col
+}
+
+// startInsert enters insert mode where an i, a, I, A, o or O command puts it
+func (e *Editor) startInsert(key string, n int, r *keyReader) error {
+	line := e.lines[e.cursor.row]
+	switch key {
+	case "a":
+		if len(line) > 0 {
+			e.cursor.col++
+		}
+	case "I":
+		e.cursor.col = e.firstNonBlank(e.cursor.row)
+	case "A":
+		e.cursor.col = len(line)
+	case "o", "O":
+		e.openLine(key == "O")
+		return e.insert(r, n, key)
+	}
+	return e.insert(r, n, "")
+}
+
+// openLine adds an empty line below the cursor, or above it, and moves there
+func (e *Editor) openLine(above bool) {
+	row := e.cursor.row + 1
+	if above {
+		row = e.cursor.row
+	}
+	e.lines = insertRows(e.lines, row, [][]rune{{}})
+	e.cursor = pos{row, 0}
+}
+
+// insert types the keys that follow until <Esc>, n times in all. Lines opened
+// by o or O are opened again for each repeat.
+func (e *Editor) insert(r *keyReader, n int, open string) error {
+	var typed []string
+	escaped := false
+	for {
+		key, ok := r.next()
+		if !ok {
+			break
+		}
+		if key == KeyEscape {
+			escaped = true
+			break
+		}
+		if err := e.typeKey(key); err != nil {
+			return err
+		}
+		typed = append(typed, key)
+	}
+	if !escaped {
+		// The keys ran out in insert mode
+		return nil
+	}
+
+	for i := 1; i < n; i++ {
+		if open != "" {
+			e.openLine(false)
+		}
+		for _, key := range typed {
+			if err := e.typeKey(key); err != nil {
+				return err
+			}
+		}
+		if e.size() > MaxEditorText {
+			return errors.New("the text grew too large")
+		}
+	}
+
+	if e.cursor.col > 0 {
+		e.cursor.col--
+	}
+	e.clampCursor()
+	e.curswant = e.cursor.col
+	return nil
+}
+
+// typeKey types one key in insert mode
+func (e *Editor) typeKey(key string) error {
+	row, col := e.cursor.row, e.cursor.col
+	line := e.lines[row]
+	switch key {
+	case KeyEnter:
+		head := append([]rune(nil), line[:col]...)
+		tail := append([]rune(nil), line[col:]...)
+		e.lines = replaceRows(e.lines, row, row, [][]rune{head, tail})
+		e.cursor = pos{row + 1, 0}
+	case KeyBackspace:
+		if col > 0 {
+			e.lines[row] = append(line[:col-1:col-1], line[col:]...)
+			e.cursor.col--
+		} else if row > 0 {
+			previous := e.lines[row-1]
+			joined := append(append([]rune(nil), previous...), line...)
+			e.lines = replaceRows(e.lines, row-1, row, [][]rune{joined})
+			e.cursor = pos{row - 1, len(previous)}
+		}
+	default:
+		char, err := typedChar(key)
+		if err != nil {
+			return err
+		}
+		e.lines[row] = append(line[:col:col], append([]rune{char}, line[col:]...)...)
+		e.cursor.col++
+	}
+	return nil
+}
+
+// typedChar returns the character a key types
+func typedChar(key string) (rune, error) {
+	if key == KeyTab {
+		return '\t', nil
+	}
+	runes := []rune(key)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("unsupported key %s", key)
+	}
+	return runes[0], nil
+}
+
+// motion calculates where the motion of key goes from the cursor, op is
+// the operator waiting for it. ok is false when the motion fails.
+func (e *Editor) motion(key string, count int, r *keyReader, op string) (motion, bool, error) {
+	n := max(count, 1)
+	cur := e.cursor
+	line := e.lines[cur.row]
+
+	switch key {
+	case "h", KeyBackspace:
+		if cur.col == 0 {
+			return motion{}, false, nil
+		}
+		return motion{to: pos{cur.row, max(cur.col-n, 0)}, kind: exclusive}, true, nil
+	case "l", " ":
+		limit := len(line) - 1
+		if op != "" {
+			limit = len(line)
+		}
+		if cur.col >= limit {
+			return motion{}, false, nil
+		}
+		return motion{to: pos{cur.row, min(cur.col+n, limit)}, kind: exclusive}, true, nil
+	case "j", "k", "+", "-", KeyEnter:
+		row := cur.row + n
+		if key == "k" || key == "-" {
+			row = cur.row - n
+		}
+		if row == cur.row || (row < 0 && cur.row == 0) || (row >= len(e.lines) && cur.row == len(e.lines)-1) {
+			return motion{}, false, nil
+		}
+		row = max(0, min(row, len(e.lines)-1))
+		if key == "j" || key == "k" {
+			return motion{to: pos{row, min(e.curswant, max(len(e.lines[row])-1, 0))}, kind: linewise, vertical: true}, true, nil
+		}
+		return motion{to: pos{row, e.firstNonBlank(row)}, kind: linewise}, true, nil
+	case "0":
+		return motion{to: pos{cur.row, 0}, kind: exclusive}, true, nil
+	case "^":
+		return motion{to: pos{cur.row, e.firstNonBlank(cur.row)}, kind: exclusive}, true, nil
+	case "|":
+		return motion{to: pos{cur.row, min(n-1, max(len(line)-1, 0))}, kind: exclusive}, true, nil
+	case "$":
+		row := cur.row + n - 1
+		if row >= len(e.lines) {
+			return motion{}, false, nil
+		}
+		m := motion{to: pos{row, max(len(e.lines[row])-1, 0)}, kind: inclusive}
+		if op == "" {
+			e.moveTo(m)
+			e.curswant = maxInt
+			return motion{to: e.cursor, kind: inclusive, vertical: true}, true, nil
+		}
+		return m, true, nil
+	case "w", "W":
+		return e.wordForward(n, key == "W", op != "")
+	case "b", "B":
+		p := cur
+		for i := 0; i < n; i++ {
+			next, ok := e.wordBackward(p, key == "B")
+			if !ok {
+				if i == 0 {
+					return motion{}, false, nil
+				}
+				break
+			}
+			p = next
+		}
+		return motion{to: p, kind: exclusive}, true, nil
+	case "e", "E":
+		p := cur
+		for i := 0; i < n; i++ {
+			next, ok := e.wordEnd(p, key == "E")
+			if !ok {
+				if i == 0 {
+					return motion{}, false, nil
+				}
+				break
+			}
+			p = next
+		}
+		return motion{to: p, kind: inclusive}, true, nil
+	case "G":
+		row := len(e.lines) - 1
+		if count > 0 {
+			row = min(count, len(e.lines)) - 1
+		}
+		return motion{to: pos{row, e.firstNonBlank(row)}, kind: linewise}, true, nil
+	case "g":
+		next, ok := r.next()
+		if !ok {
+			return motion{}, false, nil
+		}
+		switch next {
+		case "g":
+			row := 0
+			if count > 0 {
+				row = min(count, len(e.lines)) - 1
+			}
+			return motion{to: pos{row, e.firstNonBlank(row)}, kind: linewise}, true, nil
+		case "_":
+			row := cur.row + n - 1
+			if row >= len(e.lines) {
+				return motion{}, false, nil
+			}
+			return motion{to: pos{row, e.lastNonBlank(row)}, kind: inclusive}, true, nil
+		}
+		return motion{}, false, fmt.Errorf("unsupported key g%s", next)
+	case "f", "F", "t", "T":
+		char, ok := r.next()
+		if !ok {
+			return motion{}, false, nil
+		}
+		target, err := typedChar(char)
+		if err != nil {
+			return motion{}, false, err
+		}
+		e.lastFind = key + string(target)
+		return e.find(key, target, n, false)
+	case ";", ",":
+		if e.lastFind == "" {
+			return motion{}, false, nil
+		}
+		find := e.lastFind[:1]
+		if key == "," {
+			find = map[string]string{"f": "F", "F": "f", "t": "T", "T": "t"}[find]
+		}
+		return e.find(find, []rune(e.lastFind[1:])[0], n, true)
+	case "%":
+		return e.matchBracket()
+	case "/", "?":
+		pattern, ok, err := readPattern(r)
+		if err != nil || !ok {
+			return motion{}, false, err
+		}
+		if pattern == "" {
+			pattern = e.lastSearch
+		} else {
+			e.lastSearch = pattern
+			e.searchWord = false
+		}
+		e.searchBack = key == "?"
+		return e.search(e.lastSearch, e.searchBack, n)
+	case "n", "N":
+		backward := e.searchBack
+		if key == "N" {
+			backward = !backward
+		}
+		return e.search(e.lastSearch, backward, n)
+	case "*", "#":
+		word := e.wordUnderCursor()
+		if word == "" {
+			return motion{}, false, nil
+		}
+		e.lastSearch = word
+		e.searchWord = true
+		e.searchBack = key == "#"
+		return e.search(word, e.searchBack, n)
+	}
+	return motion{}, false, fmt.Errorf("unsupported key %s", key)
+}
+
+// Character classes that make up words, an empty line is a word of its own
+const (
+	classEmpty = iota
+	classBlank
+	classKeyword
+	classOther
+)
+
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+func isKeyword(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// classAt classifies the character at p, bigWords makes every non-blank alike for W, B and E
+func (e *Editor) classAt(p pos, bigWords bool) int {
+	line := e.lines[p.row]
+	if len(line) == 0 {
+		return classEmpty
+	}
+	r := line[p.col]
+	switch {
+	case isBlank(r):
+		return classBlank
+	case bigWords || isKeyword(r):
+		return classKeyword
+	default:
+		return classOther
+	}
+}
+
+// next returns the position after p, moving on to the next line at the end of one
+func (e *Editor) next(p pos) (pos, bool) {
+	if p.col+1 < len(e.lines[p.row]) {
+		return pos{p.row, p.col + 1}, true
+	}
+	if p.row+1 < len(e.lines) {
+		return pos{p.row + 1, 0}, true
+	}
+	return p, false
+}
+
+// prev returns the position before p, moving back to the end of the previous line
+func (e *Editor) prev(p pos) (pos, bool) {
+	if p.col > 0 {
+		return pos{p.row, p.col - 1}, true
+	}
+	if p.row > 0 {
+		return pos{p.row - 1, max(len(e.lines[p.row-1])-1, 0)}, true
+	}
+	return p, false
+}
+
+// wordForward moves n words forward like w. With an operator, a last word at
+// the end of a line ends the motion there instead of on the next line.
+func (e *Editor) wordForward(n int, bigWords, operator bool) (motion, bool, error) {
+	p := e.cursor
+	last := len(e.lines) - 1
+	for i := 0; i < n; i++ {
+		next, ok := e.wordStart(p, bigWords)
+		if !ok {
+			// Out of words, w stops on the last character and an operator takes the rest of the text
+			if operator {
+				return motion{to: pos{last, len(e.lines[last])}, kind: exclusive}, true, nil
+			}
+			end := pos{last, max(len(e.lines[last])-1, 0)}
+			if i == 0 && p == end {
+				return motion{}, false, nil
+			}
+			return motion{to: end, kind: exclusive}, true, nil
+		}
+		if operator && i == n-1 && next.row > p.row {
+			return motion{to: pos{p.row, len(e.lines[p.row])}, kind: exclusive}, true, nil
+		}
+		p = next
+	}
+	return motion{to: p, kind: exclusive}, true, nil
+}
+
+// wordStart returns the start of the word after p
+func (e *Editor) wordStart(p pos, bigWords bool) (pos, bool) {
+	class := e.classAt(p, bigWords)
+	q := p
+	ok := true
+	if class == classKeyword || class == classOther {
+		for ok && q.row == p.row && e.classAt(q, bigWords) == class {
+			q, ok = e.next(q)
+		}
+		if q.row == p.row && e.classAt(q, bigWords) == class {
+			return p, false
+		}
+	} else {
+		q, ok = e.next(q)
+		if !ok {
+			return p, false
+		}
+	}
+	for e.classAt(q, bigWords) == classBlank {
+		next, ok := e.next(q)
+		if !ok {
+			return p, false
+		}
+		q = next
+	}
+	return q, true
+}
+
+// wordEnd returns the end of the word after p like e
+func (e *Editor) wordEnd(p pos, bigWords bool) (pos, bool) {
+	q, ok := e.next(p)
+	if !ok {
+		return p, false
+	}
+	for e.classAt(q, bigWords) <= classBlank {
+		if q, ok = e.next(q); !ok {
+			return p, false
+		}
+	}
+	return e.runEnd(q, bigWords), true
+}
+
+// runEnd returns the last position of the word p is in
+func (e *Editor) runEnd(p pos, bigWords bool) pos {
+	class := e.classAt(p, bigWords)
+	for p.col+1 < len(e.lines[p.row]) && e.classAt(pos{p.row, p.col + 1}, bigWords) == class {
+		p.col++
+	}
+	return p
+}
+
+// runStart returns the first position of the word p is in
+func (e *Editor) runStart(p pos, bigWords bool) pos {
+	class := e.classAt(p, bigWords)
+	for p.col > 0 && e.classAt(pos{p.row, p.col - 1}, bigWords) == class {
+		p.col--
+	}
+	return p
+}
+
+// wordBackward returns the start of the word before p like b
+func (e *Editor) wordBackward(p pos, bigWords bool) (pos, bool) {
+	q, ok := e.prev(p)
+	if !ok {
+		return p, false
+	}
+	for e.classAt(q, bigWords) == classBlank {
+		if q, ok = e.prev(q); !ok {
+			return pos{0, 0}, true
+		}
+	}
+	if e.classAt(q, bigWords) == classEmpty {
+		return q, true
+	}
+	return e.runStart(q, bigWords), true
+}
+
+// changeWordEnd is the motion of cw: to the end of the word, staying on it
+// when the cursor is already at its end
+func (e *Editor) changeWordEnd(n int, bigWords bool) motion {
+	p := e.runEnd(e.cursor, bigWords)
+	for i := 1; i < n; i++ {
+		next, ok := e.wordEnd(p, bigWords)
+		if !ok {
+			break
+		}
+		p = next
+	}
+	return motion{to: p, kind: inclusive}
+}
+
+// find moves to the nth occurrence of char on the line like f, F, t and T.
+// A repeated t or T skips the character right next to the cursor.
+func (e *Editor) find(key string, char rune, n int, repeat bool) (motion, bool, error) {
+	line := e.lines[e.cursor.row]
+	col := e.cursor.col
+	forward := key == "f" || key == "t"
+	step := 1
+	if !forward {
+		step = -1
+	}
+	if repeat && (key == "t" || key == "T") {
+		col += step
+	}
+
+	for found := 0; found < n; {
+		col += step
+		if col < 0 || col >= len(line) {
+			return motion{}, false, nil
+		}
+		if line[col] == char {
+			found++
+		}
+	}
+
+	switch key {
+	case "t":
+		col--
+	case "T":
+		col++
+	}
+	kind := inclusive
+	if !forward {
+		kind = exclusive
+	}
+	return motion{to: pos{e.cursor.row, col}, kind: kind}, true, nil
+}
+
+// matchBracket jumps to the bracket matching the first one at or after the cursor like %
+func (e *Editor) matchBracket() (motion, bool, error) {
+	pairs := map[rune]rune{'(': ')', '[': ']', '{': '}', ')': '(', ']': '[', '}': '{'}
+	line := e.lines[e.cursor.row]
+	col := e.cursor.col
+	for col < len(line) {
+		if _, ok := pairs[line[col]]; ok {
+			break
+		}
+		col++
+	}
+	if col >= len(line) {
+		return motion{}, false, nil
+	}
+
+	bracket := line[col]
+	partner := pairs[bracket]
+	forward := bracket == '(' || bracket == '[' || bracket == '{'
+	target, ok := e.scanBracket(pos{e.cursor.row, col}, bracket, partner, forward)
+	if !ok {
+		return motion{}, false, nil
+	}
+	return motion{to: target, kind: inclusive}, true, nil
+}
+
+// scanBracket finds the partner of the bracket at p, skipping nested pairs
+func (e *Editor) scanBracket(p pos, bracket, partner rune, forward bool) (pos, bool) {
+	depth := 0
+	for {
+		var ok bool
+		if forward {
+			p, ok = e.next(p)
+		} else {
+			p, ok = e.prev(p)
+		}
+		if !ok {
+			return p, false
+		}
+		line := e.lines[p.row]
+		if len(line) == 0 {
+			continue
+		}
+		switch line[p.col] {
+		case bracket:
+			depth++
+		case partner:
+			if depth == 0 {
+				return p, true
+			}
+			depth--
+		}
+	}
+}
+
+// readPattern reads a search pattern up to <CR>, ok is false when it was abandoned
+func readPattern(r *keyReader) (string, bool, error) {
+	var pattern []rune
+	for {
+		key, ok := r.next()
+		if !ok || key == KeyEscape {
+			return "", false, nil
+		}
+		switch key {
+		case KeyEnter:
+			return string(pattern), true, nil
+		case KeyBackspace:
+			if len(pattern) == 0 {
+				return "", false, nil
+			}
+			pattern = pattern[:len(pattern)-1]
+		default:
+			char, err := typedChar(key)
+			if err != nil {
+				return "", false, err
+			}
+			pattern = append(pattern, char)
+		}
+	}
+}
+
+// search moves to the nth match of pattern from the cursor, wrapping around
+// the text. Patterns match literally.
+func (e *Editor) search(pattern string, backward bool, n int) (motion, bool, error) {
+	if pattern == "" {
+		return motion{}, false, nil
+	}
+	matches := e.matches([]rune(pattern))
+	if len(matches) == 0 {
+		return motion{}, false, nil
+	}
+
+	p := e.cursor
+	for i := 0; i < n; i++ {
+		p = nextMatch(matches, p, backward)
+	}
+	return motion{to: p, kind: exclusive}, true, nil
+}
+
+// nextMatch returns the match after p, or before it when searching backward
+func nextMatch(matches []pos, p pos, backward bool) pos {
+	if backward {
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i].before(p) {
+				return matches[i]
+			}
+		}
+		return matches[len(matches)-1]
+	}
+	for _, match := range matches {
+		if p.before(match) {
+			return match
+		}
+	}
+	return matches[0]
+}
+
+// matches lists where pattern occurs in the text, as whole words after * and #
+func (e *Editor) matches(pattern []rune) []pos {
+	var matches []pos
+	for row, line := range e.lines {
+		for col := 0; col+len(pattern) <= len(line); col++ {
+			if string(line[col:col+len(pattern)]) != string(pattern) {
+				continue
+			}
+			if e.searchWord && ((col > 0 && isKeyword(line[col-1])) || (col+len(pattern) < len(line) && isKeyword(line[col+len(pattern)]))) {
+				continue
+			}
+			matches = append(matches, pos{row, col})
+		}
+	}
+	return matches
+}
+
+// wordUnderCursor returns the keyword at or after the cursor on its line, for * and #
+func (e *Editor) wordUnderCursor() string {
+	line := e.lines[e.cursor.row]
+	col := e.cursor.col
+	for col < len(line) && !isKeyword(line[col]) {
+		col++
+	}
+	if col >= len(line) {
+		return ""
+	}
+	start := e.runStart(pos{e.cursor.row, col}, false)
+	end := e.runEnd(pos{e.cursor.row, col}, false)
+	return string(line[start.col : end.col+1])
+}
+
+// textObject returns the range of an iw, aw, iW, aW, quote or bracket object
+func (e *Editor) textObject(object string, n int) (textRange, bool, error) {
+	switch object[1:] {
+	case "w", "W":
+		rng, ok := e.wordObject(object[0] == 'a', object[1] == 'W', n)
+		return rng, ok, nil
+	case "\"", "'", "`":
+		rng, ok := e.quoteObject(object[0] == 'a', []rune(object[1:])[0])
+		return rng, ok, nil
+	case "(", ")", "b":
+		rng, ok := e.bracketObject(object[0] == 'a', '(', ')', n)
+		return rng, ok, nil
+	case "{", "}", "B":
+		rng, ok := e.bracketObject(object[0] == 'a', '{', '}', n)
+		return rng, ok, nil
+	case "[", "]":
+		rng, ok := e.bracketObject(object[0] == 'a', '[', ']', n)
+		return rng, ok, nil
+	case "<", ">":
+		rng, ok := e.bracketObject(object[0] == 'a', '<', '>', n)
+		return rng, ok, nil
+	}
+	return textRange{}, false, fmt.Errorf("unsupported text object %s", object)
+}
+
+// wordObject covers n words, and the blanks after them for aw, or before
+// them when the words end the line
+func (e *Editor) wordObject(around, bigWords bool, n int) (textRange, bool) {
+	row := e.cursor.row
+	line := e.lines[row]
+	if len(line) == 0 {
+		return textRange{}, false
+	}
+
+	start := e.runStart(e.cursor, bigWords)
+	end := e.cursor
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if end.col+1 >= len(line) {
+				break
+			}
+			end.col++
+		}
+		end = e.runEnd(end, bigWords)
+		if around {
+			// A word takes the blanks after it, blanks take the word after them
+			if end.col+1 < len(line) {
+				end = e.runEnd(pos{row, end.col + 1}, bigWords)
+			} else if i == 0 && e.classAt(start, bigWords) != classBlank {
+				for start.col > 0 && isBlank(line[start.col-1]) {
+					start.col--
+				}
+			}
+		}
+	}
+	return textRange{start: start, end: pos{row, end.col + 1}}, true
+}
+
+// quoteObject covers the quoted string around or after the cursor on its line
+func (e *Editor) quoteObject(around bool, quote rune) (textRange, bool) {
+	row := e.cursor.row
+	line := e.lines[row]
+	var quotes []int
+	before := 0
+	onQuote := -1
+	for col, r := range line {
+		if r != quote || (col > 0 && line[col-1] == '\\') {
+			continue
+		}
+		if col == e.cursor.col {
+			onQuote = len(quotes)
+		} else if col < e.cursor.col {
+			before++
+		}
+		quotes = append(quotes, col)
+	}
+
+	var first int
+	switch {
+	case onQuote >= 0:
+		first = onQuote - onQuote%2
+	case before%2 == 1:
+		first = before - 1
+	default:
+		first = before
+	}
+	if first+1 >= len(quotes) {
+		return textRange{}, false
+	}
+
+	open, close := quotes[first], quotes[first+1]
+	if !around {
+		return textRange{start: pos{row, open + 1}, end: pos{row, close}}, true
+	}
+	end := close + 1
+	for end < len(line) && isBlank(line[end]) {
+		end++
+	}
+	if end == close+1 {
+		for open > 0 && isBlank(line[open-1]) {
+			open--
+		}
+	}
+	return textRange{start: pos{row, open}, end: pos{row, end}}, true
+}
+
+// bracketObject covers the nth pair of brackets around the cursor, without
+// them for the inner object. An inner block whose brackets sit on their own
+// lines covers the lines between them.
+func (e *Editor) bracketObject(around bool, open, close rune, n int) (textRange, bool) {
+	p := e.cursor
+	if e.charAt(p) != open {
+		// Scanning back from a closing bracket finds its own opening one
+		var ok bool
+		if p, ok = e.scanBracket(p, close, open, false); !ok {
+			return textRange{}, false
+		}
+	}
+	for i := 1; i < n; i++ {
+		outer, ok := e.scanBracket(p, close, open, false)
+		if !ok {
+			return textRange{}, false
+		}
+		p = outer
+	}
+	end, ok := e.scanBracket(p, open, close, true)
+	if !ok {
+		return textRange{}, false
+	}
+
+	if around {
+		return textRange{start: p, end: pos{end.row, end.col + 1}}, true
+	}
+	start := pos{p.row, p.col + 1}
+	if start.col == len(e.lines[p.row]) && p.row < end.row {
+		start = pos{p.row + 1, 0}
+		if end.col <= e.firstNonBlank(end.row) && end.row > start.row {
+			return textRange{start: start, end: pos{end.row - 1, 0}, linewise: true}, true
+		}
+	}
+	return textRange{start: start, end: end}, true
+}
+
+// charAt returns the character at p, 0 on an empty line
+func (e *Editor) charAt(p pos) rune {
+	line := e.lines[p.row]
+	if p.col >= len(line) {
+		return 0
+	}
+	return line[p.col]
+}
+
+func (e *Editor) firstNonBlank(row int) int {
+	line := e.lines[row]
+	for col, r := range line {
+		if !isBlank(r) {
+			return col
+		}
+	}
+	return max(len(line)-1, 0)
+}
+
+func (e *Editor) lastNonBlank(row int) int {
+	line := e.lines[row]
+	for col := len(line) - 1; col >= 0; col-- {
+		if !isBlank(line[col]) {
+			return col
+		}
+	}
+	return 0
+}
+
+// clampCursor keeps the cursor on a character, as normal mode does
+func (e *Editor) clampCursor() {
+	e.cursor.row = max(0, min(e.cursor.row, len(e.lines)-1))
+	e.cursor.col = max(0, min(e.cursor.col, len(e.lines[e.cursor.row])-1))
+}
+
+func (e *Editor) size() int {
+	size := len(e.lines)
+	for _, line := range e.lines {
+		size += len(line)
+	}
+	return size
+}
+
+func (e *Editor) state() editorState {
+	return editorState{lines: copyLines(e.lines), cursor: e.cursor}
+}
+
+func (e *Editor) undoChange() {
+	if len(e.undo) == 0 {
+		return
+	}
+	e.redo = append(e.redo, e.state())
+	e.restore(e.undo[len(e.undo)-1])
+	e.undo = e.undo[:len(e.undo)-1]
+}
+
+func (e *Editor) redoChange() {
+	if len(e.redo) == 0 {
+		return
+	}
+	e.undo = append(e.undo, e.state())
+	e.restore(e.redo[len(e.redo)-1])
+	e.redo = e.redo[:len(e.redo)-1]
+}
+
+func (e *Editor) restore(state editorState) {
+	e.lines = copyLines(state.lines)
+	e.cursor = state.cursor
+	e.clampCursor()
+	e.curswant = e.cursor.col
+}
+
+func copyLines(lines [][]rune) [][]rune {
+	copied := make([][]rune, len(lines))
+	for i, line := range lines {
+		copied[i] = append([]rune(nil), line...)
+	}
+	return copied
+}
+
+func sameLines(a, b [][]rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if string(a[i]) != string(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceRows replaces the rows from first to last with rows
+func replaceRows(lines [][]rune, first, last int, rows [][]rune) [][]rune {
+	replaced := make([][]rune, 0, len(lines)-(last-first+1)+len(rows))
+	replaced = append(replaced, lines[:first]...)
+	replaced = append(replaced, rows...)
+	return append(replaced, lines[last+1:]...)
+}
+
+// insertRows inserts rows before the row at index
+func insertRows(lines [][]rune, index int, rows [][]rune) [][]rune {
+	inserted := make([][]rune, 0, len(lines)+len(rows))
+	inserted = append(inserted, lines[:index]...)
+	inserted = append(inserted, rows...)
+	return append(inserted, lines[index:]...)
+}
+
+// keyReader hands out keys one at a time, continuing with then once its own run out
+type keyReader struct {
+	keys []string
+	pos  int
+	then *keyReader
+}
+
+func (r *keyReader) done() bool {
+	return r.pos >= len(r.keys) && (r.then == nil || r.then.done())
+}
+
+func (r *keyReader) next() (string, bool) {
+	if r.pos < len(r.keys) {
+		r.pos++
+		return r.keys[r.pos-1], true
+	}
+	if r.then != nil {
+		return r.then.next()
+	}
+	return "", false
+}
+
+func (r *keyReader) peek() (string, bool) {
+	if r.pos < len(r.keys) {
+		return r.keys[r.pos], true
+	}
+	if r.then != nil {
+		return r.then.peek()
+	}
+	return "", false
+}
+
+// count reads a count, 0 when there is none. A count can't start with 0, which is a motion.
+func (r *keyReader) count() (int, error) {
+	count := 0
+	for {
+		key, ok := r.peek()
+		if !ok || len(key) != 1 || key[0] < '0' || key[0] > '9' || (key == "0" && count == 0) {
+			return count, nil
+		}
+		r.next()
+		count = count*10 + int(key[0]-'0')
+		if count > MaxEditorCount {
+			return 0, errors.New("count is too large")
+		}
+	}
+}
+
+// countKeys returns the keys typing a count, none for 0
+func countKeys(count int) []string {
+	if count == 0 {
+		return nil
+	}
+	var keys []string
+	for _, digit := range strconv.Itoa(count) {
+		keys = append(keys, string(digit))
+	}
+	return keys
+}
@@ -0,0 +1,105 @@
+package game
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"plain characters", "ciwfoo<Esc>", []string{"c", "i", "w", "f", "o", "o", KeyEscape}, false},
+		{"names ignore case", "<esc><Space><bar>", []string{KeyEscape, " ", "|"}, false},
+		{"escaped less than", "a<lt>b<CR>", []string{"a", "<", "b", KeyEnter}, false},
+		{"unknown names are typed", "<nope>", []string{"<", "n", "o", "p", "e", ">"}, false},
+		{"raw newlines and tabs", "x\r\ny\tz", []string{"x", KeyEnter, "y", KeyTab, "z"}, false},
+		{"control characters", "a\x01", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeys(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseKeys = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeys: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseKeys = %q, want %q", got, tt.want)
+			}
+			// Formatting and parsing again gives the same keystrokes
+			again, err := ParseKeys(FormatKeys(got))
+			if err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("ParseKeys(FormatKeys) = %q, %v, want %q", again, err, got)
+			}
+		})
+	}
+}
+
+func TestReplayKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		keys    string
+		want    string
+		wantErr string
+	}{
+		{"change word", "foo bar baz", "wcwqux<Esc>", "foo qux baz", ""},
+		{"dot repeats the change", "foo bar baz", "dw.", "baz", ""},
+		{"count on an operator", "a b c d", "2dw", "c d", ""},
+		{"count on x", "abc", "3x", "", ""},
+		{"linewise put", "a\nb\nc", "ddp", "b\na\nc", ""},
+		{"put with a count", "x", "yy3p", "x\nx\nx\nx", ""},
+		{"join", "a\nb\nc", "Jx", "ab\nc", ""},
+		{"toggle case", "hello", "~~~", "HELlo", ""},
+		{"uppercase a word", "hello", "gUiw", "HELLO", ""},
+		{"replace", "abc", "rX", "Xbc", ""},
+		{"open a line", "abc", "ofoo<Esc>", "abc\nfoo", ""},
+		{"undo", "abc", "xu", "abc", ""},
+		{"redo", "abc", "xu<C-r>", "bc", ""},
+		{"search the word under the cursor", "one two one", "*D", "one two ", ""},
+		{"inside brackets", "f(a, b)", "f(ci(x<Esc>", "f(x)", ""},
+		{"inside quotes", `say "hi"`, `di"`, `say ""`, ""},
+		{"unknown key", "abc", "q", "", "unsupported key q"},
+		{"keys in insert mode", "abc", "A<C-r>", "", "unsupported key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(tt.keys)
+			if err != nil {
+				t.Fatalf("ParseKeys: %v", err)
+			}
+			got, err := ReplayKeys(tt.text, keys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReplayKeys = %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReplayKeys: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReplayKeys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayKeysTextLimit(t *testing.T) {
+	keys, err := ParseKeys("yy999p999.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReplayKeys(strings.Repeat("x", 200), keys); err == nil {
+		t.Error("ReplayKeys let the text grow past MaxEditorText")
+	}
+}
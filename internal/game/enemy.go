@@ -0,0 +1,88 @@
+package game
+
+// Enemy is a black boba that chases the player across the map
+type Enemy struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+	// Under holds the map value the enemy is standing on so it can be restored when it moves
+	Under int `json:"under"`
+}
+
+// PlaceEnemies puts enemies on the map, remembering what each one covers
+func PlaceEnemies(gameMap [][]int, positions []Enemy) []Enemy {
+	enemies := make([]Enemy, 0, len(positions))
+	for _, pos := range positions {
+		if !IsValidPosition(pos.Row, pos.Col, gameMap) {
+			continue
+		}
+		enemies = append(enemies, Enemy{Row: pos.Row, Col: pos.Col, Under: gameMap[pos.Row][pos.Col]})
+		gameMap[pos.Row][pos.Col] = ENEMY
+	}
+	return enemies
+}
+
+// MoveEnemies moves every enemy one cell towards the player and reports whether
+// one of them reached the player. Enemies are moved in order and the choice of
+// step only depends on the map, so replaying the same moves gives the same result.
+func MoveEnemies(gameMap [][]int, enemies []Enemy, playerRow, playerCol int) bool {
+	caught := false
+	for i := range enemies {
+		enemy := &enemies[i]
+		row, col, ok := nextEnemyStep(gameMap, enemy.Row, enemy.Col, playerRow, playerCol)
+		if !ok {
+			continue
+		}
+		if row == playerRow && col == playerCol {
+			caught = true
+			continue
+		}
+
+		gameMap[enemy.Row][enemy.Col] = enemy.Under
+		enemy.Row, enemy.Col = row, col
+		enemy.Under = gameMap[row][col]
+		gameMap[row][col] = ENEMY
+	}
+	return caught
+}
+
+// nextEnemyStep picks the cell an enemy steps to, closing the larger distance first
+// (rows win ties) and falling back to the other axis when the preferred step is blocked
+func nextEnemyStep(gameMap [][]int, row, col, playerRow, playerCol int) (int, int, bool) {
+	rowStep := sign(playerRow - row)
+	colStep := sign(playerCol - col)
+
+	candidates := [][2]int{{row + rowStep, col}, {row, col + colStep}}
+	if abs(playerCol-col) > abs(playerRow-row) {
+		candidates[0], candidates[1] = candidates[1], candidates[0]
+	}
+
+	for _, candidate := range candidates {
+		r, c := candidate[0], candidate[1]
+		if (r == row && c == col) || !IsValidPosition(r, c, gameMap) {
+			continue
+		}
+		if IsWall(gameMap[r][c]) || gameMap[r][c] == ENEMY {
+			continue
+		}
+		return r, c, true
+	}
+	return row, col, false
+}
+
+func sign(value int) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,45 @@
+package game
+
+import "testing"
+
+func TestMoveEnemies(t *testing.T) {
+	tests := []struct {
+		name       string
+		walls      []string
+		enemy      Enemy
+		playerRow  int
+		playerCol  int
+		wantRow    int
+		wantCol    int
+		wantCaught bool
+	}{
+		{"closes the larger column distance first", []string{".....", "....."}, Enemy{Row: 0, Col: 0}, 1, 4, 0, 1, false},
+		{"closes the larger row distance first", []string{"..", "..", "..", ".."}, Enemy{Row: 0, Col: 0}, 3, 1, 1, 0, false},
+		{"rows win ties", []string{"...", "...", "..."}, Enemy{Row: 0, Col: 0}, 2, 2, 1, 0, false},
+		{"falls back to the other axis behind a wall", []string{"...", "#..", "..."}, Enemy{Row: 0, Col: 0}, 2, 2, 0, 1, false},
+		{"stays put when both steps are walls", []string{".#.", "#..", "..."}, Enemy{Row: 0, Col: 0}, 2, 2, 0, 0, false},
+		{"catches an adjacent player", []string{"...", "...", "..."}, Enemy{Row: 1, Col: 1}, 1, 2, 1, 1, true},
+		{"restores the pearl it walked over", []string{"...", "...", "..."}, Enemy{Row: 0, Col: 0, Under: PEARL}, 0, 2, 0, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameMap := wallMap(tt.walls)
+			gameMap[tt.enemy.Row][tt.enemy.Col] = ENEMY
+			enemies := []Enemy{tt.enemy}
+
+			caught := MoveEnemies(gameMap, enemies, tt.playerRow, tt.playerCol)
+			if caught != tt.wantCaught {
+				t.Errorf("caught = %v, want %v", caught, tt.wantCaught)
+			}
+			if enemies[0].Row != tt.wantRow || enemies[0].Col != tt.wantCol {
+				t.Errorf("enemy at %d,%d, want %d,%d", enemies[0].Row, enemies[0].Col, tt.wantRow, tt.wantCol)
+			}
+			if gameMap[tt.wantRow][tt.wantCol] != ENEMY {
+				t.Errorf("map at %d,%d = %d, want the enemy", tt.wantRow, tt.wantCol, gameMap[tt.wantRow][tt.wantCol])
+			}
+			if moved := tt.wantRow != tt.enemy.Row || tt.wantCol != tt.enemy.Col; moved && gameMap[tt.enemy.Row][tt.enemy.Col] != tt.enemy.Under {
+				t.Errorf("left cell holds %d, want %d", gameMap[tt.enemy.Row][tt.enemy.Col], tt.enemy.Under)
+			}
+		})
+	}
+}
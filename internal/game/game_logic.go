@@ -8,42 +8,124 @@ import (
 
 // Game constants
 const (
-	INITIAL_PEARLS = 1
+	INITIAL_PEARLS      = 3
+	GOLDEN_PEARL_CHANCE = 10 // percent of spawns that are golden pearls
+	BLACK_PEARL_CHANCE  = 15 // percent of spawns that are black pearls
+
+	PEARL_PLACEMENT_ATTEMPTS = 32 // random cells tried before scanning for a free one
+)
+
+// Pearl strategies decide how many pearls a map holds and which kinds spawn
+const (
+	PearlStrategyMixed   = "mixed"   // INITIAL_PEARLS pearls, golden and black ones included
+	PearlStrategyClassic = "classic" // a single regular pearl at a time
+	PearlStrategyRegular = "regular" // INITIAL_PEARLS regular pearls only
 )
 
 // Map values
 const (
-	EMPTY  = 0
-	PLAYER = 1
-	PEARL  = 3
+	EMPTY        = 0
+	PLAYER       = 1
+	WALL         = 2
+	PEARL        = 3
+	GOLDEN_PEARL = 4
+	BLACK_PEARL  = 5
+	ENEMY        = 6
 )
 
-// InitializeGameSession creates a new game with text grid and game map
-func InitializeGameSession() map[string]interface{} {
-	textGrid := createTextLines()
-	gameMap := createGameMap(textGrid)
+// IsPearl reports whether a map value holds any kind of pearl
+func IsPearl(value int) bool {
+	return value == PEARL || value == GOLDEN_PEARL || value == BLACK_PEARL
+}
+
+// IsWall reports whether a map value is a wall
+func IsWall(value int) bool {
+	return value == WALL
+}
+
+// IsValidPearlStrategy reports whether strategy is a known pearl strategy
+func IsValidPearlStrategy(strategy string) bool {
+	switch strategy {
+	case PearlStrategyMixed, PearlStrategyClassic, PearlStrategyRegular:
+		return true
+	}
+	return false
+}
+
+// pearlCount returns how many pearls the strategy keeps on the map
+func pearlCount(strategy string) int {
+	if strategy == PearlStrategyClassic {
+		return 1
+	}
+	return INITIAL_PEARLS
+}
+
+// NewSeed returns a fresh seed for a game session
+func NewSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// PearlRNG returns the random source of the spawn-th pearl of a seeded game. Every
+// spawn gets its own source so games sharing a seed draw the same pearl sequence
+// no matter how many moves were made in between.
+func PearlRNG(seed int64, spawn int) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(spawn)*7919))
+}
+
+// InitializeGameSession creates a new game with text grid and game map, the seed
+// decides the text pattern and every pearl placement
+func InitializeGameSession(strategy string, seed int64) map[string]interface{} {
+	return newGameSession(createTextLines(rand.New(rand.NewSource(seed))), strategy, seed)
+}
+
+// InitializePatternSession creates a new game on a chosen text pattern, numbered
+// from 1. The seed still decides every pearl placement, 0 lets it pick the pattern too.
+func InitializePatternSession(pattern int, strategy string, seed int64) map[string]interface{} {
+	return newGameSession(TextPatternGrid(SessionPattern(pattern, seed)-1), strategy, seed)
+}
+
+// SessionPattern returns the text pattern, numbered from 1, that
+// InitializePatternSession plays on for the given pattern and seed
+func SessionPattern(pattern int, seed int64) int {
+	if pattern <= 0 || pattern > len(TextPatterns) {
+		return rand.New(rand.NewSource(seed)).Intn(len(TextPatterns)) + 1
+	}
+	return pattern
+}
+
+// newGameSession creates the game map of a text grid with the player at (0,0)
+func newGameSession(textGrid [][]string, strategy string, seed int64) map[string]interface{} {
+	gameMap := createGameMap(textGrid, strategy, seed)
 	
 	return map[string]interface{}{
 		"text_grid":        textGrid,
 		"game_map":         gameMap,
 		"player_pos":       map[string]int{"row": 0, "col": 0},
 		"preferred_column": 0,
+		"enemies":          []Enemy{},
+		"pearl_spawns":     pearlCount(strategy),
 	}
 }
 
-// createTextLines creates text grid from game text (variable row lengths)
-func createTextLines() [][]string {
-	// Text patterns for vim practice with reasonable sizes (max ~50 chars width)
-	textPatterns := []string{
-		// Pattern 1: Welcome message
-		`Welcome to boba.vim !
+// TextPattern is one of the texts games are played on
+type TextPattern struct {
+	Name string
+	Text string
+}
+
+// TextPatterns are the texts for vim practice with reasonable sizes (max ~50 chars width)
+var TextPatterns = []TextPattern{
+	{
+		Name: "Welcome message",
+		Text: `Welcome to boba.vim !
 This game helps you learn vim motions fundamentals,
 it's a long journey but with patience,
 determination you'll master it!
 Florent.`,
-		
-		// Pattern 2: JavaScript function
-		`function movePlayer(direction) {
+	},
+	{
+		Name: "JavaScript function",
+		Text: `function movePlayer(direction) {
     if (direction === "up") {
         player.y -= 1;
     } else if (direction === "down") {
@@ -55,9 +137,10 @@ Florent.`,
     }
     return player;
 }`,
-
-		// Pattern 3: Configuration syntax
-		`server:
+	},
+	{
+		Name: "Configuration syntax",
+		Text: `server:
   host: localhost
   port: 8080
   ssl: true
@@ -69,9 +152,10 @@ database:
 cache:
   redis_url: redis://localhost:6379
   ttl: 3600`,
-
-		// Pattern 4: Markdown guide
-		`# Vim Motions Guide
+	},
+	{
+		Name: "Markdown guide",
+		Text: `# Vim Motions Guide
 ## Basic Movement
 - h: move left
 - j: move down  
@@ -92,9 +176,10 @@ cache:
 ## File Movement
 - gg: top of file
 - G: bottom of file`,
-
-		// Pattern 5: JSON configuration
-		`{
+	},
+	{
+		Name: "JSON configuration",
+		Text: `{
   "name": "boba-vim",
   "version": "1.0.0",
   "description": "Learn vim with boba tea!",
@@ -109,9 +194,10 @@ cache:
     "line_navigation": true
   }
 }`,
-
-		// Pattern 6: CSS styles
-		`.vim-game {
+	},
+	{
+		Name: "CSS styles",
+		Text: `.vim-game {
   background: #2c3e50;
   color: #ecf0f1;
   font-family: monospace;
@@ -134,9 +220,10 @@ cache:
   border: 2px solid #bdc3c7;
   border-radius: 4px;
 }`,
-
-		// Pattern 7: Python code
-		`import random
+	},
+	{
+		Name: "Python code",
+		Text: `import random
 import time
 
 class BobaGame:
@@ -163,9 +250,10 @@ class BobaGame:
         x = random.randint(0, 20)
         y = random.randint(0, 15)
         self.pearls.append({"x": x, "y": y})`,
-
-		// Pattern 8: Heavy spacing for ^ and g_ practice
-		`        function calculateScore() {        
+	},
+	{
+		Name: "Heavy spacing for ^ and g_ practice",
+		Text: `        function calculateScore() {        
             let base = 1000;        
             let penalty = time * 10;        
                 
@@ -176,9 +264,10 @@ class BobaGame:
             }        
             return base + bonus - penalty;        
         }`,
-
-		// Pattern 9: SQL queries
-		`SELECT u.username, u.email, p.score, p.completion_time
+	},
+	{
+		Name: "SQL queries",
+		Text: `SELECT u.username, u.email, p.score, p.completion_time
 FROM users u
 JOIN player_stats p ON u.id = p.user_id
 WHERE p.score > 1000
@@ -191,9 +280,10 @@ SET is_completed = true,
     final_score = current_score,
     end_time = NOW()
 WHERE session_token = ? AND is_active = true;`,
-
-		// Pattern 10: Spaced configuration for practice
-		`     server:     
+	},
+	{
+		Name: "Spaced configuration for practice",
+		Text: `     server:     
        host: localhost      
        port: 8080    
        ssl: true     
@@ -203,16 +293,18 @@ WHERE session_token = ? AND is_active = true;`,
      cache:    
        redis: localhost:6379     
        ttl: 3600      `,
-
-		// Pattern 11: Mixed spacing challenge
-		`         x = 1;           
+	},
+	{
+		Name: "Mixed spacing challenge",
+		Text: `         x = 1;           
               y = 2;        
       a = 4;            
              b = 5;      
      final = x + y + a + b;        `,
-
-		// Pattern 12: Go code
-		`package main
+	},
+	{
+		Name: "Go code",
+		Text: `package main
 
 import (
     "fmt"
@@ -242,16 +334,23 @@ func (p *Player) Move(direction string) {
         p.Position.X++
     }
 }`,
-	}
-	
+	},
+}
+
+// createTextLines creates text grid from game text (variable row lengths)
+func createTextLines(rng *rand.Rand) [][]string {
 	// Randomly select one pattern
-	rand.Seed(time.Now().UnixNano())
-	text := textPatterns[rand.Intn(len(textPatterns))]
-	
+	return TextPatternGrid(rng.Intn(len(TextPatterns)))
+}
+
+// TextPatternGrid returns the character grid of a text pattern
+func TextPatternGrid(index int) [][]string {
 	// Split text into lines preserving all whitespace structure
-	lines := strings.Split(text, "\n")
-	
-	// Convert lines to character grid
+	return linesToGrid(strings.Split(TextPatterns[index].Text, "\n"))
+}
+
+// linesToGrid converts text lines to a character grid
+func linesToGrid(lines []string) [][]string {
 	var grid [][]string
 	for _, line := range lines {
 		row := make([]string, len(line))
@@ -265,7 +364,12 @@ func (p *Player) Move(direction string) {
 }
 
 // createGameMap creates initial game map with player at (0,0)
-func createGameMap(textGrid [][]string) [][]int {
+func createGameMap(textGrid [][]string, strategy string, seed int64) [][]int {
+	return createGameMapWithWalls(textGrid, func(row, col int) bool { return false }, strategy, seed)
+}
+
+// createGameMapWithWalls creates initial game map with player at (0,0) and walls where isWall says so
+func createGameMapWithWalls(textGrid [][]string, isWall func(row, col int) bool, strategy string, seed int64) [][]int {
 	gameMap := make([][]int, len(textGrid))
 	
 	for rowIdx, row := range textGrid {
@@ -273,6 +377,8 @@ func createGameMap(textGrid [][]string) [][]int {
 		for colIdx := range row {
 			if rowIdx == 0 && colIdx == 0 {
 				mapRow[colIdx] = PLAYER // Player position
+			} else if isWall(rowIdx, colIdx) {
+				mapRow[colIdx] = WALL
 			} else {
 				mapRow[colIdx] = EMPTY // Empty space
 			}
@@ -280,35 +386,100 @@ func createGameMap(textGrid [][]string) [][]int {
 		gameMap[rowIdx] = mapRow
 	}
 	
-	// Place one pearl randomly
-	placeNewPearl(gameMap, 0, 0)
+	// Place the initial pearls randomly
+	for i := 0; i < pearlCount(strategy); i++ {
+		placeNewPearl(gameMap, 0, 0, strategy, PearlRNG(seed, i))
+	}
 	return gameMap
 }
 
-// placeNewPearl places a new pearl at random empty position
-func placeNewPearl(gameMap [][]int, playerRow, playerCol int) {
-	rand.Seed(time.Now().UnixNano())
-	
+// placeNewPearl places a new pearl at random empty position and returns
+// where it was placed and which kind of pearl it is (row is -1 if the map is full)
+func placeNewPearl(gameMap [][]int, playerRow, playerCol int, strategy string, rng *rand.Rand) (int, int, int) {
+	isFree := func(row, col int) bool {
+		return gameMap[row][col] == EMPTY && !(row == playerRow && col == playerCol)
+	}
+
+	// Draw random cells first so games sharing a seed pick the same cell
+	// whenever it is free on both maps, even if the rest of the maps differ
+	for attempt := 0; attempt < PEARL_PLACEMENT_ATTEMPTS; attempt++ {
+		row := rng.Intn(len(gameMap))
+		if len(gameMap[row]) == 0 {
+			continue
+		}
+		col := rng.Intn(len(gameMap[row]))
+		if isFree(row, col) {
+			return setPearl(gameMap, row, col, strategy, rng)
+		}
+	}
+
 	// Find all empty positions
 	var emptyPositions [][2]int
 	for rowIdx := 0; rowIdx < len(gameMap); rowIdx++ {
 		for colIdx := 0; colIdx < len(gameMap[rowIdx]); colIdx++ {
-			if gameMap[rowIdx][colIdx] == EMPTY && !(rowIdx == playerRow && colIdx == playerCol) {
+			if isFree(rowIdx, colIdx) {
 				emptyPositions = append(emptyPositions, [2]int{rowIdx, colIdx})
 			}
 		}
 	}
 	
 	// Place pearl at random empty position
-	if len(emptyPositions) > 0 {
-		pos := emptyPositions[rand.Intn(len(emptyPositions))]
-		gameMap[pos[0]][pos[1]] = PEARL
+	if len(emptyPositions) == 0 {
+		return -1, -1, EMPTY
+	}
+	pos := emptyPositions[rng.Intn(len(emptyPositions))]
+	return setPearl(gameMap, pos[0], pos[1], strategy, rng)
+}
+
+// setPearl puts a pearl of the kind the strategy rolls on a free cell
+func setPearl(gameMap [][]int, row, col int, strategy string, rng *rand.Rand) (int, int, int) {
+	pearlType := PEARL
+	if strategy == PearlStrategyMixed || strategy == "" {
+		pearlType = choosePearlType(gameMap, rng)
+	}
+	gameMap[row][col] = pearlType
+	return row, col, pearlType
+}
+
+// choosePearlType rolls the kind of the next pearl, making sure the map
+// always keeps at least one regular or golden pearl to score with
+func choosePearlType(gameMap [][]int, rng *rand.Rand) int {
+	hasScoringPearl := false
+	for _, row := range gameMap {
+		for _, value := range row {
+			if value == PEARL || value == GOLDEN_PEARL {
+				hasScoringPearl = true
+			}
+		}
+	}
+
+	roll := rng.Intn(100)
+	switch {
+	case roll < GOLDEN_PEARL_CHANCE:
+		return GOLDEN_PEARL
+	case roll < GOLDEN_PEARL_CHANCE+BLACK_PEARL_CHANCE && hasScoringPearl:
+		return BLACK_PEARL
+	default:
+		return PEARL
 	}
 }
 
 // PlaceNewPearl is the exported version for external use
-func PlaceNewPearl(gameMap [][]int, excludeRow, excludeCol int) {
-	placeNewPearl(gameMap, excludeRow, excludeCol)
+func PlaceNewPearl(gameMap [][]int, excludeRow, excludeCol int, strategy string, rng *rand.Rand) (int, int, int) {
+	return placeNewPearl(gameMap, excludeRow, excludeCol, strategy, rng)
+}
+
+// CountPearls returns the number of pearls of any kind on the map
+func CountPearls(gameMap [][]int) int {
+	count := 0
+	for _, row := range gameMap {
+		for _, value := range row {
+			if IsPearl(value) {
+				count++
+			}
+		}
+	}
+	return count
 }
 
 // IsValidPosition checks if a position is within bounds
@@ -317,4 +488,36 @@ func IsValidPosition(row, col int, gameMap [][]int) bool {
 		return false
 	}
 	return col >= 0 && col < len(gameMap[row])
-}
\ No newline at end of file
+}
+
+// CopyMap returns a deep copy of a game map
+func CopyMap(gameMap [][]int) [][]int {
+	mapCopy := make([][]int, len(gameMap))
+	for i, row := range gameMap {
+		mapCopy[i] = make([]int, len(row))
+		copy(mapCopy[i], row)
+	}
+	return mapCopy
+}
+
+// CellChange is a map cell whose value changed between two states
+type CellChange struct {
+	Row   int `json:"row"`
+	Col   int `json:"col"`
+	Value int `json:"value"`
+}
+
+// DiffMaps returns the cells of after that differ from before. Cells outside
+// of before count as changed, so a nil before yields the whole map.
+func DiffMaps(before, after [][]int) []CellChange {
+	changes := []CellChange{}
+	for row := range after {
+		for col, value := range after[row] {
+			if IsValidPosition(row, col, before) && before[row][col] == value {
+				continue
+			}
+			changes = append(changes, CellChange{Row: row, Col: col, Value: value})
+		}
+	}
+	return changes
+}
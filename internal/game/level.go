@@ -0,0 +1,109 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WALL_MARKER marks a wall cell in a level's wall layout
+const WALL_MARKER = '#'
+
+// Level describes a hand-made map loaded from a level file
+type Level struct {
+	Name  string   `json:"name"`
+	Lines []string `json:"lines"`
+	// Walls is drawn over Lines, a '#' at a given row/column turns that cell into a wall
+	Walls []string `json:"walls"`
+	// Enemies lists the starting cells of the enemy bobas chasing the player
+	Enemies []Enemy `json:"enemies"`
+}
+
+// LoadLevels reads every *.json level file in dir, keyed by level name
+func LoadLevels(dir string) (map[string]*Level, error) {
+	levels := make(map[string]*Level)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var level Level
+		if err := json.Unmarshal(data, &level); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if level.Name == "" {
+			level.Name = strings.TrimSuffix(filepath.Base(file), ".json")
+		}
+		if err := level.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		levels[level.Name] = &level
+	}
+
+	return levels, nil
+}
+
+// Validate checks that the level has text and a wall layout that fits it
+func (l *Level) Validate() error {
+	if len(l.Lines) == 0 || len(l.Lines[0]) == 0 {
+		return fmt.Errorf("level %q has no text", l.Name)
+	}
+	if len(l.Walls) > len(l.Lines) {
+		return fmt.Errorf("level %q declares walls below the last line", l.Name)
+	}
+	for rowIdx, wallRow := range l.Walls {
+		if len(wallRow) > len(l.Lines[rowIdx]) {
+			return fmt.Errorf("level %q wall row %d is longer than its text line", l.Name, rowIdx)
+		}
+	}
+	if l.IsWall(0, 0) {
+		return fmt.Errorf("level %q starts the player inside a wall", l.Name)
+	}
+	for _, enemy := range l.Enemies {
+		if enemy.Row < 0 || enemy.Row >= len(l.Lines) || enemy.Col < 0 || enemy.Col >= len(l.Lines[enemy.Row]) {
+			return fmt.Errorf("level %q places an enemy outside the text", l.Name)
+		}
+		if l.IsWall(enemy.Row, enemy.Col) || (enemy.Row == 0 && enemy.Col == 0) {
+			return fmt.Errorf("level %q places an enemy on a wall or the player", l.Name)
+		}
+	}
+	return nil
+}
+
+// IsWall reports whether the level declares a wall at the given cell
+func (l *Level) IsWall(row, col int) bool {
+	if row < 0 || row >= len(l.Walls) || col < 0 || col >= len(l.Walls[row]) {
+		return false
+	}
+	return l.Walls[row][col] == WALL_MARKER
+}
+
+// TextGrid converts the level lines to a character grid
+func (l *Level) TextGrid() [][]string {
+	return linesToGrid(l.Lines)
+}
+
+// InitializeLevelSession creates a new game from a level, placing its walls on the map
+func InitializeLevelSession(level *Level, strategy string, seed int64) map[string]interface{} {
+	textGrid := level.TextGrid()
+	gameMap := createGameMapWithWalls(textGrid, level.IsWall, strategy, seed)
+	enemies := PlaceEnemies(gameMap, level.Enemies)
+
+	return map[string]interface{}{
+		"text_grid":        textGrid,
+		"game_map":         gameMap,
+		"player_pos":       map[string]int{"row": 0, "col": 0},
+		"preferred_column": 0,
+		"enemies":          enemies,
+		"pearl_spawns":     pearlCount(strategy),
+	}
+}
@@ -45,6 +45,29 @@ type MovementResult struct {
 	NewCol          int  `json:"new_col"`
 	PreferredColumn int  `json:"preferred_column"`
 	IsValid         bool `json:"is_valid"`
+	BlockedByWall   bool `json:"blocked_by_wall"`
+}
+
+// Wall rules decide what a motion does when it meets a wall
+const (
+	// WallBlocks rejects the motion when its target is a wall (hjkl)
+	WallBlocks = iota
+	// WallStopShort pulls the cursor back towards its start until it is off the wall (line motions)
+	WallStopShort
+	// WallJumpOver lets the motion pass over walls but rejects it if it lands on one
+	WallJumpOver
+)
+
+// wallRule returns the wall rule for a direction
+func wallRule(direction string) int {
+	switch direction {
+	case "left", "right", "up", "down":
+		return WallBlocks
+	case "line_end", "line_start", "line_first_non_blank", "line_last_non_blank":
+		return WallStopShort
+	default:
+		return WallJumpOver
+	}
 }
 
 // CalculateNewPosition calculates the new position based on vim-style movement
@@ -166,15 +189,41 @@ func CalculateNewPosition(direction string, currentRow, currentCol int, gameMap
 	}
 	
 	isValid := IsValidPosition(newRow, newCol, gameMap)
+	blockedByWall := false
+
+	// Apply the wall rules of this motion
+	if isValid && IsWall(gameMap[newRow][newCol]) {
+		switch wallRule(direction) {
+		case WallStopShort:
+			newCol = stopShortOfWall(newRow, newCol, currentCol, gameMap)
+			newPreferredColumn = newCol
+		default:
+			isValid = false
+			blockedByWall = true
+		}
+	}
 	
 	return &MovementResult{
 		NewRow:          newRow,
 		NewCol:          newCol,
 		PreferredColumn: newPreferredColumn,
 		IsValid:         isValid,
+		BlockedByWall:   blockedByWall,
 	}, nil
 }
 
+// stopShortOfWall walks from col back towards fromCol until it leaves the wall
+func stopShortOfWall(row, col, fromCol int, gameMap [][]int) int {
+	step := 1
+	if col > fromCol {
+		step = -1
+	}
+	for col != fromCol && IsWall(gameMap[row][col]) {
+		col += step
+	}
+	return col
+}
+
 // isValidDirection checks if the direction is valid
 func isValidDirection(direction string) bool {
 	validDirections := map[string]bool{
@@ -261,6 +310,73 @@ func GetAvailableMovements() []map[string]interface{} {
 	return movements
 }
 
+// ResolveDirection turns a movement key sent by the client into a direction name,
+// character search directions ("find_char_forward_x") are passed through unchanged
+func ResolveDirection(key string) (string, bool) {
+	if (len(key) > 17 && key[:17] == "find_char_forward") ||
+		(len(key) > 18 && key[:18] == "find_char_backward") ||
+		(len(key) > 17 && key[:17] == "till_char_forward") ||
+		(len(key) > 18 && key[:18] == "till_char_backward") {
+		return key, true
+	}
+
+	info, exists := MovementKeys[key]
+	if !exists {
+		return "", false
+	}
+	return info["direction"].(string), true
+}
+
+// MotionKey returns the movement key a direction request was made with,
+// character searches like "find_char_forward_x" map back to their key ("f")
+func MotionKey(direction string) string {
+	switch {
+	case len(direction) > 17 && direction[:17] == "find_char_forward":
+		return "f"
+	case len(direction) > 18 && direction[:18] == "find_char_backward":
+		return "F"
+	case len(direction) > 17 && direction[:17] == "till_char_forward":
+		return "t"
+	case len(direction) > 18 && direction[:18] == "till_char_backward":
+		return "T"
+	}
+	return direction
+}
+
+// KeystrokeCount returns how many keys the player typed for a movement request,
+// character searches count the motion key and the searched character
+func KeystrokeCount(direction string) int {
+	if MotionKey(direction) != direction {
+		return 2
+	}
+	return len(direction)
+}
+
+// IsMovementKey reports whether key is a known movement key
+func IsMovementKey(key string) bool {
+	_, exists := MovementKeys[key]
+	return exists
+}
+
+// IsMotionAllowed checks a movement key against a level's allowlist and blocklist,
+// an empty allowlist allows every key that is not blocked
+func IsMotionAllowed(key string, allowed, blocked []string) bool {
+	for _, blockedKey := range blocked {
+		if blockedKey == key {
+			return false
+		}
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, allowedKey := range allowed {
+		if allowedKey == key {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidateMovement validates if a movement is allowed
 func ValidateMovement(direction string, currentRow, currentCol int, gameMap [][]int) error {
 	if !isValidDirection(direction) {
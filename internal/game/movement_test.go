@@ -0,0 +1,98 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+// wallMap builds a game map from wall masks, where '#' marks a wall cell
+func wallMap(walls []string) [][]int {
+	gameMap := make([][]int, len(walls))
+	for row, mask := range walls {
+		gameMap[row] = make([]int, len(mask))
+		for col := range mask {
+			if mask[col] == '#' {
+				gameMap[row][col] = WALL
+			}
+		}
+	}
+	return gameMap
+}
+
+// testBoard builds a game map and the text grid laid over it
+func testBoard(lines, walls []string) ([][]int, [][]string) {
+	textGrid := make([][]string, len(lines))
+	for row, line := range lines {
+		textGrid[row] = strings.Split(line, "")
+	}
+	return wallMap(walls), textGrid
+}
+
+func TestCalculateNewPositionWallRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		lines       []string
+		walls       []string
+		direction   string
+		row, col    int
+		wantRow     int
+		wantCol     int
+		wantValid   bool
+		wantBlocked bool
+	}{
+		{"l into a wall is blocked", []string{"ab cd"}, []string{"..#.."}, "right", 0, 1, 0, 2, false, true},
+		{"h into a wall is blocked", []string{"ab cd"}, []string{"#...."}, "left", 0, 1, 0, 0, false, true},
+		{"j into a wall is blocked", []string{"ab", "cd"}, []string{"..", ".#"}, "down", 0, 1, 1, 1, false, true},
+		{"l onto an open cell moves", []string{"ab cd"}, []string{"..#.."}, "right", 0, 0, 0, 1, true, false},
+		{"$ stops short of a wall at the line end", []string{"ab cd"}, []string{"...##"}, "line_end", 0, 0, 0, 2, true, false},
+		{"0 stops short of a wall at the line start", []string{"ab cd"}, []string{"##..."}, "line_start", 0, 4, 0, 2, true, false},
+		{"$ against walls up to the cursor stays put", []string{"ab cd"}, []string{".####"}, "line_end", 0, 0, 0, 0, true, false},
+		{"w jumps over a wall", []string{"ab cd"}, []string{"..#.."}, "word_forward", 0, 0, 0, 3, true, false},
+		{"w landing on a wall is blocked", []string{"ab cd"}, []string{"...#."}, "word_forward", 0, 0, 0, 3, false, true},
+		{"G landing on a wall is blocked", []string{"ab", "cd"}, []string{"..", ".#"}, "file_end", 0, 0, 1, 1, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameMap, textGrid := testBoard(tt.lines, tt.walls)
+			result, err := CalculateNewPosition(tt.direction, tt.row, tt.col, gameMap, textGrid, tt.col)
+			if err != nil {
+				t.Fatalf("CalculateNewPosition: %v", err)
+			}
+			if result.NewRow != tt.wantRow || result.NewCol != tt.wantCol {
+				t.Errorf("position = %d,%d, want %d,%d", result.NewRow, result.NewCol, tt.wantRow, tt.wantCol)
+			}
+			if result.IsValid != tt.wantValid || result.BlockedByWall != tt.wantBlocked {
+				t.Errorf("valid/blocked = %v/%v, want %v/%v", result.IsValid, result.BlockedByWall, tt.wantValid, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestMotionRestrictions(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction string
+		allowed   []string
+		blocked   []string
+		want      bool
+	}{
+		{"no restrictions allow everything", "w", nil, nil, true},
+		{"allowlisted key", "h", []string{"h", "l"}, nil, true},
+		{"key missing from the allowlist", "w", []string{"h", "l"}, nil, false},
+		{"two-key motion on the allowlist", "gg", []string{"gg"}, nil, true},
+		{"blocked key", "w", nil, []string{"w"}, false},
+		{"key not on the blocklist", "b", nil, []string{"w"}, true},
+		{"blocklist wins over the allowlist", "w", []string{"w", "b"}, []string{"w"}, false},
+		{"character search counts as f", "find_char_forward_x", []string{"f"}, nil, true},
+		{"character search off the allowlist", "find_char_backward_x", []string{"f"}, nil, false},
+		{"blocked till", "till_char_forward_x", nil, []string{"t"}, false},
+		{"blocking t keeps T", "till_char_backward_x", nil, []string{"t"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsMotionAllowed(MotionKey(tt.direction), tt.allowed, tt.blocked); got != tt.want {
+				t.Errorf("IsMotionAllowed(%q) = %v, want %v", tt.direction, got, tt.want)
+			}
+		})
+	}
+}
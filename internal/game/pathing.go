@@ -0,0 +1,47 @@
+package game
+
+// pathState is a cursor position with the column vertical motions aim for
+type pathState struct {
+	row, col, preferredColumn int
+}
+
+// ShortestPath returns the fewest moves taking the cursor from one cell to
+// another using only the given directions, or -1 when it takes more than
+// maxMoves. Only walls get in the way, pearls and enemies are ignored.
+func ShortestPath(gameMap [][]int, textGrid [][]string, fromRow, fromCol, preferredColumn, toRow, toCol int, directions []string, maxMoves int) int {
+	if fromRow == toRow && fromCol == toCol {
+		return 0
+	}
+
+	var resolved []string
+	for _, direction := range directions {
+		if name, ok := ResolveDirection(direction); ok {
+			resolved = append(resolved, name)
+		}
+	}
+
+	start := pathState{fromRow, fromCol, preferredColumn}
+	seen := map[pathState]bool{start: true}
+	frontier := []pathState{start}
+	for moves := 1; moves <= maxMoves && len(frontier) > 0; moves++ {
+		var next []pathState
+		for _, current := range frontier {
+			for _, direction := range resolved {
+				result, err := CalculateNewPosition(direction, current.row, current.col, gameMap, textGrid, current.preferredColumn)
+				if err != nil || !result.IsValid || result.BlockedByWall {
+					continue
+				}
+				if result.NewRow == toRow && result.NewCol == toCol {
+					return moves
+				}
+				state := pathState{result.NewRow, result.NewCol, result.PreferredColumn}
+				if !seen[state] {
+					seen[state] = true
+					next = append(next, state)
+				}
+			}
+		}
+		frontier = next
+	}
+	return -1
+}
@@ -0,0 +1,231 @@
+package game
+
+import "math/rand"
+
+// COOP_PAIRS is how many target pairs a coop map holds at once
+const COOP_PAIRS = 2
+
+// Cursor is one player's position on a shared map
+type Cursor struct {
+	OwnerID         uint `json:"owner_id"`
+	Row             int  `json:"row"`
+	Col             int  `json:"col"`
+	PreferredColumn int  `json:"preferred_column"`
+}
+
+// TargetPair is a pair of targets on a coop map. It scores once each of its
+// targets was visited by a different player, a visited target shows as a
+// golden pearl until its partner is visited too.
+type TargetPair struct {
+	Targets   [2][2]int `json:"targets"`    // row and col of each target
+	VisitedBy [2]uint   `json:"visited_by"` // player who visited each target, 0 until then
+}
+
+// SharedMap is a game map several players move on at once. Every cursor is a
+// PLAYER marker on the map and Cursors tells who owns it. A cell holds at most
+// one cursor, so the first player to reach a pearl takes it. Coop maps hold
+// target pairs instead of pearls.
+type SharedMap struct {
+	GameMap     [][]int      `json:"game_map"`
+	TextGrid    [][]string   `json:"text_grid"`
+	Cursors     []Cursor     `json:"cursors"`
+	Strategy    string       `json:"strategy"`
+	Seed        int64        `json:"seed"`
+	PearlSpawns int          `json:"pearl_spawns"`
+	Coop        bool         `json:"coop"`
+	Pairs       []TargetPair `json:"pairs,omitempty"`
+}
+
+// NewSharedMap creates the map of a seeded game without any player on it,
+// pattern picks the text like in InitializePatternSession
+func NewSharedMap(pattern int, strategy string, seed int64) *SharedMap {
+	gameData := InitializePatternSession(pattern, strategy, seed)
+	gameMap := gameData["game_map"].([][]int)
+	position := gameData["player_pos"].(map[string]int)
+	gameMap[position["row"]][position["col"]] = EMPTY
+
+	return &SharedMap{
+		GameMap:     gameMap,
+		TextGrid:    gameData["text_grid"].([][]string),
+		Cursors:     []Cursor{},
+		Strategy:    strategy,
+		Seed:        seed,
+		PearlSpawns: gameData["pearl_spawns"].(int),
+	}
+}
+
+// NewCoopMap creates the map of a seeded coop game, with target pairs instead of pearls
+func NewCoopMap(pattern int, seed int64) *SharedMap {
+	m := NewSharedMap(pattern, PearlStrategyRegular, seed)
+	for row := range m.GameMap {
+		for col, value := range m.GameMap[row] {
+			if IsPearl(value) {
+				m.GameMap[row][col] = EMPTY
+			}
+		}
+	}
+
+	m.Coop = true
+	for i := 0; i < COOP_PAIRS; i++ {
+		m.spawnPair()
+	}
+	return m
+}
+
+// Clone copies the map to restore it later, the text is shared since nothing changes it
+func (m *SharedMap) Clone() *SharedMap {
+	clone := *m
+	clone.GameMap = CopyMap(m.GameMap)
+	clone.Cursors = append([]Cursor{}, m.Cursors...)
+	clone.Pairs = append([]TargetPair(nil), m.Pairs...)
+	return &clone
+}
+
+// Cursor returns the cursor of a player, nil when the player is not on the map
+func (m *SharedMap) Cursor(ownerID uint) *Cursor {
+	for i := range m.Cursors {
+		if m.Cursors[i].OwnerID == ownerID {
+			return &m.Cursors[i]
+		}
+	}
+	return nil
+}
+
+// OwnerAt returns the player whose cursor is on a cell
+func (m *SharedMap) OwnerAt(row, col int) (uint, bool) {
+	for _, cursor := range m.Cursors {
+		if cursor.Row == row && cursor.Col == col {
+			return cursor.OwnerID, true
+		}
+	}
+	return 0, false
+}
+
+// AddCursor puts a player on a random empty cell, players already on the map keep their cursor
+func (m *SharedMap) AddCursor(ownerID uint, rng *rand.Rand) *Cursor {
+	if cursor := m.Cursor(ownerID); cursor != nil {
+		return cursor
+	}
+
+	var emptyPositions [][2]int
+	for row := range m.GameMap {
+		for col, value := range m.GameMap[row] {
+			if value == EMPTY {
+				emptyPositions = append(emptyPositions, [2]int{row, col})
+			}
+		}
+	}
+	if len(emptyPositions) == 0 {
+		return nil
+	}
+
+	pos := emptyPositions[rng.Intn(len(emptyPositions))]
+	m.GameMap[pos[0]][pos[1]] = PLAYER
+	m.Cursors = append(m.Cursors, Cursor{OwnerID: ownerID, Row: pos[0], Col: pos[1], PreferredColumn: pos[1]})
+	return &m.Cursors[len(m.Cursors)-1]
+}
+
+// RemoveCursor takes a player off the map
+func (m *SharedMap) RemoveCursor(ownerID uint) {
+	for i, cursor := range m.Cursors {
+		if cursor.OwnerID == ownerID {
+			m.GameMap[cursor.Row][cursor.Col] = m.uncoveredValue(cursor.Row, cursor.Col)
+			m.Cursors = append(m.Cursors[:i], m.Cursors[i+1:]...)
+			return
+		}
+	}
+}
+
+// MoveCursor moves a player's cursor to a free cell and returns what the cell held
+func (m *SharedMap) MoveCursor(ownerID uint, row, col, preferredColumn int) int {
+	cursor := m.Cursor(ownerID)
+	target := m.GameMap[row][col]
+
+	m.GameMap[cursor.Row][cursor.Col] = m.uncoveredValue(cursor.Row, cursor.Col)
+	m.GameMap[row][col] = PLAYER
+	cursor.Row = row
+	cursor.Col = col
+	cursor.PreferredColumn = preferredColumn
+	return target
+}
+
+// PlaceNewPearl replaces a collected pearl, drawing from the map's pearl sequence
+func (m *SharedMap) PlaceNewPearl(excludeRow, excludeCol int) (int, int, int) {
+	rng := PearlRNG(m.Seed, m.PearlSpawns)
+	m.PearlSpawns++
+	return placeNewPearl(m.GameMap, excludeRow, excludeCol, m.Strategy, rng)
+}
+
+// VisitTarget records a player reaching a cell of a coop map. When it completes
+// a target pair the pair is replaced by a new one and the partner who visited
+// the other target is returned.
+func (m *SharedMap) VisitTarget(ownerID uint, row, col int) (uint, bool) {
+	pairIndex, target, found := m.targetAt(row, col)
+	if !found {
+		return 0, false
+	}
+
+	pair := &m.Pairs[pairIndex]
+	other := 1 - target
+	// Each player only counts for one target of a pair
+	if pair.VisitedBy[target] != 0 || pair.VisitedBy[other] == ownerID {
+		return 0, false
+	}
+	pair.VisitedBy[target] = ownerID
+	if pair.VisitedBy[other] == 0 {
+		return 0, false
+	}
+
+	partnerID := pair.VisitedBy[other]
+	otherRow, otherCol := pair.Targets[other][0], pair.Targets[other][1]
+	m.Pairs = append(m.Pairs[:pairIndex], m.Pairs[pairIndex+1:]...)
+	if _, covered := m.OwnerAt(otherRow, otherCol); !covered {
+		m.GameMap[otherRow][otherCol] = EMPTY
+	}
+	m.spawnPair()
+	return partnerID, true
+}
+
+// spawnPair places a new target pair on empty cells
+func (m *SharedMap) spawnPair() {
+	rng := PearlRNG(m.Seed, m.PearlSpawns)
+	m.PearlSpawns++
+
+	var pair TargetPair
+	for i := range pair.Targets {
+		row, col, _ := placeNewPearl(m.GameMap, -1, -1, PearlStrategyRegular, rng)
+		if row < 0 {
+			// No room for the whole pair, take back its first target
+			if i > 0 {
+				m.GameMap[pair.Targets[0][0]][pair.Targets[0][1]] = EMPTY
+			}
+			return
+		}
+		pair.Targets[i] = [2]int{row, col}
+	}
+	m.Pairs = append(m.Pairs, pair)
+}
+
+// targetAt finds the target pair with a target on a cell
+func (m *SharedMap) targetAt(row, col int) (int, int, bool) {
+	for i, pair := range m.Pairs {
+		for target, position := range pair.Targets {
+			if position[0] == row && position[1] == col {
+				return i, target, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// uncoveredValue is what a cell shows once no cursor is on it
+func (m *SharedMap) uncoveredValue(row, col int) int {
+	pairIndex, target, found := m.targetAt(row, col)
+	if !found {
+		return EMPTY
+	}
+	if m.Pairs[pairIndex].VisitedBy[target] != 0 {
+		return GOLDEN_PEARL
+	}
+	return PEARL
+}
@@ -0,0 +1,165 @@
+package game
+
+import "testing"
+
+// testCoopMap builds a coop map from wall masks with one target pair on the given cells
+func testCoopMap(walls []string, targets [2][2]int) *SharedMap {
+	m := &SharedMap{GameMap: wallMap(walls), Cursors: []Cursor{}, Seed: 42, Coop: true}
+	for _, target := range targets {
+		m.GameMap[target[0]][target[1]] = PEARL
+	}
+	m.Pairs = []TargetPair{{Targets: targets}}
+	return m
+}
+
+// visit moves a player's cursor onto a cell, adding the cursor first if needed, and records the visit
+func visit(m *SharedMap, ownerID uint, row, col int) (uint, bool) {
+	if m.Cursor(ownerID) == nil {
+		m.GameMap[row][col] = PLAYER
+		m.Cursors = append(m.Cursors, Cursor{OwnerID: ownerID, Row: row, Col: col, PreferredColumn: col})
+	} else {
+		m.MoveCursor(ownerID, row, col, col)
+	}
+	return m.VisitTarget(ownerID, row, col)
+}
+
+func TestVisitTarget(t *testing.T) {
+	targets := [2][2]int{{0, 0}, {0, 4}}
+
+	t.Run("first target waits for a partner", func(t *testing.T) {
+		m := testCoopMap([]string{"......"}, targets)
+		if _, completed := visit(m, 1, 0, 0); completed {
+			t.Fatal("one visited target completed the pair")
+		}
+		if m.Pairs[0].VisitedBy != [2]uint{1, 0} {
+			t.Errorf("visited by %v, want [1 0]", m.Pairs[0].VisitedBy)
+		}
+		m.MoveCursor(1, 0, 1, 1)
+		if m.GameMap[0][0] != GOLDEN_PEARL {
+			t.Errorf("visited target shows %d once left, want a golden pearl", m.GameMap[0][0])
+		}
+	})
+
+	t.Run("the same player cannot visit both targets", func(t *testing.T) {
+		m := testCoopMap([]string{"......"}, targets)
+		visit(m, 1, 0, 0)
+		if _, completed := visit(m, 1, 0, 4); completed {
+			t.Fatal("one player completed a pair alone")
+		}
+		if m.Pairs[0].VisitedBy != [2]uint{1, 0} {
+			t.Errorf("visited by %v, want [1 0]", m.Pairs[0].VisitedBy)
+		}
+		m.MoveCursor(1, 0, 3, 3)
+		if m.GameMap[0][4] != PEARL {
+			t.Errorf("target the player visited twice shows %d, want a pearl", m.GameMap[0][4])
+		}
+	})
+
+	t.Run("a partner completes the pair", func(t *testing.T) {
+		m := testCoopMap([]string{"......"}, targets)
+		visit(m, 1, 0, 0)
+		m.MoveCursor(1, 0, 1, 1)
+		partnerID, completed := visit(m, 2, 0, 4)
+		if !completed || partnerID != 1 {
+			t.Fatalf("VisitTarget = %d, %v, want 1, true", partnerID, completed)
+		}
+		if len(m.Pairs) != 1 || m.Pairs[0].Targets == targets {
+			t.Fatalf("pairs after completion %v, want one new pair", m.Pairs)
+		}
+		// The new pair may reuse the freed cell
+		if _, _, retargeted := m.targetAt(0, 0); !retargeted && m.GameMap[0][0] != EMPTY {
+			t.Errorf("first target of the completed pair shows %d, want empty", m.GameMap[0][0])
+		}
+		for _, target := range m.Pairs[0].Targets {
+			if m.GameMap[target[0]][target[1]] != PEARL {
+				t.Errorf("new target %v shows %d, want a pearl", target, m.GameMap[target[0]][target[1]])
+			}
+		}
+	})
+
+	t.Run("a partner standing on the other target keeps its cursor", func(t *testing.T) {
+		m := testCoopMap([]string{"......"}, targets)
+		visit(m, 1, 0, 0)
+		if _, completed := visit(m, 2, 0, 4); !completed {
+			t.Fatal("pair not completed")
+		}
+		if m.GameMap[0][0] != PLAYER {
+			t.Errorf("partner's cell shows %d, want the cursor", m.GameMap[0][0])
+		}
+		m.MoveCursor(1, 0, 1, 1)
+		if m.GameMap[0][0] != EMPTY {
+			t.Errorf("old target shows %d once the partner left, want empty", m.GameMap[0][0])
+		}
+	})
+
+	t.Run("a visited target does not count twice", func(t *testing.T) {
+		m := testCoopMap([]string{"......"}, targets)
+		visit(m, 1, 0, 0)
+		m.MoveCursor(1, 0, 1, 1)
+		if _, completed := visit(m, 2, 0, 0); completed {
+			t.Fatal("a second visit of the same target completed the pair")
+		}
+		if m.Pairs[0].VisitedBy != [2]uint{1, 0} {
+			t.Errorf("visited by %v, want [1 0]", m.Pairs[0].VisitedBy)
+		}
+	})
+
+	t.Run("a cell without a target", func(t *testing.T) {
+		m := testCoopMap([]string{"......"}, targets)
+		if _, completed := visit(m, 1, 0, 2); completed {
+			t.Fatal("an empty cell completed a pair")
+		}
+		if m.Pairs[0].VisitedBy != [2]uint{} {
+			t.Errorf("visited by %v, want nobody", m.Pairs[0].VisitedBy)
+		}
+	})
+}
+
+func TestSpawnPairOnFullBoard(t *testing.T) {
+	tests := []struct {
+		name  string
+		walls []string
+	}{
+		{"no empty cell", []string{"..##"}},
+		{"room for one target only", []string{"..#."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testCoopMap(tt.walls, [2][2]int{{0, 0}, {0, 1}})
+			before := CopyMap(m.GameMap)
+			m.spawnPair()
+			if len(m.Pairs) != 1 {
+				t.Errorf("%d pairs, want the old pair only", len(m.Pairs))
+			}
+			for row := range before {
+				for col := range before[row] {
+					if m.GameMap[row][col] != before[row][col] {
+						t.Errorf("cell %d,%d changed from %d to %d", row, col, before[row][col], m.GameMap[row][col])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUncoveredValue(t *testing.T) {
+	m := testCoopMap([]string{"...."}, [2][2]int{{0, 0}, {0, 1}})
+	m.Pairs[0].VisitedBy[0] = 1
+
+	tests := []struct {
+		name string
+		col  int
+		want int
+	}{
+		{"visited target", 0, GOLDEN_PEARL},
+		{"unvisited target", 1, PEARL},
+		{"no target", 2, EMPTY},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.uncoveredValue(0, tt.col); got != tt.want {
+				t.Errorf("uncoveredValue = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
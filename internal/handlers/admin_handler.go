@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	gameService *services.GameService
+	cfg         *config.Config
+}
+
+func NewAdminHandler(cfg *config.Config, gameService *services.GameService) *AdminHandler {
+	return &AdminHandler{
+		gameService: gameService,
+		cfg:         cfg,
+	}
+}
+
+// ListReviews returns the games the anti-cheat checks queued for review
+func (ah *AdminHandler) ListReviews(c *gin.Context) {
+	if _, ok := ah.requireAdmin(c); !ok {
+		return
+	}
+
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 200 {
+			limit = parsedLimit
+		}
+	}
+
+	result, err := ah.gameService.ReviewQueue(limit)
+	ah.respond(c, result, err)
+}
+
+// ReviewGame clears or confirms a queued game
+func (ah *AdminHandler) ReviewGame(c *gin.Context) {
+	adminID, ok := ah.requireAdmin(c)
+	if !ok {
+		return
+	}
+	gameID, ok := gameParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Action string `json:"action" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	result, err := ah.gameService.ReviewGame(adminID, gameID, request.Action)
+	ah.respond(c, result, err)
+}
+
+// requireAdmin returns the logged in player's id when they are an admin,
+// writing the error otherwise
+func (ah *AdminHandler) requireAdmin(c *gin.Context) (uint, bool) {
+	playerID := sessionPlayerID(c)
+	if playerID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Log in to review games",
+		})
+		return 0, false
+	}
+
+	isAdmin, err := ah.gameService.IsAdmin(playerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return 0, false
+	}
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Only admins can review games",
+		})
+		return 0, false
+	}
+	return playerID, true
+}
+
+// respond writes a service result, failures are the client's fault
+func (ah *AdminHandler) respond(c *gin.Context, result map[string]interface{}, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
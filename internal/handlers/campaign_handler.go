@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type CampaignHandler struct {
+	campaignService *services.CampaignService
+	cfg             *config.Config
+}
+
+func NewCampaignHandler(cfg *config.Config, campaignService *services.CampaignService) *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: campaignService,
+		cfg:             cfg,
+	}
+}
+
+// ListLevels returns the campaign levels with the current player's progress
+func (ch *CampaignHandler) ListLevels(c *gin.Context) {
+	result, err := ch.campaignService.ListLevels(sessionPlayerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// StartLevel starts a game on a campaign level and stores its session token
+func (ch *CampaignHandler) StartLevel(c *gin.Context) {
+	levelID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid level id",
+		})
+		return
+	}
+
+	var request struct {
+		Character string `json:"character"`
+	}
+	// The body is optional, the default character is used without it
+	_ = c.ShouldBindJSON(&request)
+
+	result, err := ch.campaignService.StartLevel(sessionPlayerID(c), request.Character, uint(levelID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusForbidden, result)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("game_session_token", result["session_token"])
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to save session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// sessionPlayerID returns the id of the logged-in player, 0 for anonymous sessions.
+// Anyone can change the session username through /api/set-username, so only the
+// user id set at login identifies a player.
+func sessionPlayerID(c *gin.Context) uint {
+	playerID, _ := sessions.Default(c).Get("user_id").(uint)
+	return playerID
+}
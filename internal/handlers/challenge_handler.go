@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ChallengeHandler struct {
+	challengeService *services.ChallengeService
+}
+
+func NewChallengeHandler(db *gorm.DB, cfg *config.Config) *ChallengeHandler {
+	challengeService := services.NewChallengeService(db, cfg)
+	if err := challengeService.SyncChallenges(); err != nil {
+		log.Printf("Failed to sync challenges from %s: %v", cfg.ChallengesFile, err)
+	}
+
+	return &ChallengeHandler{
+		challengeService: challengeService,
+	}
+}
+
+// ListChallenges returns the vimgolf challenges with their records and the player's bests
+func (ch *ChallengeHandler) ListChallenges(c *gin.Context) {
+	result, err := ch.challengeService.ListChallenges(sessionPlayerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetChallenge returns a challenge with its leaderboard
+func (ch *ChallengeHandler) GetChallenge(c *gin.Context) {
+	challengeID, ok := challengeParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := ch.challengeService.GetChallenge(sessionPlayerID(c), challengeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusNotFound, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Submit verifies a solution by replaying its keys and records it
+func (ch *ChallengeHandler) Submit(c *gin.Context) {
+	challengeID, ok := challengeParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Keys string `json:"keys" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	result, err := ch.challengeService.Submit(sessionPlayerID(c), challengeID, request.Keys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// challengeParam parses the challenge id of the route, writing the error when it is invalid
+func challengeParam(c *gin.Context) (uint, bool) {
+	challengeID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid challenge id",
+		})
+		return 0, false
+	}
+	return uint(challengeID), true
+}
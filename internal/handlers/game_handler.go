@@ -5,7 +5,7 @@ import (
 	"strconv"
 
 	"boba-vim/internal/config"
-	"boba-vim/internal/game"
+	"boba-vim/internal/models"
 	"boba-vim/internal/services"
 
 	"github.com/gin-contrib/sessions"
@@ -14,15 +14,18 @@ import (
 )
 
 type GameHandler struct {
-	gameService *services.GameService
-	cfg         *config.Config
+	gameService     *services.GameService
+	tutorialService *services.TutorialService
+	matchService    *services.MatchService
+	cfg             *config.Config
 }
 
-func NewGameHandler(db *gorm.DB) *GameHandler {
-	cfg := config.Load()
+func NewGameHandler(db *gorm.DB, cfg *config.Config, gameService *services.GameService, matchService *services.MatchService) *GameHandler {
 	return &GameHandler{
-		gameService: services.NewGameService(db, cfg),
-		cfg:         cfg,
+		gameService:     gameService,
+		tutorialService: services.NewTutorialService(db, cfg),
+		matchService:    matchService,
+		cfg:             cfg,
 	}
 }
 
@@ -83,8 +86,8 @@ func (gh *GameHandler) MovePlayer(c *gin.Context) {
 		return
 	}
 
-	// Process move
-	result, err := gh.gameService.ProcessMove(sessionToken.(string), request.Direction)
+	// Process move, online matches share it with the other players
+	result, err := gh.matchService.ProcessMove(sessionToken.(string), request.Direction)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -92,6 +95,11 @@ func (gh *GameHandler) MovePlayer(c *gin.Context) {
 		})
 		return
 	}
+	// A parallel move was saved first, the client may send the move again
+	if result["error_code"] == models.ErrMoveConflict.Code {
+		c.JSON(http.StatusConflict, result)
+		return
+	}
 
 	c.JSON(http.StatusOK, result)
 }
@@ -108,7 +116,7 @@ func (gh *GameHandler) GetGameState(c *gin.Context) {
 		return
 	}
 
-	result, err := gh.gameService.GetGameState(sessionToken.(string))
+	result, err := gh.matchService.GetGameState(sessionToken.(string))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -142,12 +150,24 @@ func (gh *GameHandler) GetLeaderboard(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// GetAvailableMovements returns all available movement keys
+// GetAvailableMovements returns the movement keys allowed in the current game
 func (gh *GameHandler) GetAvailableMovements(c *gin.Context) {
-	movements := game.GetAvailableMovements()
+	session := sessions.Default(c)
+	sessionToken, _ := session.Get("game_session_token").(string)
+
+	movements, restricted, err := gh.gameService.GetAvailableMovements(sessionToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"movements": movements,
-		"total":     len(movements),
+		"movements":  movements,
+		"total":      len(movements),
+		"restricted": restricted,
 	})
 }
 
@@ -173,18 +193,197 @@ func (gh *GameHandler) GetPlayerStats(c *gin.Context) {
 	})
 }
 
-// PlayTutorial placeholder for tutorial mode
+// PlayTutorial starts a tutorial lesson and stores its session token
 func (gh *GameHandler) PlayTutorial(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"success": false,
-		"message": "Tutorial mode not implemented yet",
-	})
+	var request struct {
+		LessonID string `json:"lesson_id"`
+	}
+	// Without a lesson id the player resumes the first lesson they have not completed
+	_ = c.ShouldBindJSON(&request)
+
+	result, err := gh.tutorialService.StartLesson(sessionPlayerID(c), request.LessonID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusNotFound, result)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("tutorial_session_token", result["session_token"])
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to save session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListTutorialLessons returns the tutorial lessons with the current player's progress
+func (gh *GameHandler) ListTutorialLessons(c *gin.Context) {
+	result, err := gh.tutorialService.ListLessons(sessionPlayerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// TutorialMove applies a motion to the current tutorial step
+func (gh *GameHandler) TutorialMove(c *gin.Context) {
+	var request struct {
+		Direction string `json:"direction" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	session := sessions.Default(c)
+	sessionToken, ok := session.Get("tutorial_session_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "No active tutorial session",
+		})
+		return
+	}
+
+	result, err := gh.tutorialService.ProcessMove(sessionToken, request.Direction)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetTutorialState returns the current tutorial step
+func (gh *GameHandler) GetTutorialState(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionToken, ok := session.Get("tutorial_session_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "No active tutorial session",
+		})
+		return
+	}
+
+	result, err := gh.tutorialService.GetState(sessionToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
-// PlayOnline placeholder for online mode
+// PlayOnline joins the online race lobby and stores the race's game session token
 func (gh *GameHandler) PlayOnline(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"success": false,
-		"message": "Online mode not implemented yet",
-	})
-}
\ No newline at end of file
+	var request struct {
+		Character string `json:"character"`
+		Mode      string `json:"mode"`
+	}
+	// The body is optional, the default character races without it
+	_ = c.ShouldBindJSON(&request)
+
+	result, err := gh.matchService.JoinLobby(sessionPlayerID(c), request.Character, request.Mode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusUnauthorized, result)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("game_session_token", result["session_token"])
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to save session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetReplay returns the seed, text and timed keys of a finished game
+func (gh *GameHandler) GetReplay(c *gin.Context) {
+	gameID, ok := gameParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := gh.gameService.GetReplay(gameID)
+	gh.replayResponse(c, result, err)
+}
+
+// GetReplayFrames returns a finished game's state after each of its moves
+func (gh *GameHandler) GetReplayFrames(c *gin.Context) {
+	gameID, ok := gameParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := gh.gameService.GetReplayFrames(gameID)
+	gh.replayResponse(c, result, err)
+}
+
+// replayResponse writes the result of a replay request
+func (gh *GameHandler) replayResponse(c *gin.Context, result map[string]interface{}, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusNotFound, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// gameParam parses the game id of the route, writing the error when it is invalid
+func gameParam(c *gin.Context) (uint, bool) {
+	gameID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid game id",
+		})
+		return 0, false
+	}
+	return uint(gameID), true
+}
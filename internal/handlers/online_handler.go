@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"boba-vim/internal/realtime"
+	"boba-vim/internal/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type OnlineHandler struct {
+	matchService *services.MatchService
+	hub          *realtime.Hub
+}
+
+func NewOnlineHandler(hub *realtime.Hub, matchService *services.MatchService) *OnlineHandler {
+	return &OnlineHandler{
+		matchService: matchService,
+		hub:          hub,
+	}
+}
+
+// GetMatch returns the state of a match
+func (oh *OnlineHandler) GetMatch(c *gin.Context) {
+	result, err := oh.matchService.GetMatch(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusNotFound, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// LeaveLobby gives up the player's seat in a race that has not started
+func (oh *OnlineHandler) LeaveLobby(c *gin.Context) {
+	result, err := oh.matchService.LeaveLobby(sessionPlayerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RatingHistory returns the player's online rating and its recent changes
+func (oh *OnlineHandler) RatingHistory(c *gin.Context) {
+	result, err := oh.matchService.RatingHistory(sessionPlayerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusUnauthorized, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// TextPatterns lists the texts a private room can be played on
+func (oh *OnlineHandler) TextPatterns(c *gin.Context) {
+	c.JSON(http.StatusOK, oh.matchService.TextPatterns())
+}
+
+// CreateRoom opens a private room with the host's rules and stores the host's session token
+func (oh *OnlineHandler) CreateRoom(c *gin.Context) {
+	var request struct {
+		Character      string   `json:"character"`
+		Mode           string   `json:"mode"`
+		TextPattern    int      `json:"text_pattern"`
+		PearlPoints    int      `json:"pearl_points"`
+		TargetScore    int      `json:"target_score"`
+		TimeLimit      int      `json:"time_limit"`
+		AllowedMotions []string `json:"allowed_motions"`
+		MaxPlayers     int      `json:"max_players"`
+	}
+	// Every rule is optional, an empty body opens a race room with the default rules
+	_ = c.ShouldBindJSON(&request)
+
+	result, err := oh.matchService.CreateRoom(sessionPlayerID(c), request.Character, services.RoomOptions{
+		Mode:           request.Mode,
+		TextPattern:    request.TextPattern,
+		PearlPoints:    request.PearlPoints,
+		TargetScore:    request.TargetScore,
+		TimeLimit:      request.TimeLimit,
+		AllowedMotions: request.AllowedMotions,
+		MaxPlayers:     request.MaxPlayers,
+	})
+	oh.respondSeat(c, result, err)
+}
+
+// JoinRoom seats the player in the private room with the code and stores their session token
+func (oh *OnlineHandler) JoinRoom(c *gin.Context) {
+	var request struct {
+		Code      string `json:"code" binding:"required"`
+		Character string `json:"character"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	result, err := oh.matchService.JoinRoom(sessionPlayerID(c), request.Character, request.Code)
+	oh.respondSeat(c, result, err)
+}
+
+// StartRoom starts the countdown of the host's private room
+func (oh *OnlineHandler) StartRoom(c *gin.Context) {
+	result, err := oh.matchService.StartRoom(sessionPlayerID(c), c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondSeat writes the result of taking a seat in a match, storing the game's session token
+func (oh *OnlineHandler) respondSeat(c *gin.Context, result map[string]interface{}, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("game_session_token", result["session_token"])
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to save session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MatchSocket streams a match's events to one of its players over a websocket
+func (oh *OnlineHandler) MatchSocket(c *gin.Context) {
+	session := sessions.Default(c)
+	playerID, ok := session.Get("user_id").(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Log in to play online",
+		})
+		return
+	}
+
+	client, err := oh.hub.Upgrade(c.Writer, c.Request, playerID)
+	if err != nil {
+		// The upgrader already wrote the HTTP error
+		return
+	}
+
+	matchToken := c.Param("token")
+	if err := oh.matchService.Subscribe(client, matchToken); err != nil {
+		client.Reject(err.Error())
+		return
+	}
+	client.Run(func(client *realtime.Client, msg realtime.IncomingMessage) {
+		oh.matchService.HandleMessage(client, matchToken, msg)
+	})
+}
+
+// GameSocket plays the session's current game over a websocket: moves come in
+// as messages and only the changes they make are pushed back
+func (oh *OnlineHandler) GameSocket(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionToken, ok := session.Get("game_session_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "No active game session",
+		})
+		return
+	}
+
+	// Guests play with player id 0
+	playerID, _ := session.Get("user_id").(uint)
+	client, err := oh.hub.Upgrade(c.Writer, c.Request, playerID)
+	if err != nil {
+		return
+	}
+
+	stream := services.NewGameStream(oh.matchService, sessionToken)
+	defer stream.Close()
+	if err := stream.SendState(client); err != nil {
+		client.Reject(err.Error())
+		return
+	}
+	oh.hub.Join(services.GameRoom(sessionToken), client)
+	client.Run(stream.HandleMessage)
+}
+
+// LiveGames lists the games and matches that can be watched
+func (oh *OnlineHandler) LiveGames(c *gin.Context) {
+	result, err := oh.matchService.LiveGames()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SpectateGameSocket streams a game to a read-only spectator
+func (oh *OnlineHandler) SpectateGameSocket(c *gin.Context) {
+	gameID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid game id",
+		})
+		return
+	}
+
+	oh.spectate(c, func(client *realtime.Client) error {
+		return oh.matchService.SpectateGame(client, uint(gameID))
+	})
+}
+
+// SpectateMatchSocket streams a match to a read-only spectator
+func (oh *OnlineHandler) SpectateMatchSocket(c *gin.Context) {
+	oh.spectate(c, func(client *realtime.Client) error {
+		return oh.matchService.SpectateMatch(client, c.Param("token"))
+	})
+}
+
+// spectate upgrades the request and runs a spectator connection, anyone can watch
+func (oh *OnlineHandler) spectate(c *gin.Context, subscribe func(client *realtime.Client) error) {
+	client, err := oh.hub.Upgrade(c.Writer, c.Request, 0)
+	if err != nil {
+		return
+	}
+
+	if err := subscribe(client); err != nil {
+		client.Reject(err.Error())
+		return
+	}
+	client.Run(oh.matchService.HandleSpectatorMessage)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"boba-vim/internal/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type TournamentHandler struct {
+	tournamentService *services.TournamentService
+}
+
+func NewTournamentHandler(tournamentService *services.TournamentService) *TournamentHandler {
+	return &TournamentHandler{
+		tournamentService: tournamentService,
+	}
+}
+
+// ListTournaments returns the open, running and recently finished tournaments
+func (th *TournamentHandler) ListTournaments(c *gin.Context) {
+	result, err := th.tournamentService.ListTournaments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateTournament opens the registration of a tournament organized by the current player
+func (th *TournamentHandler) CreateTournament(c *gin.Context) {
+	var request struct {
+		Name       string     `json:"name"`
+		Format     string     `json:"format"`
+		MaxPlayers int        `json:"max_players"`
+		Rounds     int        `json:"rounds"`
+		StartsAt   *time.Time `json:"starts_at"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	result, err := th.tournamentService.CreateTournament(sessionPlayerID(c), services.TournamentOptions{
+		Name:       request.Name,
+		Format:     request.Format,
+		MaxPlayers: request.MaxPlayers,
+		Rounds:     request.Rounds,
+		StartsAt:   request.StartsAt,
+	})
+	th.respond(c, result, err)
+}
+
+// GetBracket returns the standings and rounds of a tournament
+func (th *TournamentHandler) GetBracket(c *gin.Context) {
+	tournamentID, ok := tournamentParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := th.tournamentService.GetBracket(tournamentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusNotFound, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Register signs the current player up for a tournament
+func (th *TournamentHandler) Register(c *gin.Context) {
+	tournamentID, ok := tournamentParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := th.tournamentService.Register(sessionPlayerID(c), tournamentID)
+	th.respond(c, result, err)
+}
+
+// Withdraw takes the current player off a tournament that has not started
+func (th *TournamentHandler) Withdraw(c *gin.Context) {
+	tournamentID, ok := tournamentParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := th.tournamentService.Withdraw(sessionPlayerID(c), tournamentID)
+	th.respond(c, result, err)
+}
+
+// Start plays the first round of a tournament before its scheduled start
+func (th *TournamentHandler) Start(c *gin.Context) {
+	tournamentID, ok := tournamentParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := th.tournamentService.Start(sessionPlayerID(c), tournamentID)
+	th.respond(c, result, err)
+}
+
+// Play seats the current player in their match of the round and stores its session token
+func (th *TournamentHandler) Play(c *gin.Context) {
+	tournamentID, ok := tournamentParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Character string `json:"character"`
+	}
+	_ = c.ShouldBindJSON(&request)
+
+	result, err := th.tournamentService.Play(sessionPlayerID(c), request.Character, tournamentID)
+	if err != nil || !result["success"].(bool) {
+		th.respond(c, result, err)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("game_session_token", result["session_token"])
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to save session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respond writes a service result, failures the player can fix are bad requests
+func (th *TournamentHandler) respond(c *gin.Context, result map[string]interface{}, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// tournamentParam parses the tournament id of the route, writing the error when it is invalid
+func tournamentParam(c *gin.Context) (uint, bool) {
+	tournamentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid tournament id",
+		})
+		return 0, false
+	}
+	return uint(tournamentID), true
+}
@@ -2,23 +2,28 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 	"boba-vim/internal/config"
+	"boba-vim/internal/models"
 	"boba-vim/internal/services"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type WebHandler struct {
-	gameService *services.GameService
-	cfg         *config.Config
+	gameService     *services.GameService
+	campaignService *services.CampaignService
+	matchService    *services.MatchService
+	cfg             *config.Config
 }
 
-func NewWebHandler(db *gorm.DB) *WebHandler {
-	cfg := config.Load()
+func NewWebHandler(cfg *config.Config, gameService *services.GameService, campaignService *services.CampaignService, matchService *services.MatchService) *WebHandler {
 	return &WebHandler{
-		gameService: services.NewGameService(db, cfg),
-		cfg:         cfg,
+		gameService:     gameService,
+		campaignService: campaignService,
+		matchService:    matchService,
+		cfg:             cfg,
 	}
 }
 
@@ -39,9 +44,32 @@ func (wh *WebHandler) PlayGame(c *gin.Context) {
 
 	// Get selected character from query parameter, default to "boba"
 	selectedCharacter := c.DefaultQuery("character", "boba")
+	levelName := c.Query("level")
 
-	// Create new game
-	result, err := wh.gameService.CreateNewGame(username.(string), selectedCharacter)
+	// Create new game, on a campaign level when one is requested
+	playerID := sessionPlayerID(c)
+	var result map[string]interface{}
+	var err error
+	matchToken := c.Query("match")
+	if matchToken != "" {
+		// Online races resume the seat taken in the lobby
+		result, err = wh.matchService.ResumeMatch(playerID, matchToken)
+	} else if c.Query("mode") == models.GameModeTimeAttack {
+		result, err = wh.matchService.StartTimeAttack(playerID, selectedCharacter)
+	} else if c.Query("mode") == models.GameModeSurvival {
+		result, err = wh.matchService.StartSurvival(playerID, selectedCharacter)
+	} else if campaignLevel := c.Query("campaign"); campaignLevel != "" {
+		levelID, parseErr := strconv.ParseUint(campaignLevel, 10, 64)
+		if parseErr != nil {
+			wh.NotFound(c)
+			return
+		}
+		result, err = wh.campaignService.StartLevel(playerID, selectedCharacter, uint(levelID))
+	} else if c.Query("ghost") == "1" {
+		result, err = wh.gameService.CreateGhostGame(playerID, selectedCharacter, levelName)
+	} else {
+		result, err = wh.gameService.CreateNewGame(playerID, selectedCharacter, levelName)
+	}
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "500_go.html", gin.H{
 			"error": "Failed to initialize game: " + err.Error(),
@@ -50,8 +78,12 @@ func (wh *WebHandler) PlayGame(c *gin.Context) {
 	}
 
 	if !result["success"].(bool) {
+		errorMessage := "Failed to create game session"
+		if message, ok := result["error"].(string); ok {
+			errorMessage = message
+		}
 		c.HTML(http.StatusInternalServerError, "500_go.html", gin.H{
-			"error": "Failed to create game session",
+			"error": errorMessage,
 		})
 		return
 	}
@@ -67,6 +99,11 @@ func (wh *WebHandler) PlayGame(c *gin.Context) {
 	}
 
 	gameData := result["game_data"].(map[string]interface{})
+	endsAt := ""
+	if t, ok := gameData["ends_at"].(time.Time); ok {
+		endsAt = t.Format(time.RFC3339Nano)
+	}
+	_, ghostRace := gameData["ghost_session_id"]
 
 	c.HTML(http.StatusOK, "game_go.html", gin.H{
 		"title":              "Boba.vim - Game",
@@ -74,6 +111,86 @@ func (wh *WebHandler) PlayGame(c *gin.Context) {
 		"game_map":           gameData["game_map"],
 		"score":              gameData["score"],
 		"selected_character": gameData["selected_character"],
+		"match_token":        matchToken,
+		"mode":               gameData["mode"],
+		"ends_at":            endsAt,
+		"lives":              gameData["lives"],
+		"ghost_race":         ghostRace,
+	})
+}
+
+// SpectateGame serves the read-only page of a game in progress
+func (wh *WebHandler) SpectateGame(c *gin.Context) {
+	gameID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		wh.NotFound(c)
+		return
+	}
+
+	result, err := wh.matchService.SpectatePage(uint(gameID), "")
+	wh.renderSpectatePage(c, result, err, "/ws/spectate/game/"+c.Param("id"))
+}
+
+// SpectateMatch serves the read-only page of a live match
+func (wh *WebHandler) SpectateMatch(c *gin.Context) {
+	result, err := wh.matchService.SpectatePage(0, c.Param("token"))
+	wh.renderSpectatePage(c, result, err, "/ws/spectate/match/"+c.Param("token"))
+}
+
+// renderSpectatePage renders the game page without controls, fed by the spectator socket
+func (wh *WebHandler) renderSpectatePage(c *gin.Context, result map[string]interface{}, err error, socketPath string) {
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "500_go.html", gin.H{
+			"error": "Failed to load game: " + err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		wh.NotFound(c)
+		return
+	}
+
+	gameData := result["game_data"].(map[string]interface{})
+	c.HTML(http.StatusOK, "game_go.html", gin.H{
+		"title":              "Boba.vim - Spectating",
+		"text_grid":          gameData["text_grid"],
+		"game_map":           gameData["game_map"],
+		"score":              0,
+		"selected_character": gameData["selected_character"],
+		"spectate_socket":    socketPath,
+	})
+}
+
+// ReplayGame serves the read-only page replaying a finished game
+func (wh *WebHandler) ReplayGame(c *gin.Context) {
+	gameID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		wh.NotFound(c)
+		return
+	}
+
+	result, err := wh.gameService.ReplayPage(uint(gameID))
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "500_go.html", gin.H{
+			"error": "Failed to load replay: " + err.Error(),
+		})
+		return
+	}
+	if !result["success"].(bool) {
+		wh.NotFound(c)
+		return
+	}
+
+	gameData := result["game_data"].(map[string]interface{})
+	c.HTML(http.StatusOK, "game_go.html", gin.H{
+		"title":              "Boba.vim - Replay",
+		"text_grid":          gameData["text_grid"],
+		"game_map":           gameData["game_map"],
+		"score":              0,
+		"selected_character": gameData["selected_character"],
+		"mode":               gameData["mode"],
+		"lives":              gameData["lives"],
+		"replay_frames":      "/api/replays/" + c.Param("id") + "/frames",
 	})
 }
 
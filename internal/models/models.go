@@ -2,9 +2,11 @@ package models
 
 import (
 	"encoding/json"
-	"sync"
+	"math/rand"
 	"time"
 
+	"boba-vim/internal/game"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -25,8 +27,15 @@ type Player struct {
 	TotalPearls      int `json:"total_pearls"`
 	TotalMoves       int `json:"total_moves"`
 	FastestTime      *int `json:"fastest_time"`
+
+	// Online skill rating, provisional until RATING_PROVISIONAL_MATCHES rated matches
+	Rating       int `gorm:"default:1200" json:"rating"`
+	RatedMatches int `json:"rated_matches"`
 }
 
+// GameSession is one player's game. It is not safe for concurrent use: every
+// request loads its own copy, and the shared map of an arena match is only
+// changed by the match's room.
 type GameSession struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
 	SessionToken  string    `gorm:"unique;not null" json:"session_token"`
@@ -34,48 +43,449 @@ type GameSession struct {
 	Player        Player    `gorm:"foreignKey:PlayerID" json:"player,omitempty"`
 	SelectedCharacter string `gorm:"default:boba" json:"selected_character"`
 	
-	// Game state with mutex for concurrent access
-	gameMapMutex  sync.RWMutex `gorm:"-" json:"-"`
-	GameMapJSON   string       `json:"-"`
-	gameMap       [][]int      `gorm:"-" json:"game_map"`
-	
+	// Per-session rules, zero values fall back to the global config
+	Mode            string `gorm:"default:classic;index" json:"mode"`
+	CampaignLevelID *uint  `json:"campaign_level_id"`
+	PearlStrategy   string `gorm:"default:mixed" json:"pearl_strategy"`
+	TargetScore     int    `json:"target_score"`
+	TimeLimit       int    `json:"time_limit"` // seconds
+	PearlPoints     int    `json:"pearl_points"`
+	AllowedMotions  string `json:"-"` // comma-separated movement keys, empty allows all
+	BlockedMotions  string `json:"-"` // comma-separated movement keys
+
+	// Seed of the text pattern and pearl sequence, kept secret so pearls can't be predicted
+	Seed           int64  `json:"-"`
+	TextID         string `json:"-"` // text the session is played on, "pattern:2", "level:maze" or "campaign:3"
+	PearlSpawns    int    `json:"-"` // pearls placed so far, picks the next pearl's random source
+	MatchID        *uint  `gorm:"index" json:"match_id"`
+	GhostSessionID *uint  `json:"ghost_session_id"` // the player's best run raced as a ghost, on the same seed
+
+	// Game state
+	GameMapJSON string  `json:"-"`
+	gameMap     [][]int `gorm:"-"`
+
 	// Text grid for movement calculations
-	textGridMutex sync.RWMutex  `gorm:"-" json:"-"`
-	TextGridJSON  string        `json:"-"`
-	textGrid      [][]string    `gorm:"-" json:"text_grid"`
-	
+	TextGridJSON string     `json:"-"`
+	textGrid     [][]string `gorm:"-"`
+
+	// Expiry timers for golden pearls
+	PearlTimersJSON string       `json:"-"`
+	pearlTimers     []PearlTimer `gorm:"-"`
+
+	// Enemy bobas chasing the player
+	EnemiesJSON string       `json:"-"`
+	enemies     []game.Enemy `gorm:"-"`
+
 	// Position and game state
 	CurrentRow      int  `json:"current_row"`
 	CurrentCol      int  `json:"current_col"`
 	PreferredColumn int  `json:"preferred_column"`
 	CurrentScore    int  `json:"current_score"`
 	FinalScore      *int `json:"final_score"`
+	Lives           int  `json:"lives"` // survival only, the game ends when they run out
 	
-	// Move tracking with mutex
-	moveMutex     sync.Mutex `gorm:"-" json:"-"`
-	TotalMoves    int        `json:"total_moves"`
-	PearlsCollected int      `json:"pearls_collected"`
-	LastMoveTime  *time.Time `json:"last_move_time"`
-	
+	// Move tracking
+	TotalMoves            int        `json:"total_moves"`
+	PearlsCollected       int        `json:"pearls_collected"`
+	GoldenPearlsCollected int        `json:"golden_pearls_collected"`
+	BlackPearlsCollected  int        `json:"black_pearls_collected"`
+	LastMoveTime          *time.Time `json:"last_move_time"`
+
 	// Game status
-	IsActive    bool       `json:"is_active"`
-	IsCompleted bool       `json:"is_completed"`
-	StartTime   *time.Time `json:"start_time"`
-	EndTime     *time.Time `json:"end_time"`
-	CompletionTime *int    `json:"completion_time"` // seconds
-	
+	IsActive       bool       `json:"is_active"`
+	IsCompleted    bool       `json:"is_completed"`
+	StartTime      *time.Time `json:"start_time"`
+	EndTime        *time.Time `json:"end_time"`
+	CompletionTime *int       `json:"completion_time"` // seconds
+	EndReason      string     `json:"end_reason"`
+
+	// Bumped by every saved move, a move saved against an older version lost a race and is rejected
+	Version int `gorm:"not null;default:0" json:"-"`
+
+	// Leaderboard check, a finished game is re-run from its seed and moves before it is listed
+	ReplayStatus string `gorm:"default:pending;index" json:"replay_status"`
+	ReplayIssue  string `json:"-"` // why the re-run didn't reproduce the game
+
+	// Anti-cheat, suspicious verified games are queued for an admin to review
+	CheatScore   int        `json:"-"`
+	CheatFlags   string     `json:"-"` // comma separated heuristics the game tripped
+	ReviewStatus string     `gorm:"default:clean;index" json:"-"`
+	ReviewedBy   *uint      `json:"-"`
+	ReviewedAt   *time.Time `json:"-"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// GameMove is one accepted move of a game session, logged in the same
+// transaction that applied it
+type GameMove struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	GameSessionID uint      `gorm:"uniqueIndex:idx_game_move;not null" json:"game_session_id"`
+	Sequence      int       `gorm:"uniqueIndex:idx_game_move;not null" json:"sequence"` // 1 for the first move
+	Key           string    `gorm:"not null" json:"key"`                                // movement key, "f" for "find_char_forward_x"
+	Direction     string    `gorm:"not null" json:"direction"`                          // direction as requested
+	FromRow       int       `json:"from_row"`
+	FromCol       int       `json:"from_col"`
+	ToRow         int       `json:"to_row"`
+	ToCol         int       `json:"to_col"`
+	PearlType     int       `json:"pearl_type"` // map value of the pearl picked up, 0 for none
+	Points        int       `json:"points"`
+	LatencyMicros int64     `json:"latency_us"` // server time from receiving the move to logging it
+	PlayedAt      time.Time `gorm:"not null" json:"played_at"`
+}
+
+// CampaignLevel is one ordered stage of the campaign
+type CampaignLevel struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Position       int       `gorm:"index;not null" json:"position"`
+	Name           string    `gorm:"uniqueIndex;not null" json:"name"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	Text           string    `gorm:"not null" json:"-"`
+	Walls          string    `json:"-"`
+	EnemiesJSON    string    `json:"-"`
+	AllowedMotions string    `json:"-"` // comma-separated movement keys, empty allows all
+	BlockedMotions string    `json:"-"` // comma-separated movement keys
+	PearlStrategy  string    `gorm:"default:mixed" json:"pearl_strategy"`
+	TargetScore    int       `json:"target_score"`
+	TimeLimit      int       `json:"time_limit"`                           // seconds
+	IsActive       bool      `gorm:"not null;default:true;index" json:"-"` // false once the stage is gone from the campaign file
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// LevelProgress records a player's progress on one campaign level
+type LevelProgress struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	PlayerID        uint       `gorm:"uniqueIndex:idx_player_level;not null" json:"player_id"`
+	CampaignLevelID uint       `gorm:"uniqueIndex:idx_player_level;not null" json:"campaign_level_id"`
+	IsUnlocked      bool       `json:"is_unlocked"`
+	IsCompleted     bool       `json:"is_completed"`
+	Attempts        int        `json:"attempts"`
+	BestScore       int        `json:"best_score"`
+	BestTime        *int       `json:"best_time"` // seconds
+	CompletedAt     *time.Time `json:"completed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TutorialSession tracks a player working through one tutorial lesson
+type TutorialSession struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	SessionToken    string     `gorm:"unique;not null" json:"session_token"`
+	PlayerID        uint       `json:"player_id"` // 0 for anonymous players
+	LessonID        string     `gorm:"not null" json:"lesson_id"`
+	StepIndex       int        `json:"step_index"`
+	CurrentRow      int        `json:"current_row"`
+	CurrentCol      int        `json:"current_col"`
+	PreferredColumn int        `json:"preferred_column"`
+	StepMoves       int        `json:"step_moves"`
+	StepKeystrokes  int        `json:"step_keystrokes"`
+	TotalMoves      int        `json:"total_moves"`
+	IsActive        bool       `json:"is_active"`
+	IsCompleted     bool       `json:"is_completed"`
+	StartTime       *time.Time `json:"start_time"`
+	EndTime         *time.Time `json:"end_time"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
 // BeforeCreate sets session token and start time
+func (ts *TutorialSession) BeforeCreate(tx *gorm.DB) error {
+	ts.SessionToken = uuid.New().String()
+	now := time.Now()
+	ts.StartTime = &now
+	return nil
+}
+
+// TutorialProgress records how far a player got in a tutorial lesson
+type TutorialProgress struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	PlayerID       uint       `gorm:"uniqueIndex:idx_player_lesson;not null" json:"player_id"`
+	LessonID       string     `gorm:"uniqueIndex:idx_player_lesson;not null" json:"lesson_id"`
+	CompletedSteps int        `json:"completed_steps"`
+	IsCompleted    bool       `json:"is_completed"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Match is an online game where several players race on copies of the same
+// seeded map, or play on one shared map in arena and coop modes
+type Match struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	MatchToken    string     `gorm:"unique;not null" json:"match_token"`
+	Mode          string     `gorm:"not null" json:"mode"`
+	Status        string     `gorm:"index;not null" json:"status"`
+	Seed          int64      `json:"-"`
+	PearlStrategy string     `gorm:"default:mixed" json:"pearl_strategy"`
+	TargetScore   int        `json:"target_score"`
+	TimeLimit     int        `json:"time_limit"` // seconds
+	MinPlayers    int        `json:"min_players"`
+	MaxPlayers    int        `json:"max_players"`
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	WinnerID      *uint      `json:"winner_id"`
+	BoardJSON     string     `json:"-"`                          // shared map of arena and coop matches
+	TournamentID  *uint      `gorm:"index" json:"tournament_id"` // tournament matches are not open to the lobby
+
+	// Private rooms are joined by code and started by their host, with their own rules
+	HostID         *uint         `json:"host_id"`
+	JoinCode       string        `gorm:"index" json:"join_code,omitempty"`
+	TextPattern    int           `json:"text_pattern"` // 0 lets the seed pick the text
+	PearlPoints    int           `json:"pearl_points"`
+	AllowedMotions string        `json:"allowed_motions"` // comma-separated movement keys, empty allows all
+	Players        []MatchPlayer `gorm:"foreignKey:MatchID" json:"players,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// MatchPlayer is one player's entry and result in a match
+type MatchPlayer struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	MatchID         uint      `gorm:"uniqueIndex:idx_match_player;not null" json:"match_id"`
+	PlayerID        uint      `gorm:"uniqueIndex:idx_match_player;not null" json:"player_id"`
+	Username        string    `json:"username"`
+	GameSessionID   uint      `json:"-"`
+	SessionToken    string    `gorm:"index" json:"-"`
+	Score           int       `json:"score"`
+	PearlsCollected int       `json:"pearls_collected"`
+	TotalMoves      int       `json:"total_moves"`
+	CurrentRow      int       `json:"current_row"`
+	CurrentCol      int       `json:"current_col"`
+	IsFinished      bool      `json:"is_finished"`
+	EndReason       string    `json:"end_reason"`
+	FinishTime      *float64  `json:"finish_time"` // seconds from the match start to reaching the target
+	Rank            int       `json:"rank"`
+	Rating          int       `json:"rating"`        // player's rating when joining
+	RatingChange    *int      `json:"rating_change"` // set once a rated match finished
+	Muted           bool      `json:"muted"`         // muted by the host of a private room
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ChatMessage is a message sent to the players of a match
+type ChatMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MatchID   uint      `gorm:"index;not null" json:"-"`
+	PlayerID  uint      `json:"player_id"`
+	Username  string    `json:"username"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RoomBan keeps a player kicked by the host out of a private room
+type RoomBan struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MatchID   uint      `gorm:"uniqueIndex:idx_room_ban;not null" json:"match_id"`
+	PlayerID  uint      `gorm:"uniqueIndex:idx_room_ban;not null" json:"player_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Challenge is a vimgolf challenge: turn the start text into the target text in as few keystrokes as possible
+type Challenge struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Position    int       `gorm:"index;not null" json:"position"`
+	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	StartText   string    `gorm:"not null" json:"start_text"`
+	TargetText  string    `gorm:"not null" json:"target_text"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ChallengeSubmission is one verified solution of a challenge
+type ChallengeSubmission struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ChallengeID uint      `gorm:"index;not null" json:"challenge_id"`
+	PlayerID    uint      `gorm:"index;not null" json:"player_id"`
+	Username    string    `gorm:"not null" json:"username"`
+	Keys        string    `gorm:"not null" json:"keys"` // vim key notation
+	Keystrokes  int       `gorm:"not null" json:"keystrokes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Match modes
+const (
+	MatchModeRace  = "race"  // every player on their own copy of the same seeded map
+	MatchModeArena = "arena" // every player on one shared map, the first to reach a pearl takes it
+	MatchModeCoop  = "coop"  // two players on one shared map, scoring target pairs together
+)
+
+// Match statuses
+const (
+	MatchStatusWaiting   = "waiting"   // lobby open, not enough players yet
+	MatchStatusCountdown = "countdown" // enough players, start time scheduled
+	MatchStatusRunning   = "running"
+	MatchStatusFinished  = "finished"
+	MatchStatusAbandoned = "abandoned" // interrupted, e.g. by a server restart
+)
+
+// SharesMap reports whether the players of the match move on one shared map
+func (m *Match) SharesMap() bool {
+	return m.Mode == MatchModeArena || m.Mode == MatchModeCoop
+}
+
+// IsPrivate reports whether the match is a private room
+func (m *Match) IsPrivate() bool {
+	return m.HostID != nil
+}
+
+// IsRated reports whether the match result changes the players' ratings. Coop
+// partners don't play against each other and private rooms have their own rules.
+func (m *Match) IsRated() bool {
+	return m.Mode != MatchModeCoop && !m.IsPrivate()
+}
+
+// RatingChange is one entry of a player's rating history, written when a rated match finishes
+type RatingChange struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PlayerID    uint      `gorm:"index;not null" json:"player_id"`
+	MatchID     uint      `gorm:"index;not null" json:"match_id"`
+	OldRating   int       `json:"old_rating"`
+	NewRating   int       `json:"new_rating"`
+	Rank        int       `json:"rank"`
+	Opponents   int       `json:"opponents"`
+	Provisional bool      `json:"provisional"` // the player was still in their provisional period
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BeforeCreate sets the match token
+func (m *Match) BeforeCreate(tx *gorm.DB) error {
+	m.MatchToken = uuid.New().String()
+	return nil
+}
+
+// Tournament is a bracket of online races. Players register until it starts,
+// then every round pairs them into seeded two-player matches.
+type Tournament struct {
+	ID           uint                `gorm:"primaryKey" json:"id"`
+	Name         string              `gorm:"not null" json:"name"`
+	Format       string              `gorm:"not null" json:"format"`
+	Status       string              `gorm:"index;not null" json:"status"`
+	OrganizerID  uint                `json:"organizer_id"`
+	MaxPlayers   int                 `json:"max_players"`
+	Rounds       int                 `json:"rounds"` // planned rounds of a swiss tournament
+	CurrentRound int                 `json:"current_round"`
+	WinnersRound int                 `json:"-"`         // rounds played by the winners bracket of a double elimination
+	StartsAt     *time.Time          `json:"starts_at"` // scheduled start, nil when the organizer starts it
+	NextRoundAt  *time.Time          `json:"next_round_at"`
+	FinishedAt   *time.Time          `json:"finished_at"`
+	WinnerID     *uint               `json:"winner_id"`
+	Entries      []TournamentEntry   `gorm:"foreignKey:TournamentID" json:"entries,omitempty"`
+	Pairings     []TournamentPairing `gorm:"foreignKey:TournamentID" json:"pairings,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+}
+
+// TournamentEntry is one player's registration and standing in a tournament
+type TournamentEntry struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	TournamentID    uint      `gorm:"uniqueIndex:idx_tournament_player;not null" json:"tournament_id"`
+	PlayerID        uint      `gorm:"uniqueIndex:idx_tournament_player;not null" json:"player_id"`
+	Username        string    `json:"username"`
+	Rating          int       `json:"rating"`
+	Seed            int       `json:"seed"` // 1 is the best rated player
+	Slot            int       `json:"-"`    // position in the elimination bracket
+	Wins            int       `json:"wins"`
+	Losses          int       `json:"losses"`
+	Points          int       `json:"points"` // swiss points, a win or a bye is worth one
+	HadBye          bool      `json:"had_bye"`
+	EliminatedRound int       `json:"eliminated_round"` // 0 while still in the tournament
+	Rank            int       `json:"rank"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TournamentPairing is one game of a tournament round, a bye when Player2ID is nil
+type TournamentPairing struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	TournamentID uint      `gorm:"index;not null" json:"tournament_id"`
+	Round        int       `json:"round"`
+	Bracket      string    `json:"bracket"`
+	Player1ID    uint      `json:"player1_id"`
+	Player2ID    *uint     `json:"player2_id"`
+	MatchID      *uint     `gorm:"index" json:"match_id"`
+	WinnerID     *uint     `json:"winner_id"`
+	Status       string    `json:"status"`
+	Forfeit      bool      `json:"forfeit"` // decided without the match being played
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Tournament formats
+const (
+	TournamentFormatSingle = "single_elimination"
+	TournamentFormatDouble = "double_elimination"
+	TournamentFormatSwiss  = "swiss"
+)
+
+// Tournament statuses
+const (
+	TournamentStatusRegistration = "registration"
+	TournamentStatusRunning      = "running"
+	TournamentStatusFinished     = "finished"
+	TournamentStatusCancelled    = "cancelled" // not enough players when it was due to start
+)
+
+// Tournament brackets a pairing belongs to
+const (
+	BracketWinners = "winners"
+	BracketLosers  = "losers" // double elimination players with one loss
+	BracketFinal   = "final"
+	BracketSwiss   = "swiss"
+)
+
+// Tournament pairing statuses
+const (
+	PairingStatusPlaying  = "playing"
+	PairingStatusFinished = "finished"
+	PairingStatusBye      = "bye"
+)
+
+// Opponent returns the other player of a pairing, 0 for a bye
+func (p *TournamentPairing) Opponent(playerID uint) uint {
+	if p.Player2ID == nil {
+		return 0
+	}
+	if p.Player1ID == playerID {
+		return *p.Player2ID
+	}
+	return p.Player1ID
+}
+
+// PearlTimer tracks when a timed pearl disappears from the map
+type PearlTimer struct {
+	Row       int       `json:"row"`
+	Col       int       `json:"col"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// BeforeCreate sets session token and start time, match sessions get
+// their start time from the match clock instead
 func (gs *GameSession) BeforeCreate(tx *gorm.DB) error {
 	gs.SessionToken = uuid.New().String()
-	now := time.Now()
-	gs.StartTime = &now
+	if gs.StartTime == nil && gs.MatchID == nil {
+		now := time.Now()
+		gs.StartTime = &now
+	}
 	return nil
 }
 
+// NextPearlRNG returns the random source of the next pearl to place
+func (gs *GameSession) NextPearlRNG() *rand.Rand {
+	rng := game.PearlRNG(gs.Seed, gs.PearlSpawns)
+	gs.PearlSpawns++
+	return rng
+}
+
+// HasStarted reports whether the session's clock is running
+func (gs *GameSession) HasStarted(now time.Time) bool {
+	return gs.StartTime != nil && !now.Before(*gs.StartTime)
+}
+
 // AfterFind loads game map and text grid from JSON
 func (gs *GameSession) AfterFind(tx *gorm.DB) error {
 	if gs.GameMapJSON != "" {
@@ -88,14 +498,21 @@ func (gs *GameSession) AfterFind(tx *gorm.DB) error {
 			return err
 		}
 	}
+	if gs.PearlTimersJSON != "" {
+		if err := json.Unmarshal([]byte(gs.PearlTimersJSON), &gs.pearlTimers); err != nil {
+			return err
+		}
+	}
+	if gs.EnemiesJSON != "" {
+		if err := json.Unmarshal([]byte(gs.EnemiesJSON), &gs.enemies); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // BeforeSave saves game map and text grid to JSON
 func (gs *GameSession) BeforeSave(tx *gorm.DB) error {
-	gs.gameMapMutex.RLock()
-	defer gs.gameMapMutex.RUnlock()
-	
 	if gs.gameMap != nil {
 		mapJSON, err := json.Marshal(gs.gameMap)
 		if err != nil {
@@ -104,9 +521,22 @@ func (gs *GameSession) BeforeSave(tx *gorm.DB) error {
 		gs.GameMapJSON = string(mapJSON)
 	}
 	
-	gs.textGridMutex.RLock()
-	defer gs.textGridMutex.RUnlock()
-	
+	if gs.pearlTimers != nil {
+		timersJSON, err := json.Marshal(gs.pearlTimers)
+		if err != nil {
+			return err
+		}
+		gs.PearlTimersJSON = string(timersJSON)
+	}
+
+	if gs.enemies != nil {
+		enemiesJSON, err := json.Marshal(gs.enemies)
+		if err != nil {
+			return err
+		}
+		gs.EnemiesJSON = string(enemiesJSON)
+	}
+
 	if gs.textGrid != nil {
 		textJSON, err := json.Marshal(gs.textGrid)
 		if err != nil {
@@ -117,11 +547,8 @@ func (gs *GameSession) BeforeSave(tx *gorm.DB) error {
 	return nil
 }
 
-// GetGameMap returns a copy of the game map safely
+// GetGameMap returns a copy of the game map
 func (gs *GameSession) GetGameMap() [][]int {
-	gs.gameMapMutex.RLock()
-	defer gs.gameMapMutex.RUnlock()
-	
 	if gs.gameMap == nil {
 		return nil
 	}
@@ -135,11 +562,8 @@ func (gs *GameSession) GetGameMap() [][]int {
 	return mapCopy
 }
 
-// SetGameMap sets the game map safely
+// SetGameMap sets the game map
 func (gs *GameSession) SetGameMap(gameMap [][]int) {
-	gs.gameMapMutex.Lock()
-	defer gs.gameMapMutex.Unlock()
-	
 	// Create deep copy to prevent external modification
 	gs.gameMap = make([][]int, len(gameMap))
 	for i, row := range gameMap {
@@ -148,11 +572,8 @@ func (gs *GameSession) SetGameMap(gameMap [][]int) {
 	}
 }
 
-// GetTextGrid returns a copy of the text grid safely
+// GetTextGrid returns a copy of the text grid
 func (gs *GameSession) GetTextGrid() [][]string {
-	gs.textGridMutex.RLock()
-	defer gs.textGridMutex.RUnlock()
-	
 	if gs.textGrid == nil {
 		return nil
 	}
@@ -166,11 +587,8 @@ func (gs *GameSession) GetTextGrid() [][]string {
 	return textCopy
 }
 
-// SetTextGrid sets the text grid safely
+// SetTextGrid sets the text grid
 func (gs *GameSession) SetTextGrid(textGrid [][]string) {
-	gs.textGridMutex.Lock()
-	defer gs.textGridMutex.Unlock()
-	
 	// Create deep copy to prevent external modification
 	gs.textGrid = make([][]string, len(textGrid))
 	for i, row := range textGrid {
@@ -179,25 +597,51 @@ func (gs *GameSession) SetTextGrid(textGrid [][]string) {
 	}
 }
 
-// ProcessMove handles a move with proper concurrency control
-func (gs *GameSession) ProcessMove(newRow, newCol, preferredCol int, pearlCollected bool, pearlPoints int) error {
-	gs.moveMutex.Lock()
-	defer gs.moveMutex.Unlock()
-	
-	now := time.Now()
-	
+// GetPearlTimers returns a copy of the golden pearl timers
+func (gs *GameSession) GetPearlTimers() []PearlTimer {
+	timersCopy := make([]PearlTimer, len(gs.pearlTimers))
+	copy(timersCopy, gs.pearlTimers)
+	return timersCopy
+}
+
+// SetPearlTimers sets the golden pearl timers
+func (gs *GameSession) SetPearlTimers(timers []PearlTimer) {
+	gs.pearlTimers = make([]PearlTimer, len(timers))
+	copy(gs.pearlTimers, timers)
+}
+
+// GetEnemies returns a copy of the enemies
+func (gs *GameSession) GetEnemies() []game.Enemy {
+	enemiesCopy := make([]game.Enemy, len(gs.enemies))
+	copy(enemiesCopy, gs.enemies)
+	return enemiesCopy
+}
+
+// SetEnemies sets the enemies
+func (gs *GameSession) SetEnemies(enemies []game.Enemy) {
+	gs.enemies = make([]game.Enemy, len(enemies))
+	copy(gs.enemies, enemies)
+}
+
+// ProcessMove applies a move made at now to the session.
+// pearlType is the map value found on the target cell and points the score change it grants.
+func (gs *GameSession) ProcessMove(newRow, newCol, preferredCol int, pearlType int, points int, now time.Time) error {
 	// Check if enough time has passed since last move (prevent spam)
 	if gs.LastMoveTime != nil && now.Sub(*gs.LastMoveTime) < 50*time.Millisecond {
 		return ErrMoveTooFast
 	}
 	
-	gs.gameMapMutex.Lock()
-	defer gs.gameMapMutex.Unlock()
-	
+	gs.ApplyMove(newRow, newCol, preferredCol, pearlType, points, now)
+	return nil
+}
+
+// ApplyMove moves the player and counts the pearl collected on the way as of
+// now, without checking anything. Replays use it to re-run logged moves.
+func (gs *GameSession) ApplyMove(newRow, newCol, preferredCol int, pearlType int, points int, now time.Time) {
 	// Update map
 	if gs.gameMap != nil {
-		gs.gameMap[gs.CurrentRow][gs.CurrentCol] = 0
-		gs.gameMap[newRow][newCol] = 1
+		gs.gameMap[gs.CurrentRow][gs.CurrentCol] = game.EMPTY
+		gs.gameMap[newRow][newCol] = game.PLAYER
 	}
 	
 	// Update position
@@ -208,24 +652,27 @@ func (gs *GameSession) ProcessMove(newRow, newCol, preferredCol int, pearlCollec
 	gs.LastMoveTime = &now
 	
 	// Handle pearl collection
-	if pearlCollected {
-		gs.CurrentScore += pearlPoints
+	switch pearlType {
+	case game.PEARL:
 		gs.PearlsCollected++
+	case game.GOLDEN_PEARL:
+		gs.GoldenPearlsCollected++
+	case game.BLACK_PEARL:
+		gs.BlackPearlsCollected++
+	}
+	if game.IsPearl(pearlType) {
+		gs.CurrentScore += points
 	}
-	
-	return nil
 }
 
 // CompleteGame marks the game as completed
 func (gs *GameSession) CompleteGame() {
-	gs.moveMutex.Lock()
-	defer gs.moveMutex.Unlock()
-	
 	now := time.Now()
 	gs.IsCompleted = true
 	gs.IsActive = false
 	gs.EndTime = &now
 	gs.FinalScore = &gs.CurrentScore
+	gs.EndReason = EndReasonCompleted
 	
 	if gs.StartTime != nil {
 		completionTime := int(now.Sub(*gs.StartTime).Seconds())
@@ -233,17 +680,81 @@ func (gs *GameSession) CompleteGame() {
 	}
 }
 
+// FailGame ends the game without completing it
+func (gs *GameSession) FailGame(reason string) {
+	now := time.Now()
+	gs.IsActive = false
+	gs.EndTime = &now
+	gs.EndReason = reason
+}
+
 // ValidateScoreIntegrity validates that the score matches pearl collection
-func (gs *GameSession) ValidateScoreIntegrity(pearlPoints int) bool {
-	expectedScore := gs.PearlsCollected * pearlPoints
+func (gs *GameSession) ValidateScoreIntegrity(pearlPoints, goldenPearlPoints, blackPearlPenalty int) bool {
+	expectedScore := gs.PearlsCollected*pearlPoints +
+		gs.GoldenPearlsCollected*goldenPearlPoints -
+		gs.BlackPearlsCollected*blackPearlPenalty
 	return gs.CurrentScore == expectedScore
 }
 
+// IsTimeAttack reports whether the session is scored by pearls collected before its clock runs out
+func (gs *GameSession) IsTimeAttack() bool {
+	return gs.Mode == GameModeTimeAttack
+}
+
+// IsSurvival reports whether the session lasts until its pearls have expired its lives
+func (gs *GameSession) IsSurvival() bool {
+	return gs.Mode == GameModeSurvival
+}
+
+// CountsPearls reports whether the session scores one point per pearl with
+// no target score, as time attack and survival do
+func (gs *GameSession) CountsPearls() bool {
+	return gs.IsTimeAttack() || gs.IsSurvival()
+}
+
+// Game session modes
+const (
+	GameModeClassic    = "classic"     // reach the target score, ranked by completion time
+	GameModeTimeAttack = "time_attack" // collect as many pearls as possible on a fixed clock
+	GameModeSurvival   = "survival"    // collect pearls before they expire, each one missed costs a life
+)
+
+// Reasons a game session ended
+const (
+	EndReasonCompleted  = "completed"
+	EndReasonExpired    = "expired"
+	EndReasonCaught     = "caught_by_enemy"
+	EndReasonMatchOver  = "match_over"
+	EndReasonTimeUp     = "time_up"
+	EndReasonOutOfLives = "out_of_lives"
+)
+
+// Replay statuses of a game session
+const (
+	ReplayPending  = "pending"  // not re-run yet
+	ReplayVerified = "verified" // the re-run reproduced the game
+	ReplayInvalid  = "invalid"  // the re-run diverged, the game stays off the leaderboard
+	ReplayLegacy   = "legacy"   // finished before moves were logged, listed without a replay
+)
+
+// Review statuses of a game session flagged by the anti-cheat checks
+const (
+	ReviewClean     = "clean"     // nothing suspicious found
+	ReviewPending   = "pending"   // waiting in the admin review queue
+	ReviewCleared   = "cleared"   // an admin found the game fair
+	ReviewConfirmed = "confirmed" // an admin confirmed cheating, the game stays off the leaderboard
+)
+
 // Custom errors
 var (
-	ErrMoveTooFast = &GameError{Code: "MOVE_TOO_FAST", Message: "Move requests too frequent"}
-	ErrGameCompleted = &GameError{Code: "GAME_COMPLETED", Message: "Game already completed"}
-	ErrInvalidMove = &GameError{Code: "INVALID_MOVE", Message: "Invalid move"}
+	ErrMoveTooFast      = &GameError{Code: "MOVE_TOO_FAST", Message: "Move requests too frequent"}
+	ErrGameCompleted    = &GameError{Code: "GAME_COMPLETED", Message: "Game already completed"}
+	ErrInvalidMove      = &GameError{Code: "INVALID_MOVE", Message: "Invalid move"}
+	ErrMotionNotAllowed = &GameError{Code: "MOTION_NOT_ALLOWED", Message: "This motion is not allowed on this level"}
+	ErrMotionBlocked    = &GameError{Code: "MOTION_BLOCKED", Message: "This motion is blocked on this level"}
+	ErrGameNotStarted   = &GameError{Code: "GAME_NOT_STARTED", Message: "Game has not started yet"}
+	ErrCellOccupied     = &GameError{Code: "CELL_OCCUPIED", Message: "Another player is on that cell"}
+	ErrMoveConflict     = &GameError{Code: "MOVE_CONFLICT", Message: "The game changed during the move, try again"}
 )
 
 type GameError struct {
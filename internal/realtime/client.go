@@ -0,0 +1,175 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Connection limits
+const (
+	WRITE_WAIT       = 10 * time.Second
+	PONG_WAIT        = 60 * time.Second
+	PING_PERIOD      = PONG_WAIT * 9 / 10
+	MAX_MESSAGE_SIZE = 4096
+	SEND_BUFFER      = 64
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// CheckOrigin is left to the default, which only accepts pages served from
+	// this host. The session cookie goes along with any upgrade, so another site
+	// must not be able to open a socket and play or chat as the player.
+}
+
+// Client is one websocket connection of a player or spectator
+type Client struct {
+	hub      *Hub
+	conn     *websocket.Conn
+	send     chan []byte
+	closed   bool
+	closeMu  sync.Mutex
+	PlayerID uint
+}
+
+// Upgrade turns an HTTP request into a websocket client registered with the hub
+func (h *Hub) Upgrade(w http.ResponseWriter, r *http.Request, playerID uint) (*Client, error) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		hub:      h,
+		conn:     conn,
+		send:     make(chan []byte, SEND_BUFFER),
+		PlayerID: playerID,
+	}, nil
+}
+
+// Send queues a message for this client only
+func (c *Client) Send(msgType string, data interface{}) {
+	payload, err := json.Marshal(NewMessage(msgType, data))
+	if err != nil {
+		log.Printf("Failed to encode %s message: %v", msgType, err)
+		return
+	}
+	c.enqueue(payload)
+}
+
+// SendAfter queues a message for this client after a delay, stamped with the time it was created
+func (c *Client) SendAfter(msgType string, data interface{}, delay time.Duration) {
+	payload, err := json.Marshal(NewMessage(msgType, data))
+	if err != nil {
+		log.Printf("Failed to encode %s message: %v", msgType, err)
+		return
+	}
+	time.AfterFunc(delay, func() { c.enqueue(payload) })
+}
+
+// enqueue hands a payload to the write loop, dropping the client if it can't keep up
+func (c *Client) enqueue(payload []byte) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.send <- payload:
+	default:
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// Close stops the write loop, which closes the connection
+func (c *Client) Close() {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// Reject sends an error to a client that was never run and closes its connection.
+// Only Run starts the write loop, so Reject flushes the queue itself.
+func (c *Client) Reject(message string) {
+	c.hub.LeaveAll(c)
+	c.Send("error", map[string]string{"error": message})
+	c.Close()
+	c.writeLoop()
+}
+
+// Run pumps messages until the connection closes, handing every incoming
+// message to onMessage. It blocks, so call it from the request goroutine.
+func (c *Client) Run(onMessage func(c *Client, msg IncomingMessage)) {
+	go c.writeLoop()
+	defer func() {
+		c.hub.LeaveAll(c)
+		c.Close()
+	}()
+
+	c.conn.SetReadLimit(MAX_MESSAGE_SIZE)
+	c.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Websocket closed unexpectedly: %v", err)
+			}
+			return
+		}
+
+		var msg IncomingMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.Send("error", map[string]string{"error": "Invalid message format"})
+			continue
+		}
+		onMessage(c, msg)
+	}
+}
+
+// writeLoop writes queued messages and keeps the connection alive with pings
+func (c *Client) writeLoop() {
+	ticker := time.NewTicker(PING_PERIOD)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// IncomingMessage is a message sent by a client, Data is decoded by the handler of its type
+type IncomingMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
@@ -0,0 +1,62 @@
+package realtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRejectWritesErrorAndCloses(t *testing.T) {
+	hub := NewHub()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := hub.Upgrade(w, r, 0)
+		if err != nil {
+			return
+		}
+		hub.Join("room", client)
+		client.Send("state", map[string]string{"phase": "lobby"})
+		client.Reject("Match not found")
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// Messages queued before the rejection are still delivered, the error comes last
+	var types []string
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNoStatusReceived, websocket.CloseNormalClosure) {
+				t.Fatalf("connection ended with %v, want a close message", err)
+			}
+			break
+		}
+		var msg struct {
+			Type string            `json:"type"`
+			Data map[string]string `json:"data"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		types = append(types, msg.Type)
+		if msg.Type == "error" && msg.Data["error"] != "Match not found" {
+			t.Errorf("error message %q, want %q", msg.Data["error"], "Match not found")
+		}
+	}
+	if strings.Join(types, ",") != "state,error" {
+		t.Errorf("received %v, want [state error]", types)
+	}
+	if size := hub.RoomSize("room"); size != 0 {
+		t.Errorf("rejected client still in a room of %d", size)
+	}
+}
@@ -0,0 +1,126 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Message is the envelope of everything sent over a realtime connection
+type Message struct {
+	Type       string      `json:"type"`
+	Data       interface{} `json:"data,omitempty"`
+	ServerTime time.Time   `json:"server_time"`
+}
+
+// NewMessage wraps data in a message stamped with the server clock
+func NewMessage(msgType string, data interface{}) Message {
+	return Message{Type: msgType, Data: data, ServerTime: time.Now()}
+}
+
+// Hub keeps track of which clients listen to which rooms
+type Hub struct {
+	mu    sync.RWMutex
+	rooms map[string]map[*Client]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		rooms: make(map[string]map[*Client]bool),
+	}
+}
+
+// Join subscribes the client to a room's broadcasts
+func (h *Hub) Join(room string, client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[*Client]bool)
+	}
+	h.rooms[room][client] = true
+}
+
+// Leave unsubscribes the client from a room
+func (h *Hub) Leave(room string, client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.rooms[room], client)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// LeaveAll unsubscribes the client from every room, used when it disconnects
+func (h *Hub) LeaveAll(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for room, clients := range h.rooms {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// Broadcast sends a message to every client in a room
+func (h *Hub) Broadcast(room, msgType string, data interface{}) {
+	payload, err := json.Marshal(NewMessage(msgType, data))
+	if err != nil {
+		log.Printf("Failed to encode %s message: %v", msgType, err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.rooms[room] {
+		client.enqueue(payload)
+	}
+}
+
+// BroadcastAfter sends a message to the clients in a room after a delay. The
+// message keeps the time it was created, and goes to the clients in the room
+// when it is delivered.
+func (h *Hub) BroadcastAfter(room, msgType string, data interface{}, delay time.Duration) {
+	payload, err := json.Marshal(NewMessage(msgType, data))
+	if err != nil {
+		log.Printf("Failed to encode %s message: %v", msgType, err)
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		for client := range h.rooms[room] {
+			client.enqueue(payload)
+		}
+	})
+}
+
+// Disconnect sends a last message to a player's clients in a room and closes them
+func (h *Hub) Disconnect(room string, playerID uint, msgType string, data interface{}) {
+	var clients []*Client
+	h.mu.RLock()
+	for client := range h.rooms[room] {
+		if client.PlayerID == playerID {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.Leave(room, client)
+		client.Send(msgType, data)
+		client.Close()
+	}
+}
+
+// RoomSize returns how many clients listen to a room
+func (h *Hub) RoomSize(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Games that reproduce on replay are also checked for play no person would
+// manage: keys pressed at a metronome's pace, bursts of different keys faster
+// than anyone types, or every pearl reached in the fewest possible moves. Each
+// heuristic adds to the game's cheat score. Games scoring CheatReviewScore go
+// to the admin review queue and games scoring CheatExcludeScore stay off the
+// leaderboard until an admin clears them.
+
+// Heuristics a game can be flagged with
+const (
+	cheatRegularTiming = "regular_timing"
+	cheatScriptedBurst = "scripted_burst"
+	cheatPerfectPath   = "perfect_pathing"
+)
+
+// cheatWeights is how much each heuristic adds to the cheat score
+var cheatWeights = map[string]int{
+	cheatRegularTiming: 50,
+	cheatScriptedBurst: 40,
+	cheatPerfectPath:   40,
+}
+
+const (
+	regularTimingIntervals = 20  // key changes needed before timing is judged
+	regularTimingVariation = 0.1 // intervals varying less than this, relative to their mean, are machine-like
+	burstLength            = 8   // key changes in a row that make a burst
+	burstInterval          = 70 * time.Millisecond
+	perfectPathSegments    = 6   // pearls needed before pathing is judged
+	perfectPathShare       = 0.9 // share of pearls reached in the fewest moves that is too perfect
+)
+
+// cheatReport is what the anti-cheat checks found in a game
+type cheatReport struct {
+	score int
+	flags []string
+}
+
+// status returns the review status the report puts a game in
+func (r cheatReport) status(reviewScore int) string {
+	if r.score >= reviewScore {
+		return models.ReviewPending
+	}
+	return models.ReviewClean
+}
+
+// analyseMoves runs the anti-cheat heuristics on a game that reproduced on replay
+func (gs *GameService) analyseMoves(gameSession *models.GameSession, moves []models.GameMove) (cheatReport, error) {
+	var report cheatReport
+	flag := func(name string) {
+		report.flags = append(report.flags, name)
+		report.score += cheatWeights[name]
+	}
+
+	intervals := keyChangeIntervals(moves)
+	if regularTiming(intervals) {
+		flag(cheatRegularTiming)
+	}
+	if scriptedBurst(intervals) {
+		flag(cheatScriptedBurst)
+	}
+
+	perfect, err := gs.perfectPathing(gameSession, moves)
+	if err != nil {
+		return report, err
+	}
+	if perfect {
+		flag(cheatPerfectPath)
+	}
+
+	if report.score > 100 {
+		report.score = 100
+	}
+	return report, nil
+}
+
+// keyChangeIntervals returns the time between moves made with a different key
+// than the move before, so holding a key down and letting it repeat is left out.
+// Moves made with the same key break the run, zero marks the break.
+func keyChangeIntervals(moves []models.GameMove) []time.Duration {
+	var intervals []time.Duration
+	for i := 1; i < len(moves); i++ {
+		if moves[i].Key == moves[i-1].Key {
+			intervals = append(intervals, 0)
+			continue
+		}
+		intervals = append(intervals, moves[i].PlayedAt.Sub(moves[i-1].PlayedAt))
+	}
+	return intervals
+}
+
+// regularTiming reports whether the time between key changes hardly varies
+func regularTiming(intervals []time.Duration) bool {
+	var samples []float64
+	for _, interval := range intervals {
+		if interval > 0 {
+			samples = append(samples, float64(interval))
+		}
+	}
+	if len(samples) < regularTimingIntervals {
+		return false
+	}
+
+	mean := 0.0
+	for _, sample := range samples {
+		mean += sample
+	}
+	mean /= float64(len(samples))
+
+	variance := 0.0
+	for _, sample := range samples {
+		variance += (sample - mean) * (sample - mean)
+	}
+	variance /= float64(len(samples))
+
+	return math.Sqrt(variance)/mean < regularTimingVariation
+}
+
+// scriptedBurst reports whether burstLength different keys in a row were
+// pressed faster than anyone types
+func scriptedBurst(intervals []time.Duration) bool {
+	run := 0
+	for _, interval := range intervals {
+		if interval > 0 && interval < burstInterval {
+			run++
+			if run >= burstLength {
+				return true
+			}
+		} else {
+			run = 0
+		}
+	}
+	return false
+}
+
+// pathSegment is the way from one pearl to the next in a replayed game
+type pathSegment struct {
+	gameMap          [][]int
+	textGrid         [][]string
+	fromRow, fromCol int
+	preferredColumn  int
+	toRow, toCol     int
+	moves            int
+}
+
+// perfectPathing replays the game and reports whether nearly every scoring
+// pearl was reached in the fewest moves the motions the player used allow
+func (gs *GameService) perfectPathing(gameSession *models.GameSession, moves []models.GameMove) (bool, error) {
+	var segments []pathSegment
+	var current pathSegment
+	directions := map[string]bool{}
+	err := gs.replayRun(gameSession, moves, func(state *models.GameSession, move *models.GameMove, before [][]int) error {
+		if move != nil {
+			directions[move.Direction] = true
+			current.moves++
+			if move.PearlType == 0 {
+				return nil
+			}
+			// Black pearls cost points, nobody heads for them on purpose
+			if move.PearlType != game.BLACK_PEARL && current.moves > 1 {
+				current.toRow, current.toCol = move.ToRow, move.ToCol
+				segments = append(segments, current)
+			}
+		} else if before != nil {
+			return nil
+		}
+
+		// A new segment starts at the beginning and after every pearl
+		current = pathSegment{
+			gameMap:         state.GetGameMap(),
+			textGrid:        state.GetTextGrid(),
+			fromRow:         state.CurrentRow,
+			fromCol:         state.CurrentCol,
+			preferredColumn: state.PreferredColumn,
+		}
+		return nil
+	})
+	var diverged *replayDivergence
+	if errors.As(err, &diverged) {
+		// verifyReplay already marks games that don't reproduce
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if len(segments) < perfectPathSegments {
+		return false, nil
+	}
+
+	var used []string
+	for direction := range directions {
+		used = append(used, direction)
+	}
+	perfect := 0
+	for _, segment := range segments {
+		shortest := game.ShortestPath(segment.gameMap, segment.textGrid, segment.fromRow, segment.fromCol,
+			segment.preferredColumn, segment.toRow, segment.toCol, used, segment.moves)
+		if shortest == segment.moves {
+			perfect++
+		}
+	}
+	return float64(perfect) >= perfectPathShare*float64(len(segments)), nil
+}
+
+// IsAdmin reports whether a logged in player may review flagged games
+func (gs *GameService) IsAdmin(playerID uint) (bool, error) {
+	var player models.Player
+	if err := gs.db.First(&player, playerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !player.IsRegistered {
+		return false, nil
+	}
+	for _, name := range strings.Split(gs.cfg.AdminUsers, ",") {
+		if strings.TrimSpace(name) == player.Username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReviewQueue lists the games waiting for review, most suspicious first
+func (gs *GameService) ReviewQueue(limit int) (map[string]interface{}, error) {
+	// Finished games are scored in the background once their replay was checked
+	var gameSessions []models.GameSession
+	err := gs.db.Preload("Player").
+		Where("review_status = ?", models.ReviewPending).
+		Order("cheat_score DESC").
+		Order("end_time ASC").
+		Limit(limit).
+		Find(&gameSessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := []map[string]interface{}{}
+	for _, gameSession := range gameSessions {
+		reviews = append(reviews, map[string]interface{}{
+			"game_id":     gameSession.ID,
+			"username":    gameSession.Player.Username,
+			"mode":        gameSession.Mode,
+			"score":       gameSession.FinalScore,
+			"total_moves": gameSession.TotalMoves,
+			"cheat_score": gameSession.CheatScore,
+			"flags":       strings.Split(gameSession.CheatFlags, ","),
+			"excluded":    gameSession.CheatScore >= gs.cfg.CheatExcludeScore,
+			"ended_at":    gameSession.EndTime,
+			"replay_url":  ReplayURL(gameSession.ID),
+		})
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"reviews": reviews,
+	}, nil
+}
+
+// ReviewGame records an admin's verdict on a queued game: "clear" lists it
+// on the leaderboard again, "confirm" keeps it off for good
+func (gs *GameService) ReviewGame(adminID, gameID uint, action string) (map[string]interface{}, error) {
+	var status string
+	switch action {
+	case "clear":
+		status = models.ReviewCleared
+	case "confirm":
+		status = models.ReviewConfirmed
+	default:
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Unknown review action: " + action,
+		}, nil
+	}
+
+	now := time.Now()
+	result := gs.db.Model(&models.GameSession{}).
+		Where("id = ? AND review_status = ?", gameID, models.ReviewPending).
+		UpdateColumns(map[string]interface{}{
+			"review_status": status,
+			"reviewed_by":   adminID,
+			"reviewed_at":   now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Game is not waiting for review",
+		}, nil
+	}
+
+	log.Printf("Game %d review %s by player %d", gameID, status, adminID)
+	return map[string]interface{}{
+		"success":       true,
+		"game_id":       gameID,
+		"review_status": status,
+	}, nil
+}
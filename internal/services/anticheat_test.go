@@ -0,0 +1,170 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+)
+
+// steadyIntervals returns n intervals of every
+func steadyIntervals(n int, every time.Duration) []time.Duration {
+	intervals := make([]time.Duration, n)
+	for i := range intervals {
+		intervals[i] = every
+	}
+	return intervals
+}
+
+func TestKeyChangeIntervals(t *testing.T) {
+	start := time.Now()
+	moves := []models.GameMove{
+		{Key: "l", PlayedAt: start},
+		{Key: "l", PlayedAt: start.Add(30 * time.Millisecond)},
+		{Key: "j", PlayedAt: start.Add(130 * time.Millisecond)},
+		{Key: "w", PlayedAt: start.Add(330 * time.Millisecond)},
+	}
+	want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}
+	if got := keyChangeIntervals(moves); !reflect.DeepEqual(got, want) {
+		t.Errorf("keyChangeIntervals = %v, want %v", got, want)
+	}
+}
+
+func TestRegularTiming(t *testing.T) {
+	jittered := steadyIntervals(regularTimingIntervals, 100*time.Millisecond)
+	for i := range jittered {
+		if i%2 == 0 {
+			jittered[i] = 150 * time.Millisecond
+		}
+	}
+	slightJitter := steadyIntervals(regularTimingIntervals, 100*time.Millisecond)
+	for i := range slightJitter {
+		if i%2 == 0 {
+			slightJitter[i] = 110 * time.Millisecond
+		}
+	}
+	withRepeats := append(steadyIntervals(regularTimingIntervals, 100*time.Millisecond), 0, 0, 0)
+
+	tests := []struct {
+		name      string
+		intervals []time.Duration
+		want      bool
+	}{
+		{"metronome", steadyIntervals(regularTimingIntervals, 100*time.Millisecond), true},
+		{"too few key changes to judge", steadyIntervals(regularTimingIntervals-1, 100*time.Millisecond), false},
+		{"held keys are left out", withRepeats, true},
+		{"variation under the threshold", slightJitter, true},
+		{"human variation", jittered, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := regularTiming(tt.intervals); got != tt.want {
+				t.Errorf("regularTiming = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptedBurst(t *testing.T) {
+	fast := burstInterval - time.Millisecond
+	broken := append(steadyIntervals(burstLength-1, fast), 0)
+	broken = append(broken, steadyIntervals(burstLength-1, fast)...)
+
+	tests := []struct {
+		name      string
+		intervals []time.Duration
+		want      bool
+	}{
+		{"burst", steadyIntervals(burstLength, fast), true},
+		{"one key change short", steadyIntervals(burstLength-1, fast), false},
+		{"at the burst interval", steadyIntervals(burstLength, burstInterval), false},
+		{"a held key breaks the run", broken, false},
+		{"slow play", steadyIntervals(50, 150*time.Millisecond), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scriptedBurst(tt.intervals); got != tt.want {
+				t.Errorf("scriptedBurst = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheatReportStatus(t *testing.T) {
+	tests := []struct {
+		score int
+		want  string
+	}{
+		{0, models.ReviewClean},
+		{39, models.ReviewClean},
+		{40, models.ReviewPending},
+		{100, models.ReviewPending},
+	}
+	for _, tt := range tests {
+		if got := (cheatReport{score: tt.score}).status(40); got != tt.want {
+			t.Errorf("status of score %d = %s, want %s", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyseMoves(t *testing.T) {
+	gs := newTestGameService(t)
+	player := models.Player{Username: "bot", Email: "bot@example.com", IsRegistered: true}
+	if err := gs.db.Create(&player).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Every move heads straight for the nearest pearl
+	result, err := gs.CreateGame(player.ID, "", GameOptions{TextPattern: 1, Seed: 42, TargetScore: 10 * gs.cfg.PearlPoints, PearlStrategy: game.PearlStrategyRegular})
+	if err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+	gameSession := playTowardsPearls(t, gs, result["session_token"].(string), 150)
+	if !gameSession.IsCompleted {
+		t.Fatalf("game not completed after %d moves, score %d", gameSession.TotalMoves, gameSession.CurrentScore)
+	}
+	var played []models.GameMove
+	if err := gs.db.Where("game_session_id = ?", gameSession.ID).Order("sequence ASC").Find(&played).Error; err != nil {
+		t.Fatalf("load moves: %v", err)
+	}
+
+	// Timings are rewritten so each case judges them alone, the path stays the bot's.
+	// Keys are relabelled so every move counts as a key change, replays only read the direction.
+	retimed := func(count int, interval func(i int) time.Duration) []models.GameMove {
+		moves := append([]models.GameMove(nil), played[:count]...)
+		at := *gameSession.StartTime
+		for i := range moves {
+			at = at.Add(interval(i))
+			moves[i].PlayedAt = at
+			moves[i].Key = string(rune('a' + i%2))
+		}
+		return moves
+	}
+	human := func(i int) time.Duration { return time.Duration(150+(i*73)%200) * time.Millisecond }
+	metronome := func(int) time.Duration { return 100 * time.Millisecond }
+	script := func(int) time.Duration { return burstInterval / 2 }
+
+	tests := []struct {
+		name      string
+		moves     []models.GameMove
+		wantFlags []string
+		wantScore int
+	}{
+		{"too few pearls to judge the path", retimed(5, human), nil, 0},
+		{"perfect path at a human pace", retimed(len(played), human), []string{cheatPerfectPath}, 40},
+		{"perfect path at a metronome pace", retimed(len(played), metronome), []string{cheatRegularTiming, cheatPerfectPath}, 90},
+		{"every flag adds up to at most 100", retimed(len(played), script), []string{cheatRegularTiming, cheatScriptedBurst, cheatPerfectPath}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := gs.analyseMoves(gameSession, tt.moves)
+			if err != nil {
+				t.Fatalf("analyseMoves: %v", err)
+			}
+			if !reflect.DeepEqual(report.flags, tt.wantFlags) || report.score != tt.wantScore {
+				t.Errorf("analyseMoves flagged %v with score %d, want %v with %d", report.flags, report.score, tt.wantFlags, tt.wantScore)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CampaignService struct {
+	db          *gorm.DB
+	cfg         *config.Config
+	gameService *GameService
+}
+
+func NewCampaignService(db *gorm.DB, cfg *config.Config, gameService *GameService) *CampaignService {
+	return &CampaignService{
+		db:          db,
+		cfg:         cfg,
+		gameService: gameService,
+	}
+}
+
+// campaignStage is one entry of the campaign file
+type campaignStage struct {
+	Name           string   `json:"name"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Level          string   `json:"level"` // level file providing text, walls and enemies
+	Text           []string `json:"text"`  // plain text used when no level file is given
+	AllowedMotions []string `json:"allowed_motions"`
+	BlockedMotions []string `json:"blocked_motions"`
+	PearlStrategy  string   `json:"pearl_strategy"`
+	TargetScore    int      `json:"target_score"`
+	TimeLimit      int      `json:"time_limit"`
+}
+
+// SyncLevels loads the campaign file and upserts its stages into the levels table
+// in order. Levels no longer in the file are deactivated, keeping their progress.
+func (cs *CampaignService) SyncLevels() error {
+	data, err := os.ReadFile(cs.cfg.CampaignFile)
+	if err != nil {
+		return err
+	}
+
+	var stages []campaignStage
+	if err := json.Unmarshal(data, &stages); err != nil {
+		return err
+	}
+
+	return cs.db.Transaction(func(tx *gorm.DB) error {
+		names := make([]string, 0, len(stages))
+		for i, stage := range stages {
+			level, err := cs.buildLevel(stage)
+			if err != nil {
+				return err
+			}
+
+			row := models.CampaignLevel{
+				Position:       i + 1,
+				Name:           stage.Name,
+				Title:          stage.Title,
+				Description:    stage.Description,
+				Text:           strings.Join(level.Lines, "\n"),
+				Walls:          strings.Join(level.Walls, "\n"),
+				AllowedMotions: strings.Join(stage.AllowedMotions, ","),
+				BlockedMotions: strings.Join(stage.BlockedMotions, ","),
+				PearlStrategy:  stage.PearlStrategy,
+				TargetScore:    stage.TargetScore,
+				TimeLimit:      stage.TimeLimit,
+				IsActive:       true,
+			}
+			if len(level.Enemies) > 0 {
+				enemiesJSON, err := json.Marshal(level.Enemies)
+				if err != nil {
+					return err
+				}
+				row.EnemiesJSON = string(enemiesJSON)
+			}
+
+			// Stages are matched by name so progress survives reordering the file
+			err = tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"position", "title", "description", "text", "walls", "enemies_json", "allowed_motions", "blocked_motions", "pearl_strategy", "target_score", "time_limit", "is_active", "updated_at"}),
+			}).Create(&row).Error
+			if err != nil {
+				return err
+			}
+			names = append(names, stage.Name)
+		}
+
+		removed := tx.Model(&models.CampaignLevel{}).Where("is_active = ?", true)
+		if len(names) > 0 {
+			removed = removed.Where("name NOT IN ?", names)
+		}
+		return removed.Update("is_active", false).Error
+	})
+}
+
+// buildLevel resolves the text, walls and enemies of a campaign stage
+func (cs *CampaignService) buildLevel(stage campaignStage) (*game.Level, error) {
+	if stage.Name == "" {
+		return nil, errors.New("campaign stage without a name")
+	}
+	if stage.PearlStrategy != "" && !game.IsValidPearlStrategy(stage.PearlStrategy) {
+		return nil, errors.New("unknown pearl strategy in stage " + stage.Name + ": " + stage.PearlStrategy)
+	}
+	for _, key := range append(append([]string{}, stage.AllowedMotions...), stage.BlockedMotions...) {
+		if !game.IsMovementKey(key) {
+			return nil, errors.New("unknown motion in stage " + stage.Name + ": " + key)
+		}
+	}
+
+	if stage.Level != "" {
+		level, exists := cs.gameService.levels[stage.Level]
+		if !exists {
+			return nil, errors.New("level not found for stage " + stage.Name + ": " + stage.Level)
+		}
+		return level, nil
+	}
+
+	level := &game.Level{Name: stage.Name, Lines: stage.Text}
+	if err := level.Validate(); err != nil {
+		return nil, err
+	}
+	return level, nil
+}
+
+// ListLevels returns the campaign levels in order with the player's progress on each
+func (cs *CampaignService) ListLevels(playerID uint) (map[string]interface{}, error) {
+	var levels []models.CampaignLevel
+	if err := cs.db.Where("is_active = ?", true).Order("position ASC").Find(&levels).Error; err != nil {
+		return nil, err
+	}
+
+	progressByLevel, err := cs.progressByLevel(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []map[string]interface{}
+	for i, level := range levels {
+		progress := progressByLevel[level.ID]
+		entry := map[string]interface{}{
+			"id":              level.ID,
+			"position":        level.Position,
+			"name":            level.Name,
+			"title":           level.Title,
+			"description":     level.Description,
+			"allowed_motions": splitMotions(level.AllowedMotions),
+			"blocked_motions": splitMotions(level.BlockedMotions),
+			"pearl_strategy":  level.PearlStrategy,
+			"target_score":    level.TargetScore,
+			"time_limit":      level.TimeLimit,
+			"is_unlocked":     i == 0 || (progress != nil && progress.IsUnlocked),
+			"is_completed":    progress != nil && progress.IsCompleted,
+		}
+		if progress != nil {
+			entry["attempts"] = progress.Attempts
+			entry["best_score"] = progress.BestScore
+			entry["best_time"] = progress.BestTime
+		}
+		entries = append(entries, entry)
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"levels":  entries,
+	}, nil
+}
+
+// StartLevel starts a game session on an unlocked campaign level
+func (cs *CampaignService) StartLevel(playerID uint, selectedCharacter string, levelID uint) (map[string]interface{}, error) {
+	var level models.CampaignLevel
+	if err := cs.db.Where("is_active = ?", true).First(&level, levelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "Level not found",
+			}, nil
+		}
+		return nil, err
+	}
+
+	unlocked, err := cs.isUnlocked(playerID, &level)
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Level is locked - complete the previous level first",
+		}, nil
+	}
+
+	gameLevel, err := campaignGameLevel(&level)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := cs.gameService.CreateGame(playerID, selectedCharacter, GameOptions{
+		Level:           gameLevel,
+		CampaignLevelID: &level.ID,
+		PearlStrategy:   level.PearlStrategy,
+		TargetScore:     level.TargetScore,
+		TimeLimit:       level.TimeLimit,
+		AllowedMotions:  splitMotions(level.AllowedMotions),
+		BlockedMotions:  splitMotions(level.BlockedMotions),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result["level"] = map[string]interface{}{
+		"id":              level.ID,
+		"name":            level.Name,
+		"title":           level.Title,
+		"allowed_motions": splitMotions(level.AllowedMotions),
+		"blocked_motions": splitMotions(level.BlockedMotions),
+	}
+	return result, nil
+}
+
+// campaignGameLevel rebuilds the text, walls and enemies a campaign level is played on
+func campaignGameLevel(level *models.CampaignLevel) (*game.Level, error) {
+	gameLevel := &game.Level{
+		Name:  level.Name,
+		Lines: strings.Split(level.Text, "\n"),
+	}
+	if level.Walls != "" {
+		gameLevel.Walls = strings.Split(level.Walls, "\n")
+	}
+	if level.EnemiesJSON != "" {
+		if err := json.Unmarshal([]byte(level.EnemiesJSON), &gameLevel.Enemies); err != nil {
+			return nil, err
+		}
+	}
+	return gameLevel, nil
+}
+
+// isUnlocked reports whether the player may start the level, the first level is always open
+func (cs *CampaignService) isUnlocked(playerID uint, level *models.CampaignLevel) (bool, error) {
+	var first models.CampaignLevel
+	if err := cs.db.Where("is_active = ?", true).Order("position ASC").First(&first).Error; err != nil {
+		return false, err
+	}
+	if first.ID == level.ID {
+		return true, nil
+	}
+
+	player, err := findPlayer(cs.db, playerID)
+	if err != nil || player == nil {
+		return false, err
+	}
+
+	var progress models.LevelProgress
+	err = cs.db.Where("player_id = ? AND campaign_level_id = ?", player.ID, level.ID).First(&progress).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return progress.IsUnlocked, err
+}
+
+// progressByLevel loads the player's progress records keyed by level ID
+func (cs *CampaignService) progressByLevel(playerID uint) (map[uint]*models.LevelProgress, error) {
+	progressByLevel := make(map[uint]*models.LevelProgress)
+
+	player, err := findPlayer(cs.db, playerID)
+	if err != nil || player == nil {
+		return progressByLevel, err
+	}
+
+	var records []models.LevelProgress
+	if err := cs.db.Where("player_id = ?", player.ID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for i := range records {
+		progressByLevel[records[i].CampaignLevelID] = &records[i]
+	}
+	return progressByLevel, nil
+}
+
+// findPlayer returns the logged-in player with that id, or nil for anonymous players (id 0)
+func findPlayer(db *gorm.DB, playerID uint) (*models.Player, error) {
+	if playerID == 0 {
+		return nil, nil
+	}
+
+	var player models.Player
+	if err := db.First(&player, playerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &player, nil
+}
+
+// recordLevelCompletion stores the result of a completed campaign session and unlocks the next level
+func recordLevelCompletion(tx *gorm.DB, gameSession *models.GameSession) error {
+	if gameSession.CampaignLevelID == nil {
+		return nil
+	}
+
+	progress, err := findOrCreateProgress(tx, gameSession.PlayerID, *gameSession.CampaignLevelID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	progress.IsUnlocked = true
+	progress.IsCompleted = true
+	if progress.CompletedAt == nil {
+		progress.CompletedAt = &now
+	}
+	if gameSession.CurrentScore > progress.BestScore {
+		progress.BestScore = gameSession.CurrentScore
+	}
+	if gameSession.CompletionTime != nil && (progress.BestTime == nil || *gameSession.CompletionTime < *progress.BestTime) {
+		progress.BestTime = gameSession.CompletionTime
+	}
+	if err := tx.Save(progress).Error; err != nil {
+		return err
+	}
+
+	// Unlock the next level in campaign order
+	var current, next models.CampaignLevel
+	if err := tx.First(&current, *gameSession.CampaignLevelID).Error; err != nil {
+		return err
+	}
+	err = tx.Where("position > ? AND is_active = ?", current.Position, true).Order("position ASC").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // Campaign finished
+	} else if err != nil {
+		return err
+	}
+
+	nextProgress, err := findOrCreateProgress(tx, gameSession.PlayerID, next.ID)
+	if err != nil {
+		return err
+	}
+	nextProgress.IsUnlocked = true
+	return tx.Save(nextProgress).Error
+}
+
+// recordLevelAttempt counts a finished campaign session, won or lost, as an
+// attempt on its level. Anonymous players have no progress to count it on.
+func recordLevelAttempt(tx *gorm.DB, gameSession *models.GameSession) error {
+	if gameSession.CampaignLevelID == nil || gameSession.PlayerID == 0 {
+		return nil
+	}
+
+	progress, err := findOrCreateProgress(tx, gameSession.PlayerID, *gameSession.CampaignLevelID)
+	if err != nil {
+		return err
+	}
+	progress.Attempts++
+	return tx.Save(progress).Error
+}
+
+// abandonSessions deactivates the player's active sessions before a new game
+// starts. A campaign session left this way counts as an attempt on its level.
+func abandonSessions(db *gorm.DB, playerID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var abandoned []models.GameSession
+		err := tx.Where("player_id = ? AND is_active = ? AND campaign_level_id IS NOT NULL", playerID, true).
+			Find(&abandoned).Error
+		if err != nil {
+			return err
+		}
+
+		err = updateSessions(tx.Model(&models.GameSession{}).Where("player_id = ? AND is_active = ?", playerID, true),
+			map[string]interface{}{"is_active": false})
+		if err != nil {
+			return err
+		}
+		for i := range abandoned {
+			if err := recordLevelAttempt(tx, &abandoned[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// findOrCreateProgress returns the player's progress record for a level, creating it if needed
+func findOrCreateProgress(db *gorm.DB, playerID, levelID uint) (*models.LevelProgress, error) {
+	progress := models.LevelProgress{PlayerID: playerID, CampaignLevelID: levelID}
+	err := db.Where("player_id = ? AND campaign_level_id = ?", playerID, levelID).FirstOrCreate(&progress).Error
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// splitMotions turns a comma-separated motion list into a slice, nil means all motions
+func splitMotions(motions string) []string {
+	if motions == "" {
+		return nil
+	}
+	return strings.Split(motions, ",")
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"boba-vim/internal/models"
+)
+
+func TestSyncLevelsDeactivatesRemovedStages(t *testing.T) {
+	gs := newTestGameService(t)
+	cs := NewCampaignService(gs.db, gs.cfg, gs)
+	cs.cfg.CampaignFile = filepath.Join(t.TempDir(), "campaign.json")
+
+	syncs := []struct {
+		name       string
+		file       string
+		wantActive []string
+	}{
+		{"first sync", `[{"name":"one","text":["a b"]},{"name":"two","text":["c d"]},{"name":"three","text":["e f"]}]`, []string{"one", "two", "three"}},
+		{"stage removed", `[{"name":"one","text":["a b"]},{"name":"three","text":["e f"]}]`, []string{"one", "three"}},
+		{"stage restored", `[{"name":"two","text":["c d"]},{"name":"three","text":["e f"]}]`, []string{"two", "three"}},
+	}
+	for _, sync := range syncs {
+		if err := os.WriteFile(cs.cfg.CampaignFile, []byte(sync.file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := cs.SyncLevels(); err != nil {
+			t.Fatalf("%s: SyncLevels: %v", sync.name, err)
+		}
+
+		result, err := cs.ListLevels(0)
+		if err != nil {
+			t.Fatalf("%s: ListLevels: %v", sync.name, err)
+		}
+		levels := result["levels"].([]map[string]interface{})
+		if len(levels) != len(sync.wantActive) {
+			t.Fatalf("%s: listed %d levels, want %v", sync.name, len(levels), sync.wantActive)
+		}
+		for i, name := range sync.wantActive {
+			if levels[i]["name"] != name {
+				t.Errorf("%s: level %d is %v, want %s", sync.name, i, levels[i]["name"], name)
+			}
+		}
+	}
+
+	var total int64
+	gs.db.Model(&models.CampaignLevel{}).Count(&total)
+	if total != 3 {
+		t.Errorf("kept %d level rows, want 3", total)
+	}
+}
+
+func TestNewGameCountsAbandonedLevelAsAttempt(t *testing.T) {
+	gs := newTestGameService(t)
+	cs := NewCampaignService(gs.db, gs.cfg, gs)
+	cs.cfg.CampaignFile = filepath.Join(t.TempDir(), "campaign.json")
+	if err := os.WriteFile(cs.cfg.CampaignFile, []byte(`[{"name":"one","text":["a b"]}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.SyncLevels(); err != nil {
+		t.Fatalf("SyncLevels: %v", err)
+	}
+	var level models.CampaignLevel
+	if err := gs.db.First(&level).Error; err != nil {
+		t.Fatal(err)
+	}
+	player := models.Player{Username: "quitter", Email: "quitter@example.com", IsRegistered: true}
+	if err := gs.db.Create(&player).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name         string
+		start        func() error
+		wantAttempts int
+	}{
+		{"first start", func() error { _, err := cs.StartLevel(player.ID, "", level.ID); return err }, 0},
+		{"level restarted", func() error { _, err := cs.StartLevel(player.ID, "", level.ID); return err }, 1},
+		{"classic game after the level", func() error { _, err := gs.CreateGame(player.ID, "", GameOptions{Seed: 42}); return err }, 2},
+		{"classic game after a classic game", func() error { _, err := gs.CreateGame(player.ID, "", GameOptions{Seed: 42}); return err }, 2},
+	}
+	for _, step := range steps {
+		if err := step.start(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		var progress models.LevelProgress
+		gs.db.Where("player_id = ? AND campaign_level_id = ?", player.ID, level.ID).FirstOrInit(&progress)
+		if progress.Attempts != step.wantAttempts {
+			t.Errorf("%s: %d attempts, want %d", step.name, progress.Attempts, step.wantAttempts)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	challengeLeaderboardSize = 20
+	maxSolutionLength        = 5000 // characters of vim key notation a submission may use
+)
+
+type ChallengeService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewChallengeService(db *gorm.DB, cfg *config.Config) *ChallengeService {
+	return &ChallengeService{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// challengeEntry is one entry of the challenges file
+type challengeEntry struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Start       []string `json:"start"`
+	Target      []string `json:"target"`
+}
+
+// SyncChallenges loads the challenges file and upserts its entries into the challenges table in order
+func (cs *ChallengeService) SyncChallenges() error {
+	data, err := os.ReadFile(cs.cfg.ChallengesFile)
+	if err != nil {
+		return err
+	}
+
+	var entries []challengeEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	return cs.db.Transaction(func(tx *gorm.DB) error {
+		for i, entry := range entries {
+			if entry.Name == "" {
+				return errors.New("challenge without a name")
+			}
+			row := models.Challenge{
+				Position:    i + 1,
+				Name:        entry.Name,
+				Title:       entry.Title,
+				Description: entry.Description,
+				StartText:   strings.Join(entry.Start, "\n"),
+				TargetText:  strings.Join(entry.Target, "\n"),
+			}
+			if row.StartText == row.TargetText {
+				return errors.New("challenge " + entry.Name + " starts out solved")
+			}
+
+			// Challenges are matched by name so submissions survive reordering the file
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"position", "title", "description", "start_text", "target_text", "updated_at"}),
+			}).Create(&row).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListChallenges returns the challenges in order with their record and the player's best
+func (cs *ChallengeService) ListChallenges(playerID uint) (map[string]interface{}, error) {
+	var challenges []models.Challenge
+	if err := cs.db.Order("position ASC").Find(&challenges).Error; err != nil {
+		return nil, err
+	}
+
+	player, err := findPlayer(cs.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]map[string]interface{}, 0, len(challenges))
+	for _, challenge := range challenges {
+		best, err := cs.bestSubmissions(challenge.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := challengeData(&challenge)
+		entry["solved_by"] = len(best)
+		if len(best) > 0 {
+			entry["record"] = best[0].Keystrokes
+		}
+		if player != nil {
+			if own := playerSubmission(best, player.ID); own != nil {
+				entry["personal_best"] = own.Keystrokes
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"challenges": entries,
+	}, nil
+}
+
+// GetChallenge returns a challenge with its leaderboard. The solutions are
+// only shown to players who have solved the challenge themselves.
+func (cs *ChallengeService) GetChallenge(playerID, challengeID uint) (map[string]interface{}, error) {
+	challenge, result, err := cs.findChallenge(challengeID)
+	if challenge == nil {
+		return result, err
+	}
+
+	player, err := findPlayer(cs.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	best, err := cs.bestSubmissions(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var own *models.ChallengeSubmission
+	if player != nil {
+		own = playerSubmission(best, player.ID)
+	}
+	showSolutions := own != nil
+
+	leaderboard := make([]map[string]interface{}, 0, challengeLeaderboardSize)
+	for i, submission := range best {
+		if i == challengeLeaderboardSize {
+			break
+		}
+		entry := map[string]interface{}{
+			"rank":         i + 1,
+			"username":     submission.Username,
+			"keystrokes":   submission.Keystrokes,
+			"submitted_at": submission.CreatedAt,
+		}
+		if showSolutions {
+			entry["keys"] = submission.Keys
+		}
+		leaderboard = append(leaderboard, entry)
+	}
+
+	data := challengeData(challenge)
+	data["solved_by"] = len(best)
+	if own != nil {
+		data["personal_best"] = map[string]interface{}{
+			"keys":       own.Keys,
+			"keystrokes": own.Keystrokes,
+			"rank":       submissionRank(best, own.Keystrokes),
+		}
+	}
+
+	return map[string]interface{}{
+		"success":         true,
+		"challenge":       data,
+		"leaderboard":     leaderboard,
+		"solutions_shown": showSolutions,
+	}, nil
+}
+
+// Submit replays a solution on the start text and records it when it produces the target text
+func (cs *ChallengeService) Submit(playerID uint, challengeID uint, input string) (map[string]interface{}, error) {
+	player, err := findPlayer(cs.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to submit solutions",
+		}, nil
+	}
+
+	challenge, result, err := cs.findChallenge(challengeID)
+	if challenge == nil {
+		return result, err
+	}
+
+	if len(input) > maxSolutionLength {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Solution is too long",
+		}, nil
+	}
+	keys, err := game.ParseKeys(input)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Invalid keys: " + err.Error(),
+		}, nil
+	}
+	if len(keys) == 0 {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Solution is empty",
+		}, nil
+	}
+
+	text, err := game.ReplayKeys(challenge.StartText, keys)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Solution could not be replayed: " + err.Error(),
+		}, nil
+	}
+	if text != challenge.TargetText {
+		return map[string]interface{}{
+			"success":     false,
+			"error":       "Solution does not produce the target text",
+			"result_text": text,
+		}, nil
+	}
+
+	best, err := cs.bestSubmissions(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	previous := playerSubmission(best, player.ID)
+
+	submission := models.ChallengeSubmission{
+		ChallengeID: challenge.ID,
+		PlayerID:    player.ID,
+		Username:    player.Username,
+		Keys:        game.FormatKeys(keys),
+		Keystrokes:  len(keys),
+	}
+	if err := cs.db.Create(&submission).Error; err != nil {
+		return nil, err
+	}
+
+	isPersonalBest := previous == nil || submission.Keystrokes < previous.Keystrokes
+	bestKeystrokes := submission.Keystrokes
+	if !isPersonalBest {
+		bestKeystrokes = previous.Keystrokes
+	}
+
+	return map[string]interface{}{
+		"success":          true,
+		"keys":             submission.Keys,
+		"keystrokes":       submission.Keystrokes,
+		"is_personal_best": isPersonalBest,
+		"rank":             submissionRank(best, bestKeystrokes),
+	}, nil
+}
+
+// findChallenge loads a challenge, returning a not found result when it doesn't exist
+func (cs *ChallengeService) findChallenge(challengeID uint) (*models.Challenge, map[string]interface{}, error) {
+	var challenge models.Challenge
+	if err := cs.db.First(&challenge, challengeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, map[string]interface{}{
+				"success": false,
+				"error":   "Challenge not found",
+			}, nil
+		}
+		return nil, nil, err
+	}
+	return &challenge, nil, nil
+}
+
+// bestSubmissions returns each player's best submission, fewest keystrokes
+// first and ties going to the earlier submission
+func (cs *ChallengeService) bestSubmissions(challengeID uint) ([]models.ChallengeSubmission, error) {
+	var submissions []models.ChallengeSubmission
+	err := cs.db.Where("challenge_id = ?", challengeID).
+		Order("keystrokes ASC").
+		Order("created_at ASC").
+		Order("id ASC").
+		Find(&submissions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	best := make([]models.ChallengeSubmission, 0, len(submissions))
+	for _, submission := range submissions {
+		if !seen[submission.PlayerID] {
+			seen[submission.PlayerID] = true
+			best = append(best, submission)
+		}
+	}
+	return best, nil
+}
+
+// playerSubmission finds the player's entry among the best submissions
+func playerSubmission(best []models.ChallengeSubmission, playerID uint) *models.ChallengeSubmission {
+	for i := range best {
+		if best[i].PlayerID == playerID {
+			return &best[i]
+		}
+	}
+	return nil
+}
+
+// submissionRank is the leaderboard position a solution of that many keystrokes reaches
+func submissionRank(best []models.ChallengeSubmission, keystrokes int) int {
+	rank := 1
+	for _, submission := range best {
+		if submission.Keystrokes < keystrokes {
+			rank++
+		}
+	}
+	return rank
+}
+
+// challengeData describes a challenge for the API
+func challengeData(challenge *models.Challenge) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          challenge.ID,
+		"position":    challenge.Position,
+		"name":        challenge.Name,
+		"title":       challenge.Title,
+		"description": challenge.Description,
+		"start_text":  challenge.StartText,
+		"target_text": challenge.TargetText,
+	}
+}
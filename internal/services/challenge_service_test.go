@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"boba-vim/internal/models"
+)
+
+func TestChallengeSubmit(t *testing.T) {
+	gs := newTestGameService(t)
+	cs := NewChallengeService(gs.db, gs.cfg)
+
+	challenge := models.Challenge{Position: 1, Name: "swap", StartText: "world hello", TargetText: "hello world"}
+	if err := gs.db.Create(&challenge).Error; err != nil {
+		t.Fatal(err)
+	}
+	players := make([]models.Player, 2)
+	for i := range players {
+		players[i] = models.Player{Username: fmt.Sprintf("golfer%d", i+1), Email: fmt.Sprintf("golfer%d@example.com", i+1), IsRegistered: true}
+		if err := gs.db.Create(&players[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		playerID       uint
+		keys           string
+		wantSuccess    bool
+		wantKeystrokes int
+		wantBest       bool
+		wantRank       int
+	}{
+		{"anonymous players can't submit", 0, "dwA <Esc>px", false, 0, false, 0},
+		{"wrong result", players[0].ID, "dwA <Esc>p", false, 0, false, 0},
+		{"keys that don't replay", players[0].ID, "q", false, 0, false, 0},
+		{"first solution", players[0].ID, "cwhello<Esc>wcwworld<Esc>", true, 17, true, 1},
+		{"a second player takes the record", players[1].ID, "dwA <Esc>px", true, 7, true, 1},
+		{"a worse run keeps the personal best", players[1].ID, "xxxxxA world<Esc>0dw", true, 16, false, 1},
+		{"an improvement behind the record", players[0].ID, "dwA <Esc>p$x", true, 8, true, 2},
+		{"tying the record shares first", players[0].ID, "dwA <Esc>pD", true, 7, true, 1},
+	}
+	for _, tt := range tests {
+		result, err := cs.Submit(tt.playerID, challenge.ID, tt.keys)
+		if err != nil {
+			t.Fatalf("%s: Submit: %v", tt.name, err)
+		}
+		if result["success"] != tt.wantSuccess {
+			t.Fatalf("%s: success = %v (%v), want %v", tt.name, result["success"], result["error"], tt.wantSuccess)
+		}
+		if !tt.wantSuccess {
+			continue
+		}
+		if result["keystrokes"] != tt.wantKeystrokes || result["is_personal_best"] != tt.wantBest || result["rank"] != tt.wantRank {
+			t.Errorf("%s: keystrokes %v, personal best %v, rank %v, want %d, %v, %d", tt.name,
+				result["keystrokes"], result["is_personal_best"], result["rank"], tt.wantKeystrokes, tt.wantBest, tt.wantRank)
+		}
+	}
+}
+
+func TestSubmissionRank(t *testing.T) {
+	best := []models.ChallengeSubmission{{Keystrokes: 8}, {Keystrokes: 10}, {Keystrokes: 10}, {Keystrokes: 14}}
+	tests := []struct {
+		keystrokes int
+		want       int
+	}{
+		{5, 1},
+		{8, 1},
+		{9, 2},
+		{10, 2},
+		{12, 4},
+		{20, 5},
+	}
+	for _, tt := range tests {
+		if got := submissionRank(best, tt.keystrokes); got != tt.want {
+			t.Errorf("submissionRank(%d) = %d, want %d", tt.keystrokes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"boba-vim/internal/models"
+	"boba-vim/internal/realtime"
+
+	"gorm.io/gorm"
+)
+
+// Players of a match chat over its websocket. Messages are stored so late
+// joiners get the recent history, and the host of a private room may mute
+// players or kick them out of the room for good.
+
+// blockedWords matches words the chat masks, including their inflections
+var blockedWords = regexp.MustCompile(`(?i)\b(fuck|shit|bitch|bastard|asshole|cunt|piss)\w*`)
+
+// handleChat stores a player's message and sends it to the match
+func (ms *MatchService) handleChat(client *realtime.Client, matchToken string, data json.RawMessage) {
+	var request struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		client.Send("error", map[string]string{"error": "Invalid chat message"})
+		return
+	}
+
+	text := strings.TrimSpace(request.Text)
+	if text == "" {
+		return
+	}
+	if len([]rune(text)) > ms.cfg.ChatMaxLength {
+		client.Send("error", map[string]string{"error": "Message is too long"})
+		return
+	}
+
+	match, entry, err := ms.matchEntry(matchToken, client.PlayerID)
+	if err != nil || entry == nil {
+		client.Send("error", map[string]string{"error": "You are not playing in this match"})
+		return
+	}
+	if entry.Muted {
+		client.Send("error", map[string]string{"error": "The host muted you"})
+		return
+	}
+	if !ms.allowChat(client.PlayerID) {
+		client.Send("error", map[string]string{"error": "You are sending messages too fast"})
+		return
+	}
+
+	message := models.ChatMessage{
+		MatchID:  match.ID,
+		PlayerID: client.PlayerID,
+		Username: entry.Username,
+		Text:     filterChat(text),
+	}
+	if err := ms.db.Create(&message).Error; err != nil {
+		client.Send("error", map[string]string{"error": "Failed to send message"})
+		return
+	}
+
+	ms.broadcast(matchToken, "chat", message)
+}
+
+// allowChat records a message of a player, unless they sent too many recently.
+// Timestamps past the window are dropped for every player, so players who
+// stopped chatting don't keep an entry.
+func (ms *MatchService) allowChat(playerID uint) bool {
+	ms.chatMutex.Lock()
+	defer ms.chatMutex.Unlock()
+
+	now := time.Now()
+	for id, sentTimes := range ms.chatSent {
+		recent := sentTimes[:0]
+		for _, sent := range sentTimes {
+			if now.Sub(sent) < ms.cfg.ChatRateWindow {
+				recent = append(recent, sent)
+			}
+		}
+		if len(recent) == 0 {
+			delete(ms.chatSent, id)
+			continue
+		}
+		ms.chatSent[id] = recent
+	}
+
+	recent := ms.chatSent[playerID]
+	if len(recent) >= ms.cfg.ChatRateLimit {
+		return false
+	}
+	ms.chatSent[playerID] = append(recent, now)
+	return true
+}
+
+// filterChat masks the blocked words of a message
+func filterChat(text string) string {
+	return blockedWords.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	})
+}
+
+// sendChatHistory sends the recent messages of a match to a player who joined it
+func (ms *MatchService) sendChatHistory(client *realtime.Client, match *models.Match) {
+	var messages []models.ChatMessage
+	err := ms.db.Where("match_id = ?", match.ID).
+		Order("id DESC").
+		Limit(ms.cfg.ChatHistory).
+		Find(&messages).Error
+	if err != nil {
+		return
+	}
+
+	// Oldest first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	client.Send("chat_history", map[string]interface{}{"messages": messages})
+}
+
+// handleModeration mutes, unmutes or kicks a player, only the host of a private room may
+func (ms *MatchService) handleModeration(client *realtime.Client, matchToken, action string, data json.RawMessage) {
+	var request struct {
+		PlayerID uint `json:"player_id"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		client.Send("error", map[string]string{"error": "Invalid request format"})
+		return
+	}
+
+	if problem := ms.moderate(client.PlayerID, matchToken, action, request.PlayerID); problem != "" {
+		client.Send("error", map[string]string{"error": problem})
+	}
+}
+
+// moderate applies a host's action and returns the problem when it can't
+func (ms *MatchService) moderate(hostID uint, matchToken, action string, playerID uint) string {
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	match, entry, err := ms.matchEntry(matchToken, playerID)
+	if err != nil {
+		return "Failed to update the room"
+	}
+	if match == nil || !match.IsPrivate() {
+		return "Only private rooms have a host"
+	}
+	if *match.HostID != hostID {
+		return "Only the host can do this"
+	}
+	if playerID == hostID {
+		return "You can't do this to yourself"
+	}
+	if entry == nil {
+		return "Player not found"
+	}
+
+	switch action {
+	case "mute", "unmute":
+		entry.Muted = action == "mute"
+		if err := ms.db.Model(entry).Update("muted", entry.Muted).Error; err != nil {
+			return "Failed to update the room"
+		}
+		ms.broadcast(matchToken, "player_muted", map[string]interface{}{
+			"player_id": entry.PlayerID,
+			"username":  entry.Username,
+			"muted":     entry.Muted,
+		})
+	case "kick":
+		if match.Status == models.MatchStatusFinished || match.Status == models.MatchStatusAbandoned {
+			return "The match is over"
+		}
+		ban := models.RoomBan{MatchID: match.ID, PlayerID: entry.PlayerID}
+		if err := ms.db.Where(ban).FirstOrCreate(&ban).Error; err != nil {
+			return "Failed to update the room"
+		}
+		if err := ms.removePlayer(match, entry); err != nil {
+			return "Failed to update the room"
+		}
+		ms.broadcast(matchToken, "player_kicked", map[string]interface{}{
+			"player_id": entry.PlayerID,
+			"username":  entry.Username,
+		})
+		ms.hub.Disconnect(MatchRoom(matchToken), entry.PlayerID, "kicked", map[string]interface{}{
+			"error": "The host removed you from this room",
+		})
+		if match.Status == models.MatchStatusWaiting {
+			ms.broadcast(matchToken, "match_state", ms.matchState(match))
+		}
+	}
+	return ""
+}
+
+// matchEntry finds a match and a player's seat in it, both nil when they don't exist
+func (ms *MatchService) matchEntry(matchToken string, playerID uint) (*models.Match, *models.MatchPlayer, error) {
+	match, err := ms.findMatch(matchToken)
+	if err != nil || match == nil {
+		return nil, nil, err
+	}
+
+	var entry models.MatchPlayer
+	err = ms.db.Where("match_id = ? AND player_id = ?", match.ID, playerID).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return match, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return match, &entry, nil
+}
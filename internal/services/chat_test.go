@@ -0,0 +1,132 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/models"
+	"boba-vim/internal/realtime"
+)
+
+func TestAllowChat(t *testing.T) {
+	ms := &MatchService{
+		cfg:      &config.Config{ChatRateLimit: 2, ChatRateWindow: 50 * time.Millisecond},
+		chatSent: make(map[uint][]time.Time),
+	}
+
+	for i, want := range []bool{true, true, false} {
+		if got := ms.allowChat(1); got != want {
+			t.Errorf("message %d allowed = %v, want %v", i+1, got, want)
+		}
+	}
+	if !ms.allowChat(2) {
+		t.Error("another player was limited")
+	}
+
+	// Once the window passed the player may chat again and the quiet player's entry is gone
+	time.Sleep(60 * time.Millisecond)
+	if !ms.allowChat(1) {
+		t.Error("player still limited after the window")
+	}
+	if _, kept := ms.chatSent[2]; kept {
+		t.Error("entry of a player who stopped chatting was kept")
+	}
+	if len(ms.chatSent[1]) != 1 {
+		t.Errorf("%d messages recorded, want 1", len(ms.chatSent[1]))
+	}
+}
+
+func TestFilterChat(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"clean message", "good luck, have fun", "good luck, have fun"},
+		{"blocked word", "oh shit", "oh ****"},
+		{"any case", "SHIT happens", "**** happens"},
+		{"inflections", "fucking pearls", "******* pearls"},
+		{"words only match at their start", "mishit", "mishit"},
+		{"several words", "shit, bastards", "****, ********"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterChat(tt.text); got != tt.want {
+				t.Errorf("filterChat(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModerate(t *testing.T) {
+	gs := newTestGameService(t)
+	ms := NewMatchService(gs.db, gs.cfg, gs, realtime.NewHub())
+
+	var players []models.Player
+	for _, username := range []string{"host", "guest", "outsider"} {
+		player := models.Player{Username: username, Email: username + "@example.com", IsRegistered: true}
+		if err := gs.db.Create(&player).Error; err != nil {
+			t.Fatal(err)
+		}
+		players = append(players, player)
+	}
+	host, guest, outsider := players[0].ID, players[1].ID, players[2].ID
+
+	result, err := ms.CreateRoom(host, "", RoomOptions{})
+	if err != nil || result["success"] != true {
+		t.Fatalf("create room: %v %v", err, result)
+	}
+	if result, err := ms.JoinRoom(guest, "", result["join_code"].(string)); err != nil || result["success"] != true {
+		t.Fatalf("join room: %v %v", err, result)
+	}
+	var match models.Match
+	if err := gs.db.First(&match).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hostID   uint
+		action   string
+		playerID uint
+		want     string
+	}{
+		{"someone else than the host", guest, "mute", host, "Only the host can do this"},
+		{"the host on themselves", host, "mute", host, "You can't do this to yourself"},
+		{"a player outside the room", host, "mute", outsider, "Player not found"},
+		{"mute", host, "mute", guest, ""},
+		{"unmute", host, "unmute", guest, ""},
+		{"kick", host, "kick", guest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ms.moderate(tt.hostID, match.MatchToken, tt.action, tt.playerID); got != tt.want {
+				t.Fatalf("moderate = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" || tt.action == "kick" {
+				return
+			}
+			_, entry, err := ms.matchEntry(match.MatchToken, tt.playerID)
+			if err != nil || entry == nil {
+				t.Fatalf("match entry: %v", err)
+			}
+			if entry.Muted != (tt.action == "mute") {
+				t.Errorf("muted = %v after %s", entry.Muted, tt.action)
+			}
+		})
+	}
+
+	// A kicked player leaves the room and can't come back
+	if _, entry, err := ms.matchEntry(match.MatchToken, guest); err != nil || entry != nil {
+		t.Errorf("kicked player still seated: %v", err)
+	}
+	if result, err := ms.JoinRoom(guest, "", match.JoinCode); err != nil || result["success"] != false {
+		t.Errorf("kicked player joined again: %v %v", err, result)
+	}
+
+	// Only private rooms have a host to moderate them
+	if got := ms.moderate(host, "unknown", "mute", guest); got != "Only private rooms have a host" {
+		t.Errorf("moderate on a missing match = %q", got)
+	}
+}
@@ -2,71 +2,110 @@ package services
 
 import (
 	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"boba-vim/internal/config"
 	"boba-vim/internal/game"
 	"boba-vim/internal/models"
 
+	"github.com/mattn/go-sqlite3"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GameService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db     *gorm.DB
+	cfg    *config.Config
+	levels map[string]*game.Level
 }
 
 func NewGameService(db *gorm.DB, cfg *config.Config) *GameService {
+	levels, err := game.LoadLevels(cfg.LevelsDir)
+	if err != nil {
+		log.Printf("Failed to load levels from %s: %v", cfg.LevelsDir, err)
+		levels = map[string]*game.Level{}
+	}
+
 	return &GameService{
-		db:  db,
-		cfg: cfg,
+		db:     db,
+		cfg:    cfg,
+		levels: levels,
 	}
 }
 
-// CreateNewGame creates a new secure game session
-func (gs *GameService) CreateNewGame(username, selectedCharacter string) (map[string]interface{}, error) {
+// GameOptions customises a new game session, zero values fall back to the global config
+type GameOptions struct {
+	Mode            string // empty plays classic
+	Lives           int    // survival only
+	Level           *game.Level
+	CampaignLevelID *uint
+	PearlStrategy   string
+	TargetScore     int
+	TimeLimit       int // seconds
+	PearlPoints     int
+	TextPattern     int // picks the text when there is no level, see game.InitializePatternSession
+	AllowedMotions  []string
+	BlockedMotions  []string
+	Seed            int64 // 0 picks a fresh seed
+	MatchID         *uint
+	GhostSessionID  *uint      // best run raced as a ghost, the game should share its seed and text
+	StartTime       *time.Time // match sessions start when the match clock does
+}
+
+// CreateNewGame creates a new secure game session, on a level's map when levelName is set
+func (gs *GameService) CreateNewGame(playerID uint, selectedCharacter, levelName string) (map[string]interface{}, error) {
+	var opts GameOptions
+	if levelName != "" {
+		level, exists := gs.levels[levelName]
+		if !exists {
+			return nil, errors.New("level not found: " + levelName)
+		}
+		opts.Level = level
+	}
+	return gs.CreateGame(playerID, selectedCharacter, opts)
+}
+
+// CreateGame creates a new secure game session with the given options for
+// the logged-in player, player id 0 plays anonymously
+func (gs *GameService) CreateGame(playerID uint, selectedCharacter string, opts GameOptions) (map[string]interface{}, error) {
 	// Default to 'boba' if no character provided
 	if selectedCharacter == "" {
 		selectedCharacter = "boba"
 	}
+	if opts.PearlStrategy == "" {
+		opts.PearlStrategy = game.PearlStrategyMixed
+	}
+	if opts.Seed == 0 {
+		opts.Seed = game.NewSeed()
+	}
 
 	// Initialize game data
-	gameData := game.InitializeGameSession()
+	var gameData map[string]interface{}
+	if opts.Level != nil {
+		gameData = game.InitializeLevelSession(opts.Level, opts.PearlStrategy, opts.Seed)
+	} else {
+		gameData = game.InitializePatternSession(opts.TextPattern, opts.PearlStrategy, opts.Seed)
+	}
+
+	// Pearl timers run from the start of the game, replays start from it too.
+	// Match sessions get theirs once the match is scheduled.
+	startTime := opts.StartTime
+	if startTime == nil && opts.MatchID == nil {
+		now := time.Now()
+		startTime = &now
+	}
 
-	var gameSession *models.GameSession
-	
 	// Handle anonymous users (store in database with PlayerID = 0)
-	if username == "Anonymous" {
+	if playerID == 0 {
 		// Deactivate any existing anonymous sessions (optional cleanup)
-		gs.db.Model(&models.GameSession{}).
-			Where("player_id = 0 AND is_active = ?", true).
-			Update("is_active", false)
-
-		// Create new game session for anonymous user
-		gameSession = &models.GameSession{
-			PlayerID:          0, // 0 indicates anonymous user
-			SelectedCharacter: selectedCharacter,
-			CurrentScore:      0,
-			CurrentRow:        gameData["player_pos"].(map[string]int)["row"],
-			CurrentCol:        gameData["player_pos"].(map[string]int)["col"],
-			PreferredColumn:   gameData["preferred_column"].(int),
-			TotalMoves:        0,
-			PearlsCollected:   0,
-			IsActive:          true,
-			IsCompleted:       false,
-		}
-
-		// Set game map and text grid
-		gameSession.SetGameMap(gameData["game_map"].([][]int))
-		gameSession.SetTextGrid(gameData["text_grid"].([][]string))
-
-		if err := gs.db.Create(gameSession).Error; err != nil {
-			return nil, err
-		}
+		updateSessions(gs.db.Model(&models.GameSession{}).Where("player_id = 0 AND is_active = ?", true),
+			map[string]interface{}{"is_active": false})
 	} else {
 		// Handle registered users
 		var player models.Player
-		result := gs.db.Where("username = ?", username).First(&player)
+		result := gs.db.First(&player, playerID)
 		if result.Error != nil {
 			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 				// This shouldn't happen for registered users
@@ -77,49 +116,92 @@ func (gs *GameService) CreateNewGame(username, selectedCharacter string) (map[st
 		}
 
 		// Deactivate existing active sessions
-		gs.db.Model(&models.GameSession{}).
-			Where("player_id = ? AND is_active = ?", player.ID, true).
-			Update("is_active", false)
-
-		// Create new game session for registered user
-		gameSession = &models.GameSession{
-			PlayerID:          player.ID,
-			SelectedCharacter: selectedCharacter,
-			CurrentScore:      0,
-			CurrentRow:        gameData["player_pos"].(map[string]int)["row"],
-			CurrentCol:        gameData["player_pos"].(map[string]int)["col"],
-			PreferredColumn:   gameData["preferred_column"].(int),
-			TotalMoves:        0,
-			PearlsCollected:   0,
-			IsActive:          true,
-			IsCompleted:       false,
-		}
-
-		// Set game map and text grid
-		gameSession.SetGameMap(gameData["game_map"].([][]int))
-		gameSession.SetTextGrid(gameData["text_grid"].([][]string))
-
-		if err := gs.db.Create(gameSession).Error; err != nil {
+		if err := abandonSessions(gs.db, player.ID); err != nil {
 			return nil, err
 		}
 	}
 
+	// Create new game session (PlayerID = 0 indicates anonymous user)
+	gameSession := &models.GameSession{
+		PlayerID:          playerID,
+		SelectedCharacter: selectedCharacter,
+		Mode:              opts.Mode,
+		Lives:             opts.Lives,
+		CampaignLevelID:   opts.CampaignLevelID,
+		PearlStrategy:     opts.PearlStrategy,
+		TargetScore:       opts.TargetScore,
+		TimeLimit:         opts.TimeLimit,
+		PearlPoints:       opts.PearlPoints,
+		AllowedMotions:    strings.Join(opts.AllowedMotions, ","),
+		BlockedMotions:    strings.Join(opts.BlockedMotions, ","),
+		Seed:              opts.Seed,
+		TextID:            textID(opts),
+		PearlSpawns:       gameData["pearl_spawns"].(int),
+		MatchID:           opts.MatchID,
+		GhostSessionID:    opts.GhostSessionID,
+		StartTime:         startTime,
+		CurrentScore:      0,
+		CurrentRow:        gameData["player_pos"].(map[string]int)["row"],
+		CurrentCol:        gameData["player_pos"].(map[string]int)["col"],
+		PreferredColumn:   gameData["preferred_column"].(int),
+		TotalMoves:        0,
+		PearlsCollected:   0,
+		IsActive:          true,
+		IsCompleted:       false,
+	}
+
+	// Set game map and text grid
+	gameSession.SetGameMap(gameData["game_map"].([][]int))
+	gameSession.SetTextGrid(gameData["text_grid"].([][]string))
+	timersStart := time.Now()
+	if startTime != nil {
+		timersStart = *startTime
+	}
+	gameSession.SetPearlTimers(gs.initialPearlTimers(gameSession, timersStart))
+	gameSession.SetEnemies(gameData["enemies"].([]game.Enemy))
+
+	if err := gs.db.Create(gameSession).Error; err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"success":       true,
 		"session_token": gameSession.SessionToken,
-		"game_data": map[string]interface{}{
-			"text_grid":          gameData["text_grid"],
-			"game_map":           gameSession.GetGameMap(),
-			"player_pos":         map[string]int{"row": gameSession.CurrentRow, "col": gameSession.CurrentCol},
-			"score":              gameSession.CurrentScore,
-			"is_completed":       gameSession.IsCompleted,
-			"selected_character": gameSession.SelectedCharacter,
-		},
+		"game_data":     gs.gameData(gameSession),
 	}, nil
 }
 
-// ProcessMove processes a move with full concurrency control
+// gameData describes a session for rendering the game page
+func (gs *GameService) gameData(gameSession *models.GameSession) map[string]interface{} {
+	data := map[string]interface{}{
+		"mode":               gameSession.Mode,
+		"text_grid":          gameSession.GetTextGrid(),
+		"game_map":           gameSession.GetGameMap(),
+		"player_pos":         map[string]int{"row": gameSession.CurrentRow, "col": gameSession.CurrentCol},
+		"score":              gameSession.CurrentScore,
+		"target_score":       gs.targetScore(gameSession),
+		"time_limit":         int(gs.timeLimit(gameSession).Seconds()),
+		"pearl_timers":       gameSession.GetPearlTimers(),
+		"enemies":            gameSession.GetEnemies(),
+		"is_completed":       gameSession.IsCompleted,
+		"selected_character": gameSession.SelectedCharacter,
+	}
+	if gameSession.IsTimeAttack() && gameSession.StartTime != nil {
+		data["ends_at"] = gameSession.StartTime.Add(gs.timeLimit(gameSession))
+	}
+	if gameSession.IsSurvival() {
+		data["lives"] = gameSession.Lives
+	}
+	if gameSession.GhostSessionID != nil {
+		data["ghost_session_id"] = *gameSession.GhostSessionID
+	}
+	return data
+}
+
+// ProcessMove processes a move, parallel moves of a session are settled by its
+// version: the first one saved wins and the others fail with models.ErrMoveConflict
 func (gs *GameService) ProcessMove(sessionToken, direction string) (map[string]interface{}, error) {
+	received := time.Now()
 	var gameSession models.GameSession
 	
 	// Get session from database (works for both anonymous and registered users)
@@ -136,139 +218,300 @@ func (gs *GameService) ProcessMove(sessionToken, direction string) (map[string]i
 	// Check if it's an anonymous user (PlayerID = 0)
 	isAnonymous := gameSession.PlayerID == 0
 
-	// Check if game is completed
-	if gameSession.IsCompleted {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "Game already completed",
-		}, nil
-	}
-
-	// Check game time limit
-	if gs.isGameExpired(&gameSession) {
-		gs.expireGame(&gameSession)
-		return map[string]interface{}{
-			"success": false,
-			"error":   "Game expired due to time limit",
-		}, nil
-	}
-
-	// Handle character search directions or convert direction key to direction name
-	var finalDirection string
-	
-	// Check if this is a character search direction
-	if (len(direction) > 17 && direction[:17] == "find_char_forward") ||
-	   (len(direction) > 18 && direction[:18] == "find_char_backward") ||
-	   (len(direction) > 17 && direction[:17] == "till_char_forward") ||
-	   (len(direction) > 18 && direction[:18] == "till_char_backward") {
-		// For character search, use the direction string directly
-		finalDirection = direction
-	} else {
-		// For normal movement, look up in MovementKeys map
-		directionName, exists := game.MovementKeys[direction]
-		if !exists {
-			return map[string]interface{}{
-				"success": false,
-				"error":   "Invalid movement key",
-			}, nil
-		}
-		finalDirection = directionName["direction"].(string)
-	}
-
-	// Calculate new position
-	gameMap := gameSession.GetGameMap()
-	textGrid := gameSession.GetTextGrid()
-	movementResult, err := game.CalculateNewPosition(
-		finalDirection,
-		gameSession.CurrentRow,
-		gameSession.CurrentCol,
-		gameMap,
-		textGrid,
-		gameSession.PreferredColumn,
-	)
-	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}, nil
-	}
-
-	if !movementResult.IsValid {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "Out of bounds",
-		}, nil
+	movementResult, failure := gs.checkMove(&gameSession, direction, gameSession.GetGameMap())
+	if failure != nil {
+		return failure, nil
 	}
 
-	// Check if target position has a pearl
-	pearlCollected := gameMap[movementResult.NewRow][movementResult.NewCol] == game.PEARL
-
 	// Process the move using database transaction (for both anonymous and registered users)
-	err = gs.db.Transaction(func(tx *gorm.DB) error {
+	pearlType := game.EMPTY
+	points := 0
+	caught := false
+	outOfLives := false
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
 		// Reload session in transaction to ensure fresh state
 		var txGameSession models.GameSession
 		if err := tx.Where("session_token = ?", sessionToken).First(&txGameSession).Error; err != nil {
 			return err
 		}
+		// The server may have ended the game since it was loaded, e.g. a time attack's clock
+		if !txGameSession.IsActive {
+			return errors.New("Invalid or expired game session")
+		}
+		// Another move was saved since this one was calculated
+		if txGameSession.Version != gameSession.Version {
+			return models.ErrMoveConflict
+		}
+
+		// Remove pearls whose timer ran out before checking the target cell
+		now := time.Now()
+		expired := gs.expirePearls(&txGameSession, now)
+		if gs.loseLives(tx, &txGameSession, expired) {
+			// The last life went before the move could be made
+			outOfLives = true
+			gameSession = txGameSession
+			return saveVersioned(tx, &txGameSession)
+		}
+
+		// Check if target position has a pearl or an enemy
+		target := txGameSession.GetGameMap()[movementResult.NewRow][movementResult.NewCol]
+		if game.IsPearl(target) {
+			pearlType = target
+			points = gs.pearlValue(&txGameSession, target)
+		}
+		caught = target == game.ENEMY
 
 		// Process move with concurrency control
+		fromRow, fromCol := txGameSession.CurrentRow, txGameSession.CurrentCol
 		err := txGameSession.ProcessMove(
 			movementResult.NewRow,
 			movementResult.NewCol,
 			movementResult.PreferredColumn,
-			pearlCollected,
-			gs.cfg.PearlPoints,
+			pearlType,
+			points,
+			now,
 		)
 		if err != nil {
 			return err
 		}
 
-		// Update game map
-		updatedMap := txGameSession.GetGameMap()
-		if pearlCollected {
-			game.PlaceNewPearl(updatedMap, movementResult.NewRow, movementResult.NewCol)
-			txGameSession.SetGameMap(updatedMap)
-		}
+		caught = gs.settleMove(&txGameSession, pearlType, caught, now)
 
-		// Check if game should be completed
-		if txGameSession.CurrentScore >= gs.cfg.TargetScore {
+		// Check if game should end or be completed
+		if caught {
+			txGameSession.FailGame(models.EndReasonCaught)
+		} else if !txGameSession.CountsPearls() && txGameSession.CurrentScore >= gs.targetScore(&txGameSession) {
 			txGameSession.CompleteGame()
-			// Update player stats only for registered users
+			// Update player stats and campaign progress only for registered users
 			if !isAnonymous {
 				gs.updatePlayerStats(tx, txGameSession.PlayerID, &txGameSession)
+				if err := recordLevelCompletion(tx, &txGameSession); err != nil {
+					return err
+				}
+			}
+		}
+		// A finished campaign session counts as an attempt on its level
+		if !txGameSession.IsActive {
+			if err := recordLevelAttempt(tx, &txGameSession); err != nil {
+				return err
 			}
 		}
 
 		// Validate score integrity
-		if !txGameSession.ValidateScoreIntegrity(gs.cfg.PearlPoints) {
+		regular, golden, black := gs.pearlValues(&txGameSession)
+		if !txGameSession.ValidateScoreIntegrity(regular, golden, black) {
 			return errors.New("score integrity validation failed")
 		}
 
-		// Save the session and update our local copy
+		// Save the session unless a parallel move beat us to it, and update our local copy
+		if err := saveVersioned(tx, &txGameSession); err != nil {
+			return err
+		}
 		gameSession = txGameSession
-		return tx.Save(&txGameSession).Error
+		return logMove(tx, &txGameSession, direction, fromRow, fromCol, pearlType, points, received)
 	})
 	
+	if err = asMoveConflict(err); err != nil {
+		response := map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		}
+		// Conflicts and moves that came too fast can be sent again
+		var gameErr *models.GameError
+		if errors.As(err, &gameErr) {
+			response["error_code"] = gameErr.Code
+		}
+		return response, nil
+	}
+	if outOfLives {
+		response := gs.moveResponse(&gameSession, game.EMPTY, 0, false)
+		response["success"] = false
+		response["error"] = "Out of lives"
+		return response, nil
+	}
+
+	return gs.moveResponse(&gameSession, pearlType, points, caught), nil
+}
+
+// saveVersioned saves a session only if it is still at the version it was
+// loaded with, bumping the version. A request that saved it in the meantime
+// makes it fail with models.ErrMoveConflict.
+func saveVersioned(tx *gorm.DB, gameSession *models.GameSession) error {
+	loaded := gameSession.Version
+	gameSession.Version++
+	result := tx.Model(gameSession).
+		Where("version = ?", loaded).
+		Select("*").
+		Omit(clause.Associations).
+		Updates(gameSession)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = models.ErrMoveConflict
+	}
+	if result.Error != nil {
+		gameSession.Version = loaded
+	}
+	return result.Error
+}
+
+// versionedAttempts is how often a server-side write of sessions is tried when
+// moves keep saving them in the meantime
+const versionedAttempts = 3
+
+// retryVersioned runs write in a transaction, again while a parallel save makes
+// it fail with models.ErrMoveConflict. write loads the sessions it saves inside
+// the transaction, so every attempt works on what the last save left.
+func retryVersioned(db *gorm.DB, write func(tx *gorm.DB) error) error {
+	err := asMoveConflict(db.Transaction(write))
+	for attempt := 1; attempt < versionedAttempts && errors.Is(err, models.ErrMoveConflict); attempt++ {
+		err = asMoveConflict(db.Transaction(write))
+	}
+	return err
+}
+
+// updateSessions sets columns of the sessions query matches and bumps their
+// version, so a move saved on an older copy fails instead of undoing the update
+func updateSessions(query *gorm.DB, columns map[string]interface{}) error {
+	columns["version"] = gorm.Expr("version + 1")
+	return query.Updates(columns).Error
+}
+
+// asMoveConflict turns SQLite's busy error, reported when a parallel move
+// holds the database, into models.ErrMoveConflict
+func asMoveConflict(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy {
+		return models.ErrMoveConflict
+	}
+	return err
+}
+
+// checkMove validates a move of the session on gameMap and calculates where it
+// lands, returning the failure response when the move can't be made
+func (gs *GameService) checkMove(gameSession *models.GameSession, direction string, gameMap [][]int) (*game.MovementResult, map[string]interface{}) {
+	// Check if game is completed
+	if gameSession.IsCompleted {
+		return nil, map[string]interface{}{
+			"success": false,
+			"error":   "Game already completed",
+		}
+	}
+
+	// Match sessions wait for the match clock
+	if !gameSession.HasStarted(time.Now()) {
+		return nil, map[string]interface{}{
+			"success":    false,
+			"error":      models.ErrGameNotStarted.Message,
+			"error_code": models.ErrGameNotStarted.Code,
+		}
+	}
+
+	// Check game time limit, a time attack is over rather than failed
+	if gs.isGameExpired(gameSession) && gameSession.IsTimeAttack() {
+		if finished, err := gs.finishTimeAttack(gameSession.SessionToken); err == nil {
+			gameSession = finished
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to finish time attack %d: %v", gameSession.ID, err)
+		}
+		return nil, map[string]interface{}{
+			"success":     false,
+			"error":       "Time is up",
+			"final_score": gameSession.FinalScore,
+		}
+	}
+	if gs.isGameExpired(gameSession) {
+		gs.expireGame(gameSession)
+		return nil, map[string]interface{}{
+			"success": false,
+			"error":   "Game expired due to time limit",
+		}
+	}
+
+	return gs.calculateMove(gameSession, direction, gameMap)
+}
+
+// calculateMove checks a move against the session's rules and calculates
+// where it lands on gameMap, returning the failure response when it can't be made
+func (gs *GameService) calculateMove(gameSession *models.GameSession, direction string, gameMap [][]int) (*game.MovementResult, map[string]interface{}) {
+	// Enforce the level's motion restrictions before calculating anything
+	if restrictionErr := checkMotionRestrictions(gameSession, direction); restrictionErr != nil {
+		return nil, map[string]interface{}{
+			"success":    false,
+			"error":      restrictionErr.Message,
+			"error_code": restrictionErr.Code,
+		}
+	}
+
+	// Handle character search directions or convert direction key to direction name
+	finalDirection, exists := game.ResolveDirection(direction)
+	if !exists {
+		return nil, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid movement key",
+		}
+	}
+
+	// Calculate new position
+	movementResult, err := game.CalculateNewPosition(
+		finalDirection,
+		gameSession.CurrentRow,
+		gameSession.CurrentCol,
+		gameMap,
+		gameSession.GetTextGrid(),
+		gameSession.PreferredColumn,
+	)
 	if err != nil {
-		return map[string]interface{}{
+		return nil, map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
-		}, nil
+		}
 	}
 
-	return map[string]interface{}{
-		"success": true,
+	if movementResult.BlockedByWall {
+		return nil, map[string]interface{}{
+			"success": false,
+			"error":   "Blocked by wall",
+		}
+	}
+
+	if !movementResult.IsValid {
+		return nil, map[string]interface{}{
+			"success": false,
+			"error":   "Out of bounds",
+		}
+	}
+
+	return movementResult, nil
+}
+
+// moveResponse describes the session after an accepted move
+func (gs *GameService) moveResponse(gameSession *models.GameSession, pearlType, points int, caught bool) map[string]interface{} {
+	response := map[string]interface{}{
+		"success":  true,
+		"mode":     gameSession.Mode,
 		"game_map": gameSession.GetGameMap(),
 		"player_pos": map[string]int{
 			"row": gameSession.CurrentRow,
 			"col": gameSession.CurrentCol,
 		},
 		"score":           gameSession.CurrentScore,
-		"pearl_collected": pearlCollected,
+		"pearl_collected": game.IsPearl(pearlType),
+		"pearl_type":      pearlType,
+		"points_delta":    points,
+		"pearl_timers":    gameSession.GetPearlTimers(),
+		"enemies":         gameSession.GetEnemies(),
+		"caught":          caught,
+		"game_over":       !gameSession.IsActive,
+		"end_reason":      gameSession.EndReason,
 		"is_completed":    gameSession.IsCompleted,
 		"completion_time": gameSession.CompletionTime,
 		"final_score":     gameSession.FinalScore,
-	}, nil
+		"match_id":        gameSession.MatchID,
+		"game_id":         gameSession.ID,
+	}
+	if gameSession.IsSurvival() {
+		response["lives"] = gameSession.Lives
+	}
+	return response
 }
 
 // GetGameState returns current game state
@@ -286,7 +529,7 @@ func (gs *GameService) GetGameState(sessionToken string) (map[string]interface{}
 		return nil, err
 	}
 
-	return map[string]interface{}{
+	state := map[string]interface{}{
 		"success": true,
 		"game_map": gameSession.GetGameMap(),
 		"player_pos": map[string]int{
@@ -294,24 +537,82 @@ func (gs *GameService) GetGameState(sessionToken string) (map[string]interface{}
 			"col": gameSession.CurrentCol,
 		},
 		"score":            gameSession.CurrentScore,
+		"target_score":     gs.targetScore(&gameSession),
 		"is_completed":     gameSession.IsCompleted,
 		"completion_time":  gameSession.CompletionTime,
 		"final_score":      gameSession.FinalScore,
 		"pearls_collected": gameSession.PearlsCollected,
+		"pearl_timers":     gameSession.GetPearlTimers(),
+		"enemies":          gameSession.GetEnemies(),
 		"total_moves":      gameSession.TotalMoves,
-	}, nil
+		"match_id":         gameSession.MatchID,
+		"start_time":       gameSession.StartTime,
+		"mode":             gameSession.Mode,
+		"time_limit":       int(gs.timeLimit(&gameSession).Seconds()),
+	}
+	if gameSession.IsSurvival() {
+		state["lives"] = gameSession.Lives
+	}
+	if gameSession.GhostSessionID != nil {
+		ghost, err := gs.ghostFeed(&gameSession, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		state["ghost"] = ghost
+	}
+	return state, nil
+}
+
+// GetAvailableMovements returns the movement keys usable in the session,
+// every key when there is no session or it has no motion restrictions
+func (gs *GameService) GetAvailableMovements(sessionToken string) ([]map[string]interface{}, bool, error) {
+	movements := game.GetAvailableMovements()
+	if sessionToken == "" {
+		return movements, false, nil
+	}
+
+	var gameSession models.GameSession
+	err := gs.db.Where("session_token = ? AND is_active = ?", sessionToken, true).First(&gameSession).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return movements, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	if gameSession.AllowedMotions == "" && gameSession.BlockedMotions == "" {
+		return movements, false, nil
+	}
+
+	allowed := splitMotions(gameSession.AllowedMotions)
+	blocked := splitMotions(gameSession.BlockedMotions)
+	var available []map[string]interface{}
+	for _, movement := range movements {
+		if game.IsMotionAllowed(movement["key"].(string), allowed, blocked) {
+			available = append(available, movement)
+		}
+	}
+	return available, true, nil
 }
 
 // GetLeaderboard returns leaderboard data
 func (gs *GameService) GetLeaderboard(boardType string, limit int) (map[string]interface{}, error) {
+	if boardType == "rating" {
+		return gs.ratingLeaderboard(limit)
+	}
+
+	// Games are listed once replaying them reproduced their score and
+	// the anti-cheat checks didn't hold them back, see StartReplayVerifier
 	var sessions []models.GameSession
-	query := gs.db.Preload("Player").Where("is_completed = ? AND player_id > 0", true) // Exclude anonymous sessions
+	query := gs.leaderboardListed().Preload("Player")
 
-	if boardType == "score" {
-		query = query.Order("final_score DESC")
+	if boardType == models.GameModeTimeAttack || boardType == models.GameModeSurvival {
+		// Most pearls first, ties go to the earlier run
+		query = query.Where("mode = ?", boardType).Order("final_score DESC").Order("end_time ASC")
+	} else if boardType == "score" {
+		query = query.Where("mode = ?", models.GameModeClassic).Order("final_score DESC")
 	} else {
 		// Default to time-based leaderboard (fastest times first)
-		query = query.Where("completion_time IS NOT NULL").Order("completion_time ASC")
+		query = query.Where("mode = ?", models.GameModeClassic).Where("completion_time IS NOT NULL").Order("completion_time ASC")
 	}
 
 	if err := query.Limit(limit).Find(&sessions).Error; err != nil {
@@ -327,6 +628,11 @@ func (gs *GameService) GetLeaderboard(boardType string, limit int) (map[string]i
 			"completion_time":  session.CompletionTime,
 			"total_moves":      session.TotalMoves,
 			"pearls_collected": session.PearlsCollected,
+			"game_id":          session.ID,
+			"replay_status":    session.ReplayStatus,
+		}
+		if session.ReplayStatus == models.ReplayVerified {
+			entry["replay_url"] = ReplayURL(session.ID)
 		}
 		if session.EndTime != nil {
 			entry["completed_at"] = session.EndTime.Format(time.RFC3339)
@@ -341,19 +647,286 @@ func (gs *GameService) GetLeaderboard(boardType string, limit int) (map[string]i
 	}, nil
 }
 
+// ratingLeaderboard ranks players by online rating, provisional ratings are left out
+func (gs *GameService) ratingLeaderboard(limit int) (map[string]interface{}, error) {
+	var players []models.Player
+	err := gs.db.Where("is_registered = ? AND rated_matches >= ?", true, gs.cfg.RatingProvisional).
+		Order("rating DESC").
+		Order("rated_matches DESC").
+		Limit(limit).
+		Find(&players).Error
+	if err != nil {
+		return nil, err
+	}
+
+	leaderboard := make([]map[string]interface{}, 0, len(players))
+	for i, player := range players {
+		leaderboard = append(leaderboard, map[string]interface{}{
+			"rank":          i + 1,
+			"username":      player.Username,
+			"rating":        player.Rating,
+			"rated_matches": player.RatedMatches,
+		})
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"leaderboard": leaderboard,
+		"type":        "rating",
+	}, nil
+}
+
 // Helper methods
 func (gs *GameService) isGameExpired(gameSession *models.GameSession) bool {
 	if gameSession.StartTime == nil {
 		return false
 	}
-	return time.Since(*gameSession.StartTime) > gs.cfg.MaxGameTime
+	return time.Since(*gameSession.StartTime) > gs.timeLimit(gameSession)
+}
+
+// checkMotionRestrictions returns the error for a motion the session's level does not allow
+func checkMotionRestrictions(gameSession *models.GameSession, direction string) *models.GameError {
+	key := game.MotionKey(direction)
+	if !game.IsMotionAllowed(key, nil, splitMotions(gameSession.BlockedMotions)) {
+		return models.ErrMotionBlocked
+	}
+	if !game.IsMotionAllowed(key, splitMotions(gameSession.AllowedMotions), nil) {
+		return models.ErrMotionNotAllowed
+	}
+	return nil
+}
+
+// targetScore returns the score that completes the session
+func (gs *GameService) targetScore(gameSession *models.GameSession) int {
+	if gameSession.TargetScore > 0 {
+		return gameSession.TargetScore
+	}
+	return gs.cfg.TargetScore
+}
+
+// timeLimit returns how long the session may last
+func (gs *GameService) timeLimit(gameSession *models.GameSession) time.Duration {
+	if gameSession.TimeLimit > 0 {
+		return time.Duration(gameSession.TimeLimit) * time.Second
+	}
+	return gs.cfg.MaxGameTime
 }
 
+// expireGame ends a session that ran past its time limit
 func (gs *GameService) expireGame(gameSession *models.GameSession) {
-	gameSession.IsActive = false
-	now := time.Now()
-	gameSession.EndTime = &now
-	gs.db.Save(gameSession)
+	err := retryVersioned(gs.db, func(tx *gorm.DB) error {
+		var txGameSession models.GameSession
+		if err := tx.First(&txGameSession, gameSession.ID).Error; err != nil {
+			return err
+		}
+		// A move may have ended the game since it was loaded
+		if !txGameSession.IsActive {
+			*gameSession = txGameSession
+			return nil
+		}
+		txGameSession.FailGame(models.EndReasonExpired)
+		if err := saveVersioned(tx, &txGameSession); err != nil {
+			return err
+		}
+		*gameSession = txGameSession
+		return recordLevelAttempt(tx, &txGameSession)
+	})
+	if err != nil {
+		log.Printf("Failed to expire game %d: %v", gameSession.ID, err)
+	}
+}
+
+// settleMove replaces the pearl a move collected, keeping the same number on
+// the map, and lets the enemies chase the player. It reports whether the player
+// is caught, caught tells whether the move itself ran into an enemy.
+func (gs *GameService) settleMove(gameSession *models.GameSession, pearlType int, caught bool, now time.Time) bool {
+	if game.IsPearl(pearlType) {
+		timers := removePearlTimer(gameSession.GetPearlTimers(), gameSession.CurrentRow, gameSession.CurrentCol)
+		updatedMap := gameSession.GetGameMap()
+		row, col, newType := game.PlaceNewPearl(updatedMap, gameSession.CurrentRow, gameSession.CurrentCol, gameSession.PearlStrategy, gameSession.NextPearlRNG())
+		if timer, ok := gs.pearlTimer(gameSession, row, col, newType, now); ok {
+			timers = append(timers, timer)
+		}
+		gameSession.SetGameMap(updatedMap)
+		gameSession.SetPearlTimers(timers)
+	}
+
+	// Let the enemies chase the player every few moves
+	if !caught {
+		caught = gs.moveEnemies(gameSession)
+	}
+	return caught
+}
+
+// moveEnemies steps the enemies towards the player once every EnemyMoveEvery moves
+// and reports whether the player got caught. Driving the enemies from the move count
+// instead of wall-clock time keeps a session's outcome reproducible from its moves.
+func (gs *GameService) moveEnemies(gameSession *models.GameSession) bool {
+	enemies := gameSession.GetEnemies()
+	if len(enemies) == 0 || gs.cfg.EnemyMoveEvery <= 0 || gameSession.TotalMoves%gs.cfg.EnemyMoveEvery != 0 {
+		return false
+	}
+
+	gameMap := gameSession.GetGameMap()
+	caught := game.MoveEnemies(gameMap, enemies, gameSession.CurrentRow, gameSession.CurrentCol)
+	gameSession.SetGameMap(gameMap)
+	gameSession.SetEnemies(enemies)
+	return caught
+}
+
+// pearlPoints returns what a regular pearl is worth in the session
+func (gs *GameService) pearlPoints(gameSession *models.GameSession) int {
+	if gameSession.PearlPoints > 0 {
+		return gameSession.PearlPoints
+	}
+	return gs.cfg.PearlPoints
+}
+
+// pearlValues returns what a regular and a golden pearl are worth in the
+// session and what a black pearl takes away. Time attack and survival count
+// pearls, and a black pearl there is worth nothing instead of costing a point.
+func (gs *GameService) pearlValues(gameSession *models.GameSession) (regular, golden, black int) {
+	if gameSession.CountsPearls() {
+		return 1, 1, 0
+	}
+	return gs.pearlPoints(gameSession), gs.cfg.GoldenPearlPoints, gs.cfg.BlackPearlPenalty
+}
+
+// pearlValue returns the score change granted by collecting a pearl of the given type
+func (gs *GameService) pearlValue(gameSession *models.GameSession, pearlType int) int {
+	regular, golden, black := gs.pearlValues(gameSession)
+	switch pearlType {
+	case game.GOLDEN_PEARL:
+		return golden
+	case game.BLACK_PEARL:
+		return -black
+	default:
+		return regular
+	}
+}
+
+// initialPearlTimers starts expiry timers for the pearls on a freshly created map
+func (gs *GameService) initialPearlTimers(gameSession *models.GameSession, start time.Time) []models.PearlTimer {
+	timers := []models.PearlTimer{}
+	for rowIdx, row := range gameSession.GetGameMap() {
+		for colIdx, value := range row {
+			if timer, ok := gs.pearlTimer(gameSession, rowIdx, colIdx, value, start); ok {
+				timers = append(timers, timer)
+			}
+		}
+	}
+	return timers
+}
+
+// pearlTimer returns the expiry timer of a pearl placed at now, ok is false
+// for pearls that stay. Golden pearls expire, and in survival every pearl does.
+func (gs *GameService) pearlTimer(gameSession *models.GameSession, row, col, pearlType int, now time.Time) (models.PearlTimer, bool) {
+	var lifetime time.Duration
+	switch {
+	case !game.IsPearl(pearlType):
+		return models.PearlTimer{}, false
+	case gameSession.IsSurvival():
+		lifetime = gs.survivalPearlLife(gameSession)
+	case pearlType == game.GOLDEN_PEARL:
+		lifetime = gs.cfg.GoldenPearlLifetime
+	default:
+		return models.PearlTimer{}, false
+	}
+	return models.PearlTimer{Row: row, Col: col, ExpiresAt: now.Add(lifetime)}, true
+}
+
+// expirePearls removes pearls past their timer and replaces them with new
+// pearls, returning how many expired. Pearls go in the order their timers ran
+// out and a new pearl's countdown starts when the one it replaces ran out, not
+// when the expiry is noticed, so a late timer or a server restart expires the
+// same pearls a replay does, including the new pearls that ran out meanwhile.
+func (gs *GameService) expirePearls(gameSession *models.GameSession, now time.Time) int {
+	timers := gameSession.GetPearlTimers()
+	if len(timers) == 0 {
+		return 0
+	}
+
+	gameMap := gameSession.GetGameMap()
+	expired := 0
+	for {
+		// The first timer that ran out, a pearl hidden under an enemy waits for it to move on
+		next := -1
+		for i, timer := range timers {
+			if now.Before(timer.ExpiresAt) || gameMap[timer.Row][timer.Col] == game.ENEMY {
+				continue
+			}
+			if next < 0 || timer.ExpiresAt.Before(timers[next].ExpiresAt) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		timer := timers[next]
+		timers = append(timers[:next:next], timers[next+1:]...)
+		if !game.IsPearl(gameMap[timer.Row][timer.Col]) {
+			continue
+		}
+		gameMap[timer.Row][timer.Col] = game.EMPTY
+		expired++
+		row, col, newType := game.PlaceNewPearl(gameMap, gameSession.CurrentRow, gameSession.CurrentCol, gameSession.PearlStrategy, gameSession.NextPearlRNG())
+		if newTimer, ok := gs.pearlTimer(gameSession, row, col, newType, timer.ExpiresAt); ok {
+			timers = append(timers, newTimer)
+		}
+	}
+
+	gameSession.SetGameMap(gameMap)
+	gameSession.SetPearlTimers(timers)
+	return expired
+}
+
+// ScheduleMatchStart sets the start time of every session in a match and
+// restarts their golden pearl timers from it
+func (gs *GameService) ScheduleMatchStart(tx *gorm.DB, matchID uint, startsAt time.Time) error {
+	var gameSessions []*models.GameSession
+	if err := tx.Where("match_id = ? AND is_active = ?", matchID, true).Find(&gameSessions).Error; err != nil {
+		return err
+	}
+
+	for _, gameSession := range gameSessions {
+		gameSession.StartTime = &startsAt
+		gameSession.SetPearlTimers(gs.initialPearlTimers(gameSession, startsAt))
+		if err := saveVersioned(tx, gameSession); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logMove adds a move that was just applied to the session to its move log
+func logMove(tx *gorm.DB, gameSession *models.GameSession, direction string, fromRow, fromCol, pearlType, points int, received time.Time) error {
+	move := models.GameMove{
+		GameSessionID: gameSession.ID,
+		Sequence:      gameSession.TotalMoves,
+		Key:           game.MotionKey(direction),
+		Direction:     direction,
+		FromRow:       fromRow,
+		FromCol:       fromCol,
+		ToRow:         gameSession.CurrentRow,
+		ToCol:         gameSession.CurrentCol,
+		PearlType:     pearlType,
+		Points:        points,
+		LatencyMicros: time.Since(received).Microseconds(),
+		PlayedAt:      *gameSession.LastMoveTime,
+	}
+	return tx.Create(&move).Error
+}
+
+// removePearlTimer drops the timer attached to a cell, if any
+func removePearlTimer(timers []models.PearlTimer, row, col int) []models.PearlTimer {
+	remaining := []models.PearlTimer{}
+	for _, timer := range timers {
+		if timer.Row != row || timer.Col != col {
+			remaining = append(remaining, timer)
+		}
+	}
+	return remaining
 }
 
 func (gs *GameService) updatePlayerStats(tx *gorm.DB, playerID uint, gameSession *models.GameSession) {
@@ -363,6 +936,9 @@ func (gs *GameService) updatePlayerStats(tx *gorm.DB, playerID uint, gameSession
 	player.TotalGames++
 	if gameSession.IsCompleted {
 		player.CompletedGames++
+	}
+	// Time attack and survival scores count pearls, they don't compare with classic bests
+	if gameSession.IsCompleted && !gameSession.CountsPearls() {
 		if gameSession.FinalScore != nil && *gameSession.FinalScore > player.BestScore {
 			player.BestScore = *gameSession.FinalScore
 		}
@@ -370,7 +946,7 @@ func (gs *GameService) updatePlayerStats(tx *gorm.DB, playerID uint, gameSession
 			player.FastestTime = gameSession.CompletionTime
 		}
 	}
-	player.TotalPearls += gameSession.PearlsCollected
+	player.TotalPearls += gameSession.PearlsCollected + gameSession.GoldenPearlsCollected
 	player.TotalMoves += gameSession.TotalMoves
 
 	tx.Save(&player)
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/database"
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestGameService returns a game service on a fresh SQLite database
+func newTestGameService(t *testing.T) *GameService {
+	t.Helper()
+	db, err := database.Initialize(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("initialize database: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	return NewGameService(db, config.Load())
+}
+
+// startTestGame creates an anonymous classic game and returns its session token
+func startTestGame(t *testing.T, gs *GameService) string {
+	t.Helper()
+	return startTestGameWith(t, gs, GameOptions{TextPattern: 1, Seed: 42})
+}
+
+// startTestGameWith creates an anonymous game with opts and returns its session token
+func startTestGameWith(t *testing.T, gs *GameService, opts GameOptions) string {
+	t.Helper()
+	result, err := gs.CreateGame(0, "", opts)
+	if err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+	return result["session_token"].(string)
+}
+
+// loadTestGame loads a game session by its token
+func loadTestGame(t *testing.T, gs *GameService, sessionToken string) *models.GameSession {
+	t.Helper()
+	var gameSession models.GameSession
+	if err := gs.db.Where("session_token = ?", sessionToken).First(&gameSession).Error; err != nil {
+		t.Fatalf("load game: %v", err)
+	}
+	return &gameSession
+}
+
+// openDirection returns a movement key the session can move with right now
+func openDirection(t *testing.T, gs *GameService, gameSession *models.GameSession) string {
+	t.Helper()
+	for _, direction := range []string{"l", "j", "h", "k"} {
+		if _, failure := gs.calculateMove(gameSession, direction, gameSession.GetGameMap()); failure == nil {
+			return direction
+		}
+	}
+	t.Fatalf("no move possible from %d,%d", gameSession.CurrentRow, gameSession.CurrentCol)
+	return ""
+}
+
+func TestSaveVersionedRejectsStaleSession(t *testing.T) {
+	gs := newTestGameService(t)
+	sessionToken := startTestGame(t, gs)
+
+	first := loadTestGame(t, gs, sessionToken)
+	second := loadTestGame(t, gs, sessionToken)
+
+	first.CurrentScore = 100
+	if err := saveVersioned(gs.db, first); err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	second.CurrentScore = 200
+	if err := saveVersioned(gs.db, second); !errors.Is(err, models.ErrMoveConflict) {
+		t.Fatalf("stale save returned %v, want %v", err, models.ErrMoveConflict)
+	}
+	if second.Version != first.Version-1 {
+		t.Errorf("failed save left version %d, want %d", second.Version, first.Version-1)
+	}
+
+	saved := loadTestGame(t, gs, sessionToken)
+	if saved.CurrentScore != 100 || saved.Version != first.Version {
+		t.Errorf("saved score %d at version %d, want 100 at version %d", saved.CurrentScore, saved.Version, first.Version)
+	}
+}
+
+func TestServerWritesKeepParallelMoves(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GameOptions
+		end     func(gs *GameService, stale *models.GameSession) error
+		wantEnd string
+	}{
+		{"expired game", GameOptions{TextPattern: 1, Seed: 42}, func(gs *GameService, stale *models.GameSession) error {
+			gs.expireGame(stale)
+			return nil
+		}, models.EndReasonExpired},
+		{"finished time attack", GameOptions{Mode: models.GameModeTimeAttack, TimeLimit: 60, TextPattern: 2, Seed: 7}, func(gs *GameService, stale *models.GameSession) error {
+			_, err := gs.finishTimeAttack(stale.SessionToken)
+			return err
+		}, models.EndReasonTimeUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := newTestGameService(t)
+			sessionToken := startTestGameWith(t, gs, tt.opts)
+
+			// The server loaded the session before the player's move was saved
+			stale := loadTestGame(t, gs, sessionToken)
+			result, err := gs.ProcessMove(sessionToken, openDirection(t, gs, stale))
+			if err != nil || !result["success"].(bool) {
+				t.Fatalf("move: %v %v", err, result["error"])
+			}
+			if err := tt.end(gs, stale); err != nil {
+				t.Fatalf("end game: %v", err)
+			}
+
+			gameSession := loadTestGame(t, gs, sessionToken)
+			if gameSession.IsActive || gameSession.EndReason != tt.wantEnd {
+				t.Errorf("session active %v ended by %q, want ended by %q", gameSession.IsActive, gameSession.EndReason, tt.wantEnd)
+			}
+			if gameSession.TotalMoves != 1 || gameSession.Version != 2 {
+				t.Errorf("session counts %d moves at version %d, want 1 at version 2", gameSession.TotalMoves, gameSession.Version)
+			}
+		})
+	}
+}
+
+func TestRetryVersionedReloadsAfterConflict(t *testing.T) {
+	gs := newTestGameService(t)
+	sessionToken := startTestGame(t, gs)
+	stale := loadTestGame(t, gs, sessionToken)
+	if err := saveVersioned(gs.db, loadTestGame(t, gs, sessionToken)); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := 0
+	err := retryVersioned(gs.db, func(tx *gorm.DB) error {
+		attempts++
+		gameSession := *stale
+		if attempts > 1 {
+			if err := tx.First(&gameSession, stale.ID).Error; err != nil {
+				return err
+			}
+		}
+		gameSession.CurrentScore = 300
+		return saveVersioned(tx, &gameSession)
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("retryVersioned returned %v after %d attempts, want success after 2", err, attempts)
+	}
+
+	err = retryVersioned(gs.db, func(tx *gorm.DB) error {
+		return saveVersioned(tx, stale)
+	})
+	if !errors.Is(err, models.ErrMoveConflict) {
+		t.Errorf("stale writes returned %v, want %v", err, models.ErrMoveConflict)
+	}
+	if saved := loadTestGame(t, gs, sessionToken); saved.CurrentScore != 300 || saved.Version != 2 {
+		t.Errorf("saved score %d at version %d, want 300 at version 2", saved.CurrentScore, saved.Version)
+	}
+}
+
+func TestParallelMovesAreAppliedOnce(t *testing.T) {
+	const rounds = 5
+	const parallel = 8
+
+	gs := newTestGameService(t)
+	sessionToken := startTestGame(t, gs)
+
+	accepted := 0
+	for round := 0; round < rounds; round++ {
+		direction := openDirection(t, gs, loadTestGame(t, gs, sessionToken))
+
+		results := make([]map[string]interface{}, parallel)
+		errs := make([]error, parallel)
+		var start, done sync.WaitGroup
+		start.Add(1)
+		for i := 0; i < parallel; i++ {
+			done.Add(1)
+			go func(i int) {
+				defer done.Done()
+				start.Wait()
+				// Conflicting moves are sent again like the client does
+				for attempt := 0; attempt < 5; attempt++ {
+					results[i], errs[i] = gs.ProcessMove(sessionToken, direction)
+					if errs[i] != nil || results[i]["error_code"] != models.ErrMoveConflict.Code {
+						return
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}(i)
+		}
+		start.Done()
+		done.Wait()
+
+		roundAccepted := 0
+		for i, result := range results {
+			if errs[i] != nil {
+				t.Fatalf("round %d: move failed: %v", round, errs[i])
+			}
+			if result["success"].(bool) {
+				roundAccepted++
+				continue
+			}
+			// Losers either raced the saved move or came in right after it
+			code := result["error_code"]
+			if code != models.ErrMoveConflict.Code && code != models.ErrMoveTooFast.Code {
+				t.Errorf("round %d: move rejected with %v (%v)", round, code, result["error"])
+			}
+		}
+		if roundAccepted == 0 {
+			t.Fatalf("round %d: no move was accepted", round)
+		}
+		accepted += roundAccepted
+
+		// Let the move cooldown pass before the next round
+		time.Sleep(60 * time.Millisecond)
+	}
+
+	gameSession := loadTestGame(t, gs, sessionToken)
+	if gameSession.TotalMoves != accepted {
+		t.Errorf("session counts %d moves, %d were accepted", gameSession.TotalMoves, accepted)
+	}
+	if gameSession.Version != accepted {
+		t.Errorf("session is at version %d after %d accepted moves", gameSession.Version, accepted)
+	}
+
+	var moves []models.GameMove
+	if err := gs.db.Where("game_session_id = ?", gameSession.ID).Order("sequence ASC").Find(&moves).Error; err != nil {
+		t.Fatalf("load moves: %v", err)
+	}
+	if len(moves) != accepted {
+		t.Fatalf("%d moves logged, %d were accepted", len(moves), accepted)
+	}
+	for i, move := range moves {
+		if move.Sequence != i+1 {
+			t.Errorf("move %d is logged as move %d", i+1, move.Sequence)
+		}
+		// Every move starts where the one before ended, none was calculated on a stale position
+		if i > 0 && (move.FromRow != moves[i-1].ToRow || move.FromCol != moves[i-1].ToCol) {
+			t.Errorf("move %d starts on %d,%d but move %d ended on %d,%d",
+				move.Sequence, move.FromRow, move.FromCol, moves[i-1].Sequence, moves[i-1].ToRow, moves[i-1].ToCol)
+		}
+	}
+	last := moves[len(moves)-1]
+	if gameSession.CurrentRow != last.ToRow || gameSession.CurrentCol != last.ToCol {
+		t.Errorf("player on %d,%d but the last move went to %d,%d",
+			gameSession.CurrentRow, gameSession.CurrentCol, last.ToRow, last.ToCol)
+	}
+}
+
+func TestPearlValueByMode(t *testing.T) {
+	cfg := config.Load()
+	gs := &GameService{cfg: cfg}
+
+	tests := []struct {
+		name      string
+		session   models.GameSession
+		pearlType int
+		want      int
+	}{
+		{"classic regular", models.GameSession{Mode: models.GameModeClassic}, game.PEARL, cfg.PearlPoints},
+		{"classic golden", models.GameSession{Mode: models.GameModeClassic}, game.GOLDEN_PEARL, cfg.GoldenPearlPoints},
+		{"classic black", models.GameSession{Mode: models.GameModeClassic}, game.BLACK_PEARL, -cfg.BlackPearlPenalty},
+		{"room regular", models.GameSession{Mode: models.GameModeClassic, PearlPoints: 7}, game.PEARL, 7},
+		{"time attack regular", models.GameSession{Mode: models.GameModeTimeAttack}, game.PEARL, 1},
+		{"time attack golden", models.GameSession{Mode: models.GameModeTimeAttack}, game.GOLDEN_PEARL, 1},
+		{"time attack black", models.GameSession{Mode: models.GameModeTimeAttack}, game.BLACK_PEARL, 0},
+		{"survival regular", models.GameSession{Mode: models.GameModeSurvival}, game.PEARL, 1},
+		{"survival black", models.GameSession{Mode: models.GameModeSurvival}, game.BLACK_PEARL, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gs.pearlValue(&tt.session, tt.pearlType); got != tt.want {
+				t.Errorf("pearlValue = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMotionRestrictions(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   string
+		blocked   string
+		direction string
+		want      *models.GameError
+	}{
+		{"unrestricted level", "", "", "w", nil},
+		{"allowed motion", "h,j,k,l", "", "l", nil},
+		{"motion off the allowlist", "h,j,k,l", "", "w", models.ErrMotionNotAllowed},
+		{"blocked motion", "", "w,b", "b", models.ErrMotionBlocked},
+		{"motion next to a blocked one", "", "w,b", "e", nil},
+		{"blocked and allowlisted motion", "w,e", "w", "w", models.ErrMotionBlocked},
+		{"blocked character search", "", "f", "find_char_forward_a", models.ErrMotionBlocked},
+		{"character search off the allowlist", "h,l", "", "till_char_backward_a", models.ErrMotionNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameSession := &models.GameSession{AllowedMotions: tt.allowed, BlockedMotions: tt.blocked}
+			if got := checkMotionRestrictions(gameSession, tt.direction); got != tt.want {
+				t.Errorf("checkMotionRestrictions(%q) = %v, want %v", tt.direction, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+	"boba-vim/internal/realtime"
+)
+
+// GameStream plays one game session over a websocket. It remembers the state
+// last sent to the client so that move results only carry what changed.
+type GameStream struct {
+	matchService *MatchService
+	sessionToken string
+
+	// The server pushes changes of its own to the game, mutex guards the
+	// state last sent against the move being handled
+	mutex       sync.Mutex
+	gameMap     [][]int
+	pearlTimers []models.PearlTimer
+}
+
+// moveMessage is the data of a "move" message, Seq is echoed back so the
+// client can match results to its moves
+type moveMessage struct {
+	Direction string `json:"direction"`
+	Seq       int    `json:"seq"`
+}
+
+// GameRoom is the realtime room of the connections playing a game session,
+// the server tells them when it ends the game on its own
+func GameRoom(sessionToken string) string {
+	return "game:" + sessionToken
+}
+
+// NewGameStream registers a stream of the game session, Close it once the
+// connection ends
+func NewGameStream(matchService *MatchService, sessionToken string) *GameStream {
+	s := &GameStream{
+		matchService: matchService,
+		sessionToken: sessionToken,
+	}
+
+	matchService.streamMutex.Lock()
+	defer matchService.streamMutex.Unlock()
+	if matchService.streams[sessionToken] == nil {
+		matchService.streams[sessionToken] = make(map[*GameStream]bool)
+	}
+	matchService.streams[sessionToken][s] = true
+	return s
+}
+
+// Close unregisters the stream
+func (s *GameStream) Close() {
+	ms := s.matchService
+	ms.streamMutex.Lock()
+	defer ms.streamMutex.Unlock()
+	delete(ms.streams[s.sessionToken], s)
+	if len(ms.streams[s.sessionToken]) == 0 {
+		delete(ms.streams, s.sessionToken)
+	}
+}
+
+// pushGame sends a change the server made on its own to the connections
+// playing the game, the full state it carries becomes what later diffs are based on
+func (ms *MatchService) pushGame(sessionToken, event string, result map[string]interface{}) {
+	ms.streamMutex.Lock()
+	for s := range ms.streams[sessionToken] {
+		s.refresh(result)
+	}
+	ms.streamMutex.Unlock()
+
+	ms.hub.Broadcast(GameRoom(sessionToken), event, result)
+}
+
+// refresh remembers the state of a full result as the one last sent
+func (s *GameStream) refresh(result map[string]interface{}) {
+	gameMap, ok := result["game_map"].([][]int)
+	if !ok {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.gameMap = gameMap
+	s.pearlTimers = result["pearl_timers"].([]models.PearlTimer)
+}
+
+// SendState sends the full game state, which later diffs are based on
+func (s *GameStream) SendState(client *realtime.Client) error {
+	state, err := s.matchService.GetGameState(s.sessionToken)
+	if err != nil {
+		return err
+	}
+
+	if state["success"].(bool) {
+		s.refresh(state)
+	}
+	client.Send("game_state", state)
+	return nil
+}
+
+// HandleMessage processes a message sent on the game connection
+func (s *GameStream) HandleMessage(client *realtime.Client, msg realtime.IncomingMessage) {
+	switch msg.Type {
+	case "move":
+		var move moveMessage
+		if err := json.Unmarshal(msg.Data, &move); err != nil || move.Direction == "" {
+			client.Send("error", map[string]string{"error": "Invalid request format"})
+			return
+		}
+		s.move(client, move)
+	case "resync":
+		if err := s.SendState(client); err != nil {
+			client.Send("error", map[string]string{"error": err.Error()})
+		}
+	case "ping":
+		client.Send("pong", msg.Data)
+	default:
+		client.Send("error", map[string]string{"error": "Unknown message type: " + msg.Type})
+	}
+}
+
+// move processes a move and sends the changes it made
+func (s *GameStream) move(client *realtime.Client, move moveMessage) {
+	result, err := s.matchService.ProcessMove(s.sessionToken, move.Direction)
+	if err != nil {
+		client.Send("move_result", map[string]interface{}{
+			"seq":     move.Seq,
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	client.Send("move_result", s.diff(result, move.Seq))
+}
+
+// diff replaces the full map of a move result with the cells that changed
+// since the last state sent, leaving out pearl timers when they didn't change
+func (s *GameStream) diff(result map[string]interface{}, seq int) map[string]interface{} {
+	result["seq"] = seq
+
+	gameMap, ok := result["game_map"].([][]int)
+	if !ok {
+		return result
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(result, "game_map")
+	result["changed_cells"] = game.DiffMaps(s.gameMap, gameMap)
+	s.gameMap = gameMap
+
+	timers := result["pearl_timers"].([]models.PearlTimer)
+	if reflect.DeepEqual(timers, s.pearlTimers) {
+		delete(result, "pearl_timers")
+	}
+	s.pearlTimers = timers
+
+	// Enemies are already part of the changed cells
+	delete(result, "enemies")
+	return result
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+	"boba-vim/internal/realtime"
+)
+
+func TestPushRefreshesGameStream(t *testing.T) {
+	gs := newTestGameService(t)
+	ms := NewMatchService(gs.db, gs.cfg, gs, realtime.NewHub())
+	sessionToken := startTestGame(t, gs)
+
+	stream := NewGameStream(ms, sessionToken)
+	state, err := ms.GetGameState(sessionToken)
+	if err != nil {
+		t.Fatalf("get game state: %v", err)
+	}
+	stream.refresh(state)
+
+	// The server changes a cell on its own and pushes the full map
+	pushed := game.CopyMap(state["game_map"].([][]int))
+	pushed[0][0] = game.EMPTY
+	ms.pushGame(sessionToken, "pearl_expired", map[string]interface{}{
+		"success":      true,
+		"game_map":     pushed,
+		"pearl_timers": []models.PearlTimer{},
+	})
+
+	result := stream.diff(map[string]interface{}{
+		"success":      true,
+		"game_map":     game.CopyMap(pushed),
+		"pearl_timers": []models.PearlTimer{},
+	}, 1)
+	if changes := result["changed_cells"].([]game.CellChange); len(changes) != 0 {
+		t.Errorf("move after the push resent %v, want no changed cells", changes)
+	}
+	if _, ok := result["pearl_timers"]; ok {
+		t.Error("move after the push resent the pearl timers")
+	}
+
+	stream.Close()
+	if len(ms.streams) != 0 {
+		t.Errorf("%d games with streams after Close, want none", len(ms.streams))
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"boba-vim/internal/models"
+)
+
+// A ghost race puts the player against their best run on a level or text
+// pattern. The new game is played on the best run's seed, so both see the same
+// pearls, and the ghost is the cursor positions logged for that run. Clients
+// get the whole track with the game state and move the ghost on their clock.
+
+// ghostPoint is where the ghost's cursor is from at_ms into its run
+type ghostPoint struct {
+	AtMillis int64 `json:"at_ms"`
+	Row      int   `json:"row"`
+	Col      int   `json:"col"`
+}
+
+// CreateGhostGame starts a game racing the player's best run on the level, or
+// on the text patterns without one. Without a best run it is a normal game.
+func (gs *GameService) CreateGhostGame(playerID uint, selectedCharacter, levelName string) (map[string]interface{}, error) {
+	var opts GameOptions
+	if levelName != "" {
+		level, exists := gs.levels[levelName]
+		if !exists {
+			return nil, errors.New("level not found: " + levelName)
+		}
+		opts.Level = level
+	}
+
+	best, err := gs.bestRun(playerID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if best != nil {
+		opts.Seed = best.Seed
+		opts.PearlStrategy = best.PearlStrategy
+		opts.GhostSessionID = &best.ID
+		if opts.Level == nil {
+			opts.TextPattern, _ = strconv.Atoi(strings.TrimPrefix(best.TextID, "pattern:"))
+		}
+	}
+	return gs.CreateGame(playerID, selectedCharacter, opts)
+}
+
+// bestRun returns the player's fastest completed classic run on the text a
+// game with opts would be played on, nil for anonymous players or no run yet.
+// Only runs whose replay was verified from logged moves count, so the ghost is
+// never a run the anti-cheat checks threw out.
+func (gs *GameService) bestRun(playerID uint, opts GameOptions) (*models.GameSession, error) {
+	player, err := findPlayer(gs.db, playerID)
+	if err != nil || player == nil {
+		return nil, err
+	}
+
+	query := gs.db.Where("player_id = ? AND is_completed = ? AND mode = ?", player.ID, true, models.GameModeClassic).
+		Where("match_id IS NULL AND campaign_level_id IS NULL AND completion_time IS NOT NULL").
+		Where("replay_status = ? AND review_status <> ?", models.ReplayVerified, models.ReviewConfirmed).
+		Where("EXISTS (SELECT 1 FROM game_moves WHERE game_moves.game_session_id = game_sessions.id)")
+	if opts.Level != nil {
+		query = query.Where("text_id = ?", textID(opts))
+	} else {
+		query = query.Where("text_id LIKE ?", "pattern:%")
+	}
+
+	var best models.GameSession
+	err = query.Order("completion_time ASC").Order("total_moves ASC").Limit(1).Find(&best).Error
+	if err != nil || best.ID == 0 {
+		return nil, err
+	}
+	return &best, nil
+}
+
+// ghostFeed describes the ghost a session races, with where it is as of now
+func (gs *GameService) ghostFeed(gameSession *models.GameSession, now time.Time) (map[string]interface{}, error) {
+	var ghost models.GameSession
+	if err := gs.db.Preload("Player").First(&ghost, *gameSession.GhostSessionID).Error; err != nil {
+		return nil, err
+	}
+	var moves []models.GameMove
+	if err := gs.db.Where("game_session_id = ?", ghost.ID).Order("sequence ASC").Find(&moves).Error; err != nil {
+		return nil, err
+	}
+
+	track := []ghostPoint{{AtMillis: 0, Row: 0, Col: 0}}
+	if len(moves) > 0 {
+		track[0].Row, track[0].Col = moves[0].FromRow, moves[0].FromCol
+	}
+	for _, move := range moves {
+		track = append(track, ghostPoint{
+			AtMillis: move.PlayedAt.Sub(*ghost.StartTime).Milliseconds(),
+			Row:      move.ToRow,
+			Col:      move.ToCol,
+		})
+	}
+
+	// The ghost waits at the start until the game's clock runs
+	var elapsed time.Duration
+	if gameSession.StartTime != nil && now.After(*gameSession.StartTime) {
+		elapsed = now.Sub(*gameSession.StartTime)
+	}
+	position := track[0]
+	for _, point := range track {
+		if point.AtMillis > elapsed.Milliseconds() {
+			break
+		}
+		position = point
+	}
+
+	return map[string]interface{}{
+		"game_id":         ghost.ID,
+		"username":        ghost.Player.Username,
+		"completion_time": ghost.CompletionTime,
+		"total_moves":     ghost.TotalMoves,
+		"elapsed_ms":      elapsed.Milliseconds(),
+		"position":        map[string]int{"row": position.Row, "col": position.Col},
+		"track":           track,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"testing"
+
+	"boba-vim/internal/models"
+)
+
+func TestBestRunOnlyRacesVerifiedRuns(t *testing.T) {
+	gs := newTestGameService(t)
+	played, moves := finishTestGame(t, gs)
+
+	// The run was not checked yet
+	if best, err := gs.bestRun(played.PlayerID, GameOptions{}); err != nil || best != nil {
+		t.Fatalf("bestRun before the replay check: %v, want no run", err)
+	}
+	if err := gs.db.Model(played).UpdateColumn("replay_status", models.ReplayVerified).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Runs as fast and shorter than the played one, which the ghost must skip
+	completionTime := *played.CompletionTime
+	tests := []struct {
+		name         string
+		replayStatus string
+		reviewStatus string
+		logMoves     bool
+	}{
+		{"invalid replay", models.ReplayInvalid, models.ReviewClean, true},
+		{"pending replay", models.ReplayPending, models.ReviewClean, true},
+		{"legacy run", models.ReplayLegacy, models.ReviewClean, false},
+		{"no logged moves", models.ReplayVerified, models.ReviewClean, false},
+		{"confirmed cheat", models.ReplayVerified, models.ReviewConfirmed, true},
+	}
+	for i, tt := range tests {
+		run := models.GameSession{
+			SessionToken:   "skipped-" + tt.name,
+			PlayerID:       played.PlayerID,
+			Mode:           models.GameModeClassic,
+			TextID:         played.TextID,
+			Seed:           int64(i),
+			IsCompleted:    true,
+			CompletionTime: &completionTime,
+			TotalMoves:     1,
+			ReplayStatus:   tt.replayStatus,
+			ReviewStatus:   tt.reviewStatus,
+		}
+		if err := gs.db.Create(&run).Error; err != nil {
+			t.Fatal(err)
+		}
+		if tt.logMoves {
+			move := moves[0]
+			move.ID = 0
+			move.GameSessionID = run.ID
+			if err := gs.db.Create(&move).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	best, err := gs.bestRun(played.PlayerID, GameOptions{})
+	if err != nil {
+		t.Fatalf("bestRun: %v", err)
+	}
+	if best == nil || best.ID != played.ID {
+		t.Fatalf("bestRun picked another run than the verified run %d", played.ID)
+	}
+
+	// Clearing a review keeps the run
+	cleared := models.GameSession{}
+	if err := gs.db.Where("review_status = ?", models.ReviewConfirmed).First(&cleared).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.db.Model(&cleared).UpdateColumn("review_status", models.ReviewCleared).Error; err != nil {
+		t.Fatal(err)
+	}
+	if best, err := gs.bestRun(played.PlayerID, GameOptions{}); err != nil || best == nil || best.ID != cleared.ID {
+		t.Errorf("bestRun after clearing the review: %v, want run %d", err, cleared.ID)
+	}
+}
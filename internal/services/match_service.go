@@ -0,0 +1,840 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+	"boba-vim/internal/realtime"
+
+	"gorm.io/gorm"
+)
+
+// MatchService runs the online lobbies and the clock of every match. It keeps
+// timers and shared map rooms in memory, so a single instance must be shared by all handlers.
+type MatchService struct {
+	db          *gorm.DB
+	cfg         *config.Config
+	gameService *GameService
+	hub         *realtime.Hub
+
+	// lobbyMutex serializes lobby changes so concurrent joins can't overfill a match
+	lobbyMutex sync.Mutex
+
+	// Rooms of the matches played on a shared map, by match id and by the session tokens playing in them
+	boardMutex sync.Mutex
+	boards     map[uint]*boardRoom
+	boardSeats map[string]*boardRoom
+
+	// Called once a match finished, registered at startup
+	finishListeners []func(match models.Match)
+
+	// Send times of each player's recent chat messages, for the rate limit
+	chatMutex sync.Mutex
+	chatSent  map[uint][]time.Time
+
+	// Timer of the next pearl expiry of each survival game, by session token
+	survivalMutex  sync.Mutex
+	survivalTimers map[string]*time.Timer
+
+	// Streams of the games played over a websocket, by session token
+	streamMutex sync.Mutex
+	streams     map[string]map[*GameStream]bool
+}
+
+func NewMatchService(db *gorm.DB, cfg *config.Config, gameService *GameService, hub *realtime.Hub) *MatchService {
+	return &MatchService{
+		db:          db,
+		cfg:         cfg,
+		gameService: gameService,
+		hub:         hub,
+		boards:      make(map[uint]*boardRoom),
+		boardSeats:  make(map[string]*boardRoom),
+		chatSent:    make(map[uint][]time.Time),
+
+		survivalTimers: make(map[string]*time.Timer),
+		streams:        make(map[string]map[*GameStream]bool),
+	}
+}
+
+// MatchRoom is the realtime room a match broadcasts to
+func MatchRoom(matchToken string) string {
+	return "match:" + matchToken
+}
+
+// AbandonUnfinished marks matches whose clock was lost, e.g. by a server restart, as abandoned
+func (ms *MatchService) AbandonUnfinished() error {
+	return ms.db.Model(&models.Match{}).
+		Where("status IN ?", []string{models.MatchStatusWaiting, models.MatchStatusCountdown, models.MatchStatusRunning}).
+		Update("status", models.MatchStatusAbandoned).Error
+}
+
+// JoinLobby puts a registered player in the first open match of the mode, creating
+// one when every lobby is full, and starts the countdown once enough players joined
+func (ms *MatchService) JoinLobby(playerID uint, selectedCharacter, mode string) (map[string]interface{}, error) {
+	if mode == "" {
+		mode = models.MatchModeRace
+	}
+	if mode != models.MatchModeRace && mode != models.MatchModeArena && mode != models.MatchModeCoop {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Unknown match mode",
+		}, nil
+	}
+
+	player, err := findPlayer(ms.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to play online",
+		}, nil
+	}
+
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	// Players already waiting in a lobby get their seat back, unless they
+	// abandoned it by starting another game in the meantime
+	if entry, match, err := ms.waitingEntry(player.ID); err != nil {
+		return nil, err
+	} else if entry != nil {
+		var gameSession models.GameSession
+		if err := ms.db.First(&gameSession, entry.GameSessionID).Error; err != nil {
+			return nil, err
+		}
+		if gameSession.IsActive {
+			return ms.seatResponse(match, entry)
+		}
+		if err := ms.db.Delete(entry).Error; err != nil {
+			return nil, err
+		}
+		if match.SharesMap() {
+			ms.leaveBoard(match, entry)
+		}
+		ms.broadcast(match.MatchToken, "player_left", map[string]interface{}{"username": entry.Username})
+	}
+
+	match, err := ms.openMatch(mode, player)
+	if err != nil {
+		return nil, err
+	}
+	return ms.seatPlayer(match, player, selectedCharacter)
+}
+
+// JoinMatch seats a registered player in a given match that has not started.
+// Tournament matches are not open to the lobby, their players join them here.
+func (ms *MatchService) JoinMatch(player *models.Player, selectedCharacter string, matchID uint) (map[string]interface{}, error) {
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	var match models.Match
+	if err := ms.db.First(&match, matchID).Error; err != nil {
+		return nil, err
+	}
+
+	var entry models.MatchPlayer
+	err := ms.db.Where("match_id = ? AND player_id = ?", match.ID, player.ID).First(&entry).Error
+	if err == nil {
+		return ms.seatResponse(&match, &entry)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if match.Status != models.MatchStatusWaiting && match.Status != models.MatchStatusCountdown {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Match is no longer open",
+		}, nil
+	}
+	var playerCount int64
+	if err := ms.db.Model(&models.MatchPlayer{}).Where("match_id = ?", match.ID).Count(&playerCount).Error; err != nil {
+		return nil, err
+	}
+	if int(playerCount) >= match.MaxPlayers {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Match is full",
+		}, nil
+	}
+	var banned int64
+	if err := ms.db.Model(&models.RoomBan{}).Where("match_id = ? AND player_id = ?", match.ID, player.ID).Count(&banned).Error; err != nil {
+		return nil, err
+	}
+	if banned > 0 {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "The host removed you from this room",
+		}, nil
+	}
+	if other, _, err := ms.waitingEntry(player.ID); err != nil {
+		return nil, err
+	} else if other != nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Leave your lobby first",
+		}, nil
+	}
+	return ms.seatPlayer(&match, player, selectedCharacter)
+}
+
+// OnMatchFinished registers a function called with every match once it finished
+func (ms *MatchService) OnMatchFinished(listener func(match models.Match)) {
+	ms.finishListeners = append(ms.finishListeners, listener)
+}
+
+// seatPlayer creates the player's game in a match and starts the countdown
+// once enough players joined, the caller holds the lobby mutex
+func (ms *MatchService) seatPlayer(match *models.Match, player *models.Player, selectedCharacter string) (map[string]interface{}, error) {
+	result, err := ms.gameService.CreateGame(player.ID, selectedCharacter, GameOptions{
+		PearlStrategy:  match.PearlStrategy,
+		TargetScore:    match.TargetScore,
+		TimeLimit:      match.TimeLimit,
+		PearlPoints:    match.PearlPoints,
+		TextPattern:    match.TextPattern,
+		AllowedMotions: splitMotions(match.AllowedMotions),
+		Seed:           match.Seed,
+		MatchID:        &match.ID,
+		StartTime:      match.StartsAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var gameSession models.GameSession
+	if err := ms.db.Where("session_token = ?", result["session_token"]).First(&gameSession).Error; err != nil {
+		return nil, err
+	}
+	if match.SharesMap() {
+		if err := ms.joinBoard(match, &gameSession); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := &models.MatchPlayer{
+		MatchID:       match.ID,
+		PlayerID:      player.ID,
+		Username:      player.Username,
+		Rating:        player.Rating,
+		GameSessionID: gameSession.ID,
+		SessionToken:  gameSession.SessionToken,
+	}
+	if err := ms.db.Create(entry).Error; err != nil {
+		return nil, err
+	}
+	ms.broadcast(match.MatchToken, "player_joined", progressData(entry))
+
+	var playerCount int64
+	if err := ms.db.Model(&models.MatchPlayer{}).Where("match_id = ?", match.ID).Count(&playerCount).Error; err != nil {
+		return nil, err
+	}
+	// Private rooms start when their host says so
+	if !match.IsPrivate() && match.Status == models.MatchStatusWaiting && int(playerCount) >= match.MinPlayers {
+		if err := ms.startCountdown(match); err != nil {
+			return nil, err
+		}
+	}
+
+	return ms.seatResponse(match, entry)
+}
+
+// LeaveLobby removes the player from a race that has not started yet
+func (ms *MatchService) LeaveLobby(playerID uint) (map[string]interface{}, error) {
+	player, err := findPlayer(ms.db, playerID)
+	if err != nil || player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Not in a lobby",
+		}, err
+	}
+
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	entry, match, err := ms.waitingEntry(player.ID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Not in a lobby",
+		}, nil
+	}
+
+	if err := ms.removePlayer(match, entry); err != nil {
+		return nil, err
+	}
+	if match.IsPrivate() && *match.HostID == player.ID {
+		if err := ms.handOverRoom(match); err != nil {
+			return nil, err
+		}
+	}
+	if match.Status == models.MatchStatusWaiting {
+		ms.broadcast(match.MatchToken, "match_state", ms.matchState(match))
+	}
+
+	return map[string]interface{}{"success": true}, nil
+}
+
+// removePlayer takes a player out of a match that has not finished and ends
+// their game, the caller holds the lobby mutex
+func (ms *MatchService) removePlayer(match *models.Match, entry *models.MatchPlayer) error {
+	err := ms.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(entry).Error; err != nil {
+			return err
+		}
+		if err := updateSessions(tx.Model(&models.GameSession{}).Where("id = ?", entry.GameSessionID), map[string]interface{}{"is_active": false}); err != nil {
+			return err
+		}
+
+		// Without enough players the countdown is called off until someone else joins
+		var playerCount int64
+		if err := tx.Model(&models.MatchPlayer{}).Where("match_id = ?", match.ID).Count(&playerCount).Error; err != nil {
+			return err
+		}
+		if match.Status == models.MatchStatusCountdown && int(playerCount) < match.MinPlayers {
+			match.Status = models.MatchStatusWaiting
+			match.StartsAt = nil
+			match.EndsAt = nil
+			if err := tx.Save(match).Error; err != nil {
+				return err
+			}
+			return updateSessions(tx.Model(&models.GameSession{}).Where("match_id = ?", match.ID), map[string]interface{}{"start_time": nil})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if match.SharesMap() {
+		ms.leaveBoard(match, entry)
+	}
+	ms.broadcast(match.MatchToken, "player_left", map[string]interface{}{"username": entry.Username})
+	return nil
+}
+
+// ResumeMatch returns the player's seat in a match, used to render the game page
+func (ms *MatchService) ResumeMatch(playerID uint, matchToken string) (map[string]interface{}, error) {
+	match, err := ms.findMatch(matchToken)
+	if err != nil || match == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Match not found",
+		}, err
+	}
+
+	player, err := findPlayer(ms.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry models.MatchPlayer
+	if player == nil || ms.db.Where("match_id = ? AND player_id = ?", match.ID, player.ID).First(&entry).Error != nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "You are not playing in this match",
+		}, nil
+	}
+	return ms.seatResponse(match, &entry)
+}
+
+// GetMatch returns the public state of a match
+func (ms *MatchService) GetMatch(matchToken string) (map[string]interface{}, error) {
+	match, err := ms.findMatch(matchToken)
+	if err != nil || match == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Match not found",
+		}, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"match":   ms.matchState(match),
+	}, nil
+}
+
+// ProcessMove processes a move of any game session. Moves of online matches
+// are shared with the match, on the match's shared map when it has one.
+func (ms *MatchService) ProcessMove(sessionToken, direction string) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	var err error
+	if room := ms.boardSeat(sessionToken); room != nil {
+		result, err = ms.boardMove(room, sessionToken, direction)
+	} else if result, err = ms.gameService.ProcessMove(sessionToken, direction); err == nil {
+		ms.RecordMove(sessionToken, result)
+		ms.trackSurvival(sessionToken, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ms.publishGame(result)
+	return result, nil
+}
+
+// GetGameState returns the state of any game session, with the shared map for arena and coop sessions
+func (ms *MatchService) GetGameState(sessionToken string) (map[string]interface{}, error) {
+	if room := ms.boardSeat(sessionToken); room != nil {
+		return ms.boardState(room, sessionToken)
+	}
+	return ms.gameService.GetGameState(sessionToken)
+}
+
+// RecordMove updates the player's race entry after an accepted move and
+// broadcasts it, ending the match once every player is done
+func (ms *MatchService) RecordMove(sessionToken string, result map[string]interface{}) {
+	if matchID, _ := result["match_id"].(*uint); result["success"] != true || matchID == nil {
+		return
+	}
+
+	var entry models.MatchPlayer
+	if err := ms.db.Where("session_token = ?", sessionToken).First(&entry).Error; err != nil {
+		return
+	}
+	var match models.Match
+	if err := ms.db.First(&match, entry.MatchID).Error; err != nil {
+		return
+	}
+
+	position := result["player_pos"].(map[string]int)
+	entry.Score = result["score"].(int)
+	entry.CurrentRow = position["row"]
+	entry.CurrentCol = position["col"]
+	entry.TotalMoves++
+	if result["pearl_collected"] == true {
+		entry.PearlsCollected++
+	}
+	if result["game_over"] == true {
+		entry.IsFinished = true
+		entry.EndReason, _ = result["end_reason"].(string)
+		if result["is_completed"] == true && match.StartsAt != nil {
+			finishTime := time.Since(*match.StartsAt).Seconds()
+			entry.FinishTime = &finishTime
+		}
+	}
+	if err := ms.db.Save(&entry).Error; err != nil {
+		log.Printf("Failed to record match move: %v", err)
+		return
+	}
+
+	ms.broadcast(match.MatchToken, "player_progress", progressData(&entry))
+	if !entry.IsFinished {
+		return
+	}
+	ms.broadcast(match.MatchToken, "player_finished", progressData(&entry))
+
+	// A shared map is over as soon as someone reaches the target, the pearls are shared
+	var racing int64
+	ms.db.Model(&models.MatchPlayer{}).Where("match_id = ? AND is_finished = ?", match.ID, false).Count(&racing)
+	if racing == 0 || match.SharesMap() && result["is_completed"] == true {
+		ms.finishMatch(match.ID)
+	}
+}
+
+// openMatch returns the oldest lobby of the mode with a free seat within the
+// player's rating band, or a new one
+func (ms *MatchService) openMatch(mode string, player *models.Player) (*models.Match, error) {
+	var lobbies []models.Match
+	err := ms.db.
+		Where("mode = ? AND status IN ?", mode, []string{models.MatchStatusWaiting, models.MatchStatusCountdown}).
+		Where("tournament_id IS NULL AND host_id IS NULL").
+		Where("(SELECT COUNT(*) FROM match_players WHERE match_players.match_id = matches.id) < matches.max_players").
+		Order("id ASC").
+		Find(&lobbies).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range lobbies {
+		suits, err := ms.withinRatingBand(&lobbies[i], player)
+		if err != nil {
+			return nil, err
+		}
+		if suits {
+			return &lobbies[i], nil
+		}
+	}
+
+	match, err := ms.newMatch(mode, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.db.Create(match).Error; err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// newMatch builds a match of the mode with a fresh seed, it is not saved yet.
+// pattern picks the text like in game.InitializePatternSession.
+func (ms *MatchService) newMatch(mode string, pattern int) (*models.Match, error) {
+	match := &models.Match{
+		Mode:          mode,
+		Status:        models.MatchStatusWaiting,
+		Seed:          game.NewSeed(),
+		TextPattern:   pattern,
+		PearlStrategy: game.PearlStrategyMixed,
+		TargetScore:   ms.cfg.MatchTargetScore,
+		TimeLimit:     int(ms.cfg.MatchTimeLimit.Seconds()),
+		MinPlayers:    ms.cfg.MatchMinPlayers,
+		MaxPlayers:    ms.cfg.MatchMaxPlayers,
+	}
+	if match.SharesMap() {
+		// Timed pearls would expire on one player's clock, boards only use regular pearls
+		match.PearlStrategy = game.PearlStrategyRegular
+		board := game.NewSharedMap(pattern, match.PearlStrategy, match.Seed)
+		if mode == models.MatchModeCoop {
+			match.MinPlayers = 2
+			match.MaxPlayers = 2
+			board = game.NewCoopMap(pattern, match.Seed)
+		}
+		boardJSON, err := json.Marshal(board)
+		if err != nil {
+			return nil, err
+		}
+		match.BoardJSON = string(boardJSON)
+	}
+	return match, nil
+}
+
+// startCountdown schedules the match start and end on the server clock
+func (ms *MatchService) startCountdown(match *models.Match) error {
+	startsAt := time.Now().Add(ms.cfg.MatchCountdown)
+	endsAt := startsAt.Add(time.Duration(match.TimeLimit) * time.Second)
+
+	err := retryVersioned(ms.db, func(tx *gorm.DB) error {
+		match.Status = models.MatchStatusCountdown
+		match.StartsAt = &startsAt
+		match.EndsAt = &endsAt
+		if err := tx.Save(match).Error; err != nil {
+			return err
+		}
+		return ms.gameService.ScheduleMatchStart(tx, match.ID, startsAt)
+	})
+	if err != nil {
+		return err
+	}
+
+	ms.broadcast(match.MatchToken, "match_countdown", ms.matchState(match))
+
+	// The callbacks re-check the match, a cancelled or rescheduled countdown makes them no-ops
+	matchID := match.ID
+	time.AfterFunc(time.Until(startsAt), func() { ms.beginMatch(matchID, startsAt) })
+	time.AfterFunc(time.Until(endsAt), func() { ms.endMatchAt(matchID, endsAt) })
+	return nil
+}
+
+// beginMatch flips a match to running when its countdown is over
+func (ms *MatchService) beginMatch(matchID uint, startsAt time.Time) {
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	var match models.Match
+	if err := ms.db.First(&match, matchID).Error; err != nil {
+		return
+	}
+	if match.Status != models.MatchStatusCountdown || match.StartsAt == nil || !match.StartsAt.Equal(startsAt) {
+		return
+	}
+
+	match.Status = models.MatchStatusRunning
+	if err := ms.db.Save(&match).Error; err != nil {
+		log.Printf("Failed to start match %d: %v", matchID, err)
+		return
+	}
+	ms.broadcast(match.MatchToken, "match_started", ms.matchState(&match))
+}
+
+// endMatchAt ends a match when its clock runs out
+func (ms *MatchService) endMatchAt(matchID uint, endsAt time.Time) {
+	var match models.Match
+	if err := ms.db.First(&match, matchID).Error; err != nil {
+		return
+	}
+	if match.EndsAt == nil || !match.EndsAt.Equal(endsAt) {
+		return
+	}
+	ms.finishMatch(matchID)
+}
+
+// finishMatch stops every session still racing, ranks the players and records the result
+func (ms *MatchService) finishMatch(matchID uint) {
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	var match models.Match
+	if err := ms.db.Preload("Players").First(&match, matchID).Error; err != nil {
+		return
+	}
+	if match.Status == models.MatchStatusFinished || match.Status == models.MatchStatusAbandoned {
+		return
+	}
+
+	// Let the move in progress on a shared map finish before the sessions are ended
+	if match.SharesMap() {
+		ms.closeBoard(match.ID)
+	}
+
+	err := retryVersioned(ms.db, func(tx *gorm.DB) error {
+		// A move saved in the meantime fails the attempt, the next one starts from the saved match
+		match = models.Match{}
+		if err := tx.Preload("Players").First(&match, matchID).Error; err != nil {
+			return err
+		}
+		var gameSessions []models.GameSession
+		if err := tx.Where("match_id = ? AND is_active = ?", match.ID, true).Find(&gameSessions).Error; err != nil {
+			return err
+		}
+		for i := range gameSessions {
+			gameSessions[i].FailGame(models.EndReasonMatchOver)
+			if err := saveVersioned(tx, &gameSessions[i]); err != nil {
+				return err
+			}
+		}
+
+		rankPlayers(match.Players)
+		if err := ms.rateMatch(tx, &match); err != nil {
+			return err
+		}
+		for i := range match.Players {
+			entry := &match.Players[i]
+			if !entry.IsFinished {
+				entry.IsFinished = true
+				entry.EndReason = models.EndReasonMatchOver
+			}
+			if err := tx.Save(entry).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		match.Status = models.MatchStatusFinished
+		match.FinishedAt = &now
+		match.WinnerID = matchWinner(match.Players)
+		return tx.Omit("Players").Save(&match).Error
+	})
+	if err != nil {
+		log.Printf("Failed to finish match %d: %v", matchID, err)
+		return
+	}
+
+	ms.broadcast(match.MatchToken, "match_finished", ms.matchState(&match))
+	for _, listener := range ms.finishListeners {
+		// Listeners may start new matches, which needs the lobby mutex
+		go listener(match)
+	}
+}
+
+// rankPlayers orders players by result: finishers by time, then everyone else
+// by score and moves. Players with the same result share a rank.
+func rankPlayers(players []models.MatchPlayer) {
+	sort.SliceStable(players, func(i, j int) bool {
+		a, b := players[i], players[j]
+		if (a.FinishTime != nil) != (b.FinishTime != nil) {
+			return a.FinishTime != nil
+		}
+		if a.FinishTime != nil {
+			return *a.FinishTime < *b.FinishTime
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.TotalMoves < b.TotalMoves
+	})
+	for i := range players {
+		players[i].Rank = i + 1
+		if i > 0 && sameResult(players[i-1], players[i]) {
+			players[i].Rank = players[i-1].Rank
+		}
+	}
+}
+
+// sameResult reports whether two players tied: they finished at the same
+// time, or neither finished and they have the same score and moves
+func sameResult(a, b models.MatchPlayer) bool {
+	if (a.FinishTime != nil) != (b.FinishTime != nil) {
+		return false
+	}
+	if a.FinishTime != nil {
+		return *a.FinishTime == *b.FinishTime
+	}
+	return a.Score == b.Score && a.TotalMoves == b.TotalMoves
+}
+
+// matchTied reports whether ranked players share the first place with a result,
+// players who neither finished nor scored only tie because nobody played
+func matchTied(players []models.MatchPlayer) bool {
+	if len(players) < 2 || players[1].Rank != players[0].Rank {
+		return false
+	}
+	return players[0].FinishTime != nil || players[0].Score > 0
+}
+
+// matchWinner returns the player who won a ranked match, nil when the first
+// place is tied or nobody finished or scored
+func matchWinner(players []models.MatchPlayer) *uint {
+	if len(players) == 0 {
+		return nil
+	}
+	first := players[0]
+	if len(players) > 1 && players[1].Rank == first.Rank {
+		return nil
+	}
+	if first.FinishTime == nil && first.Score <= 0 {
+		return nil
+	}
+	return &first.PlayerID
+}
+
+// waitingEntry returns the player's seat in a race that has not started yet
+func (ms *MatchService) waitingEntry(playerID uint) (*models.MatchPlayer, *models.Match, error) {
+	var entry models.MatchPlayer
+	err := ms.db.Joins("JOIN matches ON matches.id = match_players.match_id").
+		Where("match_players.player_id = ? AND matches.status IN ?", playerID, []string{models.MatchStatusWaiting, models.MatchStatusCountdown}).
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	var match models.Match
+	if err := ms.db.First(&match, entry.MatchID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &entry, &match, nil
+}
+
+// seatResponse describes the player's seat and game in a match
+func (ms *MatchService) seatResponse(match *models.Match, entry *models.MatchPlayer) (map[string]interface{}, error) {
+	var gameSession models.GameSession
+	if err := ms.db.First(&gameSession, entry.GameSessionID).Error; err != nil {
+		return nil, err
+	}
+
+	gameData := ms.gameService.gameData(&gameSession)
+	if room := ms.boardSeat(gameSession.SessionToken); room != nil {
+		gameData["game_map"], gameData["cursors"] = room.boardView()
+	}
+
+	return map[string]interface{}{
+		"success":       true,
+		"session_token": gameSession.SessionToken,
+		"match":         ms.matchState(match),
+		"game_data":     gameData,
+	}, nil
+}
+
+// matchState is the public view of a match shared by its players
+func (ms *MatchService) matchState(match *models.Match) map[string]interface{} {
+	var players []models.MatchPlayer
+	ms.db.Where("match_id = ?", match.ID).Order("id ASC").Find(&players)
+
+	entries := make([]map[string]interface{}, 0, len(players))
+	for i := range players {
+		entries = append(entries, progressData(&players[i]))
+	}
+
+	state := map[string]interface{}{
+		"match_token":  match.MatchToken,
+		"mode":         match.Mode,
+		"status":       match.Status,
+		"target_score": match.TargetScore,
+		"time_limit":   match.TimeLimit,
+		"min_players":  match.MinPlayers,
+		"max_players":  match.MaxPlayers,
+		"starts_at":    match.StartsAt,
+		"ends_at":      match.EndsAt,
+		"finished_at":  match.FinishedAt,
+		"winner_id":    match.WinnerID,
+		"players":      entries,
+	}
+	if match.IsPrivate() {
+		state["host_id"] = match.HostID
+		state["rules"] = map[string]interface{}{
+			"text_pattern":    match.TextPattern,
+			"pearl_points":    match.PearlPoints,
+			"allowed_motions": splitMotions(match.AllowedMotions),
+		}
+	}
+	return state
+}
+
+// progressData is the broadcast view of a player's race
+func progressData(entry *models.MatchPlayer) map[string]interface{} {
+	return map[string]interface{}{
+		"player_id":        entry.PlayerID,
+		"username":         entry.Username,
+		"row":              entry.CurrentRow,
+		"col":              entry.CurrentCol,
+		"score":            entry.Score,
+		"pearls_collected": entry.PearlsCollected,
+		"total_moves":      entry.TotalMoves,
+		"is_finished":      entry.IsFinished,
+		"end_reason":       entry.EndReason,
+		"finish_time":      entry.FinishTime,
+		"rank":             entry.Rank,
+		"rating":           entry.Rating,
+		"rating_change":    entry.RatingChange,
+		"muted":            entry.Muted,
+	}
+}
+
+// findMatch loads a match by its public token, nil when it does not exist
+func (ms *MatchService) findMatch(matchToken string) (*models.Match, error) {
+	var match models.Match
+	if err := ms.db.Where("match_token = ?", matchToken).First(&match).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &match, nil
+}
+
+// Subscribe adds a player's websocket to the match room and sends the current state
+func (ms *MatchService) Subscribe(client *realtime.Client, matchToken string) error {
+	match, err := ms.findMatch(matchToken)
+	if err != nil {
+		return err
+	}
+	if match == nil {
+		return errors.New("match not found")
+	}
+
+	var entry models.MatchPlayer
+	if err := ms.db.Where("match_id = ? AND player_id = ?", match.ID, client.PlayerID).First(&entry).Error; err != nil {
+		return errors.New("you are not playing in this match")
+	}
+
+	ms.hub.Join(MatchRoom(matchToken), client)
+	client.Send("match_state", ms.matchState(match))
+	ms.sendChatHistory(client, match)
+	return nil
+}
+
+// HandleMessage answers messages sent on a match connection. Clients use
+// "ping" to measure their offset from the server clock.
+func (ms *MatchService) HandleMessage(client *realtime.Client, matchToken string, msg realtime.IncomingMessage) {
+	switch msg.Type {
+	case "ping":
+		client.Send("pong", msg.Data)
+	case "chat":
+		ms.handleChat(client, matchToken, msg.Data)
+	case "mute", "unmute", "kick":
+		ms.handleModeration(client, matchToken, msg.Type, msg.Data)
+	default:
+		client.Send("error", map[string]string{"error": "Unknown message type: " + msg.Type})
+	}
+}
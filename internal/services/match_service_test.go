@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+
+	"boba-vim/internal/models"
+)
+
+func TestRankPlayers(t *testing.T) {
+	finish := func(seconds float64) *float64 { return &seconds }
+
+	tests := []struct {
+		name      string
+		players   []models.MatchPlayer
+		wantOrder []uint
+		wantRanks []int
+	}{
+		{"finishers by time, then by score and moves", []models.MatchPlayer{
+			{PlayerID: 1, Score: 50, TotalMoves: 10},
+			{PlayerID: 2, FinishTime: finish(40), Score: 200},
+			{PlayerID: 3, Score: 50, TotalMoves: 8},
+			{PlayerID: 4, FinishTime: finish(30), Score: 200},
+		}, []uint{4, 2, 3, 1}, []int{1, 2, 3, 4}},
+		{"same finish time ties", []models.MatchPlayer{
+			{PlayerID: 1, FinishTime: finish(30), Score: 200, TotalMoves: 20},
+			{PlayerID: 2, FinishTime: finish(30), Score: 210, TotalMoves: 10},
+			{PlayerID: 3, Score: 150},
+		}, []uint{1, 2, 3}, []int{1, 1, 3}},
+		{"same score and moves tie", []models.MatchPlayer{
+			{PlayerID: 1, Score: 30, TotalMoves: 12},
+			{PlayerID: 2, Score: 50, TotalMoves: 12},
+			{PlayerID: 3, Score: 30, TotalMoves: 12},
+		}, []uint{2, 1, 3}, []int{1, 2, 2}},
+		{"same score, fewer moves rank higher", []models.MatchPlayer{
+			{PlayerID: 1, Score: 30, TotalMoves: 12},
+			{PlayerID: 2, Score: 30, TotalMoves: 11},
+		}, []uint{2, 1}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankPlayers(tt.players)
+			for i, player := range tt.players {
+				if player.PlayerID != tt.wantOrder[i] || player.Rank != tt.wantRanks[i] {
+					t.Errorf("place %d is player %d ranked %d, want player %d ranked %d", i+1, player.PlayerID, player.Rank, tt.wantOrder[i], tt.wantRanks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatchWinner(t *testing.T) {
+	finish := func(seconds float64) *float64 { return &seconds }
+
+	tests := []struct {
+		name       string
+		players    []models.MatchPlayer
+		wantWinner uint // 0 for no winner
+		wantTied   bool
+	}{
+		{"fastest finisher wins", []models.MatchPlayer{
+			{PlayerID: 1, FinishTime: finish(30)},
+			{PlayerID: 2, FinishTime: finish(40)},
+		}, 1, false},
+		{"best score wins without finishers", []models.MatchPlayer{
+			{PlayerID: 1, Score: 20},
+			{PlayerID: 2, Score: 10},
+		}, 1, false},
+		{"tie for the first place", []models.MatchPlayer{
+			{PlayerID: 1, FinishTime: finish(30)},
+			{PlayerID: 2, FinishTime: finish(30)},
+		}, 0, true},
+		{"nobody scored", []models.MatchPlayer{
+			{PlayerID: 1},
+			{PlayerID: 2},
+		}, 0, false},
+		{"alone without a score", []models.MatchPlayer{
+			{PlayerID: 1},
+		}, 0, false},
+		{"alone with a score", []models.MatchPlayer{
+			{PlayerID: 1, Score: 10},
+		}, 1, false},
+		{"empty match", nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankPlayers(tt.players)
+			winner := matchWinner(tt.players)
+			if (winner == nil) != (tt.wantWinner == 0) || winner != nil && *winner != tt.wantWinner {
+				t.Errorf("matchWinner = %v, want %d", winner, tt.wantWinner)
+			}
+			if tied := matchTied(tt.players); tied != tt.wantTied {
+				t.Errorf("matchTied = %v, want %v", tied, tt.wantTied)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Private rooms are matches outside the lobby. Players join them with the
+// room's code, the host picks the rules and starts the countdown when ready.
+
+// joinCodeAlphabet leaves out characters that are easy to mix up when read aloud
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const joinCodeLength = 6
+
+// RoomOptions are the rules a host picks for a private room, zero values keep the online defaults
+type RoomOptions struct {
+	Mode           string
+	TextPattern    int // numbered from 1, see TextPatterns
+	PearlPoints    int
+	TargetScore    int
+	TimeLimit      int // seconds
+	AllowedMotions []string
+	MaxPlayers     int
+}
+
+// TextPatterns lists the texts a host can pick for a room
+func (ms *MatchService) TextPatterns() map[string]interface{} {
+	patterns := make([]map[string]interface{}, 0, len(game.TextPatterns))
+	for i, pattern := range game.TextPatterns {
+		patterns = append(patterns, map[string]interface{}{
+			"id":   i + 1,
+			"name": pattern.Name,
+			"text": pattern.Text,
+		})
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"patterns": patterns,
+	}
+}
+
+// CreateRoom opens a private room with the host's rules and seats the host in it
+func (ms *MatchService) CreateRoom(playerID uint, selectedCharacter string, options RoomOptions) (map[string]interface{}, error) {
+	host, err := findPlayer(ms.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if host == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to create a room",
+		}, nil
+	}
+	if problem := ms.checkRoomOptions(&options); problem != "" {
+		return map[string]interface{}{
+			"success": false,
+			"error":   problem,
+		}, nil
+	}
+
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	if entry, _, err := ms.waitingEntry(host.ID); err != nil {
+		return nil, err
+	} else if entry != nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Leave your lobby first",
+		}, nil
+	}
+
+	match, err := ms.newMatch(options.Mode, options.TextPattern)
+	if err != nil {
+		return nil, err
+	}
+	match.HostID = &host.ID
+	match.PearlPoints = options.PearlPoints
+	match.AllowedMotions = strings.Join(options.AllowedMotions, ",")
+	if options.TargetScore > 0 {
+		match.TargetScore = options.TargetScore
+	}
+	if options.TimeLimit > 0 {
+		match.TimeLimit = options.TimeLimit
+	}
+	if match.Mode != models.MatchModeCoop {
+		// The host may play alone, e.g. to try the rules out
+		match.MinPlayers = 1
+		if options.MaxPlayers > 0 {
+			match.MaxPlayers = options.MaxPlayers
+		}
+	}
+	if match.JoinCode, err = ms.newJoinCode(); err != nil {
+		return nil, err
+	}
+	if err := ms.db.Create(match).Error; err != nil {
+		return nil, err
+	}
+
+	result, err := ms.seatPlayer(match, host, selectedCharacter)
+	if err != nil {
+		return nil, err
+	}
+	result["join_code"] = match.JoinCode
+	return result, nil
+}
+
+// JoinRoom seats a registered player in the private room with the code
+func (ms *MatchService) JoinRoom(playerID uint, selectedCharacter, joinCode string) (map[string]interface{}, error) {
+	player, err := findPlayer(ms.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to join a room",
+		}, nil
+	}
+
+	var match models.Match
+	err = ms.db.Where("join_code = ? AND host_id IS NOT NULL AND status IN ?", strings.ToUpper(strings.TrimSpace(joinCode)),
+		[]string{models.MatchStatusWaiting, models.MatchStatusCountdown, models.MatchStatusRunning}).
+		First(&match).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "No room with this code",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ms.JoinMatch(player, selectedCharacter, match.ID)
+	if err != nil || !result["success"].(bool) {
+		return result, err
+	}
+	result["join_code"] = match.JoinCode
+	return result, nil
+}
+
+// StartRoom starts the countdown of a private room, only its host may start it
+func (ms *MatchService) StartRoom(playerID uint, matchToken string) (map[string]interface{}, error) {
+	player, err := findPlayer(ms.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	ms.lobbyMutex.Lock()
+	defer ms.lobbyMutex.Unlock()
+
+	match, err := ms.findMatch(matchToken)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil || !match.IsPrivate() {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Room not found",
+		}, nil
+	}
+	if player == nil || *match.HostID != player.ID {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Only the host can start the room",
+		}, nil
+	}
+	if match.Status != models.MatchStatusWaiting {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Room already started",
+		}, nil
+	}
+
+	var playerCount int64
+	if err := ms.db.Model(&models.MatchPlayer{}).Where("match_id = ?", match.ID).Count(&playerCount).Error; err != nil {
+		return nil, err
+	}
+	if int(playerCount) < match.MinPlayers {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Waiting for more players",
+		}, nil
+	}
+
+	if err := ms.startCountdown(match); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"success": true,
+		"match":   ms.matchState(match),
+	}, nil
+}
+
+// handOverRoom passes a private room to its oldest remaining player once the
+// host left, an empty room is closed. The caller holds the lobby mutex.
+func (ms *MatchService) handOverRoom(match *models.Match) error {
+	var next models.MatchPlayer
+	err := ms.db.Where("match_id = ?", match.ID).Order("id ASC").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if match.SharesMap() {
+			ms.closeBoard(match.ID)
+		}
+		match.Status = models.MatchStatusAbandoned
+		return ms.db.Model(match).Update("status", match.Status).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	match.HostID = &next.PlayerID
+	if err := ms.db.Model(match).Update("host_id", next.PlayerID).Error; err != nil {
+		return err
+	}
+	ms.broadcast(match.MatchToken, "host_changed", map[string]interface{}{
+		"host_id":  next.PlayerID,
+		"username": next.Username,
+	})
+	return nil
+}
+
+// checkRoomOptions validates a host's rules and fills in the default mode
+func (ms *MatchService) checkRoomOptions(options *RoomOptions) string {
+	if options.Mode == "" {
+		options.Mode = models.MatchModeRace
+	}
+	if options.Mode != models.MatchModeRace && options.Mode != models.MatchModeArena && options.Mode != models.MatchModeCoop {
+		return "Unknown match mode"
+	}
+	if options.TextPattern < 0 || options.TextPattern > len(game.TextPatterns) {
+		return "Unknown text pattern"
+	}
+	if options.PearlPoints < 0 || options.TargetScore < 0 {
+		return "Points must be positive"
+	}
+	if options.TimeLimit < 0 || time.Duration(options.TimeLimit)*time.Second > ms.cfg.MaxGameTime {
+		return "Time limit is out of range"
+	}
+	if options.MaxPlayers < 0 || options.MaxPlayers > ms.cfg.MatchMaxPlayers {
+		return "Too many players"
+	}
+	for _, key := range options.AllowedMotions {
+		if !game.IsMovementKey(key) {
+			return "Unknown motion: " + key
+		}
+	}
+	return ""
+}
+
+// newJoinCode returns a code no open room uses
+func (ms *MatchService) newJoinCode() (string, error) {
+	for {
+		code := make([]byte, joinCodeLength)
+		for i := range code {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(joinCodeAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			code[i] = joinCodeAlphabet[n.Int64()]
+		}
+
+		var taken int64
+		err := ms.db.Model(&models.Match{}).
+			Where("join_code = ? AND status IN ?", string(code), []string{models.MatchStatusWaiting, models.MatchStatusCountdown, models.MatchStatusRunning}).
+			Count(&taken).Error
+		if err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return string(code), nil
+		}
+	}
+}
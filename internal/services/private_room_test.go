@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/game"
+)
+
+func TestCheckRoomOptions(t *testing.T) {
+	ms := &MatchService{cfg: &config.Config{MaxGameTime: 10 * time.Minute, MatchMaxPlayers: 4}}
+
+	tests := []struct {
+		name     string
+		options  RoomOptions
+		want     string
+		wantMode string
+	}{
+		{"defaults to a race", RoomOptions{}, "", "race"},
+		{"coop room", RoomOptions{Mode: "coop", PearlPoints: 5, TimeLimit: 60}, "", "coop"},
+		{"unknown mode", RoomOptions{Mode: "golf"}, "Unknown match mode", "golf"},
+		{"unknown text", RoomOptions{TextPattern: len(game.TextPatterns) + 1}, "Unknown text pattern", "race"},
+		{"negative pearl points", RoomOptions{PearlPoints: -1}, "Points must be positive", "race"},
+		{"time limit past the maximum", RoomOptions{TimeLimit: 601}, "Time limit is out of range", "race"},
+		{"too many players", RoomOptions{MaxPlayers: 5}, "Too many players", "race"},
+		{"unknown motion", RoomOptions{AllowedMotions: []string{"h", "x"}}, "Unknown motion: x", "race"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := tt.options
+			if got := ms.checkRoomOptions(&options); got != tt.want {
+				t.Errorf("checkRoomOptions = %q, want %q", got, tt.want)
+			}
+			if options.Mode != tt.wantMode {
+				t.Errorf("mode = %q, want %q", options.Mode, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestRoomPearlPoints(t *testing.T) {
+	gs := newTestGameService(t)
+	cfg := gs.cfg
+
+	tests := []struct {
+		name        string
+		pearlPoints int
+		pearlType   int
+		want        int
+	}{
+		{"default pearl", 0, game.PEARL, cfg.PearlPoints},
+		{"room pearl", 7, game.PEARL, 7},
+		{"room golden pearl", 7, game.GOLDEN_PEARL, cfg.GoldenPearlPoints},
+		{"room black pearl", 7, game.BLACK_PEARL, -cfg.BlackPearlPenalty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionToken := startTestGameWith(t, gs, GameOptions{TextPattern: 1, Seed: 42, PearlPoints: tt.pearlPoints})
+
+			// Put the pearl right next to the player
+			gameSession := loadTestGame(t, gs, sessionToken)
+			direction := openDirection(t, gs, gameSession)
+			movement, _ := gs.calculateMove(gameSession, direction, gameSession.GetGameMap())
+			gameMap := gameSession.GetGameMap()
+			gameMap[movement.NewRow][movement.NewCol] = tt.pearlType
+			gameSession.SetGameMap(gameMap)
+			if err := gs.db.Save(gameSession).Error; err != nil {
+				t.Fatalf("place pearl: %v", err)
+			}
+
+			result, err := gs.ProcessMove(sessionToken, direction)
+			if err != nil {
+				t.Fatalf("ProcessMove: %v", err)
+			}
+			if !result["success"].(bool) {
+				t.Fatalf("move rejected: %v", result["error"])
+			}
+			if result["points_delta"] != tt.want {
+				t.Errorf("points_delta = %v, want %d", result["points_delta"], tt.want)
+			}
+			if saved := loadTestGame(t, gs, sessionToken); saved.CurrentScore != tt.want {
+				t.Errorf("saved score = %d, want %d", saved.CurrentScore, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Ratings follow Elo: a match of n players counts as n-1 duels per player,
+// each won by the better ranked one and drawn between players sharing a rank,
+// and the change is scaled down by n-1 so a crowded race moves a rating as
+// much as a duel. Provisional players have a doubled K factor so their rating
+// settles quickly.
+
+// rateMatch updates the ratings of the players of a finished match, ordered by rank
+func (ms *MatchService) rateMatch(tx *gorm.DB, match *models.Match) error {
+	if !match.IsRated() || len(match.Players) < 2 {
+		return nil
+	}
+
+	playerIDs := make([]uint, len(match.Players))
+	for i, entry := range match.Players {
+		playerIDs[i] = entry.PlayerID
+	}
+	var players []models.Player
+	if err := tx.Where("id IN ?", playerIDs).Find(&players).Error; err != nil {
+		return err
+	}
+	playersByID := make(map[uint]*models.Player, len(players))
+	for i := range players {
+		playersByID[players[i].ID] = &players[i]
+	}
+
+	ratings := make([]int, len(match.Players))
+	ranks := make([]int, len(match.Players))
+	for i, entry := range match.Players {
+		if player, exists := playersByID[entry.PlayerID]; exists {
+			ratings[i] = player.Rating
+		}
+		ranks[i] = entry.Rank
+	}
+
+	for i := range match.Players {
+		entry := &match.Players[i]
+		player, exists := playersByID[entry.PlayerID]
+		if !exists {
+			continue
+		}
+
+		provisional := ms.isProvisional(player)
+		change := ratingChange(ratings, ranks, i, ms.ratingK(provisional))
+		entry.RatingChange = &change
+
+		err := tx.Model(player).Updates(map[string]interface{}{
+			"rating":        player.Rating + change,
+			"rated_matches": gorm.Expr("rated_matches + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		history := models.RatingChange{
+			PlayerID:    player.ID,
+			MatchID:     match.ID,
+			OldRating:   ratings[i],
+			NewRating:   ratings[i] + change,
+			Rank:        entry.Rank,
+			Opponents:   len(match.Players) - 1,
+			Provisional: provisional,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ratingChange returns the rating change of the player at index i, a tie with an opponent scores half a win
+func ratingChange(ratings, ranks []int, i int, k float64) int {
+	var delta float64
+	for j, opponent := range ratings {
+		if j == i {
+			continue
+		}
+		expected := 1 / (1 + math.Pow(10, float64(opponent-ratings[i])/400))
+		actual := 0.0
+		switch {
+		case ranks[i] < ranks[j]:
+			actual = 1
+		case ranks[i] == ranks[j]:
+			actual = 0.5
+		}
+		delta += actual - expected
+	}
+	return int(math.Round(k * delta / float64(len(ratings)-1)))
+}
+
+// ratingK returns the K factor of a player
+func (ms *MatchService) ratingK(provisional bool) float64 {
+	if provisional {
+		return float64(ms.cfg.RatingK * 2)
+	}
+	return float64(ms.cfg.RatingK)
+}
+
+// isProvisional reports whether a player's rating is still settling
+func (ms *MatchService) isProvisional(player *models.Player) bool {
+	return player.RatedMatches < ms.cfg.RatingProvisional
+}
+
+// withinRatingBand reports whether a lobby suits a player's rating. The band
+// widens while the lobby waits so nobody waits forever, and provisional
+// players fit anywhere since their rating says little yet.
+func (ms *MatchService) withinRatingBand(match *models.Match, player *models.Player) (bool, error) {
+	if ms.isProvisional(player) {
+		return true, nil
+	}
+
+	var average *float64
+	err := ms.db.Model(&models.MatchPlayer{}).
+		Select("AVG(rating)").
+		Where("match_id = ?", match.ID).
+		Scan(&average).Error
+	if err != nil {
+		return false, err
+	}
+	if average == nil {
+		return true, nil
+	}
+
+	band := float64(ms.cfg.RatingBand) + float64(ms.cfg.RatingBandGrowth)*time.Since(match.CreatedAt).Seconds()
+	return math.Abs(*average-float64(player.Rating)) <= band, nil
+}
+
+// RatingHistory returns a registered player's rating and its recent changes
+func (ms *MatchService) RatingHistory(playerID uint) (map[string]interface{}, error) {
+	player, err := findPlayer(ms.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to see your rating",
+		}, nil
+	}
+
+	var changes []models.RatingChange
+	err = ms.db.Where("player_id = ?", player.ID).
+		Order("id DESC").
+		Limit(50).
+		Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":       true,
+		"rating":        player.Rating,
+		"rated_matches": player.RatedMatches,
+		"provisional":   ms.isProvisional(player),
+		"history":       changes,
+	}, nil
+}
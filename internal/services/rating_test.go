@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/models"
+)
+
+func TestRatingChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratings []int // ordered by rank
+		ranks   []int
+		k       float64
+		want    []int
+	}{
+		{"even duel", []int{1200, 1200}, []int{1, 2}, 32, []int{16, -16}},
+		{"favourite wins", []int{1400, 1200}, []int{1, 2}, 32, []int{8, -8}},
+		{"upset", []int{1200, 1400}, []int{1, 2}, 32, []int{24, -24}},
+		{"three even players", []int{1200, 1200, 1200}, []int{1, 2, 3}, 32, []int{16, 0, -16}},
+		{"crowded race moves as much as a duel", []int{1500, 1500, 1500, 1500}, []int{1, 2, 3, 4}, 32, []int{16, 5, -5, -16}},
+		{"doubled provisional K", []int{1200, 1200}, []int{1, 2}, 64, []int{32, -32}},
+		{"even tie", []int{1200, 1200}, []int{1, 1}, 32, []int{0, 0}},
+		{"tie with the favourite", []int{1400, 1200}, []int{1, 1}, 32, []int{-8, 8}},
+		{"tie for the first place", []int{1200, 1200, 1200}, []int{1, 1, 3}, 32, []int{8, 8, -16}},
+		{"tie for the last place", []int{1200, 1200, 1200}, []int{1, 2, 2}, 32, []int{16, -8, -8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := 0
+			for i, want := range tt.want {
+				got := ratingChange(tt.ratings, tt.ranks, i, tt.k)
+				if got != want {
+					t.Errorf("change of rank %d = %d, want %d", i+1, got, want)
+				}
+				sum += got
+			}
+			if sum != 0 {
+				t.Errorf("changes add up to %d, want 0", sum)
+			}
+		})
+	}
+}
+
+func TestRatingKAndProvisional(t *testing.T) {
+	ms := &MatchService{cfg: &config.Config{RatingK: 24, RatingProvisional: 5}}
+
+	tests := []struct {
+		ratedMatches    int
+		wantProvisional bool
+		wantK           float64
+	}{
+		{0, true, 48},
+		{4, true, 48},
+		{5, false, 24},
+		{40, false, 24},
+	}
+	for _, tt := range tests {
+		provisional := ms.isProvisional(&models.Player{RatedMatches: tt.ratedMatches})
+		if provisional != tt.wantProvisional {
+			t.Errorf("%d rated matches: provisional = %v, want %v", tt.ratedMatches, provisional, tt.wantProvisional)
+		}
+		if k := ms.ratingK(provisional); k != tt.wantK {
+			t.Errorf("%d rated matches: K = %v, want %v", tt.ratedMatches, k, tt.wantK)
+		}
+	}
+}
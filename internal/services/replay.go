@@ -0,0 +1,397 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// A replay is what it takes to play a finished game again: its seed, the text
+// it was played on and its logged moves with the time each one was played.
+// Replays are re-run through the same movement, pearl and enemy code as live
+// games, so a replay shows what the server computed rather than what a client drew.
+
+// ReplayURL is the shareable page replaying a finished game
+func ReplayURL(gameID uint) string {
+	return "/replay/" + strconv.FormatUint(uint64(gameID), 10)
+}
+
+// textID names the text a new session is played on so a replay can rebuild its map
+func textID(opts GameOptions) string {
+	switch {
+	case opts.CampaignLevelID != nil:
+		return fmt.Sprintf("campaign:%d", *opts.CampaignLevelID)
+	case opts.Level != nil:
+		return "level:" + opts.Level.Name
+	default:
+		return fmt.Sprintf("pattern:%d", game.SessionPattern(opts.TextPattern, opts.Seed))
+	}
+}
+
+// GetReplay returns the compact replay of a finished game
+func (gs *GameService) GetReplay(gameID uint) (map[string]interface{}, error) {
+	gameSession, moves, failure, err := gs.loadReplay(gameID)
+	if failure != nil || err != nil {
+		return failure, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"replay":  gs.replayData(gameSession, moves),
+	}, nil
+}
+
+// ReplayPage returns what the replay page of a finished game renders, the
+// game as it was before the first move
+func (gs *GameService) ReplayPage(gameID uint) (map[string]interface{}, error) {
+	gameSession, _, failure, err := gs.loadReplay(gameID)
+	if failure != nil || err != nil {
+		return failure, err
+	}
+
+	replayed, err := gs.replayStart(gameSession)
+	if errors.Is(err, errUnknownText) {
+		return replayFailure("The game's text is gone, it can't be replayed anymore"), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"success":   true,
+		"game_data": gs.gameData(replayed),
+	}, nil
+}
+
+// GetReplayFrames re-runs a finished game and returns its state after every
+// move, for the replay page to step through
+func (gs *GameService) GetReplayFrames(gameID uint) (map[string]interface{}, error) {
+	gameSession, moves, failure, err := gs.loadReplay(gameID)
+	if failure != nil || err != nil {
+		return failure, err
+	}
+
+	frames := make([]map[string]interface{}, 0, len(moves))
+	var start map[string]interface{}
+	complete := true
+	err = gs.replayRun(gameSession, moves, func(replayed *models.GameSession, move *models.GameMove, before [][]int) error {
+		switch {
+		case before == nil:
+			start = gs.gameData(replayed)
+		case move == nil:
+			frames = append(frames, replayFrame(replayed, "", gameSession.EndTime.Sub(*gameSession.StartTime), before))
+		default:
+			frames = append(frames, replayFrame(replayed, move.Direction, move.PlayedAt.Sub(*gameSession.StartTime), before))
+		}
+		return nil
+	})
+	var diverged *replayDivergence
+	if errors.As(err, &diverged) {
+		// The game can't be played the same way anymore, e.g. a level file changed
+		complete = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":   true,
+		"replay":    gs.replayData(gameSession, moves),
+		"game_data": start,
+		"frames":    frames,
+		"complete":  complete,
+	}, nil
+}
+
+// replayDivergence is why a replayed game stopped playing like the original
+type replayDivergence struct {
+	reason string
+}
+
+func (d *replayDivergence) Error() string {
+	return d.reason
+}
+
+// replayRun re-runs a finished game from its start, calling step with the
+// starting state, after every logged move with the map before it, and once
+// more with no move if pearls expired after the last move. A move that can't
+// be played again stops the run with a *replayDivergence.
+func (gs *GameService) replayRun(gameSession *models.GameSession, moves []models.GameMove, step func(replayed *models.GameSession, move *models.GameMove, before [][]int) error) error {
+	replayed, err := gs.replayStart(gameSession)
+	if errors.Is(err, errUnknownText) {
+		// The text is gone since, e.g. a level file was removed
+		return &replayDivergence{reason: err.Error()}
+	} else if err != nil {
+		return err
+	}
+	if err := step(replayed, nil, nil); err != nil {
+		return err
+	}
+
+	for i := range moves {
+		before := replayed.GetGameMap()
+		if err := gs.replayMove(replayed, moves[i].Direction, moves[i].PlayedAt); err != nil {
+			return &replayDivergence{reason: fmt.Sprintf("move %d (%s): %v", moves[i].Sequence, moves[i].Direction, err)}
+		}
+		if err := step(replayed, &moves[i], before); err != nil {
+			return err
+		}
+	}
+
+	// Survival pearls kept expiring after the last move until the lives ran out
+	if replayed.IsActive && gameSession.EndTime != nil {
+		before := replayed.GetGameMap()
+		if gs.expireReplayedPearls(replayed, *gameSession.EndTime) > 0 {
+			return step(replayed, nil, before)
+		}
+	}
+	return nil
+}
+
+// loadReplay loads a finished game and its moves, returning the failure
+// response when it can't be replayed
+func (gs *GameService) loadReplay(gameID uint) (*models.GameSession, []models.GameMove, map[string]interface{}, error) {
+	var gameSession models.GameSession
+	if err := gs.db.Preload("Player").First(&gameSession, gameID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, replayFailure("Replay not found"), nil
+		}
+		return nil, nil, nil, err
+	}
+
+	// Replays give away the seed, so they wait for the game to end
+	if gameSession.IsActive {
+		return nil, nil, replayFailure("The game is still running"), nil
+	}
+	if gameSession.TextID == "" || gameSession.StartTime == nil {
+		return nil, nil, replayFailure("No replay was recorded for this game"), nil
+	}
+	if gameSession.MatchID != nil {
+		var match models.Match
+		if err := gs.db.First(&match, *gameSession.MatchID).Error; err != nil {
+			return nil, nil, nil, err
+		}
+		// Other players changed a shared map between this player's moves
+		if match.SharesMap() {
+			return nil, nil, replayFailure("Games on a shared map can't be replayed"), nil
+		}
+		// The other players of the match are still on the same seed
+		if match.Status != models.MatchStatusFinished && match.Status != models.MatchStatusAbandoned {
+			return nil, nil, replayFailure("The match is still running"), nil
+		}
+		// Players of a round could still learn from each other's runs, replays wait for the whole round
+		if match.TournamentID != nil {
+			var playing int64
+			err := gs.db.Model(&models.TournamentPairing{}).
+				Where("tournament_id = ? AND status = ?", *match.TournamentID, models.PairingStatusPlaying).
+				Where("round = (?)", gs.db.Model(&models.TournamentPairing{}).Select("round").Where("match_id = ?", match.ID)).
+				Count(&playing).Error
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if playing > 0 {
+				return nil, nil, replayFailure("The tournament round is still running"), nil
+			}
+		}
+	}
+
+	var moves []models.GameMove
+	if err := gs.db.Where("game_session_id = ?", gameSession.ID).Order("sequence ASC").Find(&moves).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	return &gameSession, moves, nil, nil
+}
+
+// replayData describes a replay in its compact form
+func (gs *GameService) replayData(gameSession *models.GameSession, moves []models.GameMove) map[string]interface{} {
+	username := "Anonymous"
+	if gameSession.PlayerID != 0 {
+		username = gameSession.Player.Username
+	}
+
+	keys := make([]map[string]interface{}, 0, len(moves))
+	for _, move := range moves {
+		keys = append(keys, map[string]interface{}{
+			"key":   move.Direction,
+			"at_ms": move.PlayedAt.Sub(*gameSession.StartTime).Milliseconds(),
+		})
+	}
+
+	return map[string]interface{}{
+		"game_id":         gameSession.ID,
+		"username":        username,
+		"mode":            gameSession.Mode,
+		"seed":            gameSession.Seed,
+		"text_id":         gameSession.TextID,
+		"pearl_strategy":  gameSession.PearlStrategy,
+		"target_score":    gs.targetScore(gameSession),
+		"final_score":     gameSession.FinalScore,
+		"completion_time": gameSession.CompletionTime,
+		"end_reason":      gameSession.EndReason,
+		"replay_status":   gameSession.ReplayStatus,
+		"keys":            keys,
+		"replay_url":      ReplayURL(gameSession.ID),
+	}
+}
+
+// replayStart rebuilds a game as it was when it started, from its seed and text.
+// The copy has no player, so nothing replayed on it touches player stats.
+func (gs *GameService) replayStart(gameSession *models.GameSession) (*models.GameSession, error) {
+	gameData, err := gs.textGameData(gameSession.TextID, gameSession.PearlStrategy, gameSession.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed := &models.GameSession{
+		SelectedCharacter: gameSession.SelectedCharacter,
+		Mode:              gameSession.Mode,
+		PearlStrategy:     gameSession.PearlStrategy,
+		TargetScore:       gameSession.TargetScore,
+		TimeLimit:         gameSession.TimeLimit,
+		PearlPoints:       gameSession.PearlPoints,
+		AllowedMotions:    gameSession.AllowedMotions,
+		BlockedMotions:    gameSession.BlockedMotions,
+		Seed:              gameSession.Seed,
+		TextID:            gameSession.TextID,
+		PearlSpawns:       gameData["pearl_spawns"].(int),
+		StartTime:         gameSession.StartTime,
+		CurrentRow:        gameData["player_pos"].(map[string]int)["row"],
+		CurrentCol:        gameData["player_pos"].(map[string]int)["col"],
+		PreferredColumn:   gameData["preferred_column"].(int),
+		IsActive:          true,
+	}
+	if replayed.IsSurvival() {
+		replayed.Lives = gs.cfg.SurvivalLives
+	}
+
+	replayed.SetGameMap(gameData["game_map"].([][]int))
+	replayed.SetTextGrid(gameData["text_grid"].([][]string))
+	replayed.SetPearlTimers(gs.initialPearlTimers(replayed, *gameSession.StartTime))
+	replayed.SetEnemies(gameData["enemies"].([]game.Enemy))
+	return replayed, nil
+}
+
+// errUnknownText is returned for a text id naming no text the server knows
+var errUnknownText = errors.New("unknown text")
+
+// textGameData creates the starting map of a game on the text named by textID
+func (gs *GameService) textGameData(textID, strategy string, seed int64) (map[string]interface{}, error) {
+	kind, name, _ := strings.Cut(textID, ":")
+	switch kind {
+	case "pattern":
+		pattern, err := strconv.Atoi(name)
+		if err != nil || pattern <= 0 || pattern > len(game.TextPatterns) {
+			return nil, fmt.Errorf("%w: %s", errUnknownText, textID)
+		}
+		return game.InitializePatternSession(pattern, strategy, seed), nil
+	case "level":
+		level, exists := gs.levels[name]
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", errUnknownText, textID)
+		}
+		return game.InitializeLevelSession(level, strategy, seed), nil
+	case "campaign":
+		var level models.CampaignLevel
+		if err := gs.db.Where("id = ?", name).First(&level).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: %s", errUnknownText, textID)
+			}
+			return nil, err
+		}
+		gameLevel, err := campaignGameLevel(&level)
+		if err != nil {
+			return nil, err
+		}
+		return game.InitializeLevelSession(gameLevel, strategy, seed), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownText, textID)
+	}
+}
+
+// replayMove plays a logged move again as it was played at playedAt, the way
+// ProcessMove did without its timing checks
+func (gs *GameService) replayMove(gameSession *models.GameSession, direction string, playedAt time.Time) error {
+	if !gameSession.IsActive {
+		return errors.New("move after the game ended")
+	}
+	gs.expireReplayedPearls(gameSession, playedAt)
+	if !gameSession.IsActive {
+		return errors.New("move after the last life was lost")
+	}
+
+	movementResult, failure := gs.calculateMove(gameSession, direction, gameSession.GetGameMap())
+	if failure != nil {
+		return errors.New(failure["error"].(string))
+	}
+
+	pearlType := game.EMPTY
+	points := 0
+	target := gameSession.GetGameMap()[movementResult.NewRow][movementResult.NewCol]
+	if game.IsPearl(target) {
+		pearlType = target
+		points = gs.pearlValue(gameSession, target)
+	}
+
+	gameSession.ApplyMove(movementResult.NewRow, movementResult.NewCol, movementResult.PreferredColumn, pearlType, points, playedAt)
+	if gs.settleMove(gameSession, pearlType, target == game.ENEMY, playedAt) {
+		gameSession.FailGame(models.EndReasonCaught)
+	} else if !gameSession.CountsPearls() && gameSession.CurrentScore >= gs.targetScore(gameSession) {
+		gameSession.CompleteGame()
+	}
+	return nil
+}
+
+// expireReplayedPearls expires the pearls of a replayed game whose timer ran
+// out by now and returns how many did, the way the live game expired them
+func (gs *GameService) expireReplayedPearls(gameSession *models.GameSession, now time.Time) int {
+	count := gs.expirePearls(gameSession, now)
+	gs.loseLives(nil, gameSession, count)
+	return count
+}
+
+// nextPearlExpiry returns when the first of the timers runs out
+func nextPearlExpiry(timers []models.PearlTimer) (time.Time, bool) {
+	if len(timers) == 0 {
+		return time.Time{}, false
+	}
+	next := timers[0].ExpiresAt
+	for _, timer := range timers[1:] {
+		if timer.ExpiresAt.Before(next) {
+			next = timer.ExpiresAt
+		}
+	}
+	return next, true
+}
+
+// replayFrame describes a replayed game after a move played at offset from
+// its start, with the cells that changed since before
+func replayFrame(gameSession *models.GameSession, key string, offset time.Duration, before [][]int) map[string]interface{} {
+	frame := map[string]interface{}{
+		"key":   key,
+		"at_ms": offset.Milliseconds(),
+		"player_pos": map[string]int{
+			"row": gameSession.CurrentRow,
+			"col": gameSession.CurrentCol,
+		},
+		"score":         gameSession.CurrentScore,
+		"changed_cells": game.DiffMaps(before, gameSession.GetGameMap()),
+		"game_over":     !gameSession.IsActive,
+		"end_reason":    gameSession.EndReason,
+	}
+	if gameSession.IsSurvival() {
+		frame["lives"] = gameSession.Lives
+	}
+	return frame
+}
+
+// replayFailure is the response for a game that can't be replayed
+func replayFailure(message string) map[string]interface{} {
+	return map[string]interface{}{
+		"success": false,
+		"error":   message,
+	}
+}
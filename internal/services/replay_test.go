@@ -0,0 +1,204 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+)
+
+// towardsPearl returns the move bringing the session closest to a pearl
+func towardsPearl(t *testing.T, gs *GameService, gameSession *models.GameSession) string {
+	t.Helper()
+	gameMap := gameSession.GetGameMap()
+	directions := []string{"h", "j", "k", "l", "w", "b", "e"}
+
+	best, bestDistance := "", -1
+	for _, direction := range directions {
+		result, failure := gs.calculateMove(gameSession, direction, gameMap)
+		if failure != nil {
+			continue
+		}
+		for row := range gameMap {
+			for col, cell := range gameMap[row] {
+				if !game.IsPearl(cell) {
+					continue
+				}
+				distance := game.ShortestPath(gameMap, gameSession.GetTextGrid(), result.NewRow, result.NewCol, result.PreferredColumn, row, col, directions, 20)
+				if distance >= 0 && (bestDistance < 0 || distance < bestDistance) {
+					best, bestDistance = direction, distance
+				}
+			}
+		}
+	}
+	if best == "" {
+		return openDirection(t, gs, gameSession)
+	}
+	return best
+}
+
+// playTowardsPearls plays up to moves moves heading for pearls, stopping when the game ends
+func playTowardsPearls(t *testing.T, gs *GameService, sessionToken string, moves int) *models.GameSession {
+	t.Helper()
+	for i := 0; i < moves; i++ {
+		gameSession := loadTestGame(t, gs, sessionToken)
+		if !gameSession.IsActive {
+			return gameSession
+		}
+		result, err := gs.ProcessMove(sessionToken, towardsPearl(t, gs, gameSession))
+		if err != nil {
+			t.Fatalf("move %d: %v", i+1, err)
+		}
+		if !result["success"].(bool) {
+			t.Fatalf("move %d rejected: %v", i+1, result["error"])
+		}
+		// Let the move cooldown pass
+		time.Sleep(55 * time.Millisecond)
+	}
+	return loadTestGame(t, gs, sessionToken)
+}
+
+// checkReplayMatches re-runs a game from its logged moves and checks it ends where the live game is
+func checkReplayMatches(t *testing.T, gs *GameService, live *models.GameSession) {
+	t.Helper()
+	var moves []models.GameMove
+	if err := gs.db.Where("game_session_id = ?", live.ID).Order("sequence ASC").Find(&moves).Error; err != nil {
+		t.Fatalf("load moves: %v", err)
+	}
+	var replayed *models.GameSession
+	err := gs.replayRun(live, moves, func(state *models.GameSession, move *models.GameMove, before [][]int) error {
+		replayed = state
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replayRun: %v", err)
+	}
+
+	if replayed.CurrentRow != live.CurrentRow || replayed.CurrentCol != live.CurrentCol {
+		t.Errorf("replay ends on %d,%d, the game on %d,%d", replayed.CurrentRow, replayed.CurrentCol, live.CurrentRow, live.CurrentCol)
+	}
+	if replayed.CurrentScore != live.CurrentScore || replayed.TotalMoves != live.TotalMoves || replayed.Lives != live.Lives {
+		t.Errorf("replay scored %d in %d moves with %d lives, the game %d in %d with %d",
+			replayed.CurrentScore, replayed.TotalMoves, replayed.Lives, live.CurrentScore, live.TotalMoves, live.Lives)
+	}
+	if !reflect.DeepEqual(replayed.GetGameMap(), live.GetGameMap()) {
+		t.Error("replayed map differs from the game's")
+	}
+	// Pearl timers start when the move that spawned them was played
+	replayedTimers, liveTimers := replayed.GetPearlTimers(), live.GetPearlTimers()
+	if len(replayedTimers) != len(liveTimers) {
+		t.Fatalf("replay has %d pearl timers, the game %d", len(replayedTimers), len(liveTimers))
+	}
+	for i := range liveTimers {
+		if replayedTimers[i].Row != liveTimers[i].Row || replayedTimers[i].Col != liveTimers[i].Col || !replayedTimers[i].ExpiresAt.Equal(liveTimers[i].ExpiresAt) {
+			t.Errorf("pearl timer %d is %+v in the replay, %+v in the game", i, replayedTimers[i], liveTimers[i])
+		}
+	}
+}
+
+func TestReplayRunMatchesLiveGame(t *testing.T) {
+	tests := []struct {
+		name string
+		opts GameOptions
+	}{
+		{"classic", GameOptions{TextPattern: 1, Seed: 42}},
+		{"time attack", GameOptions{Mode: models.GameModeTimeAttack, TimeLimit: 60, TextPattern: 2, Seed: 7}},
+		{"survival", GameOptions{Mode: models.GameModeSurvival, PearlStrategy: game.PearlStrategyClassic, Lives: 3, TextPattern: 3, Seed: 99}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := newTestGameService(t)
+			live := playTowardsPearls(t, gs, startTestGameWith(t, gs, tt.opts), 12)
+			if live.PearlsCollected+live.GoldenPearlsCollected+live.BlackPearlsCollected == 0 {
+				t.Fatal("no pearl was collected, the replay has nothing to settle")
+			}
+
+			checkReplayMatches(t, gs, live)
+		})
+	}
+}
+
+func TestLoadReplayWaitsForMatchAndRound(t *testing.T) {
+	tests := []struct {
+		name          string
+		matchStatus   string // no match when empty
+		tournament    bool
+		otherPairing  string // status of another pairing of the same round
+		wantAvailable bool
+	}{
+		{"solo game", "", false, "", true},
+		{"match still running", models.MatchStatusRunning, false, "", false},
+		{"match finished", models.MatchStatusFinished, false, "", true},
+		{"match abandoned", models.MatchStatusAbandoned, false, "", true},
+		{"tournament round still running", models.MatchStatusFinished, true, models.PairingStatusPlaying, false},
+		{"tournament round finished", models.MatchStatusFinished, true, models.PairingStatusFinished, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := newTestGameService(t)
+			gameSession := loadTestGame(t, gs, startTestGame(t, gs))
+			gameSession.FailGame(models.EndReasonCaught)
+
+			if tt.matchStatus != "" {
+				match := models.Match{Mode: models.MatchModeRace, Status: tt.matchStatus}
+				if tt.tournament {
+					tournament := models.Tournament{Name: "cup", Status: models.TournamentStatusRunning, CurrentRound: 1}
+					if err := gs.db.Create(&tournament).Error; err != nil {
+						t.Fatal(err)
+					}
+					match.TournamentID = &tournament.ID
+				}
+				if err := gs.db.Create(&match).Error; err != nil {
+					t.Fatal(err)
+				}
+				gameSession.MatchID = &match.ID
+
+				if tt.tournament {
+					pairings := []models.TournamentPairing{
+						{TournamentID: *match.TournamentID, Round: 1, Bracket: models.BracketSwiss, Player1ID: 1, MatchID: &match.ID, Status: models.PairingStatusFinished},
+						{TournamentID: *match.TournamentID, Round: 1, Bracket: models.BracketSwiss, Player1ID: 2, Status: tt.otherPairing},
+					}
+					if err := gs.db.Create(&pairings).Error; err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := gs.db.Save(gameSession).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, failure, err := gs.loadReplay(gameSession.ID)
+			if err != nil {
+				t.Fatalf("loadReplay: %v", err)
+			}
+			if available := failure == nil; available != tt.wantAvailable {
+				t.Errorf("replay available = %v (%v), want %v", available, failure["error"], tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestSurvivalReplayAfterRestart(t *testing.T) {
+	gs := newTestGameService(t)
+	gs.cfg.SurvivalPearlLife = 300 * time.Millisecond
+	gs.cfg.SurvivalMinPearlLife = 300 * time.Millisecond
+	gs.cfg.SurvivalLives = 50
+	sessionToken := startTestGameWith(t, gs, GameOptions{Mode: models.GameModeSurvival, PearlStrategy: game.PearlStrategyClassic, Lives: gs.cfg.SurvivalLives, TextPattern: 3, Seed: 99})
+	playTowardsPearls(t, gs, sessionToken, 2)
+
+	// The server is down while two countdowns run out, then resumes the game
+	// and expires everything that ran out at once
+	time.Sleep(700 * time.Millisecond)
+	result, err := gs.ExpireSurvivalPearls(sessionToken)
+	if err != nil || result == nil {
+		t.Fatalf("ExpireSurvivalPearls: %v", err)
+	}
+	if lives := loadTestGame(t, gs, sessionToken).Lives; lives == gs.cfg.SurvivalLives {
+		t.Fatal("no pearl expired while the server was down")
+	}
+
+	live := playTowardsPearls(t, gs, sessionToken, 2)
+	checkReplayMatches(t, gs, live)
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Leaderboard entries are only listed once the server has played them again.
+// A finished game is re-run from its seed and logged moves through the same
+// movement, pearl and enemy code as live games, and every move has to land
+// where it was logged and score what it was logged with. Games that don't
+// reproduce are marked invalid and stay off the leaderboard. The checks run in
+// the background, so the leaderboard only reads the status they leave behind.
+
+// replayClockSlack is how long after a time attack's clock ran out its last
+// move may be logged, the clock is checked before the move is applied
+const replayClockSlack = time.Second
+
+// leaderboardCandidates selects the finished games that can make the leaderboard
+func (gs *GameService) leaderboardCandidates() *gorm.DB {
+	return gs.db.Model(&models.GameSession{}).
+		Where("is_completed = ? AND player_id > 0", true). // Exclude anonymous sessions
+		Where("campaign_level_id IS NULL").                // Campaign runs have their own targets
+		Where("match_id IS NULL").                         // Online matches record their own results
+		Where("ghost_session_id IS NULL")                  // Ghost races replay a seed the player already knows
+}
+
+// leaderboardListed narrows the candidates to the games the leaderboard lists:
+// reproduced on replay, or older than replays, and not held back by the anti-cheat review
+func (gs *GameService) leaderboardListed() *gorm.DB {
+	return gs.leaderboardCandidates().
+		Where("replay_status IN ?", []string{models.ReplayVerified, models.ReplayLegacy}).
+		Where("review_status <> ?", models.ReviewConfirmed).
+		Where("NOT (review_status = ? AND cheat_score >= ?)", models.ReviewPending, gs.cfg.CheatExcludeScore)
+}
+
+// StartReplayVerifier marks the games finished before moves were logged as
+// legacy, then checks newly finished games every ReplayCheckInterval
+func (gs *GameService) StartReplayVerifier() {
+	if err := gs.markLegacyReplays(); err != nil {
+		log.Printf("Failed to mark legacy games: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(gs.cfg.ReplayCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := gs.verifyPendingReplays(); err != nil {
+				log.Printf("Failed to verify finished games: %v", err)
+			}
+		}
+	}()
+}
+
+// markLegacyReplays moves the unchecked games that were finished before the
+// server logged moves to legacy, there is nothing to replay them from
+func (gs *GameService) markLegacyReplays() error {
+	logged := gs.db.Model(&models.GameMove{}).Select("1").Where("game_moves.game_session_id = game_sessions.id")
+	return gs.db.Model(&models.GameSession{}).
+		Where("is_active = ? AND replay_status = ?", false, models.ReplayPending).
+		Where("text_id IS NULL OR text_id = '' OR start_time IS NULL OR (total_moves > 0 AND NOT EXISTS (?))", logged).
+		UpdateColumn("replay_status", models.ReplayLegacy).Error
+}
+
+// verifyPendingReplays re-runs the leaderboard candidates that have not been
+// checked yet and records whether they reproduce, the ones that do are
+// scored by the anti-cheat checks
+func (gs *GameService) verifyPendingReplays() error {
+	var gameSessions []models.GameSession
+	if err := gs.leaderboardCandidates().Where("replay_status = ?", models.ReplayPending).Find(&gameSessions).Error; err != nil {
+		return err
+	}
+
+	for i := range gameSessions {
+		// A game that can't be checked doesn't hold up the others, it stays pending for the next pass
+		if err := gs.checkReplay(&gameSessions[i]); err != nil {
+			log.Printf("Failed to check the replay of game %d: %v", gameSessions[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// checkReplay re-runs one finished game and saves the outcome. A replay or
+// analysis that fails marks the game invalid with the error as its issue,
+// only database errors leave it pending.
+func (gs *GameService) checkReplay(gameSession *models.GameSession) error {
+	var moves []models.GameMove
+	if err := gs.db.Where("game_session_id = ?", gameSession.ID).Order("sequence ASC").Find(&moves).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"replay_status": models.ReplayVerified,
+	}
+	issue, err := gs.verifyReplay(gameSession, moves)
+	if err != nil {
+		issue = "the replay failed: " + err.Error()
+	}
+	if issue == "" {
+		report, err := gs.analyseMoves(gameSession, moves)
+		if err != nil {
+			issue = "the anti-cheat checks failed: " + err.Error()
+		} else {
+			updates["cheat_score"] = report.score
+			updates["cheat_flags"] = strings.Join(report.flags, ",")
+			updates["review_status"] = report.status(gs.cfg.CheatReviewScore)
+			if report.score >= gs.cfg.CheatReviewScore {
+				log.Printf("Game %d queued for review with cheat score %d: %s", gameSession.ID, report.score, strings.Join(report.flags, ", "))
+			}
+		}
+	}
+	updates["replay_issue"] = issue
+	if issue != "" {
+		updates["replay_status"] = models.ReplayInvalid
+		log.Printf("Game %d failed its replay check: %s", gameSession.ID, issue)
+	}
+	return gs.db.Model(&models.GameSession{}).Where("id = ?", gameSession.ID).UpdateColumns(updates).Error
+}
+
+// verifyReplay re-runs a finished game and returns why it doesn't reproduce
+// the logged game, empty when it does
+func (gs *GameService) verifyReplay(gameSession *models.GameSession, moves []models.GameMove) (string, error) {
+	if gameSession.TextID == "" || gameSession.StartTime == nil {
+		return "no replay was recorded", nil
+	}
+
+	if len(moves) != gameSession.TotalMoves {
+		return fmt.Sprintf("%d moves logged for %d moves played", len(moves), gameSession.TotalMoves), nil
+	}
+	for i, move := range moves {
+		if move.Sequence != i+1 {
+			return fmt.Sprintf("move %d is logged as move %d", i+1, move.Sequence), nil
+		}
+	}
+
+	clockEnd := gameSession.StartTime.Add(gs.timeLimit(gameSession) + replayClockSlack)
+	var replayed *models.GameSession
+	score := 0
+	err := gs.replayRun(gameSession, moves, func(state *models.GameSession, move *models.GameMove, before [][]int) error {
+		replayed = state
+		if move == nil {
+			score = state.CurrentScore
+			return nil
+		}
+
+		if state.CurrentRow != move.ToRow || state.CurrentCol != move.ToCol {
+			return &replayDivergence{reason: fmt.Sprintf("move %d lands on %d,%d but was logged on %d,%d",
+				move.Sequence, state.CurrentRow, state.CurrentCol, move.ToRow, move.ToCol)}
+		}
+		if state.CurrentScore-score != move.Points {
+			return &replayDivergence{reason: fmt.Sprintf("move %d scores %d but was logged with %d",
+				move.Sequence, state.CurrentScore-score, move.Points)}
+		}
+		if move.PlayedAt.After(clockEnd) {
+			return &replayDivergence{reason: fmt.Sprintf("move %d was played after the clock ran out", move.Sequence)}
+		}
+		score = state.CurrentScore
+		return nil
+	})
+	var diverged *replayDivergence
+	if errors.As(err, &diverged) {
+		return diverged.reason, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	switch {
+	case replayed.CurrentScore != gameSession.CurrentScore:
+		return fmt.Sprintf("the replay scores %d instead of %d", replayed.CurrentScore, gameSession.CurrentScore), nil
+	case replayed.PearlsCollected != gameSession.PearlsCollected ||
+		replayed.GoldenPearlsCollected != gameSession.GoldenPearlsCollected ||
+		replayed.BlackPearlsCollected != gameSession.BlackPearlsCollected:
+		return "the replay collects other pearls", nil
+	case !gameSession.CountsPearls() && !replayed.IsCompleted:
+		return "the replay doesn't reach the target score", nil
+	case gameSession.EndReason == models.EndReasonOutOfLives && replayed.Lives > 0:
+		return "the replay still has lives left", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+)
+
+// finishTestGame plays a registered classic game to a low target score and
+// returns it with its logged moves
+func finishTestGame(t *testing.T, gs *GameService) (*models.GameSession, []models.GameMove) {
+	t.Helper()
+	player := models.Player{Username: "racer", Email: "racer@example.com", IsRegistered: true}
+	if err := gs.db.Create(&player).Error; err != nil {
+		t.Fatal(err)
+	}
+	result, err := gs.CreateGame(player.ID, "", GameOptions{TextPattern: 1, Seed: 42, TargetScore: 2 * gs.cfg.PearlPoints, PearlStrategy: game.PearlStrategyRegular})
+	if err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+
+	gameSession := playTowardsPearls(t, gs, result["session_token"].(string), 60)
+	if !gameSession.IsCompleted {
+		t.Fatalf("game not completed after %d moves, score %d", gameSession.TotalMoves, gameSession.CurrentScore)
+	}
+	var moves []models.GameMove
+	if err := gs.db.Where("game_session_id = ?", gameSession.ID).Order("sequence ASC").Find(&moves).Error; err != nil {
+		t.Fatalf("load moves: %v", err)
+	}
+	return gameSession, moves
+}
+
+func TestVerifyReplay(t *testing.T) {
+	gs := newTestGameService(t)
+	played, playedMoves := finishTestGame(t, gs)
+
+	tests := []struct {
+		name      string
+		tamper    func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove
+		wantIssue string
+	}{
+		{"untouched game", func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove { return moves }, ""},
+		{"no text", func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove {
+			gameSession.TextID = ""
+			return moves
+		}, "no replay was recorded"},
+		{"missing move", func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove {
+			return moves[:len(moves)-1]
+		}, "moves logged"},
+		{"moved elsewhere", func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove {
+			moves[0].ToCol++
+			return moves
+		}, "move 1 lands on"},
+		{"points added to a move", func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove {
+			moves[len(moves)-1].Points += 50
+			return moves
+		}, "was logged with"},
+		{"score raised", func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove {
+			gameSession.CurrentScore += 100
+			return moves
+		}, "the replay scores"},
+		{"other seed", func(gameSession *models.GameSession, moves []models.GameMove) []models.GameMove {
+			gameSession.Seed++
+			return moves
+		}, "move"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameSession := *played
+			moves := tt.tamper(&gameSession, append([]models.GameMove(nil), playedMoves...))
+			issue, err := gs.verifyReplay(&gameSession, moves)
+			if err != nil {
+				t.Fatalf("verifyReplay: %v", err)
+			}
+			if tt.wantIssue == "" && issue != "" {
+				t.Fatalf("verifyReplay found %q, want no issue", issue)
+			}
+			if !strings.Contains(issue, tt.wantIssue) {
+				t.Errorf("verifyReplay found %q, want an issue containing %q", issue, tt.wantIssue)
+			}
+		})
+	}
+}
+
+// leaderboardGameIDs returns the game ids on the score leaderboard
+func leaderboardGameIDs(t *testing.T, gs *GameService) []uint {
+	t.Helper()
+	result, err := gs.GetLeaderboard("score", 10)
+	if err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	var ids []uint
+	entries, _ := result["leaderboard"].([]map[string]interface{})
+	for _, entry := range entries {
+		ids = append(ids, entry["game_id"].(uint))
+	}
+	return ids
+}
+
+func TestLeaderboardListsCheckedGames(t *testing.T) {
+	gs := newTestGameService(t)
+	gameSession, _ := finishTestGame(t, gs)
+
+	// A game finished before moves were logged
+	legacyScore := 500
+	legacy := models.GameSession{
+		SessionToken: "legacy",
+		PlayerID:     gameSession.PlayerID,
+		Mode:         models.GameModeClassic,
+		FinalScore:   &legacyScore,
+		TotalMoves:   30,
+		IsCompleted:  true,
+		EndTime:      gameSession.EndTime,
+	}
+	if err := gs.db.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading the leaderboard doesn't check anything itself
+	if ids := leaderboardGameIDs(t, gs); len(ids) != 0 {
+		t.Fatalf("unchecked games listed: %v", ids)
+	}
+
+	if err := gs.markLegacyReplays(); err != nil {
+		t.Fatalf("markLegacyReplays: %v", err)
+	}
+	if err := gs.verifyPendingReplays(); err != nil {
+		t.Fatalf("verifyPendingReplays: %v", err)
+	}
+	if status := loadTestGame(t, gs, legacy.SessionToken).ReplayStatus; status != models.ReplayLegacy {
+		t.Errorf("old game is %s, want %s", status, models.ReplayLegacy)
+	}
+	if status := loadTestGame(t, gs, gameSession.SessionToken).ReplayStatus; status != models.ReplayVerified {
+		t.Errorf("played game is %s, want %s", status, models.ReplayVerified)
+	}
+
+	ids := leaderboardGameIDs(t, gs)
+	if len(ids) != 2 || ids[0] != legacy.ID || ids[1] != gameSession.ID {
+		t.Errorf("leaderboard lists %v, want [%d %d]", ids, legacy.ID, gameSession.ID)
+	}
+}
+
+func TestVerifyPendingReplaysSkipsBrokenGames(t *testing.T) {
+	gs := newTestGameService(t)
+
+	// Checked first, its text can't be built so the replay fails
+	level := models.CampaignLevel{Position: 1, Name: "broken", Text: "hello world", EnemiesJSON: "not json"}
+	if err := gs.db.Create(&level).Error; err != nil {
+		t.Fatal(err)
+	}
+	player := models.Player{Username: "broken", Email: "broken@example.com", IsRegistered: true}
+	if err := gs.db.Create(&player).Error; err != nil {
+		t.Fatal(err)
+	}
+	startTime := time.Now().Add(-time.Minute)
+	score := 100
+	broken := models.GameSession{
+		SessionToken: "broken",
+		PlayerID:     player.ID,
+		Mode:         models.GameModeClassic,
+		TextID:       fmt.Sprintf("campaign:%d", level.ID),
+		StartTime:    &startTime,
+		EndTime:      &startTime,
+		FinalScore:   &score,
+		IsCompleted:  true,
+	}
+	if err := gs.db.Create(&broken).Error; err != nil {
+		t.Fatal(err)
+	}
+	played, _ := finishTestGame(t, gs)
+
+	if err := gs.verifyPendingReplays(); err != nil {
+		t.Fatalf("verifyPendingReplays: %v", err)
+	}
+	if checked := loadTestGame(t, gs, broken.SessionToken); checked.ReplayStatus != models.ReplayInvalid || !strings.Contains(checked.ReplayIssue, "the replay failed") {
+		t.Errorf("broken game is %s (%q), want %s with the replay error", checked.ReplayStatus, checked.ReplayIssue, models.ReplayInvalid)
+	}
+	if status := loadTestGame(t, gs, played.SessionToken).ReplayStatus; status != models.ReplayVerified {
+		t.Errorf("game checked after the broken one is %s, want %s", status, models.ReplayVerified)
+	}
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// boardRoom owns the shared map of an arena or coop match. Only the room's goroutine
+// touches the map: moves are queued and applied one at a time in the order
+// they reached the server, so of two players arriving on the same pearl the
+// first one queued takes it and the other finds the cell occupied.
+type boardRoom struct {
+	matchID    uint
+	matchToken string
+	board      *game.SharedMap
+
+	requests chan func()
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newBoardRoom(match *models.Match, board *game.SharedMap) *boardRoom {
+	room := &boardRoom{
+		matchID:    match.ID,
+		matchToken: match.MatchToken,
+		board:      board,
+		requests:   make(chan func()),
+		done:       make(chan struct{}),
+	}
+	go room.run()
+	return room
+}
+
+func (r *boardRoom) run() {
+	for {
+		select {
+		case fn := <-r.requests:
+			fn()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// do runs fn on the room's goroutine and waits for it, false when the room is closed
+func (r *boardRoom) do(fn func()) bool {
+	finished := make(chan struct{})
+	select {
+	case r.requests <- func() { fn(); close(finished) }:
+		<-finished
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// stop closes the room once the request in progress is done
+func (r *boardRoom) stop() {
+	r.do(func() {
+		r.stopOnce.Do(func() { close(r.done) })
+	})
+}
+
+// boardView copies the parts of the shared map sent to players
+func (r *boardRoom) boardView() ([][]int, []game.Cursor) {
+	var gameMap [][]int
+	var cursors []game.Cursor
+	r.do(func() {
+		gameMap = game.CopyMap(r.board.GameMap)
+		cursors = append([]game.Cursor{}, r.board.Cursors...)
+	})
+	return gameMap, cursors
+}
+
+// roomFor returns the room of a match played on a shared map, opening it on first use
+func (ms *MatchService) roomFor(match *models.Match) (*boardRoom, error) {
+	ms.boardMutex.Lock()
+	defer ms.boardMutex.Unlock()
+
+	if room, exists := ms.boards[match.ID]; exists {
+		return room, nil
+	}
+
+	var board game.SharedMap
+	if err := json.Unmarshal([]byte(match.BoardJSON), &board); err != nil {
+		return nil, err
+	}
+	room := newBoardRoom(match, &board)
+	ms.boards[match.ID] = room
+	return room, nil
+}
+
+// boardSeat returns the room a game session plays in, nil for sessions outside boards
+func (ms *MatchService) boardSeat(sessionToken string) *boardRoom {
+	ms.boardMutex.Lock()
+	defer ms.boardMutex.Unlock()
+	return ms.boardSeats[sessionToken]
+}
+
+// closeBoard stops the room of a finished match
+func (ms *MatchService) closeBoard(matchID uint) {
+	ms.boardMutex.Lock()
+	room, exists := ms.boards[matchID]
+	delete(ms.boards, matchID)
+	for token, seat := range ms.boardSeats {
+		if seat == room {
+			delete(ms.boardSeats, token)
+		}
+	}
+	ms.boardMutex.Unlock()
+
+	if exists {
+		room.stop()
+	}
+}
+
+// joinBoard puts a new player's cursor on the shared map and moves their session to it
+func (ms *MatchService) joinBoard(match *models.Match, gameSession *models.GameSession) error {
+	room, err := ms.roomFor(match)
+	if err != nil {
+		return err
+	}
+
+	var joinErr error
+	var changes []game.CellChange
+	room.do(func() {
+		snapshot := room.board.Clone()
+		cursor := room.board.AddCursor(gameSession.PlayerID, rand.New(rand.NewSource(game.NewSeed())))
+		if cursor == nil {
+			joinErr = errors.New("no room left on the map")
+			return
+		}
+		changes = game.DiffMaps(snapshot.GameMap, room.board.GameMap)
+
+		joinErr = retryVersioned(ms.db, func(tx *gorm.DB) error {
+			var txGameSession models.GameSession
+			if err := tx.First(&txGameSession, gameSession.ID).Error; err != nil {
+				return err
+			}
+			txGameSession.CurrentRow = cursor.Row
+			txGameSession.CurrentCol = cursor.Col
+			txGameSession.PreferredColumn = cursor.PreferredColumn
+			txGameSession.SetGameMap(room.board.GameMap)
+			if err := saveVersioned(tx, &txGameSession); err != nil {
+				return err
+			}
+			*gameSession = txGameSession
+			return saveBoard(tx, room)
+		})
+		if joinErr != nil {
+			room.board = snapshot
+		}
+	})
+	if joinErr != nil {
+		return joinErr
+	}
+
+	ms.boardMutex.Lock()
+	ms.boardSeats[gameSession.SessionToken] = room
+	ms.boardMutex.Unlock()
+
+	ms.broadcastBoard(room, gameSession.PlayerID, changes)
+	return nil
+}
+
+// leaveBoard takes a player's cursor off the shared map
+func (ms *MatchService) leaveBoard(match *models.Match, entry *models.MatchPlayer) {
+	room, err := ms.roomFor(match)
+	if err != nil {
+		log.Printf("Failed to load the board of match %d: %v", match.ID, err)
+		return
+	}
+
+	var changes []game.CellChange
+	room.do(func() {
+		before := game.CopyMap(room.board.GameMap)
+		room.board.RemoveCursor(entry.PlayerID)
+		changes = game.DiffMaps(before, room.board.GameMap)
+		if err := saveBoard(ms.db, room); err != nil {
+			log.Printf("Failed to save the board of match %d: %v", match.ID, err)
+		}
+	})
+
+	ms.boardMutex.Lock()
+	delete(ms.boardSeats, entry.SessionToken)
+	ms.boardMutex.Unlock()
+
+	ms.broadcastBoard(room, entry.PlayerID, changes)
+}
+
+// boardMove applies a move to the shared map and broadcasts what changed
+func (ms *MatchService) boardMove(room *boardRoom, sessionToken, direction string) (map[string]interface{}, error) {
+	received := time.Now()
+	var result map[string]interface{}
+	var changes []game.CellChange
+	var playerID uint
+	var err error
+	applied := room.do(func() {
+		result, changes, playerID, err = ms.applyBoardMove(room, sessionToken, direction, received)
+	})
+	if !applied {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Match is over",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if result["success"] == true {
+		ms.broadcastBoard(room, playerID, changes)
+		if teamScore, coop := result["team_score"]; coop && result["pearl_collected"] == true {
+			// The partner scored too, without moving
+			ms.broadcast(room.matchToken, "pair_completed", map[string]interface{}{
+				"player_id":  playerID,
+				"team_score": teamScore,
+			})
+		}
+		ms.RecordMove(sessionToken, result)
+	}
+	return result, nil
+}
+
+// applyBoardMove moves a player's cursor on the shared map, it runs on the room's goroutine
+func (ms *MatchService) applyBoardMove(room *boardRoom, sessionToken, direction string, received time.Time) (map[string]interface{}, []game.CellChange, uint, error) {
+	var gameSession models.GameSession
+	if err := ms.db.Where("session_token = ? AND is_active = ?", sessionToken, true).First(&gameSession).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "Invalid or expired game session",
+			}, nil, 0, nil
+		}
+		return nil, nil, 0, err
+	}
+
+	board := room.board
+	if board.Cursor(gameSession.PlayerID) == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "You are not on the map",
+		}, nil, 0, nil
+	}
+
+	movementResult, failure := ms.gameService.checkMove(&gameSession, direction, board.GameMap)
+	if failure != nil {
+		return failure, nil, 0, nil
+	}
+
+	// Cursors can't share a cell, whoever got there first keeps it
+	if owner, occupied := board.OwnerAt(movementResult.NewRow, movementResult.NewCol); occupied && owner != gameSession.PlayerID {
+		return map[string]interface{}{
+			"success":     false,
+			"error":       models.ErrCellOccupied.Message,
+			"error_code":  models.ErrCellOccupied.Code,
+			"occupied_by": owner,
+		}, nil, 0, nil
+	}
+
+	// Coop targets only score once the pair is complete, see below
+	pearlType := game.EMPTY
+	points := 0
+	if target := board.GameMap[movementResult.NewRow][movementResult.NewCol]; game.IsPearl(target) && !board.Coop {
+		pearlType = target
+		points = ms.gameService.pearlValue(&gameSession, target)
+	}
+
+	gameSession.SetGameMap(board.GameMap)
+	fromRow, fromCol := gameSession.CurrentRow, gameSession.CurrentCol
+	if err := gameSession.ProcessMove(movementResult.NewRow, movementResult.NewCol, movementResult.PreferredColumn, pearlType, points, time.Now()); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		}, nil, 0, nil
+	}
+
+	// The move is accepted, update the shared map
+	snapshot := board.Clone()
+	board.MoveCursor(gameSession.PlayerID, movementResult.NewRow, movementResult.NewCol, movementResult.PreferredColumn)
+	partnerID, pairCompleted := uint(0), false
+	if board.Coop {
+		partnerID, pairCompleted = board.VisitTarget(gameSession.PlayerID, movementResult.NewRow, movementResult.NewCol)
+	} else if game.IsPearl(pearlType) {
+		board.PlaceNewPearl(movementResult.NewRow, movementResult.NewCol)
+	}
+	changes := game.DiffMaps(snapshot.GameMap, board.GameMap)
+	gameSession.SetGameMap(board.GameMap)
+
+	// A completed coop pair counts as a pearl for the move that completed it
+	if pairCompleted {
+		pearlType = game.PEARL
+		points = ms.gameService.pearlPoints(&gameSession)
+	}
+
+	teamScore := 0
+	err := ms.db.Transaction(func(tx *gorm.DB) error {
+		if board.Coop {
+			var err error
+			if teamScore, err = ms.scoreCoop(tx, &gameSession, partnerID, pairCompleted); err != nil {
+				return err
+			}
+		} else if gameSession.CurrentScore >= ms.gameService.targetScore(&gameSession) {
+			gameSession.CompleteGame()
+			ms.gameService.updatePlayerStats(tx, gameSession.PlayerID, &gameSession)
+		}
+		// Moves on the map take turns, only the server ending the game saves the session in between
+		if err := saveVersioned(tx, &gameSession); err != nil {
+			return err
+		}
+		if err := logMove(tx, &gameSession, direction, fromRow, fromCol, pearlType, points, received); err != nil {
+			return err
+		}
+		return saveBoard(tx, room)
+	})
+	if err = asMoveConflict(err); err != nil {
+		// The move never happened, the shared map goes back to how it was
+		room.board = snapshot
+		if errors.Is(err, models.ErrMoveConflict) {
+			return map[string]interface{}{
+				"success":    false,
+				"error":      err.Error(),
+				"error_code": models.ErrMoveConflict.Code,
+			}, nil, 0, nil
+		}
+		return nil, nil, 0, err
+	}
+
+	result := ms.gameService.moveResponse(&gameSession, pearlType, points, false)
+	if board.Coop {
+		result["team_score"] = teamScore
+	}
+	return result, changes, gameSession.PlayerID, nil
+}
+
+// scoreCoop credits both players of a completed target pair with a pearl each,
+// for the target they visited, and completes both games once their combined
+// score reaches the target. It returns the combined score.
+func (ms *MatchService) scoreCoop(tx *gorm.DB, gameSession *models.GameSession, partnerID uint, pairCompleted bool) (int, error) {
+	var partner models.GameSession
+	if err := tx.Where("match_id = ? AND player_id <> ?", gameSession.MatchID, gameSession.PlayerID).First(&partner).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return gameSession.CurrentScore, nil
+		}
+		return 0, err
+	}
+	if !pairCompleted || partner.PlayerID != partnerID {
+		return gameSession.CurrentScore + partner.CurrentScore, nil
+	}
+
+	points := ms.gameService.pearlPoints(gameSession)
+	gameSession.PearlsCollected++
+	gameSession.CurrentScore += points
+	partner.PearlsCollected++
+	partner.CurrentScore += points
+
+	teamScore := gameSession.CurrentScore + partner.CurrentScore
+	if teamScore >= ms.gameService.targetScore(gameSession) {
+		for _, teammate := range []*models.GameSession{gameSession, &partner} {
+			teammate.CompleteGame()
+			ms.gameService.updatePlayerStats(tx, teammate.PlayerID, teammate)
+		}
+	}
+	if err := saveVersioned(tx, &partner); err != nil {
+		return 0, err
+	}
+
+	// The partner's match entry follows their score, their own moves update the rest
+	err := tx.Model(&models.MatchPlayer{}).
+		Where("match_id = ? AND player_id = ?", gameSession.MatchID, partner.PlayerID).
+		Updates(map[string]interface{}{
+			"score":            partner.CurrentScore,
+			"pearls_collected": partner.PearlsCollected,
+		}).Error
+	return teamScore, err
+}
+
+// boardState is the game state of a session on a shared map, with the shared map and every cursor
+func (ms *MatchService) boardState(room *boardRoom, sessionToken string) (map[string]interface{}, error) {
+	state, err := ms.gameService.GetGameState(sessionToken)
+	if err != nil || !state["success"].(bool) {
+		return state, err
+	}
+
+	state["game_map"], state["cursors"] = room.boardView()
+	if room.board.Coop {
+		var teamScore int
+		ms.db.Model(&models.GameSession{}).
+			Select("COALESCE(SUM(current_score), 0)").
+			Where("match_id = (?)", ms.db.Model(&models.GameSession{}).Select("match_id").Where("session_token = ?", sessionToken)).
+			Scan(&teamScore)
+		state["team_score"] = teamScore
+	}
+	return state, nil
+}
+
+// broadcastBoard sends the cells a player changed on the shared map to the match
+func (ms *MatchService) broadcastBoard(room *boardRoom, playerID uint, changes []game.CellChange) {
+	if len(changes) == 0 {
+		return
+	}
+	ms.broadcast(room.matchToken, "board_update", map[string]interface{}{
+		"player_id":     playerID,
+		"changed_cells": changes,
+	})
+}
+
+// saveBoard stores the shared map with its match
+func saveBoard(tx *gorm.DB, room *boardRoom) error {
+	boardJSON, err := json.Marshal(room.board)
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Match{}).Where("id = ?", room.matchID).Update("board_json", string(boardJSON)).Error
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+	"boba-vim/internal/realtime"
+
+	"gorm.io/gorm"
+)
+
+// startTestBoard seats one player per cursor position on a running match of
+// the mode, played on one shared map, and returns the room and the session tokens
+func startTestBoard(t *testing.T, mode string, cursors [][2]int) (*MatchService, *boardRoom, []string) {
+	t.Helper()
+	gs := newTestGameService(t)
+	ms := NewMatchService(gs.db, gs.cfg, gs, realtime.NewHub())
+
+	board := game.NewSharedMap(1, game.PearlStrategyRegular, 42)
+	if mode == models.MatchModeCoop {
+		board = game.NewCoopMap(1, 42)
+	}
+	boardJSON, err := json.Marshal(board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startsAt := time.Now().Add(-time.Second)
+	match := models.Match{
+		MatchToken:    "board",
+		Mode:          mode,
+		Status:        models.MatchStatusRunning,
+		Seed:          42,
+		TextPattern:   1,
+		PearlStrategy: game.PearlStrategyRegular,
+		TargetScore:   1000,
+		TimeLimit:     300,
+		StartsAt:      &startsAt,
+		BoardJSON:     string(boardJSON),
+	}
+	if err := gs.db.Create(&match).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var tokens []string
+	for i, position := range cursors {
+		player := models.Player{Username: fmt.Sprintf("mover%d", i+1), Email: fmt.Sprintf("mover%d@example.com", i+1), IsRegistered: true}
+		if err := gs.db.Create(&player).Error; err != nil {
+			t.Fatal(err)
+		}
+		result, err := gs.CreateGame(player.ID, "", GameOptions{
+			TextPattern:   1,
+			Seed:          42,
+			PearlStrategy: game.PearlStrategyRegular,
+			TargetScore:   match.TargetScore,
+			TimeLimit:     match.TimeLimit,
+			MatchID:       &match.ID,
+			StartTime:     &startsAt,
+		})
+		if err != nil {
+			t.Fatalf("create game: %v", err)
+		}
+		gameSession := loadTestGame(t, gs, result["session_token"].(string))
+		entry := models.MatchPlayer{MatchID: match.ID, PlayerID: player.ID, Username: player.Username, GameSessionID: gameSession.ID, SessionToken: gameSession.SessionToken}
+		if err := gs.db.Create(&entry).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := ms.joinBoard(&match, gameSession); err != nil {
+			t.Fatalf("join board: %v", err)
+		}
+
+		// Put the cursor where the test wants it, on an emptied cell
+		room := ms.boardSeat(gameSession.SessionToken)
+		room.do(func() {
+			room.board.GameMap[position[0]][position[1]] = game.EMPTY
+			room.board.MoveCursor(player.ID, position[0], position[1], position[1])
+			if err := saveBoard(ms.db, room); err != nil {
+				t.Error(err)
+			}
+		})
+		gameSession.CurrentRow, gameSession.CurrentCol, gameSession.PreferredColumn = position[0], position[1], position[1]
+		if err := saveVersioned(gs.db, gameSession); err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, gameSession.SessionToken)
+	}
+	room := ms.boardSeat(tokens[0])
+	t.Cleanup(func() { ms.closeBoard(match.ID) })
+	return ms, room, tokens
+}
+
+// boardCursor returns where a session's cursor is on the shared map
+func boardCursor(t *testing.T, ms *MatchService, room *boardRoom, sessionToken string) [2]int {
+	t.Helper()
+	gameSession := loadTestGame(t, ms.gameService, sessionToken)
+	_, cursors := room.boardView()
+	for _, cursor := range cursors {
+		if cursor.OwnerID == gameSession.PlayerID {
+			return [2]int{cursor.Row, cursor.Col}
+		}
+	}
+	t.Fatalf("player %d has no cursor", gameSession.PlayerID)
+	return [2]int{}
+}
+
+func TestBoardCursorsCannotShareACell(t *testing.T) {
+	ms, room, tokens := startTestBoard(t, models.MatchModeArena, [][2]int{{0, 1}, {0, 0}})
+
+	result, err := ms.boardMove(room, tokens[1], "l")
+	if err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	owner := loadTestGame(t, ms.gameService, tokens[0]).PlayerID
+	if result["success"] != false || result["error_code"] != models.ErrCellOccupied.Code || result["occupied_by"] != owner {
+		t.Fatalf("move onto another cursor returned %v, want %s by player %d", result, models.ErrCellOccupied.Code, owner)
+	}
+	if cursor := boardCursor(t, ms, room, tokens[1]); cursor != [2]int{0, 0} {
+		t.Errorf("blocked cursor moved to %v", cursor)
+	}
+	if moves := loadTestGame(t, ms.gameService, tokens[1]).TotalMoves; moves != 0 {
+		t.Errorf("blocked move was counted, %d moves", moves)
+	}
+
+	// Once the cell is free again the move goes through
+	if result, err := ms.boardMove(room, tokens[0], "l"); err != nil || result["success"] != true {
+		t.Fatalf("move off the cell: %v %v", err, result["error"])
+	}
+	if result, err := ms.boardMove(room, tokens[1], "l"); err != nil || result["success"] != true {
+		t.Fatalf("move onto the freed cell: %v %v", err, result["error"])
+	}
+	if cursor := boardCursor(t, ms, room, tokens[1]); cursor != [2]int{0, 1} {
+		t.Errorf("cursor on %v after the cell was freed, want [0 1]", cursor)
+	}
+}
+
+func TestBoardMoveConflictRestoresBoard(t *testing.T) {
+	ms, room, tokens := startTestBoard(t, models.MatchModeArena, [][2]int{{0, 0}})
+	room.do(func() { room.board.GameMap[0][1] = game.PEARL })
+	before, _ := room.boardView()
+	pearlSpawns := room.board.PearlSpawns
+
+	// The server saves the session while the move is being applied, e.g. to end the game
+	gameSession := loadTestGame(t, ms.gameService, tokens[0])
+	saved := false
+	err := ms.db.Callback().Update().Before("gorm:update").Register("test:parallel_save", func(tx *gorm.DB) {
+		if saved || tx.Statement.Table != "game_sessions" {
+			return
+		}
+		saved = true
+		tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE game_sessions SET version = version + 1 WHERE id = ?", gameSession.ID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ms.boardMove(room, tokens[0], "l")
+	if err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if result["success"] != false || result["error_code"] != models.ErrMoveConflict.Code {
+		t.Fatalf("conflicting move returned %v, want %s", result, models.ErrMoveConflict.Code)
+	}
+
+	after, _ := room.boardView()
+	if fmt.Sprint(after) != fmt.Sprint(before) || room.board.PearlSpawns != pearlSpawns {
+		t.Error("the shared map kept the pearl the failed move collected")
+	}
+	if cursor := boardCursor(t, ms, room, tokens[0]); cursor != [2]int{0, 0} {
+		t.Errorf("cursor on %v after the failed move, want [0 0]", cursor)
+	}
+	// The client sends the move again and it goes through
+	result, err = ms.boardMove(room, tokens[0], "l")
+	if err != nil || result["success"] != true || result["pearl_collected"] != true {
+		t.Fatalf("resent move: %v %v", err, result)
+	}
+}
+
+// setCoopPair replaces the target pairs of a coop board with one pair on the given cells
+func setCoopPair(room *boardRoom, targets [2][2]int) {
+	room.do(func() {
+		for _, pair := range room.board.Pairs {
+			for _, target := range pair.Targets {
+				room.board.GameMap[target[0]][target[1]] = game.EMPTY
+			}
+		}
+		for _, target := range targets {
+			room.board.GameMap[target[0]][target[1]] = game.PEARL
+		}
+		room.board.Pairs = []game.TargetPair{{Targets: targets}}
+	})
+}
+
+func TestCoopPairScoresBothPlayers(t *testing.T) {
+	ms, room, tokens := startTestBoard(t, models.MatchModeCoop, [][2]int{{0, 0}, {0, 3}})
+	setCoopPair(room, [2][2]int{{0, 1}, {0, 2}})
+
+	result, err := ms.boardMove(room, tokens[0], "l")
+	if err != nil || result["success"] != true {
+		t.Fatalf("move onto the first target: %v %v", err, result)
+	}
+	if result["team_score"] != 0 {
+		t.Errorf("team score %v after one target, want 0", result["team_score"])
+	}
+
+	result, err = ms.boardMove(room, tokens[1], "h")
+	if err != nil || result["success"] != true {
+		t.Fatalf("move onto the second target: %v %v", err, result)
+	}
+	first := loadTestGame(t, ms.gameService, tokens[0])
+	points := ms.gameService.pearlPoints(first)
+	if result["team_score"] != 2*points {
+		t.Errorf("team score %v after the pair, want %d", result["team_score"], 2*points)
+	}
+	for _, token := range tokens {
+		gameSession := loadTestGame(t, ms.gameService, token)
+		if gameSession.CurrentScore != points || gameSession.PearlsCollected != 1 {
+			t.Errorf("player %d has %d points and %d pearls, want %d and 1", gameSession.PlayerID, gameSession.CurrentScore, gameSession.PearlsCollected, points)
+		}
+	}
+
+	// The partner's match entry follows their score
+	var entry models.MatchPlayer
+	if err := ms.db.Where("player_id = ?", first.PlayerID).First(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	if entry.Score != points || entry.PearlsCollected != 1 {
+		t.Errorf("partner's match entry has %d points and %d pearls, want %d and 1", entry.Score, entry.PearlsCollected, points)
+	}
+}
+
+func TestCoopPairNeedsTwoPlayers(t *testing.T) {
+	ms, room, tokens := startTestBoard(t, models.MatchModeCoop, [][2]int{{0, 0}, {0, 5}})
+	setCoopPair(room, [2][2]int{{0, 1}, {0, 2}})
+
+	for _, direction := range []string{"l", "l"} {
+		time.Sleep(55 * time.Millisecond)
+		result, err := ms.boardMove(room, tokens[0], direction)
+		if err != nil || result["success"] != true {
+			t.Fatalf("move: %v %v", err, result)
+		}
+		if result["team_score"] != 0 {
+			t.Errorf("team score %v with one player on both targets, want 0", result["team_score"])
+		}
+	}
+	if gameSession := loadTestGame(t, ms.gameService, tokens[0]); gameSession.CurrentScore != 0 || gameSession.PearlsCollected != 0 {
+		t.Errorf("one player scored %d points alone", gameSession.CurrentScore)
+	}
+}
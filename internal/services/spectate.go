@@ -0,0 +1,223 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+	"boba-vim/internal/realtime"
+
+	"gorm.io/gorm"
+)
+
+// Spectators get the same stream as players, cfg.SpectatorDelay late so they
+// can't help a player in real time. Every message keeps the time it happened:
+// a spectator's first state is delayed too, so clients drop the messages
+// stamped before it.
+
+// GameSpectateRoom is the realtime room spectators of a single game listen to
+func GameSpectateRoom(gameID uint) string {
+	return "spectate:game:" + strconv.FormatUint(uint64(gameID), 10)
+}
+
+// MatchSpectateRoom is the realtime room spectators of a match listen to
+func MatchSpectateRoom(matchToken string) string {
+	return "spectate:match:" + matchToken
+}
+
+// broadcast sends a match event to its players now and to its spectators later
+func (ms *MatchService) broadcast(matchToken, msgType string, data interface{}) {
+	ms.hub.Broadcast(MatchRoom(matchToken), msgType, data)
+	ms.hub.BroadcastAfter(MatchSpectateRoom(matchToken), msgType, data, ms.cfg.SpectatorDelay)
+}
+
+// publishGame streams an accepted move of a single game to its spectators
+func (ms *MatchService) publishGame(result map[string]interface{}) {
+	gameID, ok := result["game_id"].(uint)
+	if !ok || result["success"] != true {
+		return
+	}
+
+	room := GameSpectateRoom(gameID)
+	if ms.hub.RoomSize(room) == 0 {
+		return
+	}
+	ms.hub.BroadcastAfter(room, "game_update", spectatorView(result), ms.cfg.SpectatorDelay)
+}
+
+// SpectateGame subscribes a spectator to a game in progress
+func (ms *MatchService) SpectateGame(client *realtime.Client, gameID uint) error {
+	var gameSession models.GameSession
+	if err := ms.db.Where("id = ? AND is_active = ?", gameID, true).First(&gameSession).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("game not found")
+		}
+		return err
+	}
+
+	state, err := ms.GetGameState(gameSession.SessionToken)
+	if err != nil {
+		return err
+	}
+
+	ms.hub.Join(GameSpectateRoom(gameID), client)
+	client.SendAfter("spectate_state", map[string]interface{}{
+		"kind": "game",
+		"game": spectatorView(state),
+	}, ms.cfg.SpectatorDelay)
+	return nil
+}
+
+// SpectateMatch subscribes a spectator to a match that has not finished yet
+func (ms *MatchService) SpectateMatch(client *realtime.Client, matchToken string) error {
+	match, err := ms.findMatch(matchToken)
+	if err != nil {
+		return err
+	}
+	if match == nil || !isLiveMatch(match) {
+		return errors.New("match not found")
+	}
+
+	state := map[string]interface{}{
+		"kind":  "match",
+		"match": ms.matchState(match),
+	}
+	if match.SharesMap() {
+		if room, err := ms.roomFor(match); err == nil {
+			state["game_map"], state["cursors"] = room.boardView()
+		}
+	}
+
+	ms.hub.Join(MatchSpectateRoom(matchToken), client)
+	client.SendAfter("spectate_state", state, ms.cfg.SpectatorDelay)
+	return nil
+}
+
+// SpectatePage returns what the spectate page of a game or match renders. The
+// page only shows the text and walls, players and pearls come with the delayed stream.
+func (ms *MatchService) SpectatePage(gameID uint, matchToken string) (map[string]interface{}, error) {
+	query := ms.db.Where("id = ? AND is_active = ?", gameID, true)
+	if matchToken != "" {
+		match, err := ms.findMatch(matchToken)
+		if err != nil {
+			return nil, err
+		}
+		if match == nil || !isLiveMatch(match) {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "Match not found",
+			}, nil
+		}
+		// Race players share the text, any of their games shows it
+		query = ms.db.Where("match_id = ?", match.ID).Order("id ASC")
+	}
+
+	var gameSession models.GameSession
+	if err := query.First(&gameSession).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "Game not found",
+			}, nil
+		}
+		return nil, err
+	}
+
+	gameData := ms.gameService.gameData(&gameSession)
+	gameMap := gameData["game_map"].([][]int)
+	for row := range gameMap {
+		for col, value := range gameMap[row] {
+			if !game.IsWall(value) {
+				gameMap[row][col] = game.EMPTY
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"success":   true,
+		"game_data": gameData,
+	}, nil
+}
+
+// LiveGames lists the games and matches that can be watched right now
+func (ms *MatchService) LiveGames() (map[string]interface{}, error) {
+	idleSince := time.Now().Add(-ms.cfg.LiveGameIdle)
+
+	var gameSessions []models.GameSession
+	err := ms.db.Preload("Player").
+		Where("is_active = ? AND match_id IS NULL AND start_time IS NOT NULL", true).
+		Where("last_move_time > ?", idleSince).
+		Order("current_score DESC").
+		Limit(50).
+		Find(&gameSessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	games := make([]map[string]interface{}, 0, len(gameSessions))
+	for _, gameSession := range gameSessions {
+		username := "Anonymous"
+		if gameSession.PlayerID != 0 {
+			username = gameSession.Player.Username
+		}
+		games = append(games, map[string]interface{}{
+			"game_id":      gameSession.ID,
+			"username":     username,
+			"score":        gameSession.CurrentScore,
+			"total_moves":  gameSession.TotalMoves,
+			"start_time":   gameSession.StartTime,
+			"spectate_url": "/spectate/game/" + strconv.FormatUint(uint64(gameSession.ID), 10),
+		})
+	}
+
+	var matches []models.Match
+	// Private rooms are only watched by those who were given the link
+	err = ms.db.Where("status IN ?", []string{models.MatchStatusCountdown, models.MatchStatusRunning}).
+		Where("host_id IS NULL").
+		Order("id ASC").
+		Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	liveMatches := make([]map[string]interface{}, 0, len(matches))
+	for i := range matches {
+		state := ms.matchState(&matches[i])
+		state["spectate_url"] = "/spectate/match/" + matches[i].MatchToken
+		liveMatches = append(liveMatches, state)
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"games":   games,
+		"matches": liveMatches,
+	}, nil
+}
+
+// HandleSpectatorMessage answers messages of spectators, who can only ping
+func (ms *MatchService) HandleSpectatorMessage(client *realtime.Client, msg realtime.IncomingMessage) {
+	switch msg.Type {
+	case "ping":
+		client.Send("pong", msg.Data)
+	default:
+		client.Send("error", map[string]string{"error": "Spectators can't play"})
+	}
+}
+
+// spectatorView keeps the position and score of a game state or move result
+func spectatorView(state map[string]interface{}) map[string]interface{} {
+	view := map[string]interface{}{}
+	for _, key := range []string{"game_map", "player_pos", "score", "cursors", "is_completed", "game_over", "end_reason"} {
+		if value, exists := state[key]; exists {
+			view[key] = value
+		}
+	}
+	return view
+}
+
+// isLiveMatch reports whether a match can still be watched
+func isLiveMatch(match *models.Match) bool {
+	return match.Status != models.MatchStatusFinished && match.Status != models.MatchStatusAbandoned
+}
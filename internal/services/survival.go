@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Survival scores one point per pearl and lasts until the player runs out of
+// lives. Every pearl dissolves after a countdown that gets shorter as the score
+// grows, and each pearl that dissolves costs a life. The server expires pearls
+// on its own timer and pushes the change, so standing still costs lives too.
+
+// StartSurvival creates a survival game and schedules its first pearl expiry
+func (ms *MatchService) StartSurvival(playerID uint, selectedCharacter string) (map[string]interface{}, error) {
+	result, err := ms.gameService.CreateGame(playerID, selectedCharacter, GameOptions{
+		Mode:          models.GameModeSurvival,
+		PearlStrategy: game.PearlStrategyClassic,
+		Lives:         ms.cfg.SurvivalLives,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timers := result["game_data"].(map[string]interface{})["pearl_timers"].([]models.PearlTimer)
+	ms.scheduleSurvival(result["session_token"].(string), timers)
+	return result, nil
+}
+
+// ResumeSurvivals schedules the pearl expiries of the survival games still
+// running from a previous run, pearls that expired meanwhile go right away
+func (ms *MatchService) ResumeSurvivals() error {
+	var gameSessions []models.GameSession
+	if err := ms.db.Where("mode = ? AND is_active = ?", models.GameModeSurvival, true).Find(&gameSessions).Error; err != nil {
+		return err
+	}
+
+	for _, gameSession := range gameSessions {
+		ms.scheduleSurvival(gameSession.SessionToken, gameSession.GetPearlTimers())
+	}
+	return nil
+}
+
+// trackSurvival follows a survival game after a move changed its pearls
+func (ms *MatchService) trackSurvival(sessionToken string, result map[string]interface{}) {
+	if result["mode"] != models.GameModeSurvival {
+		return
+	}
+	if result["game_over"] == true {
+		ms.scheduleSurvival(sessionToken, nil)
+		return
+	}
+	ms.scheduleSurvival(sessionToken, result["pearl_timers"].([]models.PearlTimer))
+}
+
+// scheduleSurvival replaces the game's expiry timer with one for its first
+// pearl to expire, no timers stop it
+func (ms *MatchService) scheduleSurvival(sessionToken string, timers []models.PearlTimer) {
+	ms.survivalMutex.Lock()
+	defer ms.survivalMutex.Unlock()
+
+	if timer := ms.survivalTimers[sessionToken]; timer != nil {
+		timer.Stop()
+		delete(ms.survivalTimers, sessionToken)
+	}
+	next, ok := nextPearlExpiry(timers)
+	if !ok {
+		return
+	}
+	ms.survivalTimers[sessionToken] = time.AfterFunc(time.Until(next), func() { ms.expireSurvivalPearls(sessionToken) })
+}
+
+// expireSurvivalPearls expires the game's pearls whose countdown ran out and
+// tells the player what it cost them
+func (ms *MatchService) expireSurvivalPearls(sessionToken string) {
+	result, err := ms.gameService.ExpireSurvivalPearls(sessionToken)
+	if err != nil {
+		log.Printf("Failed to expire survival pearls: %v", err)
+		return
+	}
+	if result == nil {
+		// The player left or the game ended otherwise
+		ms.scheduleSurvival(sessionToken, nil)
+		return
+	}
+
+	if result["pearls_expired"].(int) > 0 {
+		ms.pushGame(sessionToken, "pearl_expired", result)
+		ms.publishGame(result)
+	}
+	ms.trackSurvival(sessionToken, result)
+}
+
+// ExpireSurvivalPearls expires the pearls of a survival game whose countdown
+// ran out, nil when the game is no longer running
+func (gs *GameService) ExpireSurvivalPearls(sessionToken string) (map[string]interface{}, error) {
+	var gameSession models.GameSession
+	expired := 0
+	expire := func(tx *gorm.DB) error {
+		gameSession = models.GameSession{}
+		err := tx.Where("session_token = ? AND is_active = ? AND mode = ?", sessionToken, true, models.GameModeSurvival).
+			First(&gameSession).Error
+		if err != nil {
+			return err
+		}
+
+		expired = gs.expirePearls(&gameSession, time.Now())
+		if expired == 0 {
+			return nil
+		}
+		gs.loseLives(tx, &gameSession, expired)
+		return saveVersioned(tx, &gameSession)
+	}
+
+	// A move saved in the meantime may have expired the pearls already, the
+	// next attempt works on what it left
+	err := retryVersioned(gs.db, expire)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := gs.moveResponse(&gameSession, game.EMPTY, 0, false)
+	response["pearls_expired"] = expired
+	return response, nil
+}
+
+// loseLives takes a life for each expired pearl of a survival game and ends
+// it once they are gone, reporting whether it ended
+func (gs *GameService) loseLives(tx *gorm.DB, gameSession *models.GameSession, expired int) bool {
+	if !gameSession.IsSurvival() || expired == 0 {
+		return false
+	}
+
+	gameSession.Lives -= expired
+	if gameSession.Lives > 0 {
+		return false
+	}
+
+	gameSession.Lives = 0
+	gameSession.CompleteGame()
+	gameSession.EndReason = models.EndReasonOutOfLives
+	if gameSession.PlayerID != 0 {
+		gs.updatePlayerStats(tx, gameSession.PlayerID, gameSession)
+	}
+	return true
+}
+
+// survivalPearlLife is the countdown of a pearl placed in a survival game, it
+// shrinks with every pearl collected down to a minimum
+func (gs *GameService) survivalPearlLife(gameSession *models.GameSession) time.Duration {
+	life := gs.cfg.SurvivalPearlLife - time.Duration(gameSession.CurrentScore)*gs.cfg.SurvivalLifeStep
+	if life < gs.cfg.SurvivalMinPearlLife {
+		return gs.cfg.SurvivalMinPearlLife
+	}
+	return life
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// A time attack runs on a fixed clock and scores one point per pearl, black
+// pearls score nothing. The server ends the game when the clock runs out, so
+// the result doesn't depend on the client sending another move.
+
+// StartTimeAttack creates a time attack game and schedules its end
+func (ms *MatchService) StartTimeAttack(playerID uint, selectedCharacter string) (map[string]interface{}, error) {
+	result, err := ms.gameService.CreateGame(playerID, selectedCharacter, GameOptions{
+		Mode:      models.GameModeTimeAttack,
+		TimeLimit: int(ms.cfg.TimeAttackDuration.Seconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endsAt := result["game_data"].(map[string]interface{})["ends_at"].(time.Time)
+	ms.scheduleTimeAttack(result["session_token"].(string), endsAt)
+	return result, nil
+}
+
+// ResumeTimeAttacks schedules the end of the time attacks still running from a
+// previous run, those whose clock ran out meanwhile end right away
+func (ms *MatchService) ResumeTimeAttacks() error {
+	var gameSessions []models.GameSession
+	err := ms.db.Select("session_token", "start_time", "time_limit").
+		Where("mode = ? AND is_active = ?", models.GameModeTimeAttack, true).
+		Find(&gameSessions).Error
+	if err != nil {
+		return err
+	}
+
+	for _, gameSession := range gameSessions {
+		if gameSession.StartTime == nil {
+			continue
+		}
+		ms.scheduleTimeAttack(gameSession.SessionToken, gameSession.StartTime.Add(ms.gameService.timeLimit(&gameSession)))
+	}
+	return nil
+}
+
+func (ms *MatchService) scheduleTimeAttack(sessionToken string, endsAt time.Time) {
+	time.AfterFunc(time.Until(endsAt), func() { ms.endTimeAttack(sessionToken) })
+}
+
+// endTimeAttack ends a time attack when its clock runs out and tells the player
+func (ms *MatchService) endTimeAttack(sessionToken string) {
+	result, err := ms.gameService.EndTimeAttack(sessionToken)
+	if err != nil {
+		log.Printf("Failed to end time attack: %v", err)
+		return
+	}
+	if result == nil {
+		// The player left or the game ended otherwise
+		return
+	}
+
+	ms.pushGame(sessionToken, "time_up", result)
+	ms.publishGame(result)
+}
+
+// EndTimeAttack ends the time attack of the session, nil when it is no longer running
+func (gs *GameService) EndTimeAttack(sessionToken string) (map[string]interface{}, error) {
+	gameSession, err := gs.finishTimeAttack(sessionToken)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return gs.moveResponse(gameSession, 0, 0, false), nil
+}
+
+// finishTimeAttack completes a running time attack at the end of its clock,
+// whenever the server gets to it, and returns the finished session
+func (gs *GameService) finishTimeAttack(sessionToken string) (*models.GameSession, error) {
+	var gameSession models.GameSession
+	err := retryVersioned(gs.db, func(tx *gorm.DB) error {
+		gameSession = models.GameSession{}
+		err := tx.Where("session_token = ? AND is_active = ? AND mode = ?", sessionToken, true, models.GameModeTimeAttack).
+			First(&gameSession).Error
+		if err != nil {
+			return err
+		}
+
+		gameSession.CompleteGame()
+		gameSession.EndReason = models.EndReasonTimeUp
+		if gameSession.StartTime != nil {
+			endTime := gameSession.StartTime.Add(gs.timeLimit(&gameSession))
+			completionTime := int(gs.timeLimit(&gameSession).Seconds())
+			gameSession.EndTime = &endTime
+			gameSession.CompletionTime = &completionTime
+		}
+
+		if gameSession.PlayerID != 0 {
+			gs.updatePlayerStats(tx, gameSession.PlayerID, &gameSession)
+		}
+		return saveVersioned(tx, &gameSession)
+	})
+	return &gameSession, err
+}
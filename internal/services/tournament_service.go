@@ -0,0 +1,962 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TournamentService runs tournaments on top of online races. Every round
+// pairs the players into two-player matches only they can join, and the next
+// round is scheduled once the last match of a round finished. Like the match
+// clock the round timers live in memory, Resume picks them up after a restart.
+type TournamentService struct {
+	db           *gorm.DB
+	cfg          *config.Config
+	matchService *MatchService
+
+	// mutex serializes registrations and results so rounds are paired once
+	mutex sync.Mutex
+}
+
+func NewTournamentService(db *gorm.DB, cfg *config.Config, matchService *MatchService) *TournamentService {
+	ts := &TournamentService{
+		db:           db,
+		cfg:          cfg,
+		matchService: matchService,
+	}
+	matchService.OnMatchFinished(ts.matchFinished)
+	return ts
+}
+
+// TournamentOptions are the settings an organizer picks when creating a tournament
+type TournamentOptions struct {
+	Name       string
+	Format     string
+	MaxPlayers int
+	Rounds     int // swiss only, 0 plays enough rounds to find a single winner
+	StartsAt   *time.Time
+}
+
+// CreateTournament opens the registration of a new tournament
+func (ts *TournamentService) CreateTournament(playerID uint, options TournamentOptions) (map[string]interface{}, error) {
+	organizer, err := findPlayer(ts.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if organizer == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to organize a tournament",
+		}, nil
+	}
+
+	switch options.Format {
+	case models.TournamentFormatSingle, models.TournamentFormatDouble, models.TournamentFormatSwiss:
+	default:
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Unknown tournament format",
+		}, nil
+	}
+	if options.Name == "" {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "A tournament needs a name",
+		}, nil
+	}
+	if options.StartsAt != nil && options.StartsAt.Before(time.Now()) {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Start time is in the past",
+		}, nil
+	}
+	if options.MaxPlayers < 2 {
+		options.MaxPlayers = 64
+	}
+
+	tournament := models.Tournament{
+		Name:        options.Name,
+		Format:      options.Format,
+		Status:      models.TournamentStatusRegistration,
+		OrganizerID: organizer.ID,
+		MaxPlayers:  options.MaxPlayers,
+		StartsAt:    options.StartsAt,
+	}
+	if options.Format == models.TournamentFormatSwiss {
+		tournament.Rounds = options.Rounds
+	}
+	if err := ts.db.Create(&tournament).Error; err != nil {
+		return nil, err
+	}
+	ts.scheduleStart(&tournament)
+
+	return map[string]interface{}{
+		"success":    true,
+		"tournament": tournament,
+	}, nil
+}
+
+// ListTournaments returns the tournaments open for registration or running, then the last finished ones
+func (ts *TournamentService) ListTournaments() (map[string]interface{}, error) {
+	var tournaments []models.Tournament
+	err := ts.db.Where("status IN ?", []string{models.TournamentStatusRegistration, models.TournamentStatusRunning}).
+		Order("id ASC").
+		Find(&tournaments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var finished []models.Tournament
+	err = ts.db.Where("status = ?", models.TournamentStatusFinished).
+		Order("finished_at DESC").
+		Limit(20).
+		Find(&finished).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"tournaments": append(tournaments, finished...),
+	}, nil
+}
+
+// Register adds a player to a tournament that has not started
+func (ts *TournamentService) Register(playerID, tournamentID uint) (map[string]interface{}, error) {
+	player, err := findPlayer(ts.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to join a tournament",
+		}, nil
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	tournament, failure, err := ts.openTournament(tournamentID)
+	if failure != nil || err != nil {
+		return failure, err
+	}
+
+	var entries int64
+	if err := ts.db.Model(&models.TournamentEntry{}).Where("tournament_id = ?", tournament.ID).Count(&entries).Error; err != nil {
+		return nil, err
+	}
+	if int(entries) >= tournament.MaxPlayers {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Tournament is full",
+		}, nil
+	}
+
+	entry := models.TournamentEntry{
+		TournamentID: tournament.ID,
+		PlayerID:     player.ID,
+		Username:     player.Username,
+		Rating:       player.Rating,
+	}
+	err = ts.db.Where("tournament_id = ? AND player_id = ?", tournament.ID, player.ID).FirstOrCreate(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"entry":   entry,
+	}, nil
+}
+
+// Withdraw removes a player from a tournament that has not started
+func (ts *TournamentService) Withdraw(playerID, tournamentID uint) (map[string]interface{}, error) {
+	player, err := findPlayer(ts.db, playerID)
+	if err != nil || player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Not registered",
+		}, err
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	tournament, failure, err := ts.openTournament(tournamentID)
+	if failure != nil || err != nil {
+		return failure, err
+	}
+
+	result := ts.db.Where("tournament_id = ? AND player_id = ?", tournament.ID, player.ID).Delete(&models.TournamentEntry{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Not registered",
+		}, nil
+	}
+
+	return map[string]interface{}{
+		"success": true,
+	}, nil
+}
+
+// Start closes the registration and plays the first round, only the organizer may start early
+func (ts *TournamentService) Start(playerID, tournamentID uint) (map[string]interface{}, error) {
+	player, err := findPlayer(ts.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	tournament, failure, err := ts.openTournament(tournamentID)
+	if failure != nil || err != nil {
+		return failure, err
+	}
+	if player == nil || player.ID != tournament.OrganizerID {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Only the organizer can start the tournament",
+		}, nil
+	}
+
+	started, err := ts.start(tournament)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "A tournament needs at least 2 players",
+		}, nil
+	}
+	return ts.bracket(tournament.ID)
+}
+
+// Play seats the player in their match of the current round
+func (ts *TournamentService) Play(playerID uint, selectedCharacter string, tournamentID uint) (map[string]interface{}, error) {
+	player, err := findPlayer(ts.db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Log in to play online",
+		}, nil
+	}
+
+	var pairing models.TournamentPairing
+	err = ts.db.Where("tournament_id = ? AND status = ? AND match_id IS NOT NULL", tournamentID, models.PairingStatusPlaying).
+		Where("player1_id = ? OR player2_id = ?", player.ID, player.ID).
+		Order("round DESC").
+		First(&pairing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "You have no match to play right now",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.matchService.JoinMatch(player, selectedCharacter, *pairing.MatchID)
+}
+
+// GetBracket returns the standings and every round of a tournament
+func (ts *TournamentService) GetBracket(tournamentID uint) (map[string]interface{}, error) {
+	return ts.bracket(tournamentID)
+}
+
+// Resume reschedules the tournaments left running or waiting for their start
+// by a previous run. Matches of the interrupted rounds were abandoned with the
+// match clocks, so they are replayed.
+func (ts *TournamentService) Resume() error {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	var tournaments []models.Tournament
+	err := ts.db.Where("status IN ?", []string{models.TournamentStatusRegistration, models.TournamentStatusRunning}).
+		Find(&tournaments).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range tournaments {
+		tournament := &tournaments[i]
+		if tournament.Status == models.TournamentStatusRegistration {
+			ts.scheduleStart(tournament)
+			continue
+		}
+		if tournament.NextRoundAt != nil {
+			ts.scheduleRound(tournament)
+			continue
+		}
+
+		var pairings []models.TournamentPairing
+		err := ts.db.Where("tournament_id = ? AND round = ? AND status = ?", tournament.ID, tournament.CurrentRound, models.PairingStatusPlaying).
+			Find(&pairings).Error
+		if err != nil {
+			return err
+		}
+		err = ts.db.Transaction(func(tx *gorm.DB) error {
+			for j := range pairings {
+				if err := ts.createMatch(tx, tournament, &pairings[j]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		ts.scheduleNoShows(tournament.ID, tournament.CurrentRound)
+	}
+	return nil
+}
+
+// openTournament loads a tournament that still takes registrations
+func (ts *TournamentService) openTournament(tournamentID uint) (*models.Tournament, map[string]interface{}, error) {
+	var tournament models.Tournament
+	if err := ts.db.First(&tournament, tournamentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, map[string]interface{}{
+				"success": false,
+				"error":   "Tournament not found",
+			}, nil
+		}
+		return nil, nil, err
+	}
+	if tournament.Status != models.TournamentStatusRegistration {
+		return nil, map[string]interface{}{
+			"success": false,
+			"error":   "Registration is closed",
+		}, nil
+	}
+	return &tournament, nil, nil
+}
+
+// scheduleStart starts a tournament at its scheduled time
+func (ts *TournamentService) scheduleStart(tournament *models.Tournament) {
+	if tournament.StartsAt == nil {
+		return
+	}
+	tournamentID := tournament.ID
+	time.AfterFunc(time.Until(*tournament.StartsAt), func() {
+		ts.mutex.Lock()
+		defer ts.mutex.Unlock()
+
+		var tournament models.Tournament
+		if err := ts.db.First(&tournament, tournamentID).Error; err != nil {
+			return
+		}
+		if tournament.Status != models.TournamentStatusRegistration {
+			return
+		}
+		started, err := ts.start(&tournament)
+		if err != nil {
+			log.Printf("Failed to start tournament %d: %v", tournamentID, err)
+			return
+		}
+		if !started {
+			ts.db.Model(&tournament).Update("status", models.TournamentStatusCancelled)
+		}
+	})
+}
+
+// start seeds the registered players by rating and plays the first round, the caller holds the mutex
+func (ts *TournamentService) start(tournament *models.Tournament) (bool, error) {
+	var entries []models.TournamentEntry
+	if err := ts.db.Where("tournament_id = ?", tournament.ID).Order("id ASC").Find(&entries).Error; err != nil {
+		return false, err
+	}
+	if len(entries) < 2 {
+		return false, nil
+	}
+
+	// Ratings may have moved since the registration
+	for i := range entries {
+		var player models.Player
+		if err := ts.db.First(&player, entries[i].PlayerID).Error; err == nil {
+			entries[i].Rating = player.Rating
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Rating > entries[j].Rating
+	})
+	slots := bracketSlots(len(entries))
+	for i := range entries {
+		entries[i].Seed = i + 1
+		entries[i].Slot = slots[i]
+	}
+
+	if tournament.Format == models.TournamentFormatSwiss && tournament.Rounds <= 0 {
+		tournament.Rounds = int(math.Ceil(math.Log2(float64(len(entries)))))
+	}
+	tournament.Status = models.TournamentStatusRunning
+	err := ts.db.Transaction(func(tx *gorm.DB) error {
+		for i := range entries {
+			if err := tx.Save(&entries[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Entries", "Pairings").Save(tournament).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, ts.startRound(tournament)
+}
+
+// scheduleRound plays the next round of a tournament once its break is over
+func (ts *TournamentService) scheduleRound(tournament *models.Tournament) {
+	tournamentID, round := tournament.ID, tournament.CurrentRound
+	time.AfterFunc(time.Until(*tournament.NextRoundAt), func() {
+		ts.mutex.Lock()
+		defer ts.mutex.Unlock()
+
+		var tournament models.Tournament
+		if err := ts.db.First(&tournament, tournamentID).Error; err != nil {
+			return
+		}
+		if tournament.Status != models.TournamentStatusRunning || tournament.CurrentRound != round {
+			return
+		}
+		if err := ts.startRound(&tournament); err != nil {
+			log.Printf("Failed to start round %d of tournament %d: %v", round+1, tournamentID, err)
+		}
+	})
+}
+
+// startRound pairs the players of the next round and creates their matches,
+// or ranks the players when the tournament is over. The caller holds the mutex.
+func (ts *TournamentService) startRound(tournament *models.Tournament) error {
+	var entries []models.TournamentEntry
+	if err := ts.db.Where("tournament_id = ?", tournament.ID).Order("seed ASC").Find(&entries).Error; err != nil {
+		return err
+	}
+	var played []models.TournamentPairing
+	if err := ts.db.Where("tournament_id = ?", tournament.ID).Find(&played).Error; err != nil {
+		return err
+	}
+
+	round := tournament.CurrentRound + 1
+	pairings := pairRound(tournament, entries, played, round)
+	if len(pairings) == 0 {
+		return ts.finish(tournament, entries)
+	}
+
+	tournament.CurrentRound = round
+	tournament.NextRoundAt = nil
+	err := ts.db.Transaction(func(tx *gorm.DB) error {
+		for i := range pairings {
+			pairing := &pairings[i]
+			pairing.TournamentID = tournament.ID
+			if pairing.Player2ID == nil {
+				pairing.Status = models.PairingStatusBye
+				pairing.WinnerID = &pairing.Player1ID
+				if err := tx.Create(pairing).Error; err != nil {
+					return err
+				}
+				if err := applyResult(tx, tournament, pairing, entries); err != nil {
+					return err
+				}
+				continue
+			}
+
+			pairing.Status = models.PairingStatusPlaying
+			if err := tx.Create(pairing).Error; err != nil {
+				return err
+			}
+			if err := ts.createMatch(tx, tournament, pairing); err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Entries", "Pairings").Save(tournament).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	ts.scheduleNoShows(tournament.ID, round)
+	return ts.checkRound(tournament)
+}
+
+// createMatch creates the private race of a pairing
+func (ts *TournamentService) createMatch(tx *gorm.DB, tournament *models.Tournament, pairing *models.TournamentPairing) error {
+	match, err := ts.matchService.newMatch(models.MatchModeRace, 0)
+	if err != nil {
+		return err
+	}
+	match.MinPlayers = 2
+	match.MaxPlayers = 2
+	match.TournamentID = &tournament.ID
+	if err := tx.Create(match).Error; err != nil {
+		return err
+	}
+
+	pairing.MatchID = &match.ID
+	return tx.Save(pairing).Error
+}
+
+// scheduleNoShows decides the matches of a round that never started, the
+// players who joined win against those who did not show up
+func (ts *TournamentService) scheduleNoShows(tournamentID uint, round int) {
+	time.AfterFunc(ts.cfg.TournamentNoShow, func() {
+		var matchIDs []uint
+		err := ts.db.Model(&models.TournamentPairing{}).
+			Joins("JOIN matches ON matches.id = tournament_pairings.match_id").
+			Where("tournament_pairings.tournament_id = ? AND tournament_pairings.round = ?", tournamentID, round).
+			Where("tournament_pairings.status = ? AND matches.status = ?", models.PairingStatusPlaying, models.MatchStatusWaiting).
+			Pluck("matches.id", &matchIDs).Error
+		if err != nil {
+			log.Printf("Failed to check no-shows of tournament %d: %v", tournamentID, err)
+			return
+		}
+		for _, matchID := range matchIDs {
+			ts.matchService.finishMatch(matchID)
+		}
+	})
+}
+
+// matchFinished records the result of a tournament match and moves the tournament on
+func (ts *TournamentService) matchFinished(match models.Match) {
+	if match.TournamentID == nil {
+		return
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	var tournament models.Tournament
+	if err := ts.db.First(&tournament, *match.TournamentID).Error; err != nil {
+		return
+	}
+	var pairing models.TournamentPairing
+	err := ts.db.Where("match_id = ? AND status = ?", match.ID, models.PairingStatusPlaying).First(&pairing).Error
+	if err != nil {
+		return
+	}
+	var entries []models.TournamentEntry
+	if err := ts.db.Where("tournament_id = ?", tournament.ID).Find(&entries).Error; err != nil {
+		return
+	}
+
+	// A tie or a match nobody played: the better seed goes through, a tie
+	// still counts as a played match
+	winnerID := match.WinnerID
+	if winnerID == nil || (*winnerID != pairing.Player1ID && *winnerID != *pairing.Player2ID) {
+		pairing.Forfeit = match.StartsAt == nil || !matchTied(match.Players)
+		winnerID = &pairing.Player1ID
+		if seedOf(entries, *pairing.Player2ID) < seedOf(entries, pairing.Player1ID) {
+			winnerID = pairing.Player2ID
+		}
+	} else if match.StartsAt == nil {
+		// The opponent never joined
+		pairing.Forfeit = true
+	}
+	pairing.WinnerID = winnerID
+	pairing.Status = models.PairingStatusFinished
+
+	err = ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&pairing).Error; err != nil {
+			return err
+		}
+		return applyResult(tx, &tournament, &pairing, entries)
+	})
+	if err != nil {
+		log.Printf("Failed to record tournament %d result: %v", tournament.ID, err)
+		return
+	}
+
+	if err := ts.checkRound(&tournament); err != nil {
+		log.Printf("Failed to advance tournament %d: %v", tournament.ID, err)
+	}
+}
+
+// checkRound schedules the next round once every match of the current one finished
+func (ts *TournamentService) checkRound(tournament *models.Tournament) error {
+	var playing int64
+	err := ts.db.Model(&models.TournamentPairing{}).
+		Where("tournament_id = ? AND round = ? AND status = ?", tournament.ID, tournament.CurrentRound, models.PairingStatusPlaying).
+		Count(&playing).Error
+	if err != nil || playing > 0 {
+		return err
+	}
+
+	nextRoundAt := time.Now().Add(ts.cfg.TournamentRoundBreak)
+	tournament.NextRoundAt = &nextRoundAt
+	if err := ts.db.Model(tournament).Update("next_round_at", nextRoundAt).Error; err != nil {
+		return err
+	}
+	ts.scheduleRound(tournament)
+	return nil
+}
+
+// finish ranks the players of a tournament that has no round left to play
+func (ts *TournamentService) finish(tournament *models.Tournament, entries []models.TournamentEntry) error {
+	rankEntries(tournament.Format, entries)
+
+	now := time.Now()
+	tournament.Status = models.TournamentStatusFinished
+	tournament.FinishedAt = &now
+	tournament.NextRoundAt = nil
+	if len(entries) > 0 {
+		tournament.WinnerID = &entries[0].PlayerID
+	}
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		for i := range entries {
+			entries[i].Rank = i + 1
+			if err := tx.Model(&entries[i]).Update("rank", entries[i].Rank).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Entries", "Pairings").Save(tournament).Error
+	})
+}
+
+// rankEntries orders the players of a finished tournament from first to last.
+// Swiss players rank by points, then wins so a bye counts less than a won game,
+// then seed. Elimination players rank by how long they lasted, then seed.
+func rankEntries(format string, entries []models.TournamentEntry) {
+	if format == models.TournamentFormatSwiss {
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			if a.Points != b.Points {
+				return a.Points > b.Points
+			}
+			if a.Wins != b.Wins {
+				return a.Wins > b.Wins
+			}
+			return a.Seed < b.Seed
+		})
+	} else {
+		// The last player standing wins, the others rank by how long they lasted
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			if (a.EliminatedRound == 0) != (b.EliminatedRound == 0) {
+				return a.EliminatedRound == 0
+			}
+			if a.EliminatedRound != b.EliminatedRound {
+				return a.EliminatedRound > b.EliminatedRound
+			}
+			return a.Seed < b.Seed
+		})
+	}
+}
+
+// bracket is the public view of a tournament with its standings and rounds
+func (ts *TournamentService) bracket(tournamentID uint) (map[string]interface{}, error) {
+	var tournament models.Tournament
+	if err := ts.db.First(&tournament, tournamentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "Tournament not found",
+			}, nil
+		}
+		return nil, err
+	}
+
+	var entries []models.TournamentEntry
+	if err := ts.db.Where("tournament_id = ?", tournament.ID).Order("seed ASC, id ASC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	var pairings []models.TournamentPairing
+	if err := ts.db.Where("tournament_id = ?", tournament.ID).Order("round ASC, id ASC").Find(&pairings).Error; err != nil {
+		return nil, err
+	}
+	var matches []models.Match
+	if err := ts.db.Where("tournament_id = ?", tournament.ID).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	matchesByID := make(map[uint]*models.Match, len(matches))
+	for i := range matches {
+		matchesByID[matches[i].ID] = &matches[i]
+	}
+	entriesByPlayer := make(map[uint]*models.TournamentEntry, len(entries))
+	for i := range entries {
+		entriesByPlayer[entries[i].PlayerID] = &entries[i]
+	}
+	slot := func(playerID uint) interface{} {
+		entry, exists := entriesByPlayer[playerID]
+		if !exists {
+			return nil
+		}
+		return map[string]interface{}{
+			"player_id": entry.PlayerID,
+			"username":  entry.Username,
+			"seed":      entry.Seed,
+		}
+	}
+
+	rounds := []map[string]interface{}{}
+	for _, pairing := range pairings {
+		if len(rounds) == 0 || rounds[len(rounds)-1]["round"] != pairing.Round {
+			rounds = append(rounds, map[string]interface{}{
+				"round":    pairing.Round,
+				"pairings": []map[string]interface{}{},
+			})
+		}
+		view := map[string]interface{}{
+			"id":        pairing.ID,
+			"bracket":   pairing.Bracket,
+			"player1":   slot(pairing.Player1ID),
+			"player2":   nil,
+			"winner_id": pairing.WinnerID,
+			"status":    pairing.Status,
+			"forfeit":   pairing.Forfeit,
+		}
+		if pairing.Player2ID != nil {
+			view["player2"] = slot(*pairing.Player2ID)
+		}
+		if pairing.MatchID != nil {
+			if match, exists := matchesByID[*pairing.MatchID]; exists {
+				view["match_token"] = match.MatchToken
+				view["match_status"] = match.Status
+			}
+		}
+		current := rounds[len(rounds)-1]
+		current["pairings"] = append(current["pairings"].([]map[string]interface{}), view)
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"tournament": tournament,
+		"entries":    entries,
+		"rounds":     rounds,
+	}, nil
+}
+
+// pairRound returns the pairings of a round, none when the tournament is over
+func pairRound(tournament *models.Tournament, entries []models.TournamentEntry, played []models.TournamentPairing, round int) []models.TournamentPairing {
+	switch tournament.Format {
+	case models.TournamentFormatSwiss:
+		if round > tournament.Rounds {
+			return nil
+		}
+		return pairSwiss(entries, played, round)
+	case models.TournamentFormatDouble:
+		return pairDouble(tournament, entries, round)
+	default:
+		alive := filterEntries(entries, func(entry models.TournamentEntry) bool { return entry.Losses == 0 })
+		if len(alive) < 2 {
+			return nil
+		}
+		return pairBracket(alive, round, round, models.BracketWinners)
+	}
+}
+
+// pairDouble pairs the players without a loss in the winners bracket and
+// those with one loss among themselves, until the last two meet in the final.
+// When the player coming from the losers bracket wins the final, both have
+// one loss and the final is played again.
+func pairDouble(tournament *models.Tournament, entries []models.TournamentEntry, round int) []models.TournamentPairing {
+	unbeaten := filterEntries(entries, func(entry models.TournamentEntry) bool { return entry.Losses == 0 })
+	oneLoss := filterEntries(entries, func(entry models.TournamentEntry) bool { return entry.Losses == 1 })
+	if len(unbeaten)+len(oneLoss) < 2 {
+		return nil
+	}
+	if len(unbeaten)+len(oneLoss) == 2 {
+		finalists := append(unbeaten, oneLoss...)
+		return []models.TournamentPairing{newPairing(round, models.BracketFinal, finalists[0].PlayerID, &finalists[1].PlayerID)}
+	}
+
+	var pairings []models.TournamentPairing
+	if len(unbeaten) >= 2 {
+		tournament.WinnersRound++
+		pairings = append(pairings, pairBracket(unbeaten, tournament.WinnersRound, round, models.BracketWinners)...)
+	}
+	if len(oneLoss) >= 2 {
+		// Best seed against worst seed, the best seed sits out an odd round
+		if len(oneLoss)%2 == 1 {
+			pairings = append(pairings, newPairing(round, models.BracketLosers, oneLoss[0].PlayerID, nil))
+			oneLoss = oneLoss[1:]
+		}
+		for i := 0; i < len(oneLoss)/2; i++ {
+			pairings = append(pairings, newPairing(round, models.BracketLosers, oneLoss[i].PlayerID, &oneLoss[len(oneLoss)-1-i].PlayerID))
+		}
+	}
+	return pairings
+}
+
+// pairBracket pairs the players of an elimination bracket. Round r of the
+// bracket groups the slots by pairs of 2^r, each group holds the two winners
+// of the previous round or a single player who gets a bye.
+func pairBracket(alive []models.TournamentEntry, bracketRound, round int, bracket string) []models.TournamentPairing {
+	groups := map[int][]models.TournamentEntry{}
+	var keys []int
+	for _, entry := range alive {
+		key := entry.Slot >> bracketRound
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], entry)
+	}
+	sort.Ints(keys)
+
+	var pairings []models.TournamentPairing
+	for _, key := range keys {
+		group := groups[key]
+		sort.Slice(group, func(i, j int) bool { return group[i].Seed < group[j].Seed })
+		if len(group) == 1 {
+			pairings = append(pairings, newPairing(round, bracket, group[0].PlayerID, nil))
+			continue
+		}
+		pairings = append(pairings, newPairing(round, bracket, group[0].PlayerID, &group[1].PlayerID))
+	}
+	return pairings
+}
+
+// pairSwiss pairs players with the same score, avoiding rematches where
+// possible. With an odd count the lowest ranked player without a bye gets one.
+func pairSwiss(entries []models.TournamentEntry, played []models.TournamentPairing, round int) []models.TournamentPairing {
+	standings := append([]models.TournamentEntry(nil), entries...)
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Points != standings[j].Points {
+			return standings[i].Points > standings[j].Points
+		}
+		return standings[i].Seed < standings[j].Seed
+	})
+
+	met := map[[2]uint]bool{}
+	for _, pairing := range played {
+		if pairing.Player2ID != nil {
+			met[[2]uint{pairing.Player1ID, *pairing.Player2ID}] = true
+			met[[2]uint{*pairing.Player2ID, pairing.Player1ID}] = true
+		}
+	}
+
+	var pairings []models.TournamentPairing
+	if len(standings)%2 == 1 {
+		bye := len(standings) - 1
+		for i := len(standings) - 1; i >= 0; i-- {
+			if !standings[i].HadBye {
+				bye = i
+				break
+			}
+		}
+		pairings = append(pairings, newPairing(round, models.BracketSwiss, standings[bye].PlayerID, nil))
+		standings = append(standings[:bye], standings[bye+1:]...)
+	}
+
+	paired := make([]bool, len(standings))
+	for i := range standings {
+		if paired[i] {
+			continue
+		}
+		opponent := -1
+		for j := i + 1; j < len(standings); j++ {
+			if paired[j] {
+				continue
+			}
+			if opponent < 0 {
+				opponent = j
+			}
+			if !met[[2]uint{standings[i].PlayerID, standings[j].PlayerID}] {
+				opponent = j
+				break
+			}
+		}
+		paired[i] = true
+		paired[opponent] = true
+		pairings = append(pairings, newPairing(round, models.BracketSwiss, standings[i].PlayerID, &standings[opponent].PlayerID))
+	}
+	return pairings
+}
+
+// applyResult updates the standings of the players of a decided pairing
+func applyResult(tx *gorm.DB, tournament *models.Tournament, pairing *models.TournamentPairing, entries []models.TournamentEntry) error {
+	for i := range entries {
+		entry := &entries[i]
+		switch entry.PlayerID {
+		case *pairing.WinnerID:
+			if pairing.Player2ID == nil {
+				entry.HadBye = true
+			} else {
+				entry.Wins++
+			}
+			entry.Points++
+		case pairing.Opponent(*pairing.WinnerID):
+			entry.Losses++
+			if tournament.Format == models.TournamentFormatSingle && entry.Losses >= 1 ||
+				tournament.Format == models.TournamentFormatDouble && entry.Losses >= 2 {
+				entry.EliminatedRound = pairing.Round
+			}
+		default:
+			continue
+		}
+		if err := tx.Save(entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bracketSlots returns the bracket slot of each seed, placing the best seeds
+// apart so they can only meet late. Seeds past the player count are byes.
+func bracketSlots(players int) []int {
+	order := []int{1}
+	for len(order) < players {
+		size := len(order) * 2
+		next := make([]int, 0, size)
+		for _, seed := range order {
+			next = append(next, seed, size+1-seed)
+		}
+		order = next
+	}
+
+	slots := make([]int, players)
+	for slot, seed := range order {
+		if seed <= players {
+			slots[seed-1] = slot
+		}
+	}
+	return slots
+}
+
+func newPairing(round int, bracket string, player1ID uint, player2ID *uint) models.TournamentPairing {
+	return models.TournamentPairing{
+		Round:     round,
+		Bracket:   bracket,
+		Player1ID: player1ID,
+		Player2ID: player2ID,
+	}
+}
+
+func filterEntries(entries []models.TournamentEntry, keep func(entry models.TournamentEntry) bool) []models.TournamentEntry {
+	var kept []models.TournamentEntry
+	for _, entry := range entries {
+		if keep(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// seedOf returns a player's seed, players who withdrew rank last
+func seedOf(entries []models.TournamentEntry, playerID uint) int {
+	for _, entry := range entries {
+		if entry.PlayerID == playerID {
+			return entry.Seed
+		}
+	}
+	return math.MaxInt32
+}
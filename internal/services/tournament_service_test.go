@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"boba-vim/internal/models"
+)
+
+// testEntries returns entries for players 1..n seeded in id order
+func testEntries(n int) []models.TournamentEntry {
+	entries := make([]models.TournamentEntry, n)
+	for i := range entries {
+		entries[i] = models.TournamentEntry{PlayerID: uint(i + 1), Seed: i + 1}
+	}
+	return entries
+}
+
+// describePairings renders pairings as "1-2" and byes as "3-bye"
+func describePairings(pairings []models.TournamentPairing) []string {
+	described := make([]string, len(pairings))
+	for i, pairing := range pairings {
+		if pairing.Player2ID == nil {
+			described[i] = fmt.Sprintf("%d-bye", pairing.Player1ID)
+		} else {
+			described[i] = fmt.Sprintf("%d-%d", pairing.Player1ID, *pairing.Player2ID)
+		}
+	}
+	return described
+}
+
+func playedPairing(player1ID, player2ID uint) models.TournamentPairing {
+	return newPairing(1, models.BracketSwiss, player1ID, &player2ID)
+}
+
+func TestPairSwiss(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries func() []models.TournamentEntry
+		played  []models.TournamentPairing
+		want    []string
+	}{
+		{
+			name:    "first round pairs neighbours by seed",
+			entries: func() []models.TournamentEntry { return testEntries(4) },
+			want:    []string{"1-2", "3-4"},
+		},
+		{
+			name: "players with the same points meet",
+			entries: func() []models.TournamentEntry {
+				entries := testEntries(4)
+				entries[0].Points, entries[2].Points = 1, 1
+				return entries
+			},
+			played: []models.TournamentPairing{playedPairing(1, 2), playedPairing(3, 4)},
+			want:   []string{"1-3", "2-4"},
+		},
+		{
+			name:    "rematches are skipped",
+			entries: func() []models.TournamentEntry { return testEntries(4) },
+			played:  []models.TournamentPairing{playedPairing(1, 2)},
+			want:    []string{"1-3", "2-4"},
+		},
+		{
+			name:    "a rematch is played when nobody else is left",
+			entries: func() []models.TournamentEntry { return testEntries(2) },
+			played:  []models.TournamentPairing{playedPairing(1, 2)},
+			want:    []string{"1-2"},
+		},
+		{
+			name:    "the lowest ranked player gets the bye",
+			entries: func() []models.TournamentEntry { return testEntries(5) },
+			want:    []string{"5-bye", "1-2", "3-4"},
+		},
+		{
+			name: "a second bye goes to the next player up",
+			entries: func() []models.TournamentEntry {
+				entries := testEntries(5)
+				entries[4].HadBye = true
+				return entries
+			},
+			want: []string{"4-bye", "1-2", "3-5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describePairings(pairSwiss(tt.entries(), tt.played, 2))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pairSwiss = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBracketSlots(t *testing.T) {
+	tests := []struct {
+		players int
+		want    []int
+	}{
+		{2, []int{0, 1}},
+		{3, []int{0, 2, 3}},
+		{4, []int{0, 2, 3, 1}},
+		{8, []int{0, 4, 6, 2, 3, 7, 5, 1}},
+	}
+	for _, tt := range tests {
+		if got := bracketSlots(tt.players); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("bracketSlots(%d) = %v, want %v", tt.players, got, tt.want)
+		}
+	}
+}
+
+func TestRankEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		entries []models.TournamentEntry
+		want    []uint
+	}{
+		{
+			name:   "swiss ranks by points",
+			format: models.TournamentFormatSwiss,
+			entries: []models.TournamentEntry{
+				{PlayerID: 1, Seed: 1, Points: 1, Wins: 1},
+				{PlayerID: 2, Seed: 2, Points: 3, Wins: 3},
+				{PlayerID: 3, Seed: 3, Points: 2, Wins: 2},
+			},
+			want: []uint{2, 3, 1},
+		},
+		{
+			name:   "swiss ties go to wins before a bye",
+			format: models.TournamentFormatSwiss,
+			entries: []models.TournamentEntry{
+				{PlayerID: 1, Seed: 1, Points: 2, Wins: 1, HadBye: true},
+				{PlayerID: 2, Seed: 2, Points: 2, Wins: 2},
+			},
+			want: []uint{2, 1},
+		},
+		{
+			name:   "swiss ties with equal wins go to the better seed",
+			format: models.TournamentFormatSwiss,
+			entries: []models.TournamentEntry{
+				{PlayerID: 1, Seed: 3, Points: 2, Wins: 2},
+				{PlayerID: 2, Seed: 1, Points: 2, Wins: 2},
+			},
+			want: []uint{2, 1},
+		},
+		{
+			name:   "elimination ranks the last player standing first",
+			format: models.TournamentFormatSingle,
+			entries: []models.TournamentEntry{
+				{PlayerID: 1, Seed: 1, EliminatedRound: 1},
+				{PlayerID: 2, Seed: 2, EliminatedRound: 3},
+				{PlayerID: 3, Seed: 3},
+				{PlayerID: 4, Seed: 4, EliminatedRound: 2},
+			},
+			want: []uint{3, 2, 4, 1},
+		},
+		{
+			name:   "players out in the same round rank by seed",
+			format: models.TournamentFormatDouble,
+			entries: []models.TournamentEntry{
+				{PlayerID: 1, Seed: 4, EliminatedRound: 2},
+				{PlayerID: 2, Seed: 2, EliminatedRound: 2},
+				{PlayerID: 3, Seed: 1},
+			},
+			want: []uint{3, 2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankEntries(tt.format, tt.entries)
+			got := make([]uint, len(tt.entries))
+			for i, entry := range tt.entries {
+				got[i] = entry.PlayerID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ranking = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
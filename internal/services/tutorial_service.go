@@ -0,0 +1,332 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/game"
+	"boba-vim/internal/models"
+	"boba-vim/internal/tutorial"
+
+	"gorm.io/gorm"
+)
+
+type TutorialService struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	lessons []*tutorial.Lesson
+}
+
+func NewTutorialService(db *gorm.DB, cfg *config.Config) *TutorialService {
+	// Lessons that fail verification are skipped so one bad file does not take the tutorial down
+	lessons, errs := tutorial.LoadLessons(cfg.LessonsDir)
+	for _, err := range errs {
+		log.Printf("Skipping tutorial lesson: %v", err)
+	}
+
+	return &TutorialService{
+		db:      db,
+		cfg:     cfg,
+		lessons: lessons,
+	}
+}
+
+// ListLessons returns the lessons in order with the player's progress on each
+func (ts *TutorialService) ListLessons(playerID uint) (map[string]interface{}, error) {
+	progressByLesson, err := ts.progressByLesson(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []map[string]interface{}
+	for _, lesson := range ts.lessons {
+		progress := progressByLesson[lesson.ID]
+		entry := map[string]interface{}{
+			"id":              lesson.ID,
+			"title":           lesson.Title,
+			"description":     lesson.Description,
+			"total_steps":     len(lesson.Steps),
+			"completed_steps": 0,
+			"is_completed":    false,
+		}
+		if progress != nil {
+			entry["completed_steps"] = progress.CompletedSteps
+			entry["is_completed"] = progress.IsCompleted
+		}
+		entries = append(entries, entry)
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"lessons": entries,
+	}, nil
+}
+
+// StartLesson starts a tutorial session on a lesson, resuming at the player's saved step.
+// Without a lesson id the first lesson the player has not completed is picked.
+func (ts *TutorialService) StartLesson(playerID uint, lessonID string) (map[string]interface{}, error) {
+	progressByLesson, err := ts.progressByLesson(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	lesson := ts.findLesson(lessonID)
+	if lessonID == "" && len(ts.lessons) > 0 {
+		lesson = ts.lessons[len(ts.lessons)-1]
+		for _, candidate := range ts.lessons {
+			if progress := progressByLesson[candidate.ID]; progress == nil || !progress.IsCompleted {
+				lesson = candidate
+				break
+			}
+		}
+	}
+	if lesson == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Lesson not found",
+		}, nil
+	}
+
+	stepIndex := 0
+	if progress := progressByLesson[lesson.ID]; progress != nil && !progress.IsCompleted {
+		stepIndex = progress.CompletedSteps
+	}
+
+	// A login whose player no longer exists plays anonymously
+	if player, err := findPlayer(ts.db, playerID); err != nil {
+		return nil, err
+	} else if player == nil {
+		playerID = 0
+	}
+
+	// Only one tutorial session is active per player
+	if playerID > 0 {
+		ts.db.Model(&models.TutorialSession{}).
+			Where("player_id = ? AND is_active = ?", playerID, true).
+			Update("is_active", false)
+	}
+
+	step := lesson.Steps[stepIndex]
+	tutorialSession := &models.TutorialSession{
+		PlayerID:        playerID,
+		LessonID:        lesson.ID,
+		StepIndex:       stepIndex,
+		CurrentRow:      step.Start.Row,
+		CurrentCol:      step.Start.Col,
+		PreferredColumn: step.Start.Col,
+		IsActive:        true,
+	}
+	if err := ts.db.Create(tutorialSession).Error; err != nil {
+		return nil, err
+	}
+
+	result := ts.stepState(lesson, tutorialSession)
+	result["session_token"] = tutorialSession.SessionToken
+	return result, nil
+}
+
+// GetState returns the current step of a tutorial session
+func (ts *TutorialService) GetState(sessionToken string) (map[string]interface{}, error) {
+	tutorialSession, lesson, result, err := ts.loadSession(sessionToken)
+	if result != nil || err != nil {
+		return result, err
+	}
+	return ts.stepState(lesson, tutorialSession), nil
+}
+
+// ProcessMove applies a motion to the current step and advances when its goal is reached
+func (ts *TutorialService) ProcessMove(sessionToken, direction string) (map[string]interface{}, error) {
+	tutorialSession, lesson, result, err := ts.loadSession(sessionToken)
+	if result != nil || err != nil {
+		return result, err
+	}
+	if tutorialSession.IsCompleted {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Lesson already completed",
+		}, nil
+	}
+
+	step := lesson.Steps[tutorialSession.StepIndex]
+	if !game.IsMotionAllowed(game.MotionKey(direction), step.AllowedMotions, nil) {
+		return map[string]interface{}{
+			"success":    false,
+			"error":      models.ErrMotionNotAllowed.Message,
+			"error_code": models.ErrMotionNotAllowed.Code,
+			"hint":       step.Hint(tutorialSession.StepMoves),
+		}, nil
+	}
+
+	finalDirection, exists := game.ResolveDirection(direction)
+	if !exists {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Invalid movement key",
+		}, nil
+	}
+
+	movementResult, err := tutorial.ApplyMotion(
+		lesson.TextGrid(tutorialSession.StepIndex),
+		finalDirection,
+		tutorialSession.CurrentRow,
+		tutorialSession.CurrentCol,
+		tutorialSession.PreferredColumn,
+	)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		}, nil
+	}
+	if movementResult == nil {
+		return map[string]interface{}{
+			"success": false,
+			"error":   "Out of bounds",
+		}, nil
+	}
+
+	tutorialSession.CurrentRow = movementResult.NewRow
+	tutorialSession.CurrentCol = movementResult.NewCol
+	tutorialSession.PreferredColumn = movementResult.PreferredColumn
+	tutorialSession.StepMoves++
+	tutorialSession.StepKeystrokes += game.KeystrokeCount(direction)
+	tutorialSession.TotalMoves++
+
+	// The server decides when a step is done, the client only renders the result
+	stepCompleted := step.Goal.Contains(tutorialSession.CurrentRow, tutorialSession.CurrentCol)
+	stepKeystrokes := tutorialSession.StepKeystrokes
+
+	err = ts.db.Transaction(func(tx *gorm.DB) error {
+		if stepCompleted {
+			if tutorialSession.StepIndex+1 < len(lesson.Steps) {
+				tutorialSession.StepIndex++
+				next := lesson.Steps[tutorialSession.StepIndex]
+				tutorialSession.CurrentRow = next.Start.Row
+				tutorialSession.CurrentCol = next.Start.Col
+				tutorialSession.PreferredColumn = next.Start.Col
+				tutorialSession.StepMoves = 0
+				tutorialSession.StepKeystrokes = 0
+			} else {
+				now := time.Now()
+				tutorialSession.IsCompleted = true
+				tutorialSession.IsActive = false
+				tutorialSession.EndTime = &now
+			}
+
+			if tutorialSession.PlayerID > 0 {
+				if err := ts.saveProgress(tx, tutorialSession, lesson); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Save(tutorialSession).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result = ts.stepState(lesson, tutorialSession)
+	result["step_completed"] = stepCompleted
+	if stepCompleted {
+		result["step_keystrokes"] = stepKeystrokes
+		result["par"] = step.Par
+	}
+	return result, nil
+}
+
+// stepState describes the session's current step for the client
+func (ts *TutorialService) stepState(lesson *tutorial.Lesson, tutorialSession *models.TutorialSession) map[string]interface{} {
+	step := lesson.Steps[tutorialSession.StepIndex]
+	return map[string]interface{}{
+		"success": true,
+		"lesson": map[string]interface{}{
+			"id":    lesson.ID,
+			"title": lesson.Title,
+		},
+		"step_index":       tutorialSession.StepIndex,
+		"total_steps":      len(lesson.Steps),
+		"instruction":      step.Instruction,
+		"text_grid":        lesson.TextGrid(tutorialSession.StepIndex),
+		"cursor":           map[string]int{"row": tutorialSession.CurrentRow, "col": tutorialSession.CurrentCol},
+		"goal":             step.Goal,
+		"allowed_motions":  step.AllowedMotions,
+		"par":              step.Par,
+		"hint":             step.Hint(tutorialSession.StepMoves),
+		"lesson_completed": tutorialSession.IsCompleted,
+	}
+}
+
+// loadSession loads an active tutorial session and its lesson, or a failure response
+func (ts *TutorialService) loadSession(sessionToken string) (*models.TutorialSession, *tutorial.Lesson, map[string]interface{}, error) {
+	var tutorialSession models.TutorialSession
+	if err := ts.db.Where("session_token = ? AND is_active = ?", sessionToken, true).First(&tutorialSession).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, map[string]interface{}{
+				"success": false,
+				"error":   "Invalid or expired tutorial session",
+			}, nil
+		}
+		return nil, nil, nil, err
+	}
+
+	lesson := ts.findLesson(tutorialSession.LessonID)
+	if lesson == nil || tutorialSession.StepIndex >= len(lesson.Steps) {
+		return nil, nil, map[string]interface{}{
+			"success": false,
+			"error":   "Lesson no longer exists",
+		}, nil
+	}
+	return &tutorialSession, lesson, nil, nil
+}
+
+// saveProgress persists how many steps of the lesson the player has completed
+func (ts *TutorialService) saveProgress(tx *gorm.DB, tutorialSession *models.TutorialSession, lesson *tutorial.Lesson) error {
+	progress := models.TutorialProgress{PlayerID: tutorialSession.PlayerID, LessonID: lesson.ID}
+	if err := tx.Where("player_id = ? AND lesson_id = ?", progress.PlayerID, progress.LessonID).FirstOrCreate(&progress).Error; err != nil {
+		return err
+	}
+
+	completedSteps := tutorialSession.StepIndex
+	if tutorialSession.IsCompleted {
+		completedSteps = len(lesson.Steps)
+	}
+	if completedSteps > progress.CompletedSteps {
+		progress.CompletedSteps = completedSteps
+	}
+	if tutorialSession.IsCompleted && !progress.IsCompleted {
+		now := time.Now()
+		progress.IsCompleted = true
+		progress.CompletedAt = &now
+	}
+	return tx.Save(&progress).Error
+}
+
+// progressByLesson loads the player's tutorial progress keyed by lesson ID
+func (ts *TutorialService) progressByLesson(playerID uint) (map[string]*models.TutorialProgress, error) {
+	progressByLesson := make(map[string]*models.TutorialProgress)
+
+	player, err := findPlayer(ts.db, playerID)
+	if err != nil || player == nil {
+		return progressByLesson, err
+	}
+
+	var records []models.TutorialProgress
+	if err := ts.db.Where("player_id = ?", player.ID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for i := range records {
+		progressByLesson[records[i].LessonID] = &records[i]
+	}
+	return progressByLesson, nil
+}
+
+func (ts *TutorialService) findLesson(lessonID string) *tutorial.Lesson {
+	for _, lesson := range ts.lessons {
+		if lesson.ID == lessonID {
+			return lesson
+		}
+	}
+	return nil
+}
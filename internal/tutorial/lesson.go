@@ -0,0 +1,136 @@
+package tutorial
+
+import (
+	"errors"
+	"fmt"
+
+	"boba-vim/internal/game"
+)
+
+// Position is a cursor position in a lesson text
+type Position struct {
+	Row int `json:"row" yaml:"row"`
+	Col int `json:"col" yaml:"col"`
+}
+
+// Goal is the cell a step asks the player to reach, or a range of cells when To is set
+type Goal struct {
+	Position `yaml:",inline"`
+	To       *Position `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// Contains reports whether the cursor satisfies the goal, ranges are inclusive in reading order
+func (g Goal) Contains(row, col int) bool {
+	if g.To == nil {
+		return row == g.Row && col == g.Col
+	}
+	afterStart := row > g.Row || (row == g.Row && col >= g.Col)
+	beforeEnd := row < g.To.Row || (row == g.To.Row && col <= g.To.Col)
+	return afterStart && beforeEnd
+}
+
+// Step is one exercise of a lesson
+type Step struct {
+	Instruction    string   `json:"instruction" yaml:"instruction"`
+	Text           []string `json:"text,omitempty" yaml:"text,omitempty"` // overrides the lesson text for this step
+	Start          Position `json:"start" yaml:"start"`
+	Goal           Goal     `json:"goal" yaml:"goal"`
+	AllowedMotions []string `json:"allowed_motions,omitempty" yaml:"allowed_motions,omitempty"`
+	Hints          []string `json:"hints,omitempty" yaml:"hints,omitempty"`
+	Par            int      `json:"par,omitempty" yaml:"par,omitempty"` // keystrokes an efficient player needs, 0 means unrated
+}
+
+// Lesson is an ordered list of steps teaching a group of motions
+type Lesson struct {
+	ID          string   `json:"id" yaml:"id"`
+	Title       string   `json:"title" yaml:"title"`
+	Description string   `json:"description" yaml:"description"`
+	Text        []string `json:"text" yaml:"text"`
+	Steps       []Step   `json:"steps" yaml:"steps"`
+}
+
+// HINT_EVERY is how many moves without reaching the goal unlock the next hint
+const HINT_EVERY = 3
+
+// StepText returns the text the step is played on
+func (l *Lesson) StepText(stepIndex int) []string {
+	if step := l.Steps[stepIndex]; len(step.Text) > 0 {
+		return step.Text
+	}
+	return l.Text
+}
+
+// TextGrid converts the step text to a character grid
+func (l *Lesson) TextGrid(stepIndex int) [][]string {
+	level := game.Level{Lines: l.StepText(stepIndex)}
+	return level.TextGrid()
+}
+
+// Validate checks that every step starts and ends inside its text
+func (l *Lesson) Validate() error {
+	if l.ID == "" {
+		return errors.New("lesson without an id")
+	}
+	if len(l.Steps) == 0 {
+		return fmt.Errorf("lesson %q has no steps", l.ID)
+	}
+
+	for i, step := range l.Steps {
+		grid := l.TextGrid(i)
+		if len(grid) == 0 {
+			return fmt.Errorf("lesson %q step %d has no text", l.ID, i+1)
+		}
+		if !inGrid(grid, step.Start) {
+			return fmt.Errorf("lesson %q step %d starts outside the text", l.ID, i+1)
+		}
+		if !inGrid(grid, step.Goal.Position) || (step.Goal.To != nil && !inGrid(grid, *step.Goal.To)) {
+			return fmt.Errorf("lesson %q step %d has a goal outside the text", l.ID, i+1)
+		}
+		if step.Goal.Contains(step.Start.Row, step.Start.Col) {
+			return fmt.Errorf("lesson %q step %d starts on its goal", l.ID, i+1)
+		}
+		for _, key := range step.AllowedMotions {
+			if !game.IsMovementKey(key) {
+				return fmt.Errorf("lesson %q step %d allows unknown motion %q", l.ID, i+1, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Hint returns the hint unlocked after the given number of moves on the step
+func (s *Step) Hint(moves int) string {
+	if len(s.Hints) == 0 {
+		return ""
+	}
+	index := moves / HINT_EVERY
+	if index >= len(s.Hints) {
+		index = len(s.Hints) - 1
+	}
+	return s.Hints[index]
+}
+
+// ApplyMotion moves the cursor on the step text, returning nil when the motion is out of bounds
+func ApplyMotion(grid [][]string, direction string, row, col, preferredColumn int) (*game.MovementResult, error) {
+	result, err := game.CalculateNewPosition(direction, row, col, emptyMap(grid), grid, preferredColumn)
+	if err != nil {
+		return nil, err
+	}
+	if !result.IsValid {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// emptyMap builds a map without walls or pearls matching the grid's shape
+func emptyMap(grid [][]string) [][]int {
+	gameMap := make([][]int, len(grid))
+	for i, row := range grid {
+		gameMap[i] = make([]int, len(row))
+	}
+	return gameMap
+}
+
+func inGrid(grid [][]string, pos Position) bool {
+	return pos.Row >= 0 && pos.Row < len(grid) && pos.Col >= 0 && pos.Col < len(grid[pos.Row])
+}
@@ -0,0 +1,88 @@
+package tutorial
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoalContains(t *testing.T) {
+	single := Goal{Position: Position{Row: 1, Col: 2}}
+	span := Goal{Position: Position{Row: 0, Col: 4}, To: &Position{Row: 1, Col: 1}}
+
+	tests := []struct {
+		name     string
+		goal     Goal
+		row, col int
+		want     bool
+	}{
+		{"single cell hit", single, 1, 2, true},
+		{"single cell miss", single, 1, 3, false},
+		{"range start", span, 0, 4, true},
+		{"range end", span, 1, 1, true},
+		{"rest of the first row", span, 0, 9, true},
+		{"before the range", span, 0, 3, false},
+		{"after the range", span, 1, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.goal.Contains(tt.row, tt.col); got != tt.want {
+				t.Errorf("Contains(%d, %d) = %v, want %v", tt.row, tt.col, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLessonValidate(t *testing.T) {
+	step := func(start, goal Position, motions ...string) Step {
+		return Step{Start: start, Goal: Goal{Position: goal}, AllowedMotions: motions}
+	}
+
+	tests := []struct {
+		name    string
+		lesson  Lesson
+		wantErr string
+	}{
+		{"valid", Lesson{ID: "ok", Text: []string{"abc"}, Steps: []Step{step(Position{0, 0}, Position{0, 2}, "l")}}, ""},
+		{"missing id", Lesson{Text: []string{"abc"}, Steps: []Step{step(Position{0, 0}, Position{0, 2})}}, "without an id"},
+		{"no steps", Lesson{ID: "empty", Text: []string{"abc"}}, "has no steps"},
+		{"start outside", Lesson{ID: "x", Text: []string{"abc"}, Steps: []Step{step(Position{1, 0}, Position{0, 2})}}, "starts outside"},
+		{"goal outside", Lesson{ID: "x", Text: []string{"abc"}, Steps: []Step{step(Position{0, 0}, Position{0, 5})}}, "goal outside"},
+		{"starts on goal", Lesson{ID: "x", Text: []string{"abc"}, Steps: []Step{step(Position{0, 1}, Position{0, 1})}}, "starts on its goal"},
+		{"unknown motion", Lesson{ID: "x", Text: []string{"abc"}, Steps: []Step{step(Position{0, 0}, Position{0, 2}, "q")}}, "unknown motion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.lesson.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStepHint(t *testing.T) {
+	step := Step{Hints: []string{"first", "second"}}
+	tests := []struct {
+		moves int
+		want  string
+	}{
+		{0, "first"},
+		{HINT_EVERY - 1, "first"},
+		{HINT_EVERY, "second"},
+		{HINT_EVERY * 5, "second"},
+	}
+	for _, tt := range tests {
+		if got := step.Hint(tt.moves); got != tt.want {
+			t.Errorf("Hint(%d) = %q, want %q", tt.moves, got, tt.want)
+		}
+	}
+	if got := (&Step{}).Hint(10); got != "" {
+		t.Errorf("Hint without hints = %q, want none", got)
+	}
+}
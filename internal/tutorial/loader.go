@@ -0,0 +1,87 @@
+package tutorial
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LESSON_EXTENSIONS are the file types lessons can be written in
+var LESSON_EXTENSIONS = []string{".json", ".yaml", ".yml"}
+
+// ParseLesson reads a lesson from a JSON or YAML file, the id defaults to the file name
+func ParseLesson(path string) (*Lesson, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lesson Lesson
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".json":
+		err = json.Unmarshal(data, &lesson)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &lesson)
+	default:
+		return nil, fmt.Errorf("%s: unsupported lesson format %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if lesson.ID == "" {
+		lesson.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &lesson, nil
+}
+
+// LessonFiles lists the lesson files in dir sorted by name, which is the curriculum order
+func LessonFiles(dir string) ([]string, error) {
+	var files []string
+	for _, ext := range LESSON_EXTENSIONS {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// LoadLessons parses and verifies every lesson file in dir. Lessons that fail
+// verification are left out and reported in the returned errors.
+func LoadLessons(dir string) ([]*Lesson, []error) {
+	files, err := LessonFiles(dir)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var lessons []*Lesson
+	var errs []error
+	seen := make(map[string]string)
+	for _, file := range files {
+		lesson, err := ParseLesson(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, exists := seen[lesson.ID]; exists {
+			errs = append(errs, fmt.Errorf("%s: lesson id %q is already used by %s", file, lesson.ID, other))
+			continue
+		}
+		if err := lesson.Verify(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		seen[lesson.ID] = file
+		lessons = append(lessons, lesson)
+	}
+	return lessons, errs
+}
@@ -0,0 +1,160 @@
+package tutorial
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+
+	"boba-vim/internal/game"
+)
+
+// Solution is the cheapest way to finish a step, counted in keystrokes
+type Solution struct {
+	Keystrokes int
+	Moves      []string // movement requests in the format the move API accepts
+}
+
+// cursorState is a search node, the preferred column matters for j and k
+type cursorState struct {
+	row, col, preferredColumn int
+}
+
+type searchNode struct {
+	state cursorState
+	cost  int
+	index int
+}
+
+type searchQueue []*searchNode
+
+func (q searchQueue) Len() int           { return len(q) }
+func (q searchQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q searchQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i]; q[i].index = i; q[j].index = j }
+func (q *searchQueue) Push(x interface{}) {
+	node := x.(*searchNode)
+	node.index = len(*q)
+	*q = append(*q, node)
+}
+func (q *searchQueue) Pop() interface{} {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+// Solve runs a shortest-keystroke search over the step's allowed motions,
+// returning nil when the goal cannot be reached from the start
+func (l *Lesson) Solve(stepIndex int) *Solution {
+	step := l.Steps[stepIndex]
+	grid := l.TextGrid(stepIndex)
+
+	keys := step.AllowedMotions
+	if len(keys) == 0 {
+		keys = game.ValidMovementKeys
+	}
+
+	start := cursorState{step.Start.Row, step.Start.Col, step.Start.Col}
+	costs := map[cursorState]int{start: 0}
+	previous := make(map[cursorState]cursorState)
+	previousMove := make(map[cursorState]string)
+
+	queue := &searchQueue{}
+	heap.Push(queue, &searchNode{state: start})
+
+	for queue.Len() > 0 {
+		node := heap.Pop(queue).(*searchNode)
+		if node.cost > costs[node.state] {
+			continue // stale entry
+		}
+
+		if step.Goal.Contains(node.state.row, node.state.col) {
+			solution := &Solution{Keystrokes: node.cost}
+			for state := node.state; state != start; state = previous[state] {
+				solution.Moves = append([]string{previousMove[state]}, solution.Moves...)
+			}
+			return solution
+		}
+
+		for _, move := range candidateMoves(keys, grid, node.state.row) {
+			direction, exists := game.ResolveDirection(move)
+			if !exists {
+				continue
+			}
+			result, err := ApplyMotion(grid, direction, node.state.row, node.state.col, node.state.preferredColumn)
+			if err != nil || result == nil {
+				continue
+			}
+
+			next := cursorState{result.NewRow, result.NewCol, result.PreferredColumn}
+			cost := node.cost + game.KeystrokeCount(move)
+			if known, seen := costs[next]; seen && known <= cost {
+				continue
+			}
+			costs[next] = cost
+			previous[next] = node.state
+			previousMove[next] = move
+			heap.Push(queue, &searchNode{state: next, cost: cost})
+		}
+	}
+	return nil
+}
+
+// candidateMoves expands the allowed keys into move requests, character
+// searches are tried with every character of the current line
+func candidateMoves(keys []string, grid [][]string, row int) []string {
+	searches := map[string]string{
+		"f": "find_char_forward_",
+		"F": "find_char_backward_",
+		"t": "till_char_forward_",
+		"T": "till_char_backward_",
+	}
+
+	var moves []string
+	for _, key := range keys {
+		prefix, isSearch := searches[key]
+		if !isSearch {
+			moves = append(moves, key)
+			continue
+		}
+
+		tried := make(map[string]bool)
+		for _, char := range grid[row] {
+			if len(char) != 1 || tried[char] {
+				continue
+			}
+			tried[char] = true
+			moves = append(moves, prefix+char)
+		}
+	}
+	return moves
+}
+
+// Verify validates the lesson and proves every step can be finished within its par
+func (l *Lesson) Verify() error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+
+	for i, step := range l.Steps {
+		solution := l.Solve(i)
+		if solution == nil {
+			return fmt.Errorf("lesson %q step %d cannot reach its goal with the allowed motions", l.ID, i+1)
+		}
+		if step.Par > 0 && solution.Keystrokes > step.Par {
+			return fmt.Errorf("lesson %q step %d needs %d keystrokes but its par is %d", l.ID, i+1, solution.Keystrokes, step.Par)
+		}
+	}
+	return nil
+}
+
+// Keys renders the solution the way the player types it, e.g. "f{" for a find
+func (s *Solution) Keys() string {
+	keys := make([]string, len(s.Moves))
+	for i, move := range s.Moves {
+		keys[i] = move
+		if key := game.MotionKey(move); key != move {
+			keys[i] = key + move[len(move)-1:]
+		}
+	}
+	return strings.Join(keys, " ")
+}
@@ -0,0 +1,92 @@
+package tutorial
+
+import (
+	"strings"
+	"testing"
+)
+
+// oneStepLesson wraps a single step played on text into a lesson
+func oneStepLesson(text []string, start, goal Position, par int, motions ...string) *Lesson {
+	return &Lesson{
+		ID:   "test",
+		Text: text,
+		Steps: []Step{{
+			Start:          start,
+			Goal:           Goal{Position: goal},
+			AllowedMotions: motions,
+			Par:            par,
+		}},
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name           string
+		lesson         *Lesson
+		wantKeystrokes int
+		wantKeys       string
+		wantUnsolvable bool
+	}{
+		{"single steps", oneStepLesson([]string{"abcdef"}, Position{0, 0}, Position{0, 3}, 0, "h", "l"), 3, "l l l", false},
+		{"line end beats stepping", oneStepLesson([]string{"abcdef"}, Position{0, 0}, Position{0, 5}, 0, "l", "$"), 1, "$", false},
+		{"find costs two keystrokes", oneStepLesson([]string{"ab cd xy"}, Position{0, 0}, Position{0, 6}, 0, "l", "f"), 2, "fx", false},
+		{"gg costs two keystrokes", oneStepLesson([]string{"abcd", "efgh"}, Position{1, 3}, Position{0, 0}, 0, "h", "k", "gg"), 2, "gg", false},
+		{"j clamps to a shorter line", oneStepLesson([]string{"abcd", "ef"}, Position{0, 3}, Position{1, 0}, 0, "h", "j"), 2, "j h", false},
+		{"unreachable goal", oneStepLesson([]string{"abcd"}, Position{0, 0}, Position{0, 3}, 0, "j", "k"), 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution := tt.lesson.Solve(0)
+			if tt.wantUnsolvable {
+				if solution != nil {
+					t.Fatalf("Solve = %q, want no solution", solution.Keys())
+				}
+				return
+			}
+			if solution == nil {
+				t.Fatal("Solve found no solution")
+			}
+			if solution.Keystrokes != tt.wantKeystrokes || solution.Keys() != tt.wantKeys {
+				t.Errorf("Solve = %q in %d keystrokes, want %q in %d", solution.Keys(), solution.Keystrokes, tt.wantKeys, tt.wantKeystrokes)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		lesson  *Lesson
+		wantErr string
+	}{
+		{"par met", oneStepLesson([]string{"abcdef"}, Position{0, 0}, Position{0, 3}, 3, "l"), ""},
+		{"unrated step", oneStepLesson([]string{"abcdef"}, Position{0, 0}, Position{0, 3}, 0, "l"), ""},
+		{"par too low", oneStepLesson([]string{"abcdef"}, Position{0, 0}, Position{0, 3}, 2, "l"), "par is 2"},
+		{"unreachable goal", oneStepLesson([]string{"abcdef"}, Position{0, 3}, Position{0, 0}, 0, "l"), "cannot reach"},
+		{"invalid lesson", oneStepLesson([]string{"abc"}, Position{0, 0}, Position{0, 9}, 0, "l"), "goal outside"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.lesson.Verify()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Verify = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShippedLessonsVerify(t *testing.T) {
+	lessons, errs := LoadLessons("../../lessons")
+	for _, err := range errs {
+		t.Error(err)
+	}
+	if len(lessons) == 0 {
+		t.Fatal("no lessons loaded")
+	}
+}
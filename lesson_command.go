@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"boba-vim/internal/config"
+	"boba-vim/internal/tutorial"
+)
+
+const lessonUsage = `usage: boba-vim lesson validate [file or directory ...]
+
+Checks lesson files and proves every step can be finished within its par.
+Without arguments the lessons directory (LESSONS_DIR) is validated.`
+
+// runLessonCommand handles the lesson subcommands and returns the exit code
+func runLessonCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, lessonUsage)
+		return 2
+	}
+
+	paths := args[1:]
+	if len(paths) == 0 {
+		paths = []string{cfg.LessonsDir}
+	}
+
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		dirFiles, err := tutorial.LessonFiles(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		files = append(files, dirFiles...)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no lesson files found")
+		return 1
+	}
+
+	failed := 0
+	for _, file := range files {
+		if !validateLessonFile(file) {
+			failed++
+		}
+	}
+
+	fmt.Printf("\n%d lesson(s) checked, %d failed\n", len(files), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// validateLessonFile prints the shortest solution of every step next to its par
+func validateLessonFile(file string) bool {
+	lesson, err := tutorial.ParseLesson(file)
+	if err != nil {
+		fmt.Printf("FAIL %v\n", err)
+		return false
+	}
+	if err := lesson.Validate(); err != nil {
+		fmt.Printf("FAIL %s: %v\n", file, err)
+		return false
+	}
+
+	ok := true
+	fmt.Printf("%s (%s)\n", file, lesson.ID)
+	for i, step := range lesson.Steps {
+		solution := lesson.Solve(i)
+		switch {
+		case solution == nil:
+			ok = false
+			fmt.Printf("  FAIL step %d: goal cannot be reached with %s\n", i+1, describeMotions(step.AllowedMotions))
+		case step.Par > 0 && solution.Keystrokes > step.Par:
+			ok = false
+			fmt.Printf("  FAIL step %d: needs %d keystrokes, par is %d (%s)\n", i+1, solution.Keystrokes, step.Par, solution.Keys())
+		case step.Par == 0:
+			fmt.Printf("  ok   step %d: %d keystrokes, no par (%s)\n", i+1, solution.Keystrokes, solution.Keys())
+		case solution.Keystrokes < step.Par:
+			fmt.Printf("  ok   step %d: %d keystrokes, par %d is loose (%s)\n", i+1, solution.Keystrokes, step.Par, solution.Keys())
+		default:
+			fmt.Printf("  ok   step %d: %d keystrokes, par %d (%s)\n", i+1, solution.Keystrokes, step.Par, solution.Keys())
+		}
+	}
+	return ok
+}
+
+func describeMotions(motions []string) string {
+	if len(motions) == 0 {
+		return "any motion"
+	}
+	return strings.Join(motions, " ")
+}
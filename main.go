@@ -12,6 +12,8 @@ import (
 	"boba-vim/internal/database"
 	"boba-vim/internal/handlers"
 	"boba-vim/internal/middleware"
+	"boba-vim/internal/realtime"
+	"boba-vim/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,6 +21,11 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Authoring commands run instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "lesson" {
+		os.Exit(runLessonCommand(cfg, os.Args[2:]))
+	}
+
 	// Initialize database
 	db, err := database.Initialize(cfg.DatabaseURL)
 	if err != nil {
@@ -38,15 +45,63 @@ func main() {
 	router.Static("/static", "./static")
 	router.LoadHTMLGlob("templates/*_go.html")
 
+	// Every handler and service shares one game service and its loaded levels
+	gameService := services.NewGameService(db, cfg)
+	campaignService := services.NewCampaignService(db, cfg, gameService)
+
+	// Online matches share one realtime hub and match clock
+	hub := realtime.NewHub()
+	matchService := services.NewMatchService(db, cfg, gameService, hub)
+	tournamentService := services.NewTournamentService(db, cfg, matchService)
+
+	// Startup, in order: levels first, then the state left over from a previous run
+	if err := campaignService.SyncLevels(); err != nil {
+		log.Printf("Failed to sync campaign levels from %s: %v", cfg.CampaignFile, err)
+	}
+	// Match clocks live in memory, matches left over from a previous run can't finish
+	if err := matchService.AbandonUnfinished(); err != nil {
+		log.Printf("Failed to abandon unfinished matches: %v", err)
+	}
+	if err := matchService.ResumeTimeAttacks(); err != nil {
+		log.Printf("Failed to resume time attacks: %v", err)
+	}
+	if err := matchService.ResumeSurvivals(); err != nil {
+		log.Printf("Failed to resume survival games: %v", err)
+	}
+	// Tournaments replay the rounds whose matches were just abandoned
+	if err := tournamentService.Resume(); err != nil {
+		log.Printf("Failed to resume tournaments: %v", err)
+	}
+	// Finished games are replayed in the background before they make the leaderboard
+	gameService.StartReplayVerifier()
+
 	// Initialize handlers
-	gameHandler := handlers.NewGameHandler(db)
-	webHandler := handlers.NewWebHandler(db)
+	gameHandler := handlers.NewGameHandler(db, cfg, gameService, matchService)
+	webHandler := handlers.NewWebHandler(cfg, gameService, campaignService, matchService)
 	authHandler := handlers.NewAuthHandler(db)
+	campaignHandler := handlers.NewCampaignHandler(cfg, campaignService)
+	challengeHandler := handlers.NewChallengeHandler(db, cfg)
+	onlineHandler := handlers.NewOnlineHandler(hub, matchService)
+	tournamentHandler := handlers.NewTournamentHandler(tournamentService)
+	adminHandler := handlers.NewAdminHandler(cfg, gameService)
 
 	// Web routes
 	router.GET("/", webHandler.Index)
 	router.GET("/api/play", webHandler.PlayGame)
 
+	// Realtime routes
+	router.GET("/ws/game", onlineHandler.GameSocket)
+	router.GET("/ws/match/:token", onlineHandler.MatchSocket)
+
+	// Spectator routes, read-only and open to anyone
+	router.GET("/spectate/game/:id", webHandler.SpectateGame)
+	router.GET("/spectate/match/:token", webHandler.SpectateMatch)
+	router.GET("/ws/spectate/game/:id", onlineHandler.SpectateGameSocket)
+	router.GET("/ws/spectate/match/:token", onlineHandler.SpectateMatchSocket)
+
+	// Replays of finished games, shared by link
+	router.GET("/replay/:id", webHandler.ReplayGame)
+
 	// Test routes (remove in production)
 	router.GET("/test-404", func(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/nonexistent-page")
@@ -66,6 +121,64 @@ func main() {
 		api.GET("/movements", gameHandler.GetAvailableMovements)
 		api.GET("/player-stats", gameHandler.GetPlayerStats)
 		api.POST("/playonline", gameHandler.PlayOnline)
+		api.GET("/replays/:id", gameHandler.GetReplay)
+		api.GET("/replays/:id/frames", gameHandler.GetReplayFrames)
+
+		// Online match routes
+		online := api.Group("/online")
+		{
+			online.GET("/matches/:token", onlineHandler.GetMatch)
+			online.POST("/leave", onlineHandler.LeaveLobby)
+			online.GET("/live", onlineHandler.LiveGames)
+			online.GET("/ratings", onlineHandler.RatingHistory)
+			online.GET("/rooms/patterns", onlineHandler.TextPatterns)
+			online.POST("/rooms", onlineHandler.CreateRoom)
+			online.POST("/rooms/join", onlineHandler.JoinRoom)
+			online.POST("/rooms/:token/start", onlineHandler.StartRoom)
+		}
+
+		// Tournament routes
+		tournaments := api.Group("/tournaments")
+		{
+			tournaments.GET("", tournamentHandler.ListTournaments)
+			tournaments.POST("", tournamentHandler.CreateTournament)
+			tournaments.GET("/:id/bracket", tournamentHandler.GetBracket)
+			tournaments.POST("/:id/register", tournamentHandler.Register)
+			tournaments.POST("/:id/withdraw", tournamentHandler.Withdraw)
+			tournaments.POST("/:id/start", tournamentHandler.Start)
+			tournaments.POST("/:id/play", tournamentHandler.Play)
+		}
+
+		// Tutorial routes
+		api.POST("/playtutorial", gameHandler.PlayTutorial)
+		tutorial := api.Group("/tutorial")
+		{
+			tutorial.GET("/lessons", gameHandler.ListTutorialLessons)
+			tutorial.GET("/state", gameHandler.GetTutorialState)
+			tutorial.POST("/move", gameHandler.TutorialMove)
+		}
+
+		// Campaign routes
+		campaign := api.Group("/campaign")
+		{
+			campaign.GET("/levels", campaignHandler.ListLevels)
+			campaign.POST("/levels/:id/start", campaignHandler.StartLevel)
+		}
+
+		// Vimgolf challenge routes
+		challenges := api.Group("/challenges")
+		{
+			challenges.GET("", challengeHandler.ListChallenges)
+			challenges.GET("/:id", challengeHandler.GetChallenge)
+			challenges.POST("/:id/submit", challengeHandler.Submit)
+		}
+
+		// Anti-cheat review routes, admins only
+		admin := api.Group("/admin")
+		{
+			admin.GET("/reviews", adminHandler.ListReviews)
+			admin.POST("/reviews/:id", adminHandler.ReviewGame)
+		}
 		
 		// Authentication routes
 		auth := api.Group("/auth")
@@ -1,8 +1,15 @@
 import { initializeGame, gameState } from "./game_modules/gameState.js";
-import { initializeMovement } from "./game_modules/movement.js";
+import { initializeMovement, loadAllowedMovements } from "./game_modules/movement.js";
 import { initializeTutorialMode } from "./game_modules/tutorial.js";
 import { initializeChatHistory } from "./game_modules/chat.js";
+import { initializeOnlineMatch } from "./game_modules/online.js";
+import { initializeGameSocket } from "./game_modules/socket.js";
+import { initializeSpectator, isSpectating } from "./game_modules/spectate.js";
+import { initializeReplay, isReplaying } from "./game_modules/replay.js";
+import { initializeGhost } from "./game_modules/ghost.js";
 import { initializeMapToggle } from "./game_modules/map.js";
+import { initializeTimeAttack } from "./game_modules/timeAttack.js";
+import { initializeSurvival } from "./game_modules/survival.js";
 import { initializeBackToMenuButton } from "./game_modules/navigation.js";
 import { initializeResponsiveScaling } from "./game_modules/responsive_scaling.js";
 import * as chatModule from "./game_modules/chat.js";
@@ -10,6 +17,10 @@ import * as tutorialModule from "./game_modules/tutorial.js";
 import * as feedbackModule from "./game_modules/feedback.js";
 import * as displayModule from "./game_modules/display.js";
 import * as responsiveScaling from "./game_modules/responsive_scaling.js";
+import * as gameSocket from "./game_modules/socket.js";
+import * as timeAttackModule from "./game_modules/timeAttack.js";
+import * as survivalModule from "./game_modules/survival.js";
+import * as ghostModule from "./game_modules/ghost.js";
 
 import * as CONSTANTS from "./game_modules/constants.js";
 
@@ -20,6 +31,10 @@ window.tutorialModule = tutorialModule;
 window.feedbackModule = feedbackModule;
 window.displayModule = displayModule;
 window.responsiveScaling = responsiveScaling;
+window.gameSocket = gameSocket;
+window.timeAttackModule = timeAttackModule;
+window.survivalModule = survivalModule;
+window.ghostModule = ghostModule;
 
 // Make constants globally available
 window.MOVEMENT_KEYS = CONSTANTS.MOVEMENT_KEYS;
@@ -27,7 +42,6 @@ window.MOVEMENT_MESSAGES = CONSTANTS.MOVEMENT_MESSAGES;
 window.BLOCKED_MESSAGES = CONSTANTS.BLOCKED_MESSAGES;
 window.VALID_MOVEMENT_KEYS = CONSTANTS.VALID_MOVEMENT_KEYS;
 window.TUTORIAL_CONFIG = CONSTANTS.TUTORIAL_CONFIG;
-window.TUTORIAL_COMMANDS = CONSTANTS.TUTORIAL_COMMANDS;
 window.MAP_CONFIG = CONSTANTS.MAP_CONFIG;
 window.FEEDBACK_CONFIG = CONSTANTS.FEEDBACK_CONFIG;
 window.CHAT_CONFIG = CONSTANTS.CHAT_CONFIG;
@@ -37,10 +51,25 @@ window.UI_SELECTORS = CONSTANTS.UI_SELECTORS;
 document.addEventListener("DOMContentLoaded", function () {
   initializeGame();
   initializeBackToMenuButton();
-  initializeMovement();
   initializeMapToggle();
-  initializeTutorialMode();
   initializeChatHistory();
+
+  if (isSpectating()) {
+    // Spectators only watch, no moves or tutorial
+    initializeSpectator();
+  } else if (isReplaying()) {
+    // Replays play back a finished game, no moves either
+    initializeReplay();
+  } else {
+    initializeGameSocket();
+    initializeMovement();
+    loadAllowedMovements();
+    initializeTutorialMode();
+    initializeOnlineMatch();
+    initializeTimeAttack();
+    initializeSurvival();
+    initializeGhost();
+  }
   
   // Initialize responsive scaling after everything else is set up
   setTimeout(() => {
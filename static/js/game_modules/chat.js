@@ -3,6 +3,10 @@ let chatHistoryVisible = false;
 
 export function initializeChatHistory() {
   document.addEventListener("keydown", function (event) {
+    // Typing in an input, e.g. the match chat, isn't a command
+    if (event.target.tagName === "INPUT") {
+      return;
+    }
     if (event.key === window.CHAT_CONFIG.TOGGLE_KEY) {
       toggleChatHistory();
       event.preventDefault();
@@ -20,6 +24,9 @@ export function addToChatHistory(message) {
   if (chatHistory.length > window.CHAT_CONFIG.MAX_HISTORY) {
     chatHistory.shift();
   }
+  if (chatHistoryVisible) {
+    updateChatHistoryContent();
+  }
 }
 
 export function toggleChatHistory() {
@@ -75,6 +75,7 @@ export const VALID_MOVEMENT_KEYS = Object.keys(MOVEMENT_KEYS);
 // ================================
 export const TUTORIAL_CONFIG = {
   TOGGLE_KEY: "+",
+  WINDOW_ID: "tutorialWindow",
   COLORS: {
     ACTIVATED: "#9b59b6",
     INSTRUCTION: "#3498db",
@@ -83,7 +84,7 @@ export const TUTORIAL_CONFIG = {
   },
   TIMINGS: {
     ACTIVATION_DELAY: 1000,
-    NEXT_COMMAND_DELAY: 1000,
+    NEXT_STEP_DELAY: 1000,
     WRONG_ANSWER_DISPLAY: 1500,
   },
   MESSAGES: {
@@ -94,12 +95,6 @@ export const TUTORIAL_CONFIG = {
   },
 };
 
-// Generate tutorial commands from movement configuration
-export const TUTORIAL_COMMANDS = VALID_MOVEMENT_KEYS.map((key) => ({
-  key: key,
-  message: `Press ${key} to go ${MOVEMENT_KEYS[key].description}`,
-}));
-
 // ================================
 // MAP CONFIGURATION
 // ================================
@@ -145,8 +140,12 @@ export const GAME_STATE_DEFAULT = {
 // ================================
 export const API_ENDPOINTS = {
   MOVE: "/api/move",
+  MOVEMENTS: "/api/movements",
   GAME_STATE: "/api/game-state",
   PLAY_TUTORIAL: "/api/playtutorial",
+  TUTORIAL_LESSONS: "/api/tutorial/lessons",
+  TUTORIAL_STATE: "/api/tutorial/state",
+  TUTORIAL_MOVE: "/api/tutorial/move",
   PLAY_ONLINE: "/api/playonline",
   SET_USERNAME: "/api/set-username",
   LEADERBOARD: "/api/leaderboard",
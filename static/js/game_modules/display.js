@@ -1,3 +1,9 @@
+const PEARL_SPRITES = {
+  3: { sprite: "/static/sprites/pearl.png", alt: "Pearl", className: "" },
+  4: { sprite: "/static/sprites/golden_boba.png", alt: "Golden Pearl", className: "golden-pearl" },
+  5: { sprite: "/static/sprites/black_boba.png", alt: "Black Pearl", className: "black-pearl" },
+};
+
 export function updateGameDisplay(gameMap) {
   const keys = document.querySelectorAll(window.UI_SELECTORS.GAME_KEYS);
 
@@ -12,7 +18,7 @@ export function updateGameDisplay(gameMap) {
 
       const keyTop = key.querySelector(".key-top");
       
-      const existingElements = keyTop.querySelectorAll(".boba-character, .pearl");
+      const existingElements = keyTop.querySelectorAll(".boba-character, .pearl, .enemy");
       existingElements.forEach(element => {
         element.remove();
       });
@@ -32,14 +38,23 @@ export function updateGameDisplay(gameMap) {
             <img src="${spriteUrl}" alt="${character} Boba" class="boba-sprite">
           `;
           keyTop.appendChild(bobaDiv);
-        } else if (newMapValue === 3) {
+        } else if (PEARL_SPRITES[newMapValue]) {
+          const pearl = PEARL_SPRITES[newMapValue];
           const pearlDiv = document.createElement("div");
-          pearlDiv.className = "pearl";
+          pearlDiv.className = `pearl ${pearl.className}`;
           pearlDiv.innerHTML = `
             <div class="pearl-shadow"></div>
-            <img src="/static/sprites/pearl.png" alt="Pearl" class="pearl-sprite">
+            <img src="${pearl.sprite}" alt="${pearl.alt}" class="pearl-sprite">
           `;
           keyTop.appendChild(pearlDiv);
+        } else if (newMapValue === 6) {
+          const enemyDiv = document.createElement("div");
+          enemyDiv.className = "enemy";
+          enemyDiv.innerHTML = `
+            <div class="boba-shadow"></div>
+            <img src="/static/sprites/black_boba.png" alt="Enemy Boba" class="enemy-sprite">
+          `;
+          keyTop.appendChild(enemyDiv);
         }
       });
     }
@@ -0,0 +1,75 @@
+// Ghost racing: moves the cursor of the player's best run alongside the game,
+// on the track the server sends with the game state
+
+let track = [];
+let startedAt = 0;
+let ghostTimer = null;
+let ghostKey = null;
+
+export function isGhostRace() {
+  return window.ghostRace === "true";
+}
+
+export async function initializeGhost() {
+  if (!isGhostRace()) {
+    return;
+  }
+
+  try {
+    const response = await fetch(window.API_ENDPOINTS.GAME_STATE);
+    const state = await response.json();
+    if (!state.success || !state.ghost) {
+      return;
+    }
+
+    const ghost = state.ghost;
+    track = ghost.track;
+    // Follow the server's clock, the ghost started when the game did
+    startedAt = Date.now() - ghost.elapsed_ms;
+    window.chatModule.addToChatHistory(
+      `👻 Racing your best run: ${ghost.total_moves} moves in ${ghost.completion_time}s`,
+    );
+  } catch (error) {
+    console.error("Error loading ghost:", error);
+    return;
+  }
+
+  ghostTimer = setInterval(showGhost, 50);
+}
+
+// stopGhost leaves the ghost where it is, e.g. once the game is over
+export function stopGhost() {
+  if (ghostTimer) {
+    clearInterval(ghostTimer);
+    ghostTimer = null;
+  }
+}
+
+function showGhost() {
+  const elapsed = Date.now() - startedAt;
+  let position = track[0];
+  for (const point of track) {
+    if (point.at_ms > elapsed) {
+      break;
+    }
+    position = point;
+  }
+
+  const key = document.querySelector(
+    `${window.UI_SELECTORS.GAME_KEYS}[data-row="${position.row}"][data-col="${position.col}"]`,
+  );
+  if (key !== ghostKey) {
+    if (ghostKey) {
+      ghostKey.classList.remove("ghost-cursor");
+    }
+    if (key) {
+      key.classList.add("ghost-cursor");
+    }
+    ghostKey = key;
+  }
+
+  if (position === track[track.length - 1]) {
+    stopGhost();
+    window.chatModule.addToChatHistory("👻 Your ghost has finished");
+  }
+}
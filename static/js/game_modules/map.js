@@ -1,5 +1,8 @@
 export function initializeMapToggle() {
   document.addEventListener("keydown", function (event) {
+    if (event.target.tagName === "INPUT") {
+      return;
+    }
     if (event.key === window.MAP_CONFIG.TOGGLE_KEY) {
       handleMapToggle();
       event.preventDefault();
@@ -1,3 +1,26 @@
+export async function loadAllowedMovements() {
+  try {
+    const response = await fetch(window.API_ENDPOINTS.MOVEMENTS);
+    const result = await response.json();
+    if (!result.restricted) return;
+
+    const allowed = (result.movements || []).map((movement) => movement.key);
+    // "g" only starts gg and g_, keep it when one of them is allowed
+    if (allowed.includes("gg") || allowed.includes("g_")) {
+      allowed.push("g");
+    }
+
+    window.VALID_MOVEMENT_KEYS = window.VALID_MOVEMENT_KEYS.filter((key) =>
+      allowed.includes(key),
+    );
+    window.chatModule.addToChatHistory(
+      `Motions allowed on this level: ${allowed.filter((key) => key !== "g").join(" ")}`,
+    );
+  } catch (error) {
+    console.error("Error loading allowed movements:", error);
+  }
+}
+
 export function initializeMovement() {
   let lastKeyPressed = null;
   let keyReleased = true;
@@ -10,6 +33,9 @@ export function initializeMovement() {
   let waitingForGCommand = false;
 
   document.addEventListener("keydown", function (event) {
+    if (event.target.tagName === "INPUT") {
+      return;
+    }
     const key = event.key;
 
     if (waitingForChar) {
@@ -21,13 +47,7 @@ export function initializeMovement() {
         
         clearCharWaitingFeedback();
         
-        if (window.gameState.tutorialMode) {
-          window.tutorialModule.handleTutorialMovement(fullDirection);
-        } else {
-          window.feedbackModule.showMovementFeedback(fullDirection);
-        }
-        
-        movePlayer(fullDirection);
+        sendMotion(fullDirection);
         event.preventDefault();
       } else if (key === 'Escape') {
         waitingForChar = false;
@@ -47,25 +67,13 @@ export function initializeMovement() {
         waitingForGCommand = false;
         clearGCommandFeedback();
         
-        if (window.gameState.tutorialMode) {
-          window.tutorialModule.handleTutorialMovement('gg');
-        } else {
-          window.feedbackModule.showMovementFeedback('gg');
-        }
-        
-        movePlayer('gg');
+        sendMotion('gg');
         event.preventDefault();
       } else if (key === '_') {
         waitingForGCommand = false;
         clearGCommandFeedback();
         
-        if (window.gameState.tutorialMode) {
-          window.tutorialModule.handleTutorialMovement('g_');
-        } else {
-          window.feedbackModule.showMovementFeedback('g_');
-        }
-        
-        movePlayer('g_');
+        sendMotion('g_');
         event.preventDefault();
       } else if (key === 'Escape') {
         waitingForGCommand = false;
@@ -102,13 +110,7 @@ export function initializeMovement() {
         return;
       }
 
-      if (window.gameState.tutorialMode) {
-        window.tutorialModule.handleTutorialMovement(key);
-      } else {
-        window.feedbackModule.showMovementFeedback(key);
-      }
-
-      movePlayer(key);
+      sendMotion(key);
       event.preventDefault();
     }
   });
@@ -124,9 +126,23 @@ export function initializeMovement() {
   });
 }
 
+// sendMotion plays a motion on the game, or on the lesson in tutorial mode
+function sendMotion(direction) {
+  if (window.gameState.tutorialMode) {
+    window.tutorialModule.handleTutorialMovement(direction);
+    return;
+  }
+  window.feedbackModule.showMovementFeedback(direction);
+  movePlayer(direction);
+}
+
 let movePending = false;
 let lastMoveTime = 0;
 const MOVE_COOLDOWN = 20;
+// A move that lost a race with another one of the same game, e.g. from a
+// second tab, is sent again once the server accepts moves again
+const MOVE_CONFLICT_RETRIES = 2;
+const MOVE_CONFLICT_DELAY = 60;
 
 export async function movePlayer(direction) {
   if (window.gameCompleted) {
@@ -154,20 +170,18 @@ export async function movePlayer(direction) {
   lastMoveTime = now;
 
   try {
-    const response = await fetch(window.API_ENDPOINTS.MOVE, {
-      method: "POST",
-      headers: {
-        "Content-Type": "application/json",
-      },
-      body: JSON.stringify({
-        direction: direction,
-      }),
-    });
-
-    const result = await response.json();
+    let result = await sendMove(direction);
+    for (let retry = 0; retry < MOVE_CONFLICT_RETRIES && result.error_code === "MOVE_CONFLICT"; retry++) {
+      await new Promise((resolve) => setTimeout(resolve, MOVE_CONFLICT_DELAY));
+      result = await sendMove(direction);
+    }
 
     if (result.success) {
       handleSuccessfulMove(result, direction);
+    } else if (result.game_over && window.survivalModule.isSurvival()) {
+      // The last life dissolved before the move
+      window.displayModule.updateGameDisplay(result.game_map);
+      window.survivalModule.showOutOfLives(result);
     } else {
       handleBlockedMove(result, direction);
     }
@@ -178,28 +192,83 @@ export async function movePlayer(direction) {
   }
 }
 
+// sendMove uses the game socket when it is connected and HTTP otherwise
+async function sendMove(direction) {
+  if (window.gameSocket.isConnected()) {
+    // Not retried over HTTP, the server may already have applied the move
+    return window.gameSocket.sendMove(direction);
+  }
+
+  const response = await fetch(window.API_ENDPOINTS.MOVE, {
+    method: "POST",
+    headers: {
+      "Content-Type": "application/json",
+    },
+    body: JSON.stringify({
+      direction: direction,
+    }),
+  });
+  return response.json();
+}
+
 function handleSuccessfulMove(result, direction) {
   window.displayModule.updateGameDisplay(result.game_map);
   window.displayModule.updateScore(result.score);
 
   if (result.pearl_collected) {
-    handlePearlCollection(direction);
+    handlePearlCollection(direction, result);
+  }
+  window.survivalModule.updateSurvival(result);
+  if (result.game_over) {
+    window.ghostModule.stopGhost();
   }
-  if (result.is_completed) {
+  if (result.is_completed && window.timeAttackModule.isTimeAttack()) {
+    window.timeAttackModule.showTimeUp(result);
+  } else if (result.is_completed && window.survivalModule.isSurvival()) {
+    window.survivalModule.showOutOfLives(result);
+  } else if (result.is_completed) {
     handleGameCompletion(result);
+  } else if (result.game_over) {
+    handleGameOver(result);
+  }
+}
+
+function handleGameOver(result) {
+  const message =
+    result.end_reason === "caught_by_enemy"
+      ? "💀 CAUGHT BY A BLACK BOBA! GAME OVER"
+      : "GAME OVER";
+
+  const headerInfo = document.querySelector(window.UI_SELECTORS.HEADER_INFO);
+  if (headerInfo) {
+    headerInfo.innerHTML = `<strong style="color: #e74c3c; font-size: 1.2em;">${message}<br>Score: ${result.score}</strong>`;
   }
+
+  window.chatModule.addToChatHistory(`${message} (score ${result.score})`);
 }
 
-function handlePearlCollection(direction) {
-  console.log("Pearl collected! +100 points");
-  window.chatModule.addToChatHistory("🧋 PEARL COLLECTED! +100 points!");
+function getPearlMessage(result) {
+  const points = result.points_delta;
+  if (result.pearl_type === 4) {
+    return `🌟 GOLDEN PEARL! +${points} points!`;
+  }
+  if (result.pearl_type === 5) {
+    return `💀 BLACK PEARL! ${points} points!`;
+  }
+  return `🧋 PEARL COLLECTED! +${points} points!`;
+}
+
+function handlePearlCollection(direction, result) {
+  const message = getPearlMessage(result);
+  console.log(message);
+  window.chatModule.addToChatHistory(message);
 
   if (!window.gameState.tutorialMode) {
-    showPearlCollectionFeedback(direction);
+    showPearlCollectionFeedback(direction, message);
   }
 }
 
-function showPearlCollectionFeedback(direction) {
+function showPearlCollectionFeedback(direction, pearlMessage) {
   const headerInfo = document.querySelector(window.UI_SELECTORS.HEADER_INFO);
   if (!headerInfo) return;
 
@@ -207,7 +276,7 @@ function showPearlCollectionFeedback(direction) {
     headerInfo.dataset.originalContent = headerInfo.innerHTML;
   }
 
-  headerInfo.innerHTML = `<strong style="color: #ffd700; animation: pulse 0.5s ease-in-out;">🧋 PEARL COLLECTED! +100 points!</strong>`;
+  headerInfo.innerHTML = `<strong style="color: #ffd700; animation: pulse 0.5s ease-in-out;">${pearlMessage}</strong>`;
 
   setTimeout(() => {
     const message =
@@ -221,8 +290,14 @@ function handleBlockedMove(result, direction) {
   console.log("Move blocked:", result.error);
 
   let message = window.BLOCKED_MESSAGES[direction];
-  
-  if (!message) {
+
+  if (result.error_code === "GAME_NOT_STARTED") {
+    message = "Wait for the race to start!";
+  } else if (result.error_code === "CELL_OCCUPIED") {
+    message = `You pressed ${direction} - another boba got there first!`;
+  } else if (result.error_code === "MOTION_NOT_ALLOWED" || result.error_code === "MOTION_BLOCKED") {
+    message = `You pressed ${direction} - ${result.error.toUpperCase()}!`;
+  } else if (!message) {
     // Handle character search motions with custom blocked messages
     if (direction.startsWith('find_char_forward_')) {
       const char = direction.slice(18);
@@ -0,0 +1,260 @@
+// Online race: listens to the match websocket and reports the race in the chat
+
+let socket = null;
+let clockOffset = 0;
+let countdownTimer = null;
+let matchPlayers = [];
+
+export function initializeOnlineMatch() {
+  if (!window.matchToken) {
+    return;
+  }
+
+  const protocol = window.location.protocol === "https:" ? "wss" : "ws";
+  socket = new WebSocket(
+    `${protocol}://${window.location.host}/ws/match/${window.matchToken}`,
+  );
+
+  socket.addEventListener("open", () => {
+    sendPing();
+    showChatInput();
+  });
+
+  socket.addEventListener("message", (event) => {
+    const message = JSON.parse(event.data);
+    handleMessage(message);
+  });
+
+  socket.addEventListener("close", () => {
+    stopCountdown();
+    hideChatInput();
+  });
+}
+
+// showChatInput adds the box players type match chat into, Enter sends
+function showChatInput() {
+  if (document.getElementById("matchChatInput")) {
+    return;
+  }
+  const input = document.createElement("input");
+  input.id = "matchChatInput";
+  input.className = "match-chat-input";
+  input.maxLength = 200;
+  input.placeholder = "Say something to the room (Enter to send, Esc to leave)";
+  input.addEventListener("keydown", (event) => {
+    if (event.key === "Enter" && input.value.trim() !== "") {
+      sendChat(input.value.trim());
+      input.value = "";
+    } else if (event.key === "Escape") {
+      input.blur();
+    }
+  });
+  document.body.appendChild(input);
+}
+
+function hideChatInput() {
+  const input = document.getElementById("matchChatInput");
+  if (input) {
+    input.remove();
+  }
+}
+
+// escapeHTML keeps what players type from being rendered as markup
+function escapeHTML(text) {
+  const element = document.createElement("div");
+  element.textContent = text;
+  return element.innerHTML;
+}
+
+function showChatMessage(message) {
+  window.chatModule.addToChatHistory(
+    `💬 <strong>${escapeHTML(message.username)}</strong>: ${escapeHTML(message.text)}`,
+  );
+}
+
+// sendChat sends a message, or a host's "/mute", "/unmute" or "/kick" of a player
+function sendChat(text) {
+  const command = text.match(/^\/(mute|unmute|kick)\s+(\S+)$/);
+  if (!command) {
+    socket.send(JSON.stringify({ type: "chat", data: { text } }));
+    return;
+  }
+
+  const player = matchPlayers.find((p) => p.username === command[2]);
+  if (!player) {
+    window.chatModule.addToChatHistory(`No player named ${escapeHTML(command[2])}`);
+    return;
+  }
+  socket.send(
+    JSON.stringify({ type: command[1], data: { player_id: player.player_id } }),
+  );
+}
+
+function sendPing() {
+  socket.send(
+    JSON.stringify({ type: "ping", data: { client_time: Date.now() } }),
+  );
+}
+
+// serverNow estimates the server clock from the measured offset
+function serverNow() {
+  return Date.now() + clockOffset;
+}
+
+function handleMessage(message) {
+  const data = message.data || {};
+
+  switch (message.type) {
+    case "pong": {
+      const now = Date.now();
+      const latency = (now - data.client_time) / 2;
+      clockOffset = new Date(message.server_time).getTime() + latency - now;
+      break;
+    }
+    case "match_state":
+    case "match_countdown":
+      showMatchState(data);
+      break;
+    case "match_started":
+      stopCountdown();
+      window.chatModule.addToChatHistory("🏁 The race has started, go!");
+      break;
+    case "player_joined":
+      window.chatModule.addToChatHistory(`${data.username} joined the race`);
+      break;
+    case "player_left":
+      window.chatModule.addToChatHistory(`${data.username} left the race`);
+      break;
+    case "player_finished":
+      window.chatModule.addToChatHistory(
+        `${data.username} finished with ${data.score} points`,
+      );
+      break;
+    case "board_update":
+      // Arena opponents or the coop partner moved on the shared map
+      updateBoard(data.changed_cells);
+      break;
+    case "host_changed":
+      window.chatModule.addToChatHistory(`${data.username} is now the host`);
+      break;
+    case "chat":
+      showChatMessage(data);
+      break;
+    case "chat_history":
+      (data.messages || []).forEach(showChatMessage);
+      break;
+    case "player_muted":
+      window.chatModule.addToChatHistory(
+        `${escapeHTML(data.username)} was ${data.muted ? "muted" : "unmuted"} by the host`,
+      );
+      break;
+    case "player_kicked":
+      window.chatModule.addToChatHistory(
+        `${escapeHTML(data.username)} was removed by the host`,
+      );
+      break;
+    case "kicked":
+      stopCountdown();
+      hideChatInput();
+      window.chatModule.addToChatHistory(`Online: ${data.error}`);
+      break;
+    case "pair_completed":
+      window.chatModule.addToChatHistory(
+        `🤝 Pair completed! Team score: ${data.team_score}`,
+      );
+      break;
+    case "match_finished":
+      stopCountdown();
+      showResults(data);
+      break;
+    case "error":
+      window.chatModule.addToChatHistory(`Online: ${data.error}`);
+      break;
+  }
+}
+
+function updateBoard(cells) {
+  const gameMap = window.gameSocket.applyCells(cells);
+  if (gameMap) {
+    window.displayModule.updateGameDisplay(gameMap);
+  }
+}
+
+function showMatchState(match) {
+  matchPlayers = match.players || [];
+  if (match.status === "countdown" && match.starts_at) {
+    startCountdown(new Date(match.starts_at).getTime());
+  } else if (match.status === "waiting" && match.host_id) {
+    stopCountdown();
+    const players = (match.players || []).length;
+    window.chatModule.addToChatHistory(
+      `Waiting in the room (${players}/${match.max_players}), the host starts when ready`,
+    );
+    showStartButton(match.match_token);
+  } else if (match.status === "waiting") {
+    stopCountdown();
+    const players = (match.players || []).length;
+    window.chatModule.addToChatHistory(
+      `Waiting for opponents (${players}/${match.min_players})...`,
+    );
+  }
+}
+
+function showStartButton(matchToken) {
+  const headerInfo = document.querySelector(window.UI_SELECTORS.HEADER_INFO);
+  if (!headerInfo || document.getElementById("startRoom")) {
+    return;
+  }
+  headerInfo.innerHTML = `<button id="startRoom" class="btn">▶ Start</button>`;
+  document.getElementById("startRoom").addEventListener("click", async () => {
+    // Only the host may start, the server says so to everyone else
+    const response = await fetch(`/api/online/rooms/${matchToken}/start`, {
+      method: "POST",
+    });
+    const result = await response.json();
+    if (!result.success) {
+      window.chatModule.addToChatHistory(`Room: ${result.error}`);
+    }
+  });
+}
+
+function startCountdown(startsAt) {
+  stopCountdown();
+  countdownTimer = setInterval(() => {
+    const seconds = Math.ceil((startsAt - serverNow()) / 1000);
+    if (seconds <= 0) {
+      stopCountdown();
+      return;
+    }
+    const headerInfo = document.querySelector(window.UI_SELECTORS.HEADER_INFO);
+    if (headerInfo) {
+      headerInfo.innerHTML = `<strong>Race starts in ${seconds}...</strong>`;
+    }
+  }, 250);
+}
+
+function stopCountdown() {
+  if (countdownTimer) {
+    clearInterval(countdownTimer);
+    countdownTimer = null;
+  }
+}
+
+function showResults(match) {
+  window.chatModule.addToChatHistory("🏆 Race over!");
+  (match.players || [])
+    .slice()
+    .sort((a, b) => a.rank - b.rank)
+    .forEach((player) => {
+      window.chatModule.addToChatHistory(
+        `#${player.rank} ${player.username} - ${player.score} points${formatRatingChange(player.rating_change)}`,
+      );
+    });
+}
+
+function formatRatingChange(change) {
+  if (change === null || change === undefined) {
+    return "";
+  }
+  return ` (rating ${change >= 0 ? "+" : ""}${change})`;
+}
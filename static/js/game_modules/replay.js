@@ -0,0 +1,116 @@
+// Replay mode: plays back a finished game at the pace it was played, read-only.
+// Space pauses and resumes, n steps to the next move, r starts over.
+
+let frames = [];
+let gameMap = null;
+let startMap = null;
+let next = 0;
+let timer = null;
+
+export function isReplaying() {
+  return Boolean(window.replayFrames);
+}
+
+export async function initializeReplay() {
+  if (!isReplaying()) {
+    return;
+  }
+
+  try {
+    const response = await fetch(window.replayFrames);
+    const result = await response.json();
+    if (!result.success) {
+      window.chatModule.addToChatHistory(`Replay: ${result.error}`);
+      return;
+    }
+
+    frames = result.frames;
+    startMap = result.game_data.game_map;
+    const replay = result.replay;
+    window.chatModule.addToChatHistory(
+      `🎬 Replay of ${replay.username}'s ${replay.mode} game, ${replay.keys.length} moves`,
+    );
+    window.chatModule.addToChatHistory("Space: pause/resume | n: next move | r: restart");
+    if (!result.complete) {
+      window.chatModule.addToChatHistory("This game can't be replayed to the end anymore");
+    }
+  } catch (error) {
+    console.error("Error loading replay:", error);
+    window.chatModule.addToChatHistory("Failed to load the replay");
+    return;
+  }
+
+  document.addEventListener("keydown", handleKey);
+  restart();
+}
+
+function handleKey(event) {
+  switch (event.key) {
+    case " ":
+      event.preventDefault();
+      if (timer) {
+        pause();
+      } else {
+        play();
+      }
+      break;
+    case "n":
+      pause();
+      showFrame(frames[next]);
+      break;
+    case "r":
+      restart();
+      break;
+  }
+}
+
+function restart() {
+  pause();
+  next = 0;
+  gameMap = startMap.map((row) => row.slice());
+  window.displayModule.updateGameDisplay(gameMap);
+  window.displayModule.updateScore(0);
+  play();
+}
+
+// play schedules the next frame as far after the current one as it was played
+function play() {
+  if (timer || next >= frames.length) {
+    return;
+  }
+  const previousAt = next > 0 ? frames[next - 1].at_ms : 0;
+  const delay = Math.max(frames[next].at_ms - previousAt, 0);
+  timer = setTimeout(() => {
+    timer = null;
+    showFrame(frames[next]);
+    play();
+  }, delay);
+}
+
+function pause() {
+  clearTimeout(timer);
+  timer = null;
+}
+
+function showFrame(frame) {
+  if (!frame) {
+    return;
+  }
+  next++;
+
+  frame.changed_cells.forEach(({ row, col, value }) => {
+    gameMap[row][col] = value;
+  });
+  window.displayModule.updateGameDisplay(gameMap);
+  window.displayModule.updateScore(frame.score);
+
+  const lives = document.getElementById("lives");
+  if (lives && frame.lives !== undefined) {
+    lives.textContent = frame.lives;
+  }
+  if (frame.game_over) {
+    window.chatModule.addToChatHistory(
+      `Game over (${frame.end_reason}), final score ${frame.score}`,
+    );
+  }
+}
@@ -0,0 +1,92 @@
+// Game websocket: sends moves and applies the map changes the server pushes back.
+// Moves fall back to HTTP while the socket is not connected.
+
+let socket = null;
+let gameMap = null;
+let nextSeq = 1;
+const pendingMoves = new Map();
+
+export function initializeGameSocket() {
+  if (!window.WebSocket) {
+    return;
+  }
+
+  const protocol = window.location.protocol === "https:" ? "wss" : "ws";
+  socket = new WebSocket(`${protocol}://${window.location.host}/ws/game`);
+
+  socket.addEventListener("message", (event) => {
+    const message = JSON.parse(event.data);
+    handleMessage(message);
+  });
+
+  socket.addEventListener("close", () => {
+    socket = null;
+    gameMap = null;
+    // Fail the pending moves, later moves use HTTP
+    pendingMoves.forEach(({ reject }) => reject(new Error("Socket closed")));
+    pendingMoves.clear();
+  });
+}
+
+export function isConnected() {
+  return socket !== null && socket.readyState === WebSocket.OPEN && gameMap !== null;
+}
+
+// sendMove resolves with the move result, game_map rebuilt from the changed cells
+export function sendMove(direction) {
+  const seq = nextSeq++;
+  return new Promise((resolve, reject) => {
+    pendingMoves.set(seq, { resolve, reject });
+    socket.send(JSON.stringify({ type: "move", data: { direction, seq } }));
+  });
+}
+
+// applyCells updates the local map with changes made by someone else, e.g. an
+// arena opponent, and returns it
+export function applyCells(cells) {
+  if (gameMap === null) {
+    return null;
+  }
+  cells.forEach(({ row, col, value }) => {
+    gameMap[row][col] = value;
+  });
+  return gameMap;
+}
+
+function handleMessage(message) {
+  const data = message.data || {};
+
+  switch (message.type) {
+    case "game_state":
+      if (data.success) {
+        gameMap = data.game_map;
+      }
+      break;
+    case "move_result":
+      resolveMove(data);
+      break;
+    case "time_up":
+      window.timeAttackModule.showTimeUp(data);
+      break;
+    case "pearl_expired":
+      gameMap = data.game_map;
+      window.survivalModule.showPearlExpired(data);
+      break;
+    case "error":
+      console.error("Game socket error:", data.error);
+      break;
+  }
+}
+
+function resolveMove(result) {
+  const pending = pendingMoves.get(result.seq);
+  if (!pending) {
+    return;
+  }
+  pendingMoves.delete(result.seq);
+
+  if (result.changed_cells) {
+    result.game_map = applyCells(result.changed_cells);
+  }
+  pending.resolve(result);
+}
@@ -0,0 +1,153 @@
+// Spectator mode: renders the delayed stream of a game or match, read-only
+
+let snapshotTime = null;
+let kind = null;
+let gameMap = null;
+const positions = new Map();
+
+export function isSpectating() {
+  return Boolean(window.spectateSocket);
+}
+
+export function initializeSpectator() {
+  if (!isSpectating()) {
+    return;
+  }
+
+  window.chatModule.addToChatHistory("👀 Spectating, the stream runs a few seconds late");
+
+  const protocol = window.location.protocol === "https:" ? "wss" : "ws";
+  const socket = new WebSocket(
+    `${protocol}://${window.location.host}${window.spectateSocket}`,
+  );
+
+  socket.addEventListener("message", (event) => {
+    handleMessage(JSON.parse(event.data));
+  });
+
+  socket.addEventListener("close", () => {
+    window.chatModule.addToChatHistory("Spectator stream closed");
+  });
+}
+
+function handleMessage(message) {
+  const data = message.data || {};
+  const sentAt = new Date(message.server_time).getTime();
+
+  if (message.type === "spectate_state") {
+    snapshotTime = sentAt;
+    showSnapshot(data);
+    return;
+  }
+  if (message.type === "error") {
+    window.chatModule.addToChatHistory(`Spectator: ${data.error}`);
+    return;
+  }
+  // Events from before the snapshot are already part of it
+  if (snapshotTime === null || sentAt < snapshotTime) {
+    return;
+  }
+
+  switch (message.type) {
+    case "game_update":
+      showGame(data);
+      break;
+    case "board_update":
+      data.changed_cells.forEach(({ row, col, value }) => {
+        gameMap[row][col] = value;
+      });
+      window.displayModule.updateGameDisplay(gameMap);
+      break;
+    case "player_progress":
+      movePlayer(data);
+      break;
+    case "player_joined":
+      window.chatModule.addToChatHistory(`${data.username} joined`);
+      break;
+    case "player_left":
+      window.chatModule.addToChatHistory(`${data.username} left`);
+      break;
+    case "match_started":
+      window.chatModule.addToChatHistory("🏁 The match has started");
+      break;
+    case "player_finished":
+      window.chatModule.addToChatHistory(
+        `${data.username} finished with ${data.score} points`,
+      );
+      break;
+    case "match_finished":
+      showResults(data);
+      break;
+  }
+}
+
+function showSnapshot(state) {
+  kind = state.kind;
+  if (kind === "game") {
+    showGame(state.game);
+    return;
+  }
+
+  const match = state.match;
+  window.chatModule.addToChatHistory(
+    `Watching a ${match.mode} match with ${match.players.length} players`,
+  );
+  if (state.game_map) {
+    // Arenas share one map, updated cell by cell
+    gameMap = state.game_map;
+    window.displayModule.updateGameDisplay(gameMap);
+    return;
+  }
+
+  // Race players have their own pearls, only their positions are shown
+  gameMap = readDisplayedMap();
+  match.players.forEach((player) => movePlayer(player));
+}
+
+function showGame(game) {
+  if (game.game_map) {
+    window.displayModule.updateGameDisplay(game.game_map);
+  }
+  window.displayModule.updateScore(game.score);
+  if (game.is_completed || game.game_over) {
+    window.chatModule.addToChatHistory(`Game over, final score ${game.score}`);
+  }
+}
+
+function movePlayer(player) {
+  if (kind !== "match" || !gameMap || player.row === undefined) {
+    return;
+  }
+
+  const previous = positions.get(player.player_id);
+  if (previous) {
+    gameMap[previous.row][previous.col] = 0;
+  }
+  positions.set(player.player_id, { row: player.row, col: player.col });
+  gameMap[player.row][player.col] = 1;
+  window.displayModule.updateGameDisplay(gameMap);
+}
+
+// readDisplayedMap rebuilds the map the page was rendered with
+function readDisplayedMap() {
+  const map = [];
+  document.querySelectorAll(window.UI_SELECTORS.GAME_KEYS).forEach((key) => {
+    const row = parseInt(key.getAttribute("data-row"));
+    const col = parseInt(key.getAttribute("data-col"));
+    map[row] = map[row] || [];
+    map[row][col] = parseInt(key.getAttribute("data-map"));
+  });
+  return map;
+}
+
+function showResults(match) {
+  window.chatModule.addToChatHistory("🏆 Match over!");
+  (match.players || [])
+    .slice()
+    .sort((a, b) => a.rank - b.rank)
+    .forEach((player) => {
+      window.chatModule.addToChatHistory(
+        `#${player.rank} ${player.username} - ${player.score} points`,
+      );
+    });
+}
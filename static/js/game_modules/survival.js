@@ -0,0 +1,105 @@
+// Survival: every pearl dissolves after a countdown that shrinks as the score
+// grows, each one missed costs a life. The server expires pearls and pushes it.
+
+let lives = null;
+let pearlTimers = [];
+let countdownTimer = null;
+
+export function isSurvival() {
+  return window.gameMode === "survival";
+}
+
+export async function initializeSurvival() {
+  if (!isSurvival()) {
+    return;
+  }
+
+  window.chatModule.addToChatHistory(
+    "💧 Survival: grab every pearl before it dissolves, each one you miss costs a life!",
+  );
+
+  try {
+    const response = await fetch(window.API_ENDPOINTS.GAME_STATE);
+    const state = await response.json();
+    if (state.success) {
+      updateSurvival(state);
+    }
+  } catch (error) {
+    console.error("Error loading survival state:", error);
+  }
+
+  countdownTimer = setInterval(showCountdown, 100);
+}
+
+// updateSurvival takes the lives and pearl timers of a move result or pushed
+// state, move results leave the timers out when they didn't change
+export function updateSurvival(result) {
+  if (!isSurvival()) {
+    return;
+  }
+
+  if (result.pearl_timers) {
+    pearlTimers = result.pearl_timers;
+  }
+  if (result.lives === undefined) {
+    return;
+  }
+  if (lives !== null && result.lives < lives) {
+    const lost = lives - result.lives;
+    window.chatModule.addToChatHistory(
+      `💧 ${lost === 1 ? "A pearl" : `${lost} pearls`} dissolved! ${result.lives} ${result.lives === 1 ? "life" : "lives"} left`,
+    );
+  }
+  lives = result.lives;
+
+  const livesDisplay = document.getElementById("lives");
+  if (livesDisplay) {
+    livesDisplay.textContent = lives;
+  }
+}
+
+function showCountdown() {
+  const countdown = document.getElementById("pearlCountdown");
+  if (!countdown || pearlTimers.length === 0) {
+    return;
+  }
+
+  const next = Math.min(...pearlTimers.map((timer) => new Date(timer.expires_at).getTime()));
+  const seconds = Math.max(0, (next - Date.now()) / 1000);
+  countdown.textContent = `${seconds.toFixed(1)}s`;
+}
+
+// showPearlExpired applies a pearl expiry pushed by the server
+export function showPearlExpired(result) {
+  window.displayModule.updateGameDisplay(result.game_map);
+  updateSurvival(result);
+  if (result.game_over) {
+    showOutOfLives(result);
+  }
+}
+
+// showOutOfLives shows the result once the last life is gone
+export function showOutOfLives(result) {
+  updateSurvival(result);
+  if (countdownTimer) {
+    clearInterval(countdownTimer);
+    countdownTimer = null;
+  }
+  window.gameCompleted = true;
+
+  const countdown = document.getElementById("pearlCountdown");
+  if (countdown) {
+    countdown.textContent = "--";
+  }
+
+  const headerInfo = document.querySelector(window.UI_SELECTORS.HEADER_INFO);
+  if (headerInfo) {
+    headerInfo.innerHTML = `<strong style="color: #e74c3c; font-size: 1.2em;">
+      💧 OUT OF LIVES!<br>
+      Pearls: ${result.final_score}
+    </strong>`;
+  }
+  window.chatModule.addToChatHistory(
+    `💧 Out of lives! You collected ${result.final_score} pearls`,
+  );
+}
@@ -0,0 +1,53 @@
+// Time attack: counts down the fixed clock, the server ends the game and says so
+
+let clockTimer = null;
+
+export function isTimeAttack() {
+  return window.gameMode === "time_attack";
+}
+
+export function initializeTimeAttack() {
+  if (!isTimeAttack() || !window.gameEndsAt) {
+    return;
+  }
+
+  const endsAt = new Date(window.gameEndsAt).getTime();
+  window.chatModule.addToChatHistory(
+    "⏱ Time attack: collect as many pearls as you can before the clock runs out!",
+  );
+
+  clockTimer = setInterval(() => {
+    const seconds = Math.max(0, Math.ceil((endsAt - Date.now()) / 1000));
+    const headerInfo = document.querySelector(window.UI_SELECTORS.HEADER_INFO);
+    if (headerInfo) {
+      headerInfo.innerHTML = `<strong>⏱ ${seconds}s left</strong>`;
+    }
+    if (seconds === 0) {
+      stopClock();
+    }
+  }, 250);
+}
+
+function stopClock() {
+  if (clockTimer) {
+    clearInterval(clockTimer);
+    clockTimer = null;
+  }
+}
+
+// showTimeUp shows the result the server sent when it ended the game
+export function showTimeUp(result) {
+  stopClock();
+  window.gameCompleted = true;
+
+  const headerInfo = document.querySelector(window.UI_SELECTORS.HEADER_INFO);
+  if (headerInfo) {
+    headerInfo.innerHTML = `<strong style="color: #ffd700; font-size: 1.2em;">
+      ⏱ TIME'S UP!<br>
+      Pearls: ${result.final_score}
+    </strong>`;
+  }
+  window.chatModule.addToChatHistory(
+    `⏱ Time's up! You collected ${result.final_score} pearls`,
+  );
+}
@@ -1,7 +1,15 @@
-let currentTutorialCommand = null;
+// Tutorial mode: the server runs the lessons. It picks the lesson and step,
+// applies each motion and decides when a step is done, the client shows the
+// lesson text with the cursor and the goal and sends the motions typed.
+
+let tutorialState = null;
+let tutorialMovePending = false;
 
 export function initializeTutorialMode() {
   document.addEventListener("keydown", function (event) {
+    if (event.target.tagName === "INPUT") {
+      return;
+    }
     if (event.key === window.TUTORIAL_CONFIG.TOGGLE_KEY) {
       toggleTutorialMode();
       event.preventDefault();
@@ -19,20 +27,35 @@ export function toggleTutorialMode() {
   }
 }
 
-function activateTutorialMode() {
+async function activateTutorialMode() {
   showTutorialMessage(
     window.TUTORIAL_CONFIG.MESSAGES.ACTIVATED,
     window.TUTORIAL_CONFIG.COLORS.ACTIVATED,
   );
   window.chatModule.addToChatHistory("Tutorial mode activated");
 
+  // Resume the lesson in progress, or start the next one
+  let state = await fetchTutorial(window.API_ENDPOINTS.TUTORIAL_STATE);
+  if (!state || !state.success || state.lesson_completed) {
+    state = await startLesson("");
+  }
+  if (!state || !state.success) {
+    window.chatModule.addToChatHistory(
+      `Tutorial unavailable: ${state ? state.error : "server unreachable"}`,
+    );
+    window.gameState.tutorialMode = false;
+    resetToWelcomeMessage();
+    return;
+  }
+
   setTimeout(() => {
-    generateRandomTutorialCommand();
+    showStep(state);
   }, window.TUTORIAL_CONFIG.TIMINGS.ACTIVATION_DELAY);
 }
 
 function deactivateTutorialMode() {
-  currentTutorialCommand = null;
+  tutorialState = null;
+  hideTutorialWindow();
   window.chatModule.addToChatHistory(
     window.TUTORIAL_CONFIG.MESSAGES.DEACTIVATED,
   );
@@ -50,80 +73,201 @@ function resetToWelcomeMessage() {
   }
 }
 
-export function generateRandomTutorialCommand() {
-  if (!window.gameState.tutorialMode) return;
+// handleTutorialMovement sends a motion to the current step instead of the game
+export async function handleTutorialMovement(direction) {
+  if (!tutorialState || tutorialMovePending) return;
 
-  const randomIndex = Math.floor(
-    Math.random() * window.TUTORIAL_COMMANDS.length,
-  );
-  currentTutorialCommand = window.TUTORIAL_COMMANDS[randomIndex];
+  tutorialMovePending = true;
+  try {
+    const result = await fetchTutorial(window.API_ENDPOINTS.TUTORIAL_MOVE, {
+      direction: direction,
+    });
+    if (!window.gameState.tutorialMode || !result) return;
 
+    if (!result.success) {
+      handleRejectedMotion(result, direction);
+    } else if (result.step_completed) {
+      handleStepCompleted(result);
+    } else {
+      tutorialState = result;
+      renderTutorialWindow(result);
+    }
+  } finally {
+    tutorialMovePending = false;
+  }
+}
+
+function handleRejectedMotion(result, direction) {
+  const hint = result.hint ? ` | Hint: ${result.hint}` : "";
   showTutorialMessage(
-    currentTutorialCommand.message,
-    window.TUTORIAL_CONFIG.COLORS.INSTRUCTION,
+    `✗ ${direction}: ${result.error}${hint}`,
+    window.TUTORIAL_CONFIG.COLORS.WRONG,
   );
+  window.chatModule.addToChatHistory(`✗ ${direction}: ${result.error}`);
+
+  setTimeout(() => {
+    if (tutorialState) {
+      showInstruction(tutorialState);
+    }
+  }, window.TUTORIAL_CONFIG.TIMINGS.WRONG_ANSWER_DISPLAY);
 }
 
-export function handleTutorialMovement(key) {
-  if (!currentTutorialCommand) return;
+function handleStepCompleted(result) {
+  const rating =
+    result.par > 0
+      ? ` in ${result.step_keystrokes} keystrokes (par ${result.par})`
+      : "";
+  showTutorialMessage(
+    `✓ STEP DONE${rating}!`,
+    window.TUTORIAL_CONFIG.COLORS.CORRECT,
+  );
+  window.chatModule.addToChatHistory(`✓ ${result.lesson.title}: step done${rating}`);
 
-  // Handle character search motions - these aren't part of tutorial
-  if (['f', 'F', 't', 'T'].includes(key)) {
-    const skipMessage = `Character search (${key}) not in tutorial - skipping`;
-    showTutorialMessage(skipMessage, window.TUTORIAL_CONFIG.COLORS.INSTRUCTION);
-    window.chatModule.addToChatHistory(skipMessage);
-    
+  if (result.lesson_completed) {
+    tutorialState = null;
+    hideTutorialWindow();
+    window.chatModule.addToChatHistory(`🎓 Lesson completed: ${result.lesson.title}`);
     setTimeout(() => {
-      showTutorialMessage(
-        currentTutorialCommand.message,
-        window.TUTORIAL_CONFIG.COLORS.INSTRUCTION,
-      );
-    }, 1000);
+      startNextLesson(result.lesson.id);
+    }, window.TUTORIAL_CONFIG.TIMINGS.NEXT_STEP_DELAY);
+    return;
+  }
+
+  tutorialState = result;
+  setTimeout(() => {
+    showStep(result);
+  }, window.TUTORIAL_CONFIG.TIMINGS.NEXT_STEP_DELAY);
+}
+
+// startNextLesson moves on to the lesson after the completed one, if any
+async function startNextLesson(completedLessonID) {
+  if (!window.gameState.tutorialMode) return;
+
+  const result = await fetchTutorial(window.API_ENDPOINTS.TUTORIAL_LESSONS);
+  const lessons = result && result.success ? result.lessons : [];
+  const index = lessons.findIndex((lesson) => lesson.id === completedLessonID);
+  const next = lessons[index + 1];
+  if (index < 0 || !next) {
+    showTutorialMessage(
+      "🎓 Every lesson completed! Press + to leave the tutorial",
+      window.TUTORIAL_CONFIG.COLORS.CORRECT,
+    );
     return;
   }
 
-  // Handle character search direction strings (when they get passed through)
-  if (key.startsWith('find_char_') || key.startsWith('till_char_')) {
-    return; // Ignore these in tutorial mode
+  const state = await startLesson(next.id);
+  if (state && state.success) {
+    showStep(state);
   }
+}
 
-  if (key === currentTutorialCommand.key) {
-    handleCorrectAnswer();
-  } else {
-    handleWrongAnswer(key);
+async function startLesson(lessonID) {
+  return fetchTutorial(window.API_ENDPOINTS.PLAY_TUTORIAL, {
+    lesson_id: lessonID,
+  });
+}
+
+// fetchTutorial calls a tutorial endpoint, POSTing the body when there is one
+async function fetchTutorial(url, body) {
+  try {
+    const options = body
+      ? {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify(body),
+        }
+      : {};
+    const response = await fetch(url, options);
+    return await response.json();
+  } catch (error) {
+    console.error("Error calling the tutorial:", error);
+    return null;
   }
 }
 
-function handleCorrectAnswer() {
-  const correctMessage = `✓ CORRECT! ${currentTutorialCommand.message}`;
-  showTutorialMessage(correctMessage, window.TUTORIAL_CONFIG.COLORS.CORRECT);
-  window.chatModule.addToChatHistory(
-    `✓ Correct: ${currentTutorialCommand.message}`,
-  );
+function showStep(state) {
+  if (!window.gameState.tutorialMode) return;
 
-  setTimeout(() => {
-    generateRandomTutorialCommand();
-  }, window.TUTORIAL_CONFIG.TIMINGS.NEXT_COMMAND_DELAY);
+  tutorialState = state;
+  if (state.step_index === 0) {
+    window.chatModule.addToChatHistory(`📖 Lesson: ${state.lesson.title}`);
+  }
+  showInstruction(state);
+  renderTutorialWindow(state);
 }
 
-function handleWrongAnswer(pressedKey) {
-  const pressedCommand = window.TUTORIAL_COMMANDS.find(
-    (cmd) => cmd.key === pressedKey,
+function showInstruction(state) {
+  const motions =
+    state.allowed_motions && state.allowed_motions.length > 0
+      ? ` | Keys: ${state.allowed_motions.join(" ")}`
+      : "";
+  showTutorialMessage(
+    `${state.lesson.title} ${state.step_index + 1}/${state.total_steps}: ${state.instruction}${motions}`,
+    window.TUTORIAL_CONFIG.COLORS.INSTRUCTION,
   );
-  if (!pressedCommand) return;
+}
 
-  const wrongMessage = `✗ Wrong! Expected: ${currentTutorialCommand.message}`;
-  showTutorialMessage(wrongMessage, window.TUTORIAL_CONFIG.COLORS.WRONG);
+// isGoal reports whether a cell is the step's goal, ranges are inclusive in reading order
+function isGoal(goal, row, col) {
+  if (!goal.to) {
+    return row === goal.row && col === goal.col;
+  }
+  const afterStart = row > goal.row || (row === goal.row && col >= goal.col);
+  const beforeEnd = row < goal.to.row || (row === goal.to.row && col <= goal.to.col);
+  return afterStart && beforeEnd;
+}
 
-  const chatMessage = `✗ Wrong: Expected ${currentTutorialCommand.message}, pressed ${pressedCommand.message.split(" ")[1]} instead`;
-  window.chatModule.addToChatHistory(chatMessage);
+function escapeHTML(text) {
+  return text
+    .replace(/&/g, "&amp;")
+    .replace(/</g, "&lt;")
+    .replace(/>/g, "&gt;");
+}
 
-  setTimeout(() => {
-    showTutorialMessage(
-      currentTutorialCommand.message,
-      window.TUTORIAL_CONFIG.COLORS.INSTRUCTION,
-    );
-  }, window.TUTORIAL_CONFIG.TIMINGS.WRONG_ANSWER_DISPLAY);
+function renderTutorialWindow(state) {
+  let tutorialWindow = document.getElementById(window.TUTORIAL_CONFIG.WINDOW_ID);
+  if (!tutorialWindow) {
+    tutorialWindow = document.createElement("div");
+    tutorialWindow.id = window.TUTORIAL_CONFIG.WINDOW_ID;
+    tutorialWindow.className = "chat-history-window tutorial-window";
+    document.body.appendChild(tutorialWindow);
+  }
+  tutorialWindow.style.display = "flex";
+
+  const rows = state.text_grid
+    .map((line, row) => {
+      const cells = line
+        .map((char, col) => {
+          const classes = ["tutorial-cell"];
+          if (row === state.cursor.row && col === state.cursor.col) {
+            classes.push("tutorial-cursor");
+          } else if (isGoal(state.goal, row, col)) {
+            classes.push("tutorial-goal");
+          }
+          return `<span class="${classes.join(" ")}">${char === " " ? "&nbsp;" : escapeHTML(char)}</span>`;
+        })
+        .join("");
+      return `<div class="tutorial-row">${cells || "&nbsp;"}</div>`;
+    })
+    .join("");
+
+  tutorialWindow.innerHTML = `
+    <div class="chat-history-header">
+      <h3>${escapeHTML(state.lesson.title)} ${state.step_index + 1}/${state.total_steps}</h3>
+      <button class="chat-close-btn" onclick="window.tutorialModule.toggleTutorialMode()">×</button>
+    </div>
+    <div class="chat-history-content tutorial-text">${rows}</div>
+    <div class="chat-history-footer">
+      ${escapeHTML(state.instruction)} | Press ${window.TUTORIAL_CONFIG.TOGGLE_KEY} to leave
+    </div>
+  `;
+}
+
+function hideTutorialWindow() {
+  const tutorialWindow = document.getElementById(window.TUTORIAL_CONFIG.WINDOW_ID);
+  if (tutorialWindow) {
+    tutorialWindow.style.display = "none";
+  }
 }
 
 function showTutorialMessage(message, color) {
@@ -1,5 +1,14 @@
-import { initializePlayButton } from "./index_modules/playButton.js";
-import { initializeLeaderboardButton } from "./index_modules/leaderboard.js";
+import {
+  initializePlayButton,
+  initializeTimeAttackButton,
+  initializeSurvivalButton,
+  initializeGhostButton,
+} from "./index_modules/playButton.js";
+import {
+  initializeLeaderboardButton,
+  initializeTimeAttackLeaderboardButton,
+  initializeSurvivalLeaderboardButton,
+} from "./index_modules/leaderboard.js";
 import { initializeOnlineButton } from "./index_modules/onlineButton.js";
 import { initializeUsernameInput } from "./index_modules/usernameInput.js";
 import { initializeCharacterSelection } from "./index_modules/characterSelection.js";
@@ -8,8 +17,13 @@ document.addEventListener("DOMContentLoaded", function () {
   console.log("Initializing index page modules...");
 
   initializePlayButton();
+  initializeTimeAttackButton();
+  initializeSurvivalButton();
+  initializeGhostButton();
   initializeOnlineButton();
   initializeLeaderboardButton();
+  initializeTimeAttackLeaderboardButton();
+  initializeSurvivalLeaderboardButton();
   initializeUsernameInput();
   initializeCharacterSelection();
 
@@ -43,7 +43,46 @@ export function initializeLeaderboardButton() {
   });
 }
 
-function showLeaderboardModal(leaderboard) {
+// initializeTimeAttackLeaderboardButton shows the time attack runs, most pearls first
+export function initializeTimeAttackLeaderboardButton() {
+  initializePearlLeaderboardButton("timeAttackLeaderboardButton", "time_attack");
+}
+
+// initializeSurvivalLeaderboardButton shows the survival runs, most pearls first
+export function initializeSurvivalLeaderboardButton() {
+  initializePearlLeaderboardButton("survivalLeaderboardButton", "survival");
+}
+
+// initializePearlLeaderboardButton shows the leaderboard of a mode that counts pearls
+function initializePearlLeaderboardButton(buttonId, type) {
+  const button = document.getElementById(buttonId);
+
+  if (!button) {
+    return;
+  }
+
+  button.addEventListener("click", async function () {
+    button.disabled = true;
+
+    try {
+      const response = await fetch(`/api/leaderboard?type=${type}`);
+      const result = await response.json();
+
+      if (result.success) {
+        showLeaderboardModal(result.leaderboard || [], type);
+      } else {
+        alert("Failed to load leaderboard: " + result.error);
+      }
+    } catch (error) {
+      console.error("Error loading leaderboard:", error);
+      alert("Failed to load leaderboard. Please try again.");
+    } finally {
+      button.disabled = false;
+    }
+  });
+}
+
+function showLeaderboardModal(leaderboard, type = "time") {
   console.log("Showing leaderboard modal with data:", leaderboard);
 
   if (leaderboard.length === 0) {
@@ -51,13 +90,16 @@ function showLeaderboardModal(leaderboard) {
     return;
   }
 
+  const countsPearls = type === "time_attack" || type === "survival";
+  const title = { time_attack: "⏱ Time Attack ⏱", survival: "💧 Survival 💧" }[type] || "🏆 Leaderboard 🏆";
   const leaderboardHTML = leaderboard
     .map(
       (entry) => `
     <tr style="border-bottom: 1px solid #34495e;">
       <td style="padding: 0.5rem; text-align: center; font-weight: bold;">${entry.rank}</td>
       <td style="padding: 0.5rem;">${entry.username}</td>
-      <td style="padding: 0.5rem; text-align: center;">${entry.completion_time ? formatTime(entry.completion_time) : "--:--"}</td>
+      <td style="padding: 0.5rem; text-align: center;">${countsPearls ? entry.score : entry.completion_time ? formatTime(entry.completion_time) : "--:--"}</td>
+      <td style="padding: 0.5rem; text-align: center;">${entry.replay_url ? `<a href="${entry.replay_url}" style="color: #ffd700;">▶</a>` : `<span title="Played before replays were recorded">–</span>`}</td>
     </tr>
   `,
     )
@@ -87,13 +129,14 @@ function showLeaderboardModal(leaderboard) {
         overflow-y: auto;
         box-shadow: 0 10px 30px rgba(0,0,0,0.5);
       ">
-        <h2 style="color: #ffd700; margin-bottom: 1rem; text-align: center;">🏆 Leaderboard 🏆</h2>
+        <h2 style="color: #ffd700; margin-bottom: 1rem; text-align: center;">${title}</h2>
         <table style="width: 100%; border-collapse: collapse;">
           <thead>
             <tr style="background: #34495e;">
               <th style="padding: 0.8rem; text-align: center;">Rank</th>
               <th style="padding: 0.8rem;">Player</th>
-              <th style="padding: 0.8rem; text-align: center;">Time</th>
+              <th style="padding: 0.8rem; text-align: center;">${countsPearls ? "Pearls" : "Time"}</th>
+              <th style="padding: 0.8rem; text-align: center;">Replay</th>
             </tr>
           </thead>
           <tbody>
@@ -1,26 +1,75 @@
 export function initializeOnlineButton() {
-  const playOnline = document.getElementById("playOnline");
+  initializeMatchButton("playOnline", "🧋 Play online", "race");
+  initializeMatchButton("playArena", "⚔️ Arena", "arena");
+  initializeMatchButton("playCoop", "🤝 Coop", "coop");
+  initializeRoomButton();
+}
+
+function initializeRoomButton() {
+  const button = document.getElementById("playRoom");
+
+  if (!button) {
+    console.log("playRoom button not found");
+    return;
+  }
+
+  button.addEventListener("click", async function () {
+    const code = prompt("Enter a room code, or leave empty to create a room");
+    if (code === null) {
+      return;
+    }
+
+    button.disabled = true;
+    try {
+      const response = code.trim()
+        ? await fetch("/api/online/rooms/join", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ code: code }),
+          })
+        : await fetch("/api/online/rooms", { method: "POST" });
+      const data = await response.json();
+
+      if (data.success) {
+        if (!code.trim()) {
+          alert(`Room created, share the code ${data.join_code}`);
+        }
+        window.location.href = `/api/play?match=${data.match.match_token}`;
+        return;
+      }
+      alert(data.error || "Failed to open the room");
+    } catch (error) {
+      console.error("Error opening room:", error);
+      alert("Failed to connect to online mode. Please try again.");
+    } finally {
+      button.disabled = false;
+    }
+  });
+}
+
+function initializeMatchButton(buttonId, label, mode) {
+  const button = document.getElementById(buttonId);
 
-  if (!playOnline) {
-    console.log("playOnline button not found");
+  if (!button) {
+    console.log(`${buttonId} button not found`);
     return;
   }
 
-  console.log("Initializing online button...");
+  console.log(`Initializing ${mode} button...`);
 
-  playOnline.addEventListener("mouseenter", function () {
-    playOnline.textContent = "🧋";
+  button.addEventListener("mouseenter", function () {
+    button.textContent = "🧋";
   });
 
-  playOnline.addEventListener("mouseleave", function () {
-    playOnline.textContent = "🧋 Play online";
+  button.addEventListener("mouseleave", function () {
+    button.textContent = label;
   });
 
-  playOnline.addEventListener("click", async function () {
-    console.log("Online button clicked");
+  button.addEventListener("click", async function () {
+    console.log(`${mode} button clicked`);
 
-    playOnline.disabled = true;
-    playOnline.textContent = "Connecting...";
+    button.disabled = true;
+    button.textContent = "Connecting...";
 
     try {
       const response = await fetch("/api/playonline", {
@@ -28,23 +77,25 @@ export function initializeOnlineButton() {
         headers: {
           "Content-Type": "application/json",
         },
+        body: JSON.stringify({ mode: mode }),
       });
 
       const data = await response.json();
       console.log("Online game response:", data);
 
       if (data.success) {
-        // Handle successful online connection
-        alert("Online mode: " + data.message);
+        // Take the seat in the lobby
+        window.location.href = `/api/play?match=${data.match.match_token}`;
+        return;
       } else {
-        alert(data.message || "Online mode not available yet");
+        alert(data.error || data.message || "Online mode not available yet");
       }
     } catch (error) {
       console.error("Error starting online game:", error);
       alert("Failed to connect to online mode. Please try again.");
     } finally {
-      playOnline.disabled = false;
-      playOnline.textContent = "🧋 Play online";
+      button.disabled = false;
+      button.textContent = label;
     }
   });
 }
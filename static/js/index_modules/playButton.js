@@ -37,3 +37,54 @@ export function initializePlayButton() {
   });
 }
 
+
+// initializeTimeAttackButton starts a game on the fixed time attack clock
+export function initializeTimeAttackButton() {
+  const timeAttackButton = document.getElementById("playTimeAttack");
+
+  if (!timeAttackButton) {
+    return;
+  }
+
+  timeAttackButton.addEventListener("click", function () {
+    timeAttackButton.disabled = true;
+    timeAttackButton.textContent = "🚀 Starting...";
+
+    const selectedCharacter = getSelectedCharacter();
+    window.location.href = `/api/play?mode=time_attack&character=${encodeURIComponent(selectedCharacter)}`;
+  });
+}
+
+// initializeSurvivalButton starts a survival game, played until the pearls have taken every life
+export function initializeSurvivalButton() {
+  const survivalButton = document.getElementById("playSurvival");
+
+  if (!survivalButton) {
+    return;
+  }
+
+  survivalButton.addEventListener("click", function () {
+    survivalButton.disabled = true;
+    survivalButton.textContent = "🚀 Starting...";
+
+    const selectedCharacter = getSelectedCharacter();
+    window.location.href = `/api/play?mode=survival&character=${encodeURIComponent(selectedCharacter)}`;
+  });
+}
+
+// initializeGhostButton starts a game racing the ghost of the player's best run
+export function initializeGhostButton() {
+  const ghostButton = document.getElementById("playGhost");
+
+  if (!ghostButton) {
+    return;
+  }
+
+  ghostButton.addEventListener("click", function () {
+    ghostButton.disabled = true;
+    ghostButton.textContent = "🚀 Starting...";
+
+    const selectedCharacter = getSelectedCharacter();
+    window.location.href = `/api/play?ghost=1&character=${encodeURIComponent(selectedCharacter)}`;
+  });
+}